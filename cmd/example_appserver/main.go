@@ -2,33 +2,59 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
 	"log"
 	"net"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/seaweedfs/shardmanager/shardmanagerpb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 type appShardServer struct {
 	shardmanagerpb.UnimplementedAppShardServiceServer
+
+	mu     sync.Mutex
+	shards map[string]string // shard ID -> role
+}
+
+func newAppShardServer() *appShardServer {
+	return &appShardServer{shards: make(map[string]string)}
 }
 
 func (s *appShardServer) AddShard(ctx context.Context, req *shardmanagerpb.AddShardRequest) (*shardmanagerpb.AddShardResponse, error) {
 	log.Printf("AddShard called: shard_id=%s, role=%s", req.ShardId, req.Role)
+	s.mu.Lock()
+	s.shards[req.ShardId] = req.Role
+	s.mu.Unlock()
 	return &shardmanagerpb.AddShardResponse{Success: true, Message: "Shard added"}, nil
 }
 
 func (s *appShardServer) DropShard(ctx context.Context, req *shardmanagerpb.DropShardRequest) (*shardmanagerpb.DropShardResponse, error) {
 	log.Printf("DropShard called: shard_id=%s", req.ShardId)
+	s.mu.Lock()
+	delete(s.shards, req.ShardId)
+	s.mu.Unlock()
 	return &shardmanagerpb.DropShardResponse{Success: true, Message: "Shard dropped"}, nil
 }
 
 func (s *appShardServer) ChangeRole(ctx context.Context, req *shardmanagerpb.ChangeRoleRequest) (*shardmanagerpb.ChangeRoleResponse, error) {
 	log.Printf("ChangeRole called: shard_id=%s, current_role=%s, new_role=%s", req.ShardId, req.CurrentRole, req.NewRole)
+	s.mu.Lock()
+	s.shards[req.ShardId] = req.NewRole
+	s.mu.Unlock()
 	return &shardmanagerpb.ChangeRoleResponse{Success: true, Message: "Role changed"}, nil
 }
 
+func (s *appShardServer) ListLocalShards(ctx context.Context, req *shardmanagerpb.ListLocalShardsRequest) (*shardmanagerpb.ListLocalShardsResponse, error) {
+	return &shardmanagerpb.ListLocalShardsResponse{Shards: s.localShards()}, nil
+}
+
 func (s *appShardServer) PrepareAddShard(ctx context.Context, req *shardmanagerpb.PrepareAddShardRequest) (*shardmanagerpb.PrepareAddShardResponse, error) {
 	log.Printf("PrepareAddShard called: shard_id=%s, current_owner=%s, role=%s", req.ShardId, req.CurrentOwner, req.Role)
 	return &shardmanagerpb.PrepareAddShardResponse{Success: true, Message: "Prepared to add shard"}, nil
@@ -39,8 +65,43 @@ func (s *appShardServer) PrepareDropShard(ctx context.Context, req *shardmanager
 	return &shardmanagerpb.PrepareDropShardResponse{Success: true, Message: "Prepared to drop shard"}, nil
 }
 
-func registerWithShardManager(shardManagerAddr, nodeID, appServerAddr string) {
-	conn, err := grpc.Dial(shardManagerAddr, grpc.WithInsecure())
+// localShards returns the shards currently hosted by this app server
+func (s *appShardServer) localShards() []*shardmanagerpb.ShardReplica {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	replicas := make([]*shardmanagerpb.ShardReplica, 0, len(s.shards))
+	for shardID, role := range s.shards {
+		replicas = append(replicas, &shardmanagerpb.ShardReplica{ShardId: shardID, Role: role})
+	}
+	return replicas
+}
+
+// converge replaces the local shard set with the desired one returned by a
+// heartbeat in pull mode
+func (s *appShardServer) converge(desired []*shardmanagerpb.ShardReplica) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	next := make(map[string]string, len(desired))
+	for _, replica := range desired {
+		if role, ok := s.shards[replica.ShardId]; !ok {
+			log.Printf("Pull: adding shard_id=%s, role=%s", replica.ShardId, replica.Role)
+		} else if role != replica.Role {
+			log.Printf("Pull: changing role of shard_id=%s from %s to %s", replica.ShardId, role, replica.Role)
+		}
+		next[replica.ShardId] = replica.Role
+	}
+	for shardID := range s.shards {
+		if _, ok := next[shardID]; !ok {
+			log.Printf("Pull: dropping shard_id=%s", shardID)
+		}
+	}
+	s.shards = next
+}
+
+// registerWithShardManager registers this app server under a stable name and
+// returns the node ID assigned by the shardmanager
+func registerWithShardManager(shardManagerAddr, nodeName, appServerAddr, mode, faultDomain string) string {
+	conn, err := grpc.Dial(shardManagerAddr, shardManagerDialOptions()...)
 	if err != nil {
 		log.Fatalf("Failed to connect to shardmanager: %v", err)
 	}
@@ -49,21 +110,24 @@ func registerWithShardManager(shardManagerAddr, nodeID, appServerAddr string) {
 
 	req := &shardmanagerpb.RegisterNodeRequest{
 		Node: &shardmanagerpb.Node{
-			Id:       nodeID,
-			Location: appServerAddr,
-			Capacity: 100, // example value
-			Status:   "active",
+			Name:           nodeName,
+			Location:       appServerAddr,
+			Capacity:       100, // example value
+			Status:         "active",
+			AssignmentMode: mode,
+			FaultDomain:    faultDomain,
 		},
 	}
 	resp, err := client.RegisterNode(context.Background(), req)
 	if err != nil || !resp.Success {
 		log.Fatalf("RegisterNode failed: %v, message: %s", err, resp.GetMessage())
 	}
-	log.Printf("Registered with shardmanager: %s", resp.GetMessage())
+	log.Printf("Registered with shardmanager as node %s: %s", resp.GetNodeId(), resp.GetMessage())
+	return resp.GetNodeId()
 }
 
-func sendHeartbeats(shardManagerAddr, nodeID string) {
-	conn, err := grpc.Dial(shardManagerAddr, grpc.WithInsecure())
+func sendHeartbeats(shardManagerAddr, nodeID string, app *appShardServer, pull bool) {
+	conn, err := grpc.Dial(shardManagerAddr, shardManagerDialOptions()...)
 	if err != nil {
 		log.Fatalf("Failed to connect to shardmanager for heartbeat: %v", err)
 	}
@@ -76,31 +140,101 @@ func sendHeartbeats(shardManagerAddr, nodeID string) {
 				Status: "active",
 				Load:   0, // example value
 			}
-			_, err := client.Heartbeat(context.Background(), req)
+			if pull {
+				req.Shards = app.localShards()
+			}
+			resp, err := client.Heartbeat(context.Background(), req)
 			if err != nil {
 				log.Printf("Heartbeat failed: %v", err)
+			} else if pull {
+				app.converge(resp.DesiredShards)
 			}
 			time.Sleep(10 * time.Second)
 		}
 	}()
 }
 
+var (
+	mode    = flag.String("mode", "push", "Shard assignment mode: push or pull")
+	domain  = flag.String("fault-domain", "", "Fault domain (rack or zone) this app server fails with")
+	token   = flag.String("token", "", "Bearer token presented to the shardmanager")
+	tlsCert = flag.String("tls-cert", "", "PEM certificate for serving and for calling the shardmanager")
+	tlsKey  = flag.String("tls-key", "", "PEM private key for -tls-cert")
+	tlsCA   = flag.String("tls-ca", "", "PEM CA bundle verifying the shardmanager")
+)
+
+// bearerToken attaches a bearer token to every call
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t bearerToken) RequireTransportSecurity() bool {
+	return false
+}
+
+// tlsConfig loads the configured certificate and CA bundle, or returns nil
+// when TLS is not configured
+func tlsConfig() *tls.Config {
+	if *tlsCert == "" {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+	if err != nil {
+		log.Fatalf("Failed to load TLS key pair: %v", err)
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}
+	if *tlsCA != "" {
+		pem, err := os.ReadFile(*tlsCA)
+		if err != nil {
+			log.Fatalf("Failed to read CA bundle: %v", err)
+		}
+		pool := x509.NewCertPool()
+		pool.AppendCertsFromPEM(pem)
+		// Verify the shardmanager when dialing, and require its client
+		// certificate when serving
+		cfg.RootCAs = pool
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg
+}
+
+func shardManagerDialOptions() []grpc.DialOption {
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	if cfg := tlsConfig(); cfg != nil {
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(cfg))}
+	}
+	if *token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(bearerToken(*token)))
+	}
+	return opts
+}
+
 func main() {
-	nodeID := "appserver-1"
+	flag.Parse()
+
+	nodeName := "appserver-1"
 	appServerAddr := "localhost:50051"
 	shardManagerAddr := "localhost:6000"
+	app := newAppShardServer()
 
 	// Register with shardmanager
-	registerWithShardManager(shardManagerAddr, nodeID, appServerAddr)
+	nodeID := registerWithShardManager(shardManagerAddr, nodeName, appServerAddr, *mode, *domain)
 	// Start sending heartbeats
-	sendHeartbeats(shardManagerAddr, nodeID)
+	sendHeartbeats(shardManagerAddr, nodeID, app, *mode == "pull")
 
 	lis, err := net.Listen("tcp", ":50051")
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	grpcServer := grpc.NewServer()
-	shardmanagerpb.RegisterAppShardServiceServer(grpcServer, &appShardServer{})
+	var serverOpts []grpc.ServerOption
+	if cfg := tlsConfig(); cfg != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(cfg)))
+	}
+	grpcServer := grpc.NewServer(serverOpts...)
+	shardmanagerpb.RegisterAppShardServiceServer(grpcServer, app)
 	log.Println("AppShardService server listening on :50051")
 	if err := grpcServer.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
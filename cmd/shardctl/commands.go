@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/policy"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const usage = `commands:
+  nodes list
+  nodes drain <node>
+  nodes undrain <node>
+  shards list
+  shards info <shard>
+  shards assign <shard> <node>
+  shards migrate <shard> <node>
+  shards history <shard>
+  shards rollback <shard> <version>
+  policy get <type>
+  policy set <type> <file>
+  policy validate <file>
+  health
+  distribution
+`
+
+// usageError reports a command line that does not match any command
+type usageError string
+
+func (e usageError) Error() string { return string(e) }
+
+// cli runs shardctl commands against one connection
+type cli struct {
+	nodes      shardmanagerpb.NodeServiceClient
+	shards     shardmanagerpb.ShardServiceClient
+	policies   shardmanagerpb.PolicyServiceClient
+	monitoring shardmanagerpb.MonitoringServiceClient
+	out        io.Writer
+	json       bool
+}
+
+func newCLI(conn grpc.ClientConnInterface, out io.Writer, jsonOutput bool) *cli {
+	return &cli{
+		nodes:      shardmanagerpb.NewNodeServiceClient(conn),
+		shards:     shardmanagerpb.NewShardServiceClient(conn),
+		policies:   shardmanagerpb.NewPolicyServiceClient(conn),
+		monitoring: shardmanagerpb.NewMonitoringServiceClient(conn),
+		out:        out,
+		json:       jsonOutput,
+	}
+}
+
+// run executes the command named by args
+func (c *cli) run(ctx context.Context, args []string) error {
+	command := strings.Join(args[:min(2, len(args))], " ")
+	switch {
+	case command == "nodes list" && len(args) == 2:
+		return c.listNodes(ctx)
+	case command == "nodes drain" && len(args) == 3:
+		return c.drainNode(ctx, args[2])
+	case command == "nodes undrain" && len(args) == 3:
+		return c.undrainNode(ctx, args[2])
+	case command == "shards list" && len(args) == 2:
+		return c.listShards(ctx)
+	case command == "shards info" && len(args) == 3:
+		return c.shardInfo(ctx, args[2])
+	case command == "shards assign" && len(args) == 4:
+		return c.assignShard(ctx, args[2], args[3])
+	case command == "shards migrate" && len(args) == 4:
+		return c.migrateShard(ctx, args[2], args[3])
+	case command == "shards history" && len(args) == 3:
+		return c.shardHistory(ctx, args[2])
+	case command == "shards rollback" && len(args) == 4:
+		return c.rollbackShard(ctx, args[2], args[3])
+	case command == "policy get" && len(args) == 3:
+		return c.getPolicy(ctx, args[2])
+	case command == "policy set" && len(args) == 4:
+		return c.setPolicy(ctx, args[2], args[3])
+	case command == "policy validate" && len(args) == 3:
+		return c.validatePolicy(args[2])
+	case args[0] == "health" && len(args) == 1:
+		return c.health(ctx)
+	case args[0] == "distribution" && len(args) == 1:
+		return c.distribution(ctx)
+	}
+	return usageError(fmt.Sprintf("unknown command %q", strings.Join(args, " ")))
+}
+
+// resolveNode turns a node name into its ID; IDs are returned unchanged
+func (c *cli) resolveNode(ctx context.Context, ref string) (string, error) {
+	if _, err := uuid.Parse(ref); err == nil {
+		return ref, nil
+	}
+	resp, err := c.nodes.ListNodes(ctx, &shardmanagerpb.ListNodesRequest{})
+	if err != nil {
+		return "", err
+	}
+	for _, node := range resp.Nodes {
+		if node.Name == ref {
+			return node.Id, nil
+		}
+	}
+	return "", fmt.Errorf("no node named %q", ref)
+}
+
+func (c *cli) listNodes(ctx context.Context) error {
+	resp, err := c.nodes.ListNodes(ctx, &shardmanagerpb.ListNodesRequest{})
+	if err != nil {
+		return err
+	}
+	sort.Slice(resp.Nodes, func(i, j int) bool { return resp.Nodes[i].Location < resp.Nodes[j].Location })
+	return c.print(resp, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tNAME\tLOCATION\tSTATUS\tMODE\tCAPACITY\tHEADROOM")
+		for _, n := range resp.Nodes {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+				n.Id, dash(n.Name), n.Location, n.Status, dash(n.AssignmentMode), n.Capacity, resources(n.ResourceHeadroom))
+		}
+	})
+}
+
+func (c *cli) drainNode(ctx context.Context, ref string) error {
+	nodeID, err := c.resolveNode(ctx, ref)
+	if err != nil {
+		return err
+	}
+	resp, err := c.nodes.DrainNode(ctx, &shardmanagerpb.DrainNodeRequest{NodeId: nodeID})
+	if err != nil {
+		return err
+	}
+	if err := c.printMessage(resp, resp.Message); err != nil {
+		return err
+	}
+	if !resp.Success {
+		return fmt.Errorf("%d shards were not migrated", len(resp.FailedShards))
+	}
+	return nil
+}
+
+func (c *cli) undrainNode(ctx context.Context, ref string) error {
+	nodeID, err := c.resolveNode(ctx, ref)
+	if err != nil {
+		return err
+	}
+	resp, err := c.nodes.UndrainNode(ctx, &shardmanagerpb.UndrainNodeRequest{NodeId: nodeID})
+	if err != nil {
+		return err
+	}
+	return c.printMessage(resp, resp.Message)
+}
+
+func (c *cli) listShards(ctx context.Context) error {
+	resp, err := c.shards.ListShards(ctx, &shardmanagerpb.ListShardsRequest{})
+	if err != nil {
+		return err
+	}
+	sort.Slice(resp.Shards, func(i, j int) bool { return resp.Shards[i].Id < resp.Shards[j].Id })
+	return c.print(resp, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tTYPE\tSIZE\tNODE\tSTATUS")
+		for _, s := range resp.Shards {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", s.Id, s.Type, s.Size, dash(s.NodeId), s.Status)
+		}
+	})
+}
+
+func (c *cli) shardInfo(ctx context.Context, shardID string) error {
+	resp, err := c.shards.GetShardInfo(ctx, &shardmanagerpb.GetShardInfoRequest{ShardId: shardID})
+	if err != nil {
+		return err
+	}
+	return c.print(resp, func(w *tabwriter.Writer) {
+		s := resp.Shard
+		fmt.Fprintf(w, "ID:\t%s\n", s.Id)
+		fmt.Fprintf(w, "Type:\t%s\n", s.Type)
+		fmt.Fprintf(w, "Size:\t%d\n", s.Size)
+		fmt.Fprintf(w, "Node:\t%s\n", dash(s.NodeId))
+		fmt.Fprintf(w, "Status:\t%s\n", s.Status)
+		fmt.Fprintf(w, "Demand:\t%s\n", resources(s.ResourceDemand))
+	})
+}
+
+func (c *cli) assignShard(ctx context.Context, shardID, ref string) error {
+	nodeID, err := c.resolveNode(ctx, ref)
+	if err != nil {
+		return err
+	}
+	resp, err := c.shards.AssignShard(ctx, &shardmanagerpb.AssignShardRequest{ShardId: shardID, NodeId: nodeID})
+	if err != nil {
+		return err
+	}
+	return c.printMessage(resp, resp.Message)
+}
+
+// migrateShard moves a shard from wherever it is now to the given node
+func (c *cli) migrateShard(ctx context.Context, shardID, ref string) error {
+	toNodeID, err := c.resolveNode(ctx, ref)
+	if err != nil {
+		return err
+	}
+	info, err := c.shards.GetShardInfo(ctx, &shardmanagerpb.GetShardInfoRequest{ShardId: shardID})
+	if err != nil {
+		return err
+	}
+	if info.Shard.NodeId == "" {
+		return fmt.Errorf("shard %s is unassigned; use shards assign", shardID)
+	}
+	resp, err := c.shards.MigrateShard(ctx, &shardmanagerpb.MigrateShardRequest{
+		ShardId:    shardID,
+		FromNodeId: info.Shard.NodeId,
+		ToNodeId:   toNodeID,
+	})
+	if err != nil {
+		return err
+	}
+	return c.printMessage(resp, resp.Message)
+}
+
+func (c *cli) shardHistory(ctx context.Context, shardID string) error {
+	resp, err := c.shards.GetShardHistory(ctx, &shardmanagerpb.GetShardHistoryRequest{ShardId: shardID})
+	if err != nil {
+		return err
+	}
+	return c.print(resp, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "VERSION\tNODE\tSTATUS\tTYPE\tSIZE\tREPLACED")
+		for _, v := range resp.Versions {
+			replaced := "current"
+			if v.RecordedAtUnixMs != 0 {
+				replaced = time.UnixMilli(v.RecordedAtUnixMs).Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\n", v.Version, dash(v.NodeId), v.Status, v.Type, v.Size, replaced)
+		}
+	})
+}
+
+func (c *cli) rollbackShard(ctx context.Context, shardID, version string) error {
+	v, err := strconv.ParseInt(version, 10, 32)
+	if err != nil {
+		return usageError(fmt.Sprintf("invalid version %q", version))
+	}
+	resp, err := c.shards.RollbackShard(ctx, &shardmanagerpb.RollbackShardRequest{ShardId: shardID, Version: int32(v)})
+	if err != nil {
+		return err
+	}
+	return c.printMessage(resp, resp.Message)
+}
+
+func (c *cli) getPolicy(ctx context.Context, policyType string) error {
+	resp, err := c.policies.GetPolicy(ctx, &shardmanagerpb.GetPolicyRequest{PolicyType: policyType})
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.print(resp, nil)
+	}
+	fmt.Fprintln(c.out, indentJSON(resp.Parameters))
+	return nil
+}
+
+// setPolicy stores a policy file after checking that it parses
+func (c *cli) setPolicy(ctx context.Context, policyType, path string) error {
+	data, err := readInput(path)
+	if err != nil {
+		return err
+	}
+	if !json.Valid(data) {
+		return fmt.Errorf("%s is not valid JSON", path)
+	}
+	resp, err := c.policies.SetPolicy(ctx, &shardmanagerpb.SetPolicyRequest{PolicyType: policyType, Parameters: string(data)})
+	if err != nil {
+		return err
+	}
+	return c.printMessage(resp, resp.Message)
+}
+
+// validatePolicy checks a policy definition locally, without the server
+func (c *cli) validatePolicy(path string) error {
+	data, err := readInput(path)
+	if err != nil {
+		return err
+	}
+	p, err := policy.NewDefaultParser().Parse(data)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "policy %q (%s) is valid\n", p.Name, p.Type)
+	return nil
+}
+
+func (c *cli) health(ctx context.Context) error {
+	resp, err := c.monitoring.GetHealth(ctx, &shardmanagerpb.GetHealthRequest{})
+	if err != nil {
+		return err
+	}
+	return c.print(resp, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "Status:\t%s\n", resp.Status)
+		fmt.Fprintf(w, "Summary:\t%s\n", resp.Summary)
+		if resp.DbReachable {
+			fmt.Fprintln(w, "Database:\treachable")
+		} else {
+			fmt.Fprintf(w, "Database:\tunreachable: %s\n", resp.DbError)
+		}
+		fmt.Fprintf(w, "Nodes:\t%d (%d active)\n", resp.TotalNodes, resp.ActiveNodes)
+		fmt.Fprintf(w, "Shards:\t%d\n", resp.TotalShards)
+		if resp.OutboxBacklog > 0 {
+			fmt.Fprintf(w, "Outbox:\t%d pending, oldest %s\n", resp.OutboxBacklog, time.Duration(resp.OutboxOldestAgeMs)*time.Millisecond)
+		}
+		for _, list := range []struct {
+			name string
+			ids  []string
+		}{
+			{"Stale nodes", resp.StaleNodes},
+			{"Failed nodes", resp.FailedNodes},
+			{"Unassigned shards", resp.UnassignedShards},
+			{"Stuck migrations", resp.StuckMigratingShards},
+			{"Unavailable shards", resp.UnavailableShards},
+			{"Replica violations", resp.ReplicaViolations},
+			{"Fault domain violations", resp.FaultDomainViolations},
+		} {
+			if len(list.ids) > 0 {
+				fmt.Fprintf(w, "%s:\t%s\n", list.name, strings.Join(list.ids, ", "))
+			}
+		}
+	})
+}
+
+func (c *cli) distribution(ctx context.Context) error {
+	resp, err := c.monitoring.GetDistribution(ctx, &shardmanagerpb.GetDistributionRequest{})
+	if err != nil {
+		return err
+	}
+	nodeIDs := make([]string, 0, len(resp.NodeShards))
+	for id := range resp.NodeShards {
+		nodeIDs = append(nodeIDs, id)
+	}
+	sort.Strings(nodeIDs)
+	return c.print(resp, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "NODE\tSHARDS\tIDS")
+		for _, id := range nodeIDs {
+			ids := resp.NodeShards[id].ShardIds
+			fmt.Fprintf(w, "%s\t%d\t%s\n", id, len(ids), strings.Join(ids, ","))
+		}
+	})
+}
+
+// print writes msg as JSON, or as the table drawn by table
+func (c *cli) print(msg proto.Message, table func(w *tabwriter.Writer)) error {
+	if c.json {
+		data, err := protojson.MarshalOptions{Multiline: true, UseProtoNames: true}.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(c.out, string(data))
+		return err
+	}
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	table(w)
+	return w.Flush()
+}
+
+// printMessage writes msg as JSON, or just its human-readable message
+func (c *cli) printMessage(msg proto.Message, message string) error {
+	return c.print(msg, func(w *tabwriter.Writer) { fmt.Fprintln(w, message) })
+}
+
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+func indentJSON(s string) string {
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		return s
+	}
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return s
+	}
+	return string(out)
+}
+
+// resources formats a resource map as "cpu=2,memory=4"
+func resources(r map[string]float64) string {
+	if len(r) == 0 {
+		return "-"
+	}
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + strconv.FormatFloat(r[name], 'g', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
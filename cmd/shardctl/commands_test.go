@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/seaweedfs/shardmanager/server"
+	"github.com/seaweedfs/shardmanager/server/testutil"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+// startServer serves a shardmanager backed by a mock database in memory
+func startServer(t *testing.T) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	srv := server.NewServer(testutil.NewMockDB())
+	s := grpc.NewServer()
+	shardmanagerpb.RegisterNodeServiceServer(s, srv)
+	shardmanagerpb.RegisterShardServiceServer(s, srv)
+	shardmanagerpb.RegisterPolicyServiceServer(s, srv)
+	shardmanagerpb.RegisterMonitoringServiceServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestCommands(t *testing.T) {
+	ctx := context.Background()
+	conn := startServer(t)
+	var out bytes.Buffer
+	table := newCLI(conn, &out, false)
+	asJSON := newCLI(conn, &out, true)
+
+	nodes := shardmanagerpb.NewNodeServiceClient(conn)
+	for _, name := range []string{"app-1", "app-2"} {
+		_, err := nodes.RegisterNode(ctx, &shardmanagerpb.RegisterNodeRequest{
+			Node: &shardmanagerpb.Node{Name: name, Location: "localhost:0", Status: "active"},
+		})
+		require.NoError(t, err)
+	}
+	shardID := uuid.NewString()
+	_, err := shardmanagerpb.NewShardServiceClient(conn).RegisterShard(ctx, &shardmanagerpb.RegisterShardRequest{
+		Shard: &shardmanagerpb.Shard{Id: shardID, Type: "range", Size: 1, Status: "active"},
+	})
+	require.NoError(t, err)
+
+	t.Run("Table", func(t *testing.T) {
+		out.Reset()
+		require.NoError(t, table.run(ctx, []string{"nodes", "list"}))
+		assert.Contains(t, out.String(), "NAME")
+		assert.Contains(t, out.String(), "app-2")
+
+		out.Reset()
+		require.NoError(t, table.run(ctx, []string{"health"}))
+		assert.Contains(t, out.String(), "Status:")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		out.Reset()
+		require.NoError(t, asJSON.run(ctx, []string{"shards", "list"}))
+		var decoded struct {
+			Shards []struct {
+				ID string `json:"id"`
+			} `json:"shards"`
+		}
+		require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+		require.Len(t, decoded.Shards, 1)
+		assert.Equal(t, shardID, decoded.Shards[0].ID)
+	})
+
+	t.Run("DrainByName", func(t *testing.T) {
+		out.Reset()
+		// Leaves the shard on app-2
+		require.NoError(t, table.run(ctx, []string{"nodes", "drain", "app-1"}))
+		require.NoError(t, table.run(ctx, []string{"nodes", "undrain", "app-1"}))
+		assert.Contains(t, out.String(), "Node returned to service")
+
+		err := table.run(ctx, []string{"nodes", "drain", "app-3"})
+		assert.EqualError(t, err, `no node named "app-3"`)
+	})
+
+	t.Run("MigrateAndHistory", func(t *testing.T) {
+		require.NoError(t, table.run(ctx, []string{"shards", "migrate", shardID, "app-1"}))
+		out.Reset()
+		require.NoError(t, table.run(ctx, []string{"shards", "history", shardID}))
+		assert.Contains(t, out.String(), "current")
+		assert.NotContains(t, out.String(), "migrating", "a migration is a single version")
+	})
+
+	t.Run("Policy", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "policy.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{
+			"name": "spread", "type": "placement",
+			"conditions": {"all": [{"metric": "load", "operator": "lt", "value": 80}]},
+			"actions": [{"type": "place"}]
+		}`), 0o600))
+
+		out.Reset()
+		require.NoError(t, table.run(ctx, []string{"policy", "validate", path}))
+		assert.Contains(t, out.String(), `policy "spread" (placement) is valid`)
+
+		require.NoError(t, table.run(ctx, []string{"policy", "set", "placement", path}))
+		out.Reset()
+		require.NoError(t, table.run(ctx, []string{"policy", "get", "placement"}))
+		assert.Contains(t, out.String(), `"name": "spread"`)
+
+		require.NoError(t, os.WriteFile(path, []byte(`{"name": "empty", "type": "placement"}`), 0o600))
+		assert.Error(t, table.run(ctx, []string{"policy", "validate", path}))
+	})
+
+	t.Run("Usage", func(t *testing.T) {
+		err := table.run(ctx, []string{"shards", "explode"})
+		assert.IsType(t, usageError(""), err)
+		err = table.run(ctx, []string{"shards", "rollback", shardID, "latest"})
+		assert.IsType(t, usageError(""), err)
+	})
+}
@@ -0,0 +1,158 @@
+// Command shardctl administers a shardmanager server.
+//
+//	shardctl [flags] nodes list|drain <node>|undrain <node>
+//	shardctl [flags] shards list|info <shard>|assign <shard> <node>|migrate <shard> <node>
+//	shardctl [flags] shards history <shard>|rollback <shard> <version>
+//	shardctl [flags] policy get <type>|set <type> <file>|validate <file>
+//	shardctl [flags] health|distribution
+//
+// Nodes may be named by ID or by name. Policy files may be "-" for stdin.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	addr          = flag.String("addr", envOr("SHARDCTL_ADDR", "localhost:7427"), "shardmanager gRPC address (env SHARDCTL_ADDR)")
+	output        = flag.String("o", "table", "Output format: table or json")
+	token         = flag.String("token", os.Getenv("SHARDCTL_TOKEN"), "Bearer token presented to the shardmanager (env SHARDCTL_TOKEN)")
+	useTLS        = flag.Bool("tls", false, "Connect over TLS, verifying the shardmanager against the system roots unless -ca is set")
+	tlsCA         = flag.String("ca", "", "PEM CA bundle verifying the shardmanager; implies -tls")
+	tlsCert       = flag.String("cert", "", "PEM client certificate; implies -tls")
+	tlsKey        = flag.String("key", "", "PEM private key for -cert")
+	insecureToken = flag.Bool("insecure-token", false, "Allow -token over a connection without TLS")
+	timeout       = flag.Duration("timeout", 30*time.Second, "Deadline for each command")
+)
+
+func envOr(name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// bearerToken attaches a bearer token to every call
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+// RequireTransportSecurity is false so -insecure-token can send a token in
+// the clear; dialOptions refuses to otherwise
+func (t bearerToken) RequireTransportSecurity() bool {
+	return false
+}
+
+// dialConfig is how shardctl connects to the shardmanager
+type dialConfig struct {
+	tls           bool
+	ca            string
+	cert          string
+	key           string
+	token         string
+	insecureToken bool
+}
+
+var errTokenWithoutTLS = errors.New("refusing to send -token without TLS; use -tls, -ca or -cert, or pass -insecure-token")
+
+// dialOptions connects over TLS if cfg asks for it, and only attaches a
+// token to an insecure connection if cfg explicitly allows it
+func dialOptions(cfg dialConfig) ([]grpc.DialOption, error) {
+	secure := cfg.tls || cfg.ca != "" || cfg.cert != ""
+	if cfg.token != "" && !secure && !cfg.insecureToken {
+		return nil, errTokenWithoutTLS
+	}
+	if cfg.key != "" && cfg.cert == "" {
+		return nil, errors.New("-key needs -cert")
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if secure {
+		tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+		if cfg.cert != "" {
+			cert, err := tls.LoadX509KeyPair(cfg.cert, cfg.key)
+			if err != nil {
+				return nil, fmt.Errorf("load TLS key pair: %w", err)
+			}
+			tlsCfg.Certificates = []tls.Certificate{cert}
+		}
+		if cfg.ca != "" {
+			pem, err := os.ReadFile(cfg.ca)
+			if err != nil {
+				return nil, fmt.Errorf("read CA bundle: %w", err)
+			}
+			tlsCfg.RootCAs = x509.NewCertPool()
+			if !tlsCfg.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates in CA bundle %s", cfg.ca)
+			}
+		}
+		opts = []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsCfg))}
+	}
+	if cfg.token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(bearerToken(cfg.token)))
+	}
+	return opts, nil
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: shardctl [flags] <command> [args]\n\n%s\nflags:\n", usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *output != "table" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "shardctl: unknown output format %q\n", *output)
+		os.Exit(2)
+	}
+
+	opts, err := dialOptions(dialConfig{
+		tls:           *useTLS,
+		ca:            *tlsCA,
+		cert:          *tlsCert,
+		key:           *tlsKey,
+		token:         *token,
+		insecureToken: *insecureToken,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "shardctl: %v\n", err)
+		os.Exit(1)
+	}
+	conn, err := grpc.Dial(*addr, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "shardctl: connect to %s: %v\n", *addr, err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	c := newCLI(conn, os.Stdout, *output == "json")
+	if err := c.run(ctx, flag.Args()); err != nil {
+		if _, ok := err.(usageError); ok {
+			fmt.Fprintf(os.Stderr, "shardctl: %v\n\n%s", err, usage)
+			os.Exit(2)
+		}
+		if st, ok := status.FromError(err); ok {
+			err = fmt.Errorf("%s: %s", st.Code(), st.Message())
+		}
+		fmt.Fprintf(os.Stderr, "shardctl: %v\n", err)
+		os.Exit(1)
+	}
+}
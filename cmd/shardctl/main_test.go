@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialOptions(t *testing.T) {
+	t.Run("TokenNeedsTLS", func(t *testing.T) {
+		_, err := dialOptions(dialConfig{token: "secret"})
+		assert.ErrorIs(t, err, errTokenWithoutTLS)
+	})
+
+	t.Run("InsecureTokenOverride", func(t *testing.T) {
+		opts, err := dialOptions(dialConfig{token: "secret", insecureToken: true})
+		require.NoError(t, err)
+		assert.Len(t, opts, 2)
+	})
+
+	t.Run("TokenOverTLS", func(t *testing.T) {
+		opts, err := dialOptions(dialConfig{token: "secret", tls: true})
+		require.NoError(t, err)
+		assert.Len(t, opts, 2)
+	})
+
+	t.Run("NoToken", func(t *testing.T) {
+		opts, err := dialOptions(dialConfig{})
+		require.NoError(t, err)
+		assert.Len(t, opts, 1)
+	})
+
+	t.Run("KeyNeedsCert", func(t *testing.T) {
+		_, err := dialOptions(dialConfig{key: "client.key"})
+		assert.EqualError(t, err, "-key needs -cert")
+	})
+
+	t.Run("CAImpliesTLS", func(t *testing.T) {
+		// Reading the bundle shows TLS was configured
+		_, err := dialOptions(dialConfig{ca: filepath.Join(t.TempDir(), "missing.pem"), token: "secret"})
+		assert.ErrorContains(t, err, "read CA bundle")
+
+		empty := filepath.Join(t.TempDir(), "empty.pem")
+		require.NoError(t, os.WriteFile(empty, nil, 0o600))
+		_, err = dialOptions(dialConfig{ca: empty})
+		assert.ErrorContains(t, err, "no certificates in CA bundle")
+	})
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// defaultAuditLimit caps ListAuditEvents when the filter sets no limit
+const defaultAuditLimit = 100
+
+// RecordAuditEvent appends an event to the audit log
+func (db *DB) RecordAuditEvent(ctx context.Context, event *AuditEvent) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	event.CreatedAt = event.CreatedAt.UTC()
+
+	var request sql.NullString
+	if len(event.Request) > 0 {
+		request = sql.NullString{String: string(event.Request), Valid: true}
+	}
+	query := `
+		INSERT INTO audit_log (id, actor, method, entity_type, entity_id, request, result, error_message, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := db.ExecContext(ctx, query,
+		event.ID, event.Actor, event.Method, event.EntityType, event.EntityID,
+		request, event.Result, event.Error, event.CreatedAt,
+	)
+	return err
+}
+
+// ListAuditEvents retrieves audit events matching filter, newest first
+func (db *DB) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]*AuditEvent, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	var conds []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.EntityID != "" {
+		add("entity_id = $%d", filter.EntityID)
+	}
+	if filter.Actor != "" {
+		add("actor = $%d", filter.Actor)
+	}
+	if !filter.Since.IsZero() {
+		add("created_at >= $%d", filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		add("created_at < $%d", filter.Until.UTC())
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+
+	query := `
+		SELECT id, actor, method, entity_type, entity_id, request, result, error_message, created_at
+		FROM audit_log`
+	if len(conds) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf("\n\t\tORDER BY created_at DESC\n\t\tLIMIT $%d", len(args))
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*AuditEvent
+	for rows.Next() {
+		event := &AuditEvent{}
+		var entityType, entityID, request, errMsg sql.NullString
+		err := rows.Scan(
+			&event.ID, &event.Actor, &event.Method, &entityType, &entityID,
+			&request, &event.Result, &errMsg, &event.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		event.EntityType = entityType.String
+		event.EntityID = entityID.String
+		event.Error = errMsg.String
+		if request.Valid {
+			event.Request = json.RawMessage(request.String)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
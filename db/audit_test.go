@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	db := setupSchemaDB(t)
+
+	base := time.Now().Add(-time.Hour).UTC()
+	events := []*AuditEvent{
+		{Actor: "alice", Method: "/svc/AssignShard", EntityType: "shard", EntityID: "s1",
+			Request: json.RawMessage(`{"shardId":"s1"}`), Result: "OK", CreatedAt: base},
+		{Actor: "bob", Method: "/svc/MigrateShard", EntityType: "shard", EntityID: "s1",
+			Result: "FailedPrecondition", Error: "not on source", CreatedAt: base.Add(time.Minute)},
+		{Actor: "alice", Method: "/svc/SetPolicy", EntityType: "policy", EntityID: "placement",
+			Result: "OK", CreatedAt: base.Add(2 * time.Minute)},
+	}
+	for _, event := range events {
+		require.NoError(t, db.RecordAuditEvent(ctx, event))
+	}
+
+	all, err := db.ListAuditEvents(ctx, AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, "/svc/SetPolicy", all[0].Method)
+	assert.JSONEq(t, `{"shardId":"s1"}`, string(all[2].Request))
+	assert.Equal(t, "not on source", all[1].Error)
+
+	byEntity, err := db.ListAuditEvents(ctx, AuditFilter{EntityID: "s1"})
+	require.NoError(t, err)
+	assert.Len(t, byEntity, 2)
+
+	byActor, err := db.ListAuditEvents(ctx, AuditFilter{Actor: "alice", EntityID: "s1"})
+	require.NoError(t, err)
+	require.Len(t, byActor, 1)
+	assert.Equal(t, "/svc/AssignShard", byActor[0].Method)
+
+	window, err := db.ListAuditEvents(ctx, AuditFilter{Since: base.Add(30 * time.Second), Until: base.Add(2 * time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, window, 1)
+	assert.Equal(t, "bob", window[0].Actor)
+
+	limited, err := db.ListAuditEvents(ctx, AuditFilter{Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, limited, 2)
+}
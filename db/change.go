@@ -0,0 +1,202 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"maps"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Entities named by change events
+const (
+	ChangeNode   = "node"
+	ChangeShard  = "shard"
+	ChangePolicy = "policy"
+	// ChangeResync means changes may have been missed, so anything derived
+	// from the database should be reloaded
+	ChangeResync = "resync"
+)
+
+const (
+	// changeChannel is the Postgres NOTIFY channel carrying change events
+	changeChannel = "shardmanager_changes"
+	// changePollInterval is how often change_log is polled without LISTEN
+	changePollInterval = time.Second
+	// changeRetention is how long change_log rows are kept for pollers
+	changeRetention = time.Hour
+)
+
+// Change announces that a row was written by this or another instance
+type Change struct {
+	Entity string `json:"entity"`
+	// ID is the node or shard ID, or the policy type
+	ID string `json:"id,omitempty"`
+}
+
+// HeartbeatChangeInterval is how often a heartbeat that changes nothing but
+// the node's last heartbeat is announced. Other instances learn that the
+// node is alive at this granularity instead of from every heartbeat.
+const HeartbeatChangeInterval = 10 * time.Second
+
+// HeartbeatChangesNode reports whether a heartbeat at now reporting status,
+// load and resourceLoad should be announced as a change to node: it changes
+// the node's status or load, or it is the node's first heartbeat in a new
+// HeartbeatChangeInterval.
+func HeartbeatChangesNode(node *Node, status string, load int64, resourceLoad Resources, now time.Time) bool {
+	if node.Status != "maintenance" && node.Status != status {
+		return true
+	}
+	if node.CurrentLoad != load || !maps.Equal(node.ResourceLoad, resourceLoad) {
+		return true
+	}
+	return !node.LastHeartbeat.Truncate(HeartbeatChangeInterval).Equal(now.Truncate(HeartbeatChangeInterval))
+}
+
+// recordChange publishes change when tx commits: as a NOTIFY on Postgres,
+// or as a change_log row that WatchChanges polls on other drivers
+func (db *DB) recordChange(ctx context.Context, tx *sql.Tx, change Change) error {
+	if db.dialect() == dialectPostgres {
+		payload, err := json.Marshal(change)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, changeChannel, string(payload))
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO change_log (entity, entity_id, created_at)
+		VALUES ($1, $2, $3)`, change.Entity, change.ID, time.Now().UTC())
+	return err
+}
+
+// writeWithChange runs write in a transaction that also records change,
+// retried as inTx describes
+func (db *DB) writeWithChange(ctx context.Context, change Change, write func(ctx context.Context, tx *sql.Tx) error) error {
+	return db.inTx(ctx, db.withChange(change, write))
+}
+
+// serializableWriteWithChange is writeWithChange in a transaction retried
+// as inSerializableTx describes
+func (db *DB) serializableWriteWithChange(ctx context.Context, change Change, write func(ctx context.Context, tx *sql.Tx) error) error {
+	return db.inSerializableTx(ctx, db.withChange(change, write))
+}
+
+// withChange wraps write to record change after it
+func (db *DB) withChange(change Change, write func(ctx context.Context, tx *sql.Tx) error) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		if err := write(ctx, tx); err != nil {
+			return db.dialect().translateError(err)
+		}
+		return db.recordChange(ctx, tx, change)
+	}
+}
+
+// WatchChanges calls fn with every change made by any instance sharing the
+// database until ctx is cancelled. Postgres changes arrive through LISTEN;
+// other drivers poll change_log. After a lost connection fn is called with
+// a ChangeResync event.
+func (db *DB) WatchChanges(ctx context.Context, fn func(Change)) error {
+	if db.dialect() == dialectPostgres {
+		return db.listenChanges(ctx, fn)
+	}
+	return db.pollChanges(ctx, fn)
+}
+
+func (db *DB) listenChanges(ctx context.Context, fn func(Change)) error {
+	if db.dsn == "" {
+		return errors.New("listening for changes needs the connection string the DB was opened with")
+	}
+	listener := pq.NewListener(db.dsn, time.Second, 30*time.Second, nil)
+	defer listener.Close()
+	if err := listener.Listen(changeChannel); err != nil {
+		return err
+	}
+
+	keepalive := time.NewTicker(90 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			// A nil notification follows a reconnect
+			if n == nil {
+				fn(Change{Entity: ChangeResync})
+				continue
+			}
+			var change Change
+			if err := json.Unmarshal([]byte(n.Extra), &change); err != nil {
+				fn(Change{Entity: ChangeResync})
+				continue
+			}
+			fn(change)
+		case <-keepalive.C:
+			// Detects a dead connection that would otherwise drop notifications
+			go listener.Ping()
+		}
+	}
+}
+
+func (db *DB) pollChanges(ctx context.Context, fn func(Change)) error {
+	var last int64
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM change_log`).Scan(&last); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(changePollInterval)
+	defer ticker.Stop()
+	lastPrune := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		changes, seq, err := db.changesSince(ctx, last)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		last = seq
+		for _, change := range changes {
+			fn(change)
+		}
+		if time.Since(lastPrune) > changeRetention/10 {
+			lastPrune = time.Now()
+			if _, err := db.ExecContext(ctx, `DELETE FROM change_log WHERE created_at < $1`,
+				time.Now().Add(-changeRetention).UTC()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// changesSince reads the change_log entries after seq, returning the
+// sequence number of the last one
+func (db *DB) changesSince(ctx context.Context, seq int64) ([]Change, int64, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, entity, entity_id
+		FROM change_log
+		WHERE id > $1
+		ORDER BY id`, seq)
+	if err != nil {
+		return nil, seq, err
+	}
+	defer rows.Close()
+
+	var changes []Change
+	for rows.Next() {
+		var change Change
+		if err := rows.Scan(&seq, &change.Entity, &change.ID); err != nil {
+			return nil, seq, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, seq, rows.Err()
+}
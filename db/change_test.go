@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchChanges(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db := setupSchemaDB(t)
+
+	// A second instance sharing the database
+	other, err := NewDBWithDriver("sqlite3", db.dsn)
+	require.NoError(t, err)
+	defer other.Close()
+
+	// Changes from before the watch started are not replayed
+	node := &Node{ID: uuid.New(), Name: "app-1", Location: "localhost:1", Capacity: 10, Status: "active"}
+	require.NoError(t, other.RegisterNode(ctx, node))
+
+	var mu sync.Mutex
+	var changes []Change
+	done := make(chan error, 1)
+	go func() {
+		done <- db.WatchChanges(ctx, func(c Change) {
+			mu.Lock()
+			changes = append(changes, c)
+			mu.Unlock()
+		})
+	}()
+	received := func() []Change {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Change(nil), changes...)
+	}
+	// Let the watcher read its starting point
+	time.Sleep(100 * time.Millisecond)
+
+	shard := &Shard{ID: uuid.New(), Type: "kv", Size: 1, NodeID: &node.ID, Status: "active", Version: 1, Metadata: json.RawMessage(`{}`)}
+	require.NoError(t, other.RegisterShard(ctx, shard))
+	require.NoError(t, other.UpdateShardStatus(ctx, shard.ID, "migrating"))
+	require.NoError(t, other.UpdateNodeHeartbeat(ctx, node.ID, "active", 5, nil))
+	require.NoError(t, other.SetPolicy(ctx, &Policy{ID: uuid.New(), PolicyType: "placement", Parameters: json.RawMessage(`{}`)}))
+	// A write that fails records nothing
+	require.Error(t, other.UpdateNode(ctx, &Node{ID: uuid.New(), Location: "nowhere", Status: "active"}))
+
+	want := []Change{
+		{Entity: ChangeShard, ID: shard.ID.String()},
+		{Entity: ChangeShard, ID: shard.ID.String()},
+		{Entity: ChangeNode, ID: node.ID.String()},
+		{Entity: ChangePolicy, ID: "placement"},
+	}
+	require.Eventually(t, func() bool { return len(received()) >= len(want) }, 5*time.Second, 50*time.Millisecond)
+	assert.Equal(t, want, received())
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestHeartbeatChangesNode(t *testing.T) {
+	last := time.Date(2030, 1, 2, 3, 4, 1, 0, time.UTC)
+	node := &Node{Status: "active", CurrentLoad: 5, ResourceLoad: Resources{"cpu": 1}, LastHeartbeat: last}
+	soon := last.Add(time.Second)
+
+	assert.False(t, HeartbeatChangesNode(node, "active", 5, Resources{"cpu": 1}, soon), "nothing but the heartbeat changed")
+	assert.True(t, HeartbeatChangesNode(node, "failed", 5, Resources{"cpu": 1}, soon))
+	assert.True(t, HeartbeatChangesNode(node, "active", 6, Resources{"cpu": 1}, soon))
+	assert.True(t, HeartbeatChangesNode(node, "active", 5, Resources{"cpu": 2}, soon))
+	assert.True(t, HeartbeatChangesNode(node, "active", 5, Resources{"cpu": 1}, last.Add(HeartbeatChangeInterval)),
+		"liveness is still announced once per interval")
+
+	drained := &Node{Status: "maintenance", LastHeartbeat: last}
+	assert.False(t, HeartbeatChangesNode(drained, "active", 0, nil, soon), "maintenance outlasts the reported status")
+}
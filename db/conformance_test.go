@@ -0,0 +1,92 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/db/dbtest"
+)
+
+func TestConformance(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		dbtest.Run(t, func(t *testing.T) db.DBOperations { return dbtest.SQLite(t) })
+	})
+	t.Run("postgres", func(t *testing.T) {
+		dbtest.Run(t, func(t *testing.T) db.DBOperations { return dbtest.Postgres(t) })
+	})
+}
+
+func TestMigrateDown(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) { testMigrateDown(t, dbtest.SQLite(t)) })
+	t.Run("postgres", func(t *testing.T) { testMigrateDown(t, dbtest.Postgres(t)) })
+}
+
+// testMigrateDown reverts every migration of a migrated database and
+// applies them again
+func testMigrateDown(t *testing.T, database *db.DB) {
+	ctx := context.Background()
+	latest, err := database.SchemaVersion(ctx)
+	require.NoError(t, err)
+
+	// Older releases cannot read pending shards, so the status checks stay
+	pending := &db.Shard{ID: uuid.New(), Type: "range", Size: 1, Status: "pending"}
+	require.NoError(t, database.RegisterShard(ctx, pending))
+	err = database.MigrateDown(ctx, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "pending")
+	version, err := database.SchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Greater(t, version, 0)
+
+	_, err = database.ExecContext(ctx, `UPDATE shards SET status = 'active' WHERE id = $1`, pending.ID)
+	require.NoError(t, err)
+	require.NoError(t, database.MigrateDown(ctx, 0))
+	version, err = database.SchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	require.NoError(t, database.Migrate(ctx))
+	version, err = database.SchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, latest, version)
+	require.NoError(t, database.RegisterShard(ctx, &db.Shard{ID: uuid.New(), Type: "range", Size: 1, Status: "pending"}))
+}
+
+// TestSerializableRetry makes Postgres abort a serializable transaction
+// whose row another writer changed, and checks it is run again
+func TestSerializableRetry(t *testing.T) {
+	ctx := context.Background()
+	database := dbtest.Postgres(t)
+	shard := &db.Shard{ID: uuid.New(), Type: "range", Size: 1, Status: "active"}
+	require.NoError(t, database.RegisterShard(ctx, shard))
+
+	attempts := 0
+	err := db.InSerializableTx(database, ctx, func(ctx context.Context, tx *sql.Tx) error {
+		attempts++
+		var version int
+		if err := tx.QueryRowContext(ctx, `SELECT version FROM shards WHERE id = $1`, shard.ID).Scan(&version); err != nil {
+			return err
+		}
+		if attempts == 1 {
+			// Another writer changes the row after this transaction read it
+			if _, err := database.ExecContext(ctx, `UPDATE shards SET size = size + 1 WHERE id = $1`, shard.ID); err != nil {
+				return err
+			}
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE shards SET version = $1 WHERE id = $2`, version+1, shard.ID)
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, attempts, "the serialization failure is retried")
+
+	got, err := database.GetShardInfo(ctx, shard.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.Version)
+	assert.Equal(t, int64(2), got.Size, "the other writer's change is kept")
+}
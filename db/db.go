@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
 
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
@@ -14,21 +17,28 @@ import (
 type DB struct {
 	*sql.DB
 	DriverName string
+	dsn        string // reused by WatchChanges to LISTEN on its own connection
+	pool       PoolOptions
+	logger     *slog.Logger // nil logs to slog.Default
 }
 
 // DBOperations defines the interface for database operations (production, no Reset)
 type DBOperations interface {
 	RegisterNode(ctx context.Context, node *Node) error
-	UpdateNodeHeartbeat(ctx context.Context, nodeID uuid.UUID, status string, currentLoad int64) error
+	UpdateNodeHeartbeat(ctx context.Context, nodeID uuid.UUID, status string, currentLoad int64, resourceLoad Resources) error
+	UpdateNode(ctx context.Context, node *Node) error
 	ListNodes(ctx context.Context) ([]*Node, error)
 	RegisterShard(ctx context.Context, shard *Shard) error
 	ListShards(ctx context.Context) ([]*Shard, error)
 	GetShardInfo(ctx context.Context, shardID uuid.UUID) (*Shard, error)
 	AssignShard(ctx context.Context, shardID, nodeID uuid.UUID) error
+	MoveShard(ctx context.Context, shardID, fromNodeID, toNodeID uuid.UUID) error
 	UpdateShardStatus(ctx context.Context, shardID uuid.UUID, status string) error
 	SetPolicy(ctx context.Context, policy *Policy) error
 	GetPolicy(ctx context.Context, policyType string) (*Policy, error)
+	ListActivePolicies(ctx context.Context) ([]*Policy, error)
 	ReportFailure(ctx context.Context, failureType string, entityID uuid.UUID, details json.RawMessage) error
+	ListFailureReports(ctx context.Context, limit int) ([]*FailureReport, error)
 
 	// Version-related operations
 	GetShardVersion(ctx context.Context, shardID uuid.UUID, version int) (*ShardVersion, error)
@@ -38,103 +48,98 @@ type DBOperations interface {
 
 	// Node lookup
 	GetNodeInfo(ctx context.Context, nodeID uuid.UUID) (*Node, error)
+	GetNodeByName(ctx context.Context, name string) (*Node, error)
+
+	// Ownership leases
+	AcquireShardLease(ctx context.Context, shardID, nodeID uuid.UUID, now, expiresAt time.Time) (bool, error)
+	GetShardLease(ctx context.Context, shardID uuid.UUID) (*ShardLease, error)
+	RevokeShardLease(ctx context.Context, shardID, nodeID uuid.UUID) error
+	ListNodeLeases(ctx context.Context, nodeID uuid.UUID) ([]*ShardLease, error)
+
+	// Leader election
+	AcquireLeadership(ctx context.Context, holderID, address string, now, expiresAt time.Time) (bool, error)
+	GetLeader(ctx context.Context) (*LeaderLease, error)
+	ReleaseLeadership(ctx context.Context, holderID string) error
+
+	// Reconciliation
+	RecordReconcileAction(ctx context.Context, action *ReconcileAction) error
+	ListReconcileActions(ctx context.Context, nodeID uuid.UUID) ([]*ReconcileAction, error)
+
+	// Audit log
+	RecordAuditEvent(ctx context.Context, event *AuditEvent) error
+	ListAuditEvents(ctx context.Context, filter AuditFilter) ([]*AuditEvent, error)
+
+	// Connectivity
+	PingContext(ctx context.Context) error
+
+	// Change propagation between instances
+	WatchChanges(ctx context.Context, fn func(Change)) error
+
+	// Per-shard load
+	RecordShardLoads(ctx context.Context, loads []*ShardLoad) error
+	ListShardLoads(ctx context.Context) ([]*ShardLoad, error)
 }
 
 func NewDB(dsn string) (*DB, error) {
-	db, err := sql.Open("postgres", dsn)
-	if err != nil {
+	return NewDBWithDriver("postgres", dsn)
+}
+
+// NewDBWithDriver allows specifying the SQL driver (e.g., "sqlite3" or "postgres")
+func NewDBWithDriver(driver, dsn string) (*DB, error) {
+	return newDB(driver, dsn, DefaultPoolOptions())
+}
+
+// Open connects to dsn, using SQLite for "file:" DSNs and Postgres
+// otherwise
+func Open(dsn string) (*DB, error) {
+	return OpenWithOptions(dsn, DefaultPoolOptions())
+}
+
+// OpenWithOptions is Open with the pool tuned by opts
+func OpenWithOptions(dsn string, opts PoolOptions) (*DB, error) {
+	if err := opts.Validate(); err != nil {
 		return nil, err
 	}
-	if err := db.Ping(); err != nil {
-		return nil, err
+	if strings.HasPrefix(dsn, "file:") {
+		return newDB("sqlite3", dsn, opts)
 	}
-	return &DB{DB: db, DriverName: "postgres"}, nil
+	return newDB("postgres", dsn, opts)
 }
 
-// NewDBWithDriver allows specifying the SQL driver (e.g., "sqlite3" or "postgres")
-func NewDBWithDriver(driver, dsn string) (*DB, error) {
+func newDB(driver, dsn string, opts PoolOptions) (*DB, error) {
 	db, err := sql.Open(driver, dsn)
 	if err != nil {
-		return nil, err
+		return nil, redactURLError(err)
 	}
+	opts.apply(db, isMemoryDSN(driver, dsn))
 	if err := db.Ping(); err != nil {
-		return nil, err
+		db.Close()
+		return nil, redactURLError(err)
+	}
+	return &DB{DB: db, DriverName: driver, dsn: dsn, pool: opts}, nil
+}
+
+// SetLogger sends the database's logs to logger. It must be called before
+// the database is used.
+func (db *DB) SetLogger(logger *slog.Logger) {
+	db.logger = logger
+}
+
+// log returns the database's logger
+func (db *DB) log() *slog.Logger {
+	if db.logger == nil {
+		return slog.Default()
 	}
-	return &DB{DB: db, DriverName: driver}, nil
+	return db.logger
 }
 
 func (db *DB) Driver() string {
 	return db.DriverName
 }
 
+// InitSQLiteSchema brings a SQLite database's schema up to date.
+//
+// Deprecated: use Migrate, which handles every driver.
 func InitSQLiteSchema(db *DB) error {
-	schema := `
-CREATE TABLE IF NOT EXISTS nodes (
-    id TEXT PRIMARY KEY,
-    location TEXT NOT NULL,
-    capacity INTEGER NOT NULL,
-    status TEXT NOT NULL DEFAULT 'active',
-    last_heartbeat DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    current_load INTEGER NOT NULL DEFAULT 0,
-    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-);
-CREATE TABLE IF NOT EXISTS shards (
-    id TEXT PRIMARY KEY,
-    type TEXT NOT NULL,
-    size INTEGER NOT NULL,
-    node_id TEXT,
-    status TEXT NOT NULL DEFAULT 'active',
-    version INTEGER NOT NULL DEFAULT 1,
-    metadata TEXT DEFAULT '{}',
-    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-);
-CREATE TABLE IF NOT EXISTS shard_versions (
-    id TEXT PRIMARY KEY,
-    shard_id TEXT NOT NULL,
-    version INTEGER NOT NULL,
-    type TEXT NOT NULL,
-    size INTEGER NOT NULL,
-    node_id TEXT,
-    status TEXT NOT NULL,
-    metadata TEXT DEFAULT '{}',
-    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    UNIQUE(shard_id, version)
-);
-CREATE TABLE IF NOT EXISTS shard_migrations (
-    id TEXT PRIMARY KEY,
-    shard_id TEXT NOT NULL,
-    from_node_id TEXT,
-    to_node_id TEXT,
-    status TEXT NOT NULL,
-    started_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    completed_at DATETIME,
-    error_message TEXT
-);
-CREATE TABLE IF NOT EXISTS policies (
-    id TEXT PRIMARY KEY,
-    policy_type TEXT NOT NULL,
-    parameters TEXT NOT NULL,
-    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-);
-CREATE TABLE IF NOT EXISTS failure_reports (
-    id TEXT PRIMARY KEY,
-    type TEXT NOT NULL,
-    entity_id TEXT NOT NULL,
-    details TEXT NOT NULL,
-    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
-);
-CREATE INDEX IF NOT EXISTS idx_shards_node_id ON shards(node_id);
-CREATE INDEX IF NOT EXISTS idx_shards_status ON shards(status);
-CREATE INDEX IF NOT EXISTS idx_shards_version ON shards(version);
-CREATE INDEX IF NOT EXISTS idx_nodes_status ON nodes(status);
-CREATE INDEX IF NOT EXISTS idx_nodes_last_heartbeat ON nodes(last_heartbeat);
-CREATE INDEX IF NOT EXISTS idx_shard_migrations_shard_id ON shard_migrations(shard_id);
-CREATE INDEX IF NOT EXISTS idx_shard_versions_shard_id ON shard_versions(shard_id);
-CREATE INDEX IF NOT EXISTS idx_failure_reports_entity_id ON failure_reports(entity_id);
-`
-	_, err := db.Exec(schema)
-	return err
+	return db.Migrate(context.Background())
 }
@@ -0,0 +1,68 @@
+// Package dbtest checks that a backend honours the db.DBOperations
+// contract. Each backend runs the same suite from its own tests, so the
+// SQL drivers, memdb and the server's mock cannot drift apart.
+package dbtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/stretchr/testify/require"
+)
+
+// PostgresDSNEnv names the environment variable with a Postgres DSN to run
+// tests against; Postgres tests are skipped without it
+const PostgresDSNEnv = "SHARDMANAGER_TEST_POSTGRES_DSN"
+
+// SQLite returns a migrated SQLite database in a temporary directory,
+// closed when the test ends
+func SQLite(t *testing.T) *db.DB {
+	dsn := "file:" + filepath.Join(t.TempDir(), "shardmanager.db") + "?_busy_timeout=5000"
+	database, err := db.NewDBWithDriver("sqlite3", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	require.NoError(t, database.Migrate(context.Background()))
+	return database
+}
+
+// Postgres returns a connection to a migrated, throwaway schema in the
+// database named by PostgresDSNEnv, dropped when the test ends. The test is
+// skipped if the variable is not set.
+func Postgres(t *testing.T) *db.DB {
+	dsn := os.Getenv(PostgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", PostgresDSNEnv)
+	}
+	admin, err := db.NewDB(dsn)
+	require.NoError(t, err)
+	schema := fmt.Sprintf("shardmanager_test_%d", time.Now().UnixNano())
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+
+	// Extensions installed in public stay visible
+	searchPath := "search_path=" + schema + ",public"
+	if strings.Contains(dsn, "://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + searchPath
+	} else {
+		dsn += " " + searchPath
+	}
+	database, err := db.NewDB(dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	require.NoError(t, database.Migrate(context.Background()))
+	return database
+}
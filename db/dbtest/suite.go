@@ -0,0 +1,512 @@
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run checks every DBOperations method against the contract. open is called
+// for a new, empty database in each subtest.
+func Run(t *testing.T, open func(t *testing.T) db.DBOperations) {
+	for _, tc := range []struct {
+		name string
+		fn   func(t *testing.T, ops db.DBOperations)
+	}{
+		{"Nodes", testNodes},
+		{"Shards", testShards},
+		{"Versions", testVersions},
+		{"MoveShard", testMoveShard},
+		{"ConcurrentAssign", testConcurrentAssign},
+		{"Policies", testPolicies},
+		{"Failures", testFailures},
+		{"Leases", testLeases},
+		{"Leadership", testLeadership},
+		{"Reconciliation", testReconciliation},
+		{"Audit", testAudit},
+		{"ShardLoads", testShardLoads},
+		{"WatchChanges", testWatchChanges},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ops := open(t)
+			require.NoError(t, ops.PingContext(context.Background()))
+			tc.fn(t, ops)
+		})
+	}
+}
+
+// registerShard registers an active shard of version 1 on nodeID
+func registerShard(t *testing.T, ops db.DBOperations, nodeID uuid.UUID) *db.Shard {
+	shard := &db.Shard{Type: "kv", Size: 10, NodeID: &nodeID, Status: "active", Version: 1,
+		Metadata: json.RawMessage(`{"a":1}`)}
+	require.NoError(t, ops.RegisterShard(context.Background(), shard))
+	return shard
+}
+
+func testNodes(t *testing.T, ops db.DBOperations) {
+	ctx := context.Background()
+	node := &db.Node{Name: "app-1", Location: "localhost:1", Capacity: 100, Status: "active",
+		FaultDomain: "rack-1", ResourceCapacity: db.Resources{"cpu": 4}}
+	require.NoError(t, ops.RegisterNode(ctx, node))
+	assert.NotEqual(t, uuid.Nil, node.ID, "IDs are generated when missing")
+	assert.Equal(t, "push", node.AssignmentMode)
+	assert.False(t, node.CreatedAt.IsZero())
+
+	// A node may be registered with the load it reports
+	seen := time.Now().Add(-time.Hour).Truncate(time.Second)
+	other := &db.Node{Name: "app-2", Location: "localhost:2", Capacity: 100, Status: "active",
+		LastHeartbeat: seen, CurrentLoad: 5}
+	require.NoError(t, ops.RegisterNode(ctx, other))
+	got, err := ops.GetNodeInfo(ctx, other.ID)
+	require.NoError(t, err)
+	assert.True(t, seen.Equal(got.LastHeartbeat), "got %v", got.LastHeartbeat)
+	assert.Equal(t, int64(5), got.CurrentLoad)
+	assert.Equal(t, db.Resources{}, got.ResourceCapacity, "missing resources read as empty")
+
+	assert.Empty(t, got.FaultDomain)
+
+	node.Location = "localhost:11"
+	node.FaultDomain = "rack-2"
+	require.NoError(t, ops.UpdateNode(ctx, node))
+	require.NoError(t, ops.UpdateNodeHeartbeat(ctx, node.ID, "inactive", 7, db.Resources{"cpu": 1}))
+	got, err = ops.GetNodeInfo(ctx, node.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:11", got.Location)
+	assert.Equal(t, "rack-2", got.FaultDomain)
+	assert.Equal(t, "inactive", got.Status)
+	assert.Equal(t, int64(7), got.CurrentLoad)
+	assert.Equal(t, db.Resources{"cpu": 1}, got.ResourceLoad)
+	assert.Equal(t, db.Resources{"cpu": 4}, got.ResourceCapacity)
+
+	// Maintenance outlasts whatever the node reports
+	got.Status = "maintenance"
+	require.NoError(t, ops.UpdateNode(ctx, got))
+	require.NoError(t, ops.UpdateNodeHeartbeat(ctx, node.ID, "active", 8, nil))
+	got, err = ops.GetNodeInfo(ctx, node.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "maintenance", got.Status)
+	assert.Equal(t, int64(8), got.CurrentLoad)
+
+	byName, err := ops.GetNodeByName(ctx, "app-2")
+	require.NoError(t, err)
+	assert.Equal(t, other.ID, byName.ID)
+	nodes, err := ops.ListNodes(ctx)
+	require.NoError(t, err)
+	assert.Len(t, nodes, 2)
+
+	t.Run("Missing", func(t *testing.T) {
+		missing, err := ops.GetNodeInfo(ctx, uuid.New())
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+		missing, err = ops.GetNodeByName(ctx, "nope")
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+		assert.Error(t, ops.UpdateNode(ctx, &db.Node{ID: uuid.New(), Location: "x", Status: "active"}))
+		assert.NoError(t, ops.UpdateNodeHeartbeat(ctx, uuid.New(), "active", 0, nil))
+	})
+
+	t.Run("Constraints", func(t *testing.T) {
+		assert.Error(t, ops.RegisterNode(ctx, &db.Node{ID: node.ID, Location: "x", Status: "active"}), "duplicate ID")
+		assert.Error(t, ops.RegisterNode(ctx, &db.Node{Name: "app-1", Location: "x", Status: "active"}), "duplicate name")
+		err := ops.RegisterNode(ctx, &db.Node{Location: "x", Status: "sleeping"})
+		assert.True(t, errors.Is(err, db.ErrInvalidStatus), "got %v", err)
+		err = ops.UpdateNodeHeartbeat(ctx, other.ID, "sleeping", 0, nil)
+		assert.True(t, errors.Is(err, db.ErrInvalidStatus), "got %v", err)
+
+		nodes, err := ops.ListNodes(ctx)
+		require.NoError(t, err)
+		assert.Len(t, nodes, 2, "rejected nodes are not stored")
+	})
+}
+
+func testShards(t *testing.T, ops db.DBOperations) {
+	ctx := context.Background()
+	first, second := uuid.New(), uuid.New()
+	shard := registerShard(t, ops, first)
+	assert.NotEqual(t, uuid.Nil, shard.ID, "IDs are generated when missing")
+	assert.False(t, shard.CreatedAt.IsZero())
+
+	bare := &db.Shard{Type: "kv", Status: "pending", Version: 1}
+	require.NoError(t, ops.RegisterShard(ctx, bare))
+	got, err := ops.GetShardInfo(ctx, bare.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got.NodeID)
+	assert.JSONEq(t, `{}`, string(got.Metadata), "missing metadata reads as an empty object")
+
+	// Every change bumps the version
+	require.NoError(t, ops.AssignShard(ctx, shard.ID, second))
+	require.NoError(t, ops.UpdateShardStatus(ctx, shard.ID, "migrating"))
+	got, err = ops.GetShardInfo(ctx, shard.ID)
+	require.NoError(t, err)
+	assert.Equal(t, second, *got.NodeID)
+	assert.Equal(t, "migrating", got.Status)
+	assert.Equal(t, 3, got.Version)
+	assert.JSONEq(t, `{"a":1}`, string(got.Metadata))
+
+	shards, err := ops.ListShards(ctx)
+	require.NoError(t, err)
+	assert.Len(t, shards, 2)
+
+	t.Run("Missing", func(t *testing.T) {
+		missing := uuid.New()
+		got, err := ops.GetShardInfo(ctx, missing)
+		require.NoError(t, err)
+		assert.Nil(t, got)
+		// Updates of missing shards change nothing and succeed
+		assert.NoError(t, ops.AssignShard(ctx, missing, first))
+		assert.NoError(t, ops.UpdateShardStatus(ctx, missing, "active"))
+		got, err = ops.GetShardInfo(ctx, missing)
+		require.NoError(t, err)
+		assert.Nil(t, got)
+		versions, err := ops.ListShardVersions(ctx, missing)
+		require.NoError(t, err)
+		assert.Empty(t, versions)
+	})
+
+	t.Run("Constraints", func(t *testing.T) {
+		assert.Error(t, ops.RegisterShard(ctx, &db.Shard{ID: shard.ID, Type: "kv", Status: "active"}), "duplicate ID")
+		err := ops.RegisterShard(ctx, &db.Shard{Type: "kv", Status: "sleeping"})
+		assert.True(t, errors.Is(err, db.ErrInvalidStatus), "got %v", err)
+		err = ops.UpdateShardStatus(ctx, shard.ID, "sleeping")
+		assert.True(t, errors.Is(err, db.ErrInvalidStatus), "got %v", err)
+
+		got, err := ops.GetShardInfo(ctx, shard.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, got.Version, "rejected updates leave no version behind")
+		assert.Equal(t, "migrating", got.Status)
+	})
+}
+
+func testVersions(t *testing.T, ops db.DBOperations) {
+	ctx := context.Background()
+	first, second := uuid.New(), uuid.New()
+	shard := registerShard(t, ops, first)
+	require.NoError(t, ops.AssignShard(ctx, shard.ID, second))
+	require.NoError(t, ops.UpdateShardStatus(ctx, shard.ID, "migrating"))
+
+	versions, err := ops.ListShardVersions(ctx, shard.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, 2, versions[0].Version, "newest first")
+	assert.Equal(t, 1, versions[1].Version)
+
+	v1, err := ops.GetShardVersion(ctx, shard.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, first, *v1.NodeID)
+	assert.Equal(t, "active", v1.Status)
+	assert.JSONEq(t, `{"a":1}`, string(v1.Metadata))
+	missing, err := ops.GetShardVersion(ctx, shard.ID, 3)
+	require.NoError(t, err)
+	assert.Nil(t, missing, "the current state is not a recorded version")
+
+	update := &db.Shard{ID: shard.ID, Type: "kv", Size: 20, NodeID: &second, Status: "active", Metadata: json.RawMessage(`{}`)}
+	require.NoError(t, ops.UpdateShardVersion(ctx, update))
+	assert.Equal(t, 4, update.Version, "the new version is returned")
+
+	require.NoError(t, ops.RollbackShardVersion(ctx, shard.ID, 1))
+	got, err := ops.GetShardInfo(ctx, shard.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first, *got.NodeID)
+	assert.Equal(t, int64(10), got.Size)
+	assert.Equal(t, "active", got.Status)
+	assert.Equal(t, 5, got.Version, "a rollback is a new version")
+
+	t.Run("Missing", func(t *testing.T) {
+		assert.Error(t, ops.RollbackShardVersion(ctx, shard.ID, 99))
+		assert.Error(t, ops.UpdateShardVersion(ctx, &db.Shard{ID: uuid.New(), Type: "kv", Status: "active"}))
+		got, err := ops.GetShardInfo(ctx, shard.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 5, got.Version, "a failed rollback changes nothing")
+		versions, err := ops.ListShardVersions(ctx, shard.ID)
+		require.NoError(t, err)
+		assert.Len(t, versions, 4)
+	})
+}
+
+func testMoveShard(t *testing.T, ops db.DBOperations) {
+	ctx := context.Background()
+	from, to := uuid.New(), uuid.New()
+	shard := registerShard(t, ops, from)
+
+	require.NoError(t, ops.MoveShard(ctx, shard.ID, from, to))
+	got, err := ops.GetShardInfo(ctx, shard.ID)
+	require.NoError(t, err)
+	assert.Equal(t, to, *got.NodeID)
+	assert.Equal(t, 2, got.Version)
+	versions, err := ops.ListShardVersions(ctx, shard.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 1, "a move is a single version")
+	assert.Equal(t, from, *versions[0].NodeID)
+
+	assert.ErrorIs(t, ops.MoveShard(ctx, shard.ID, from, to), db.ErrShardMoved)
+	assert.ErrorIs(t, ops.MoveShard(ctx, uuid.New(), from, to), sql.ErrNoRows)
+	versions, err = ops.ListShardVersions(ctx, shard.ID)
+	require.NoError(t, err)
+	assert.Len(t, versions, 1, "a refused move records nothing")
+}
+
+func testConcurrentAssign(t *testing.T, ops db.DBOperations) {
+	ctx := context.Background()
+	shard := registerShard(t, ops, uuid.New())
+
+	const writers = 8
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, ops.AssignShard(ctx, shard.ID, uuid.New()))
+		}()
+	}
+	wg.Wait()
+
+	got, err := ops.GetShardInfo(ctx, shard.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1+writers, got.Version)
+	versions, err := ops.ListShardVersions(ctx, shard.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, writers)
+	for i, sv := range versions {
+		assert.Equal(t, writers-i, sv.Version, "each assignment records its own version")
+	}
+}
+
+func testPolicies(t *testing.T, ops db.DBOperations) {
+	ctx := context.Background()
+	missing, err := ops.GetPolicy(ctx, "placement")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	first := &db.Policy{PolicyType: "placement", Parameters: json.RawMessage(`{"v":1}`)}
+	require.NoError(t, ops.SetPolicy(ctx, first))
+	assert.NotEqual(t, uuid.Nil, first.ID)
+	require.NoError(t, ops.SetPolicy(ctx, &db.Policy{PolicyType: "placement", Parameters: json.RawMessage(`{"v":2}`)}))
+	require.NoError(t, ops.SetPolicy(ctx, &db.Policy{PolicyType: "migration", Parameters: json.RawMessage(`{}`)}))
+
+	got, err := ops.GetPolicy(ctx, "placement")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"v":2}`, string(got.Parameters), "the latest policy of a type wins")
+
+	active, err := ops.ListActivePolicies(ctx)
+	require.NoError(t, err)
+	require.Len(t, active, 2)
+	assert.Equal(t, "migration", active[0].PolicyType, "ordered by type")
+	assert.JSONEq(t, `{"v":2}`, string(active[1].Parameters))
+}
+
+func testFailures(t *testing.T, ops db.DBOperations) {
+	ctx := context.Background()
+	reports, err := ops.ListFailureReports(ctx, 0)
+	require.NoError(t, err)
+	assert.Empty(t, reports)
+
+	entity := uuid.New()
+	require.NoError(t, ops.ReportFailure(ctx, "node_failure", entity, json.RawMessage(`{"e":1}`)))
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, ops.ReportFailure(ctx, "shard_failure", entity, json.RawMessage(`{"e":2}`)))
+
+	reports, err = ops.ListFailureReports(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, "shard_failure", reports[0].Type, "newest first")
+	assert.Equal(t, entity, reports[0].EntityID)
+	assert.JSONEq(t, `{"e":2}`, string(reports[0].Details))
+
+	reports, err = ops.ListFailureReports(ctx, 0)
+	require.NoError(t, err)
+	assert.Len(t, reports, 2, "no limit means the default limit")
+}
+
+func testLeases(t *testing.T, ops db.DBOperations) {
+	ctx := context.Background()
+	shardID, holder, other := uuid.New(), uuid.New(), uuid.New()
+	now := time.Now()
+
+	missing, err := ops.GetShardLease(ctx, shardID)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	granted, err := ops.AcquireShardLease(ctx, shardID, holder, now, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, granted)
+	granted, err = ops.AcquireShardLease(ctx, shardID, other, now, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.False(t, granted, "another node holds the lease")
+	granted, err = ops.AcquireShardLease(ctx, shardID, holder, now, now.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.True(t, granted, "the holder may renew")
+
+	lease, err := ops.GetShardLease(ctx, shardID)
+	require.NoError(t, err)
+	assert.Equal(t, holder, lease.NodeID)
+	assert.False(t, lease.Revoked)
+	leases, err := ops.ListNodeLeases(ctx, holder)
+	require.NoError(t, err)
+	assert.Len(t, leases, 1)
+
+	require.NoError(t, ops.RevokeShardLease(ctx, shardID, other))
+	lease, err = ops.GetShardLease(ctx, shardID)
+	require.NoError(t, err)
+	assert.False(t, lease.Revoked, "only the holder's lease is revoked")
+
+	require.NoError(t, ops.RevokeShardLease(ctx, shardID, holder))
+	leases, err = ops.ListNodeLeases(ctx, holder)
+	require.NoError(t, err)
+	assert.Empty(t, leases)
+	granted, err = ops.AcquireShardLease(ctx, shardID, other, now, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, granted, "a revoked lease may be taken over")
+
+	later := now.Add(5 * time.Minute)
+	granted, err = ops.AcquireShardLease(ctx, shardID, holder, later, later.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, granted, "an expired lease may be taken over")
+}
+
+func testLeadership(t *testing.T, ops db.DBOperations) {
+	ctx := context.Background()
+	now := time.Now()
+	leader, err := ops.GetLeader(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, leader)
+
+	won, err := ops.AcquireLeadership(ctx, "a", "a:1", now, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, won)
+	won, err = ops.AcquireLeadership(ctx, "b", "b:1", now, now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.False(t, won)
+	won, err = ops.AcquireLeadership(ctx, "a", "a:1", now, now.Add(2*time.Minute))
+	require.NoError(t, err)
+	assert.True(t, won, "the leader may renew")
+
+	require.NoError(t, ops.ReleaseLeadership(ctx, "b"))
+	leader, err = ops.GetLeader(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "a:1", leader.Address, "only the holder can release")
+
+	later := now.Add(5 * time.Minute)
+	won, err = ops.AcquireLeadership(ctx, "b", "b:1", later, later.Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, won, "an expired lease may be taken over")
+	require.NoError(t, ops.ReleaseLeadership(ctx, "b"))
+	leader, err = ops.GetLeader(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, leader)
+}
+
+func testReconciliation(t *testing.T, ops db.DBOperations) {
+	ctx := context.Background()
+	nodeID, shardID := uuid.New(), uuid.New()
+	first := &db.ReconcileAction{NodeID: nodeID, ShardID: shardID, Action: "add_shard", Error: "boom"}
+	require.NoError(t, ops.RecordReconcileAction(ctx, first))
+	assert.NotEqual(t, uuid.Nil, first.ID)
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, ops.RecordReconcileAction(ctx, &db.ReconcileAction{NodeID: nodeID, ShardID: shardID, Action: "drop_shard", Role: "replica"}))
+	require.NoError(t, ops.RecordReconcileAction(ctx, &db.ReconcileAction{NodeID: uuid.New(), ShardID: shardID, Action: "add_shard"}))
+
+	actions, err := ops.ListReconcileActions(ctx, nodeID)
+	require.NoError(t, err)
+	require.Len(t, actions, 2)
+	assert.Equal(t, "drop_shard", actions[0].Action, "newest first")
+	assert.Equal(t, "replica", actions[0].Role)
+	assert.Equal(t, "boom", actions[1].Error)
+	assert.Empty(t, actions[1].Role)
+}
+
+func testAudit(t *testing.T, ops db.DBOperations) {
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	shardID := uuid.New().String()
+	for i, event := range []*db.AuditEvent{
+		{Actor: "alice", Method: "/a", EntityType: "shard", EntityID: shardID, Result: "ok"},
+		{Actor: "bob", Method: "/b", Result: "error", Error: "no", Request: json.RawMessage(`{"k":"v"}`)},
+		{Actor: "alice", Method: "/c", Result: "ok"},
+	} {
+		event.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, ops.RecordAuditEvent(ctx, event))
+		assert.NotEqual(t, uuid.Nil, event.ID)
+	}
+
+	events, err := ops.ListAuditEvents(ctx, db.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.Equal(t, "/c", events[0].Method, "newest first")
+	assert.Nil(t, events[0].Request)
+
+	events, err = ops.ListAuditEvents(ctx, db.AuditFilter{Actor: "bob"})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.JSONEq(t, `{"k":"v"}`, string(events[0].Request))
+	assert.Equal(t, "no", events[0].Error)
+
+	events, err = ops.ListAuditEvents(ctx, db.AuditFilter{EntityID: shardID})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "shard", events[0].EntityType)
+
+	events, err = ops.ListAuditEvents(ctx, db.AuditFilter{Since: base.Add(time.Minute), Until: base.Add(2 * time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, events, 1, "Since is inclusive and Until exclusive")
+	assert.Equal(t, "/b", events[0].Method)
+
+	events, err = ops.ListAuditEvents(ctx, db.AuditFilter{Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+}
+
+func testShardLoads(t *testing.T, ops db.DBOperations) {
+	ctx := context.Background()
+	require.NoError(t, ops.RecordShardLoads(ctx, nil))
+	shardID, nodeID := uuid.New(), uuid.New()
+	sample := &db.ShardLoad{ShardID: shardID, NodeID: nodeID, QPS: 1.5, SizeBytes: 3}
+	require.NoError(t, ops.RecordShardLoads(ctx, []*db.ShardLoad{sample}))
+	assert.False(t, sample.ReportedAt.IsZero(), "samples are stamped")
+	require.NoError(t, ops.RecordShardLoads(ctx, []*db.ShardLoad{
+		{ShardID: shardID, NodeID: nodeID, QPS: 2.5, BytesPerSecond: 7, CPUShare: 0.5, SizeBytes: 4},
+		{ShardID: uuid.New(), NodeID: nodeID, QPS: 1},
+	}))
+
+	loads, err := ops.ListShardLoads(ctx)
+	require.NoError(t, err)
+	require.Len(t, loads, 2)
+	for _, load := range loads {
+		if load.ShardID == shardID {
+			assert.Equal(t, 2.5, load.QPS, "the latest sample replaces earlier ones")
+			assert.Equal(t, int64(7), load.BytesPerSecond)
+			assert.Equal(t, 0.5, load.CPUShare)
+			assert.Equal(t, int64(4), load.SizeBytes)
+		}
+	}
+}
+
+func testWatchChanges(t *testing.T, ops db.DBOperations) {
+	ctx := context.Background()
+	shard := registerShard(t, ops, uuid.New())
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	changes := make(chan db.Change, 16)
+	done := make(chan error, 1)
+	go func() { done <- ops.WatchChanges(watchCtx, func(c db.Change) { changes <- c }) }()
+	// Writes made before the watch is listening may be missed
+	time.Sleep(200 * time.Millisecond)
+
+	require.NoError(t, ops.UpdateShardStatus(ctx, shard.ID, "migrating"))
+	select {
+	case c := <-changes:
+		assert.Equal(t, db.Change{Entity: db.ChangeShard, ID: shard.ID.String()}, c)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change delivered")
+	}
+	cancel()
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// ErrInvalidStatus is returned when a node or shard status is not one of
+// the values the schema allows
+var ErrInvalidStatus = errors.New("invalid status")
+
+// The statuses the schema allows, for backends without one
+var (
+	nodeStatuses  = []string{"active", "inactive", "maintenance", "failed"}
+	shardStatuses = []string{"active", "pending", "migrating", "failed", "maintenance"}
+)
+
+// ValidNodeStatus reports whether the schema allows a node to have status
+func ValidNodeStatus(status string) bool {
+	return slices.Contains(nodeStatuses, status)
+}
+
+// ValidShardStatus reports whether the schema allows a shard to have status
+func ValidShardStatus(status string) bool {
+	return slices.Contains(shardStatuses, status)
+}
+
+// dialect papers over the differences between the SQL drivers. Queries are
+// written with $n placeholders, which SQLite accepts too, or with ? when
+// passed through rebind. Both drivers support RETURNING (SQLite since
+// 3.35). IDs are generated in Go rather than by column defaults, which only
+// exist on Postgres.
+type dialect int
+
+const (
+	dialectSQLite dialect = iota
+	dialectPostgres
+)
+
+// dialectFor returns the dialect of a driver name as given to sql.Open
+func dialectFor(driverName string) dialect {
+	if driverName == "postgres" {
+		return dialectPostgres
+	}
+	return dialectSQLite
+}
+
+// dialectOf returns the dialect of an open *sql.DB
+func dialectOf(sqlDB *sql.DB) dialect {
+	if _, ok := sqlDB.Driver().(*pq.Driver); ok {
+		return dialectPostgres
+	}
+	return dialectSQLite
+}
+
+func (db *DB) dialect() dialect {
+	return dialectFor(db.DriverName)
+}
+
+// serializableTxOptions returns the options for a SERIALIZABLE transaction.
+// Postgres defaults to READ COMMITTED, so it is asked for SERIALIZABLE;
+// SQLite transactions are always serializable.
+func (d dialect) serializableTxOptions() *sql.TxOptions {
+	if d == dialectPostgres {
+		return &sql.TxOptions{Isolation: sql.LevelSerializable}
+	}
+	return nil
+}
+
+// String names the dialect's directory of migrations
+func (d dialect) String() string {
+	if d == dialectPostgres {
+		return "postgres"
+	}
+	return "sqlite"
+}
+
+// rebind rewrites ? placeholders for the dialect. Question marks inside
+// quoted strings are left alone.
+func (d dialect) rebind(query string) string {
+	if d != dialectPostgres || !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			quoted = !quoted
+		case r == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Rebind rewrites a query written with ? placeholders for this database's
+// driver
+func (db *DB) Rebind(query string) string {
+	return db.dialect().rebind(query)
+}
+
+// tableExistsQuery counts the tables named $1 in the current schema
+func (d dialect) tableExistsQuery() string {
+	if d == dialectPostgres {
+		return `SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = $1`
+	}
+	return `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = $1`
+}
+
+// translateError turns a driver's rejection of a status value into
+// ErrInvalidStatus. Postgres rejects values outside its enum types; SQLite
+// triggers added by migration 4 raise "invalid node status" or "invalid
+// shard status".
+func (d dialect) translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var pqErr *pq.Error
+	switch {
+	case errors.As(err, &pqErr) && pqErr.Code == "22P02" && strings.Contains(pqErr.Message, "enum"):
+	case d == dialectSQLite && (strings.Contains(err.Error(), "invalid node status") ||
+		strings.Contains(err.Error(), "invalid shard status")):
+	default:
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrInvalidStatus, err)
+}
@@ -0,0 +1,20 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebind(t *testing.T) {
+	query := `SELECT id FROM nodes WHERE name = ? AND location <> '?' AND status = ?`
+	assert.Equal(t, query, dialectSQLite.rebind(query))
+	assert.Equal(t, `SELECT id FROM nodes WHERE name = $1 AND location <> '?' AND status = $2`, dialectPostgres.rebind(query))
+}
+
+func TestValidStatus(t *testing.T) {
+	assert.True(t, ValidNodeStatus("maintenance"))
+	assert.False(t, ValidNodeStatus("pending"))
+	assert.True(t, ValidShardStatus("pending"))
+	assert.False(t, ValidShardStatus(""))
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// passwordParam matches password settings in key=value DSNs and DSN query
+// strings, such as password=... or SQLite's _auth_pass=...
+var passwordParam = regexp.MustCompile(`(?i)\b(\w*pass\w*)\s*=\s*('(?:[^'\\]|\\.)*'|[^\s&]*)`)
+
+// userPassword matches the password in a URL that does not parse
+var userPassword = regexp.MustCompile(`(://[^/:@]*:)[^@]*@`)
+
+// RedactDSN returns dsn with every password replaced by "xxxxx", so that it
+// can be logged
+func RedactDSN(dsn string) string {
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			dsn = userPassword.ReplaceAllString(dsn, "${1}xxxxx@")
+			return passwordParam.ReplaceAllString(dsn, "${1}=xxxxx")
+		}
+		u.RawQuery = passwordParam.ReplaceAllString(u.RawQuery, "${1}=xxxxx")
+		return u.Redacted()
+	}
+	return passwordParam.ReplaceAllString(dsn, "${1}=xxxxx")
+}
+
+// redactURLError redacts the DSN quoted by a URL parsing error, since
+// drivers return those as is
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = RedactDSN(urlErr.URL)
+	}
+	return err
+}
@@ -0,0 +1,5 @@
+package db
+
+// InSerializableTx lets the external tests run inSerializableTx against
+// the backends in dbtest
+var InSerializableTx = (*DB).inSerializableTx
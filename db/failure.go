@@ -3,16 +3,54 @@ package db
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// defaultFailureLimit caps ListFailureReports when no limit is given
+const defaultFailureLimit = 50
+
 // Failure operations
 func (db *DB) ReportFailure(ctx context.Context, failureType string, entityID uuid.UUID, details json.RawMessage) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
 	query := `
-		INSERT INTO failure_reports (type, entity_id, details)
-		VALUES ($1, $2, $3)`
+		INSERT INTO failure_reports (id, type, entity_id, details, created_at)
+		VALUES ($1, $2, $3, $4, $5)`
 
-	_, err := db.ExecContext(ctx, query, failureType, entityID, details)
+	_, err := db.ExecContext(ctx, query, uuid.New(), failureType, entityID, details, time.Now().UTC())
 	return err
 }
+
+// ListFailureReports retrieves the most recent failure reports, newest first
+func (db *DB) ListFailureReports(ctx context.Context, limit int) ([]*FailureReport, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	if limit <= 0 {
+		limit = defaultFailureLimit
+	}
+	query := `
+		SELECT id, type, entity_id, details, created_at
+		FROM failure_reports
+		ORDER BY created_at DESC
+		LIMIT $1`
+
+	rows, err := db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []*FailureReport
+	for rows.Next() {
+		report := &FailureReport{}
+		if err := rows.Scan(&report.ID, &report.Type, &report.EntityID, &report.Details, &report.CreatedAt); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
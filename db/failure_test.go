@@ -1,9 +1,13 @@
 package db
 
 import (
+	"context"
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -57,3 +61,25 @@ func TestFailureDBReporting(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+func TestListFailureReports(t *testing.T) {
+	ctx := context.Background()
+	db := setupSchemaDB(t)
+
+	nodeID, shardID := uuid.New(), uuid.New()
+	require.NoError(t, db.ReportFailure(ctx, "node_failure", nodeID, json.RawMessage(`{"error": "timeout"}`)))
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, db.ReportFailure(ctx, "shard_failure", shardID, json.RawMessage(`{}`)))
+
+	reports, err := db.ListFailureReports(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	assert.Equal(t, "shard_failure", reports[0].Type)
+	assert.Equal(t, shardID, reports[0].EntityID)
+	assert.Equal(t, nodeID, reports[1].EntityID)
+	assert.JSONEq(t, `{"error": "timeout"}`, string(reports[1].Details))
+
+	reports, err = db.ListFailureReports(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, reports, 1)
+}
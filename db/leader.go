@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// leaderLeaseName keys the single row of leader_leases shared by every
+// manager replica
+const leaderLeaseName = "shardmanager"
+
+// AcquireLeadership makes holderID the leader until expiresAt, or renews its
+// lease. It is only granted if no other replica holds an unexpired lease at
+// now. The conditional upsert is a single statement, so concurrent
+// candidates cannot both win.
+func (db *DB) AcquireLeadership(ctx context.Context, holderID, address string, now, expiresAt time.Time) (bool, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO leader_leases (name, holder_id, address, expires_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (name) DO UPDATE
+		SET holder_id = excluded.holder_id, address = excluded.address,
+			expires_at = excluded.expires_at, updated_at = excluded.updated_at
+		WHERE leader_leases.holder_id = excluded.holder_id
+			OR leader_leases.expires_at <= $5`
+
+	res, err := db.ExecContext(ctx, query, leaderLeaseName, holderID, address, expiresAt.UTC(), now.UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// GetLeader retrieves the latest leader lease, or nil if no replica was ever
+// elected. The lease may have expired.
+func (db *DB) GetLeader(ctx context.Context) (*LeaderLease, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT holder_id, address, expires_at, updated_at
+		FROM leader_leases
+		WHERE name = $1`
+
+	lease := &LeaderLease{}
+	err := db.QueryRowContext(ctx, query, leaderLeaseName).Scan(
+		&lease.HolderID, &lease.Address, &lease.ExpiresAt, &lease.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// ReleaseLeadership gives up holderID's lease so another replica can take
+// over without waiting for it to expire
+func (db *DB) ReleaseLeadership(ctx context.Context, holderID string) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `DELETE FROM leader_leases WHERE name = $1 AND holder_id = $2`
+
+	_, err := db.ExecContext(ctx, query, leaderLeaseName, holderID)
+	return err
+}
@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaderElection(t *testing.T) {
+	ctx := context.Background()
+	db := setupSchemaDB(t)
+	now := time.Now()
+
+	leader, err := db.GetLeader(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, leader)
+
+	won, err := db.AcquireLeadership(ctx, "a", "a:7427", now, now.Add(15*time.Second))
+	require.NoError(t, err)
+	assert.True(t, won)
+
+	// Another replica cannot take over an unexpired lease
+	won, err = db.AcquireLeadership(ctx, "b", "b:7427", now.Add(time.Second), now.Add(16*time.Second))
+	require.NoError(t, err)
+	assert.False(t, won)
+
+	// The leader renews its own lease
+	won, err = db.AcquireLeadership(ctx, "a", "a:7427", now.Add(5*time.Second), now.Add(20*time.Second))
+	require.NoError(t, err)
+	assert.True(t, won)
+
+	leader, err = db.GetLeader(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, leader)
+	assert.Equal(t, "a", leader.HolderID)
+	assert.Equal(t, "a:7427", leader.Address)
+	assert.WithinDuration(t, now.Add(20*time.Second), leader.ExpiresAt, time.Second)
+
+	// Once the lease expires another replica takes over
+	won, err = db.AcquireLeadership(ctx, "b", "b:7427", now.Add(21*time.Second), now.Add(36*time.Second))
+	require.NoError(t, err)
+	assert.True(t, won)
+
+	// Only the holder can release the lease
+	require.NoError(t, db.ReleaseLeadership(ctx, "a"))
+	leader, err = db.GetLeader(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "b", leader.HolderID)
+
+	require.NoError(t, db.ReleaseLeadership(ctx, "b"))
+	won, err = db.AcquireLeadership(ctx, "a", "a:7427", now.Add(22*time.Second), now.Add(37*time.Second))
+	require.NoError(t, err)
+	assert.True(t, won)
+}
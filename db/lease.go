@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AcquireShardLease grants or renews a node's lease on a shard. The lease is
+// only granted if no other node holds an unexpired, unrevoked lease at now.
+func (db *DB) AcquireShardLease(ctx context.Context, shardID, nodeID uuid.UUID, now, expiresAt time.Time) (bool, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO shard_leases (shard_id, node_id, expires_at, revoked)
+		VALUES ($1, $2, $3, FALSE)
+		ON CONFLICT (shard_id) DO UPDATE
+		SET node_id = excluded.node_id, expires_at = excluded.expires_at, revoked = FALSE
+		WHERE shard_leases.node_id = excluded.node_id
+			OR shard_leases.revoked
+			OR shard_leases.expires_at <= $4`
+
+	res, err := db.ExecContext(ctx, query, shardID, nodeID, expiresAt.UTC(), now.UTC())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// GetShardLease retrieves the current lease on a shard, or nil if none was granted
+func (db *DB) GetShardLease(ctx context.Context, shardID uuid.UUID) (*ShardLease, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT shard_id, node_id, expires_at, revoked, updated_at
+		FROM shard_leases
+		WHERE shard_id = $1`
+
+	lease := &ShardLease{}
+	err := db.QueryRowContext(ctx, query, shardID).Scan(
+		&lease.ShardID, &lease.NodeID, &lease.ExpiresAt, &lease.Revoked, &lease.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// RevokeShardLease releases a node's lease on a shard ahead of its expiry
+func (db *DB) RevokeShardLease(ctx context.Context, shardID, nodeID uuid.UUID) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE shard_leases
+		SET revoked = TRUE, updated_at = CURRENT_TIMESTAMP
+		WHERE shard_id = $1 AND node_id = $2`
+
+	_, err := db.ExecContext(ctx, query, shardID, nodeID)
+	return err
+}
+
+// ListNodeLeases retrieves every unrevoked lease held by a node
+func (db *DB) ListNodeLeases(ctx context.Context, nodeID uuid.UUID) ([]*ShardLease, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT shard_id, node_id, expires_at, revoked, updated_at
+		FROM shard_leases
+		WHERE node_id = $1 AND NOT revoked`
+
+	rows, err := db.QueryContext(ctx, query, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var leases []*ShardLease
+	for rows.Next() {
+		lease := &ShardLease{}
+		err := rows.Scan(&lease.ShardID, &lease.NodeID, &lease.ExpiresAt, &lease.Revoked, &lease.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		leases = append(leases, lease)
+	}
+	return leases, rows.Err()
+}
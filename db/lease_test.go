@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardLeases(t *testing.T) {
+	ctx := context.Background()
+	db := setupSchemaDB(t)
+
+	shardID := uuid.New()
+	owner := uuid.New()
+	other := uuid.New()
+	now := time.Now()
+
+	lease, err := db.GetShardLease(ctx, shardID)
+	require.NoError(t, err)
+	assert.Nil(t, lease)
+
+	granted, err := db.AcquireShardLease(ctx, shardID, owner, now, now.Add(10*time.Second))
+	require.NoError(t, err)
+	assert.True(t, granted)
+
+	// The owner can renew its own lease
+	granted, err = db.AcquireShardLease(ctx, shardID, owner, now.Add(time.Second), now.Add(11*time.Second))
+	require.NoError(t, err)
+	assert.True(t, granted)
+
+	// Another node cannot take over an unexpired lease
+	granted, err = db.AcquireShardLease(ctx, shardID, other, now.Add(2*time.Second), now.Add(12*time.Second))
+	require.NoError(t, err)
+	assert.False(t, granted)
+
+	lease, err = db.GetShardLease(ctx, shardID)
+	require.NoError(t, err)
+	require.NotNil(t, lease)
+	assert.Equal(t, owner, lease.NodeID)
+	assert.False(t, lease.Revoked)
+	assert.WithinDuration(t, now.Add(11*time.Second), lease.ExpiresAt, time.Millisecond)
+
+	// Once expired the lease can move
+	granted, err = db.AcquireShardLease(ctx, shardID, other, now.Add(11*time.Second), now.Add(21*time.Second))
+	require.NoError(t, err)
+	assert.True(t, granted)
+
+	// Revoking only affects the holder's lease
+	require.NoError(t, db.RevokeShardLease(ctx, shardID, owner))
+	lease, err = db.GetShardLease(ctx, shardID)
+	require.NoError(t, err)
+	assert.Equal(t, other, lease.NodeID)
+	assert.False(t, lease.Revoked)
+
+	require.NoError(t, db.RevokeShardLease(ctx, shardID, other))
+	lease, err = db.GetShardLease(ctx, shardID)
+	require.NoError(t, err)
+	assert.True(t, lease.Revoked)
+
+	// A revoked lease is free to be acquired before it expires
+	granted, err = db.AcquireShardLease(ctx, shardID, owner, now.Add(12*time.Second), now.Add(22*time.Second))
+	require.NoError(t, err)
+	assert.True(t, granted)
+
+	leases, err := db.ListNodeLeases(ctx, owner)
+	require.NoError(t, err)
+	require.Len(t, leases, 1)
+	assert.Equal(t, shardID, leases[0].ShardID)
+
+	require.NoError(t, db.RevokeShardLease(ctx, shardID, owner))
+	leases, err = db.ListNodeLeases(ctx, owner)
+	require.NoError(t, err)
+	assert.Empty(t, leases)
+}
@@ -0,0 +1,24 @@
+package memdb
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/db/dbtest"
+)
+
+func TestConformance(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		dbtest.Run(t, func(t *testing.T) db.DBOperations { return New() })
+	})
+	t.Run("persistent", func(t *testing.T) {
+		dbtest.Run(t, func(t *testing.T) db.DBOperations {
+			m, err := Open(t.TempDir(), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { m.Close() })
+			return m
+		})
+	})
+}
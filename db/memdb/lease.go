@@ -0,0 +1,112 @@
+package memdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+)
+
+// AcquireShardLease grants or renews a node's lease on a shard. The lease is
+// only granted if no other node holds an unexpired, unrevoked lease at now.
+func (m *DB) AcquireShardLease(ctx context.Context, shardID, nodeID uuid.UUID, now, expiresAt time.Time) (bool, error) {
+	var granted bool
+	err := m.write(db.Change{}, func(s *state) ([]*entry, error) {
+		if lease, ok := s.Leases[shardID]; ok &&
+			lease.NodeID != nodeID && !lease.Revoked && lease.ExpiresAt.After(now) {
+			return nil, nil
+		}
+		granted = true
+		return []*entry{{Lease: &db.ShardLease{
+			ShardID:   shardID,
+			NodeID:    nodeID,
+			ExpiresAt: expiresAt.UTC(),
+			UpdatedAt: now.UTC(),
+		}}}, nil
+	})
+	return granted && err == nil, err
+}
+
+// GetShardLease returns the current lease on a shard, or nil if none was granted
+func (m *DB) GetShardLease(ctx context.Context, shardID uuid.UUID) (*db.ShardLease, error) {
+	var lease *db.ShardLease
+	m.read(func(s *state) {
+		if l, ok := s.Leases[shardID]; ok {
+			copied := *l
+			lease = &copied
+		}
+	})
+	return lease, nil
+}
+
+// RevokeShardLease releases a node's lease on a shard ahead of its expiry
+func (m *DB) RevokeShardLease(ctx context.Context, shardID, nodeID uuid.UUID) error {
+	return m.write(db.Change{}, func(s *state) ([]*entry, error) {
+		lease, ok := s.Leases[shardID]
+		if !ok || lease.NodeID != nodeID {
+			return nil, nil
+		}
+		revoked := *lease
+		revoked.Revoked = true
+		revoked.UpdatedAt = m.now()
+		return []*entry{{Lease: &revoked}}, nil
+	})
+}
+
+// ListNodeLeases returns every unrevoked lease held by a node
+func (m *DB) ListNodeLeases(ctx context.Context, nodeID uuid.UUID) ([]*db.ShardLease, error) {
+	var leases []*db.ShardLease
+	m.read(func(s *state) {
+		for _, lease := range s.Leases {
+			if lease.NodeID == nodeID && !lease.Revoked {
+				copied := *lease
+				leases = append(leases, &copied)
+			}
+		}
+	})
+	return leases, nil
+}
+
+// AcquireLeadership makes holderID the leader until expiresAt, or renews its
+// lease. It is only granted if no other holder has an unexpired lease at now.
+func (m *DB) AcquireLeadership(ctx context.Context, holderID, address string, now, expiresAt time.Time) (bool, error) {
+	var granted bool
+	err := m.write(db.Change{}, func(s *state) ([]*entry, error) {
+		if s.Leader != nil && s.Leader.HolderID != holderID && s.Leader.ExpiresAt.After(now) {
+			return nil, nil
+		}
+		granted = true
+		return []*entry{{Leader: &db.LeaderLease{
+			HolderID:  holderID,
+			Address:   address,
+			ExpiresAt: expiresAt.UTC(),
+			UpdatedAt: now.UTC(),
+		}}}, nil
+	})
+	return granted && err == nil, err
+}
+
+// GetLeader returns the latest leader lease, or nil if there is none. The
+// lease may have expired.
+func (m *DB) GetLeader(ctx context.Context) (*db.LeaderLease, error) {
+	var leader *db.LeaderLease
+	m.read(func(s *state) {
+		if s.Leader != nil {
+			copied := *s.Leader
+			leader = &copied
+		}
+	})
+	return leader, nil
+}
+
+// ReleaseLeadership gives up holderID's lease so another holder can take
+// over without waiting for it to expire
+func (m *DB) ReleaseLeadership(ctx context.Context, holderID string) error {
+	return m.write(db.Change{}, func(s *state) ([]*entry, error) {
+		if s.Leader == nil || s.Leader.HolderID != holderID {
+			return nil, nil
+		}
+		return []*entry{{LeaderReleased: true}}, nil
+	})
+}
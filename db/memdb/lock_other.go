@@ -0,0 +1,10 @@
+//go:build !unix
+
+package memdb
+
+import "os"
+
+// lockDir opens dir without locking it, since flock is not available
+func lockDir(dir string) (*os.File, error) {
+	return os.Open(dir)
+}
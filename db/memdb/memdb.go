@@ -0,0 +1,344 @@
+// Package memdb is a db.DBOperations backend held entirely in memory, for
+// small deployments and CI that have no database server. Given a data
+// directory it survives restarts: every write is appended to a log, and
+// the whole state is periodically written as an atomic snapshot, after
+// which the log starts over.
+//
+// Open locks the data directory, so only one process may use it at a time
+// and manager replicas cannot share a memdb.
+package memdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/seaweedfs/shardmanager/db"
+)
+
+// DefaultSnapshotInterval is how often Open snapshots when given no interval
+const DefaultSnapshotInterval = time.Minute
+
+// dsnPrefix marks a DSN naming a memdb, optionally followed by its data
+// directory
+const dsnPrefix = "mem:"
+
+var errClosed = errors.New("memdb is closed")
+
+// DB is an in-memory database, safe for concurrent use
+type DB struct {
+	mu     sync.RWMutex
+	state  *state
+	closed bool
+	clock  func() time.Time // nil means the wall clock
+
+	// Persistence, unset for a database that is only held in memory
+	dir         string
+	dirLock     *os.File // holds the data directory's lock until Close
+	log         appendLog
+	logSize     int64
+	logErr      error // set when the log could not be restored after a failed append
+	snapshotSeq int64
+	stop        chan struct{}
+	stopped     chan struct{}
+
+	watchMu  sync.Mutex
+	watchers map[int]func(db.Change)
+	watchSeq int
+
+	logger atomic.Pointer[slog.Logger] // unset logs to slog.Default
+}
+
+// appendLog is the write-ahead log file
+type appendLog interface {
+	Write(p []byte) (int, error)
+	Sync() error
+	Truncate(size int64) error
+	Close() error
+}
+
+// New returns an empty database that is lost when the process exits
+func New() *DB {
+	return &DB{state: newState()}
+}
+
+// SetClock makes the database stamp rows with the time now returns instead
+// of the wall clock. It must be called before the database is shared.
+func (m *DB) SetClock(now func() time.Time) {
+	m.clock = now
+}
+
+// now returns the time to stamp rows with, in UTC
+func (m *DB) now() time.Time {
+	if m.clock == nil {
+		return time.Now().UTC()
+	}
+	return m.clock().UTC()
+}
+
+// Open loads the database kept in dir, creating dir if needed, and
+// snapshots it every snapshotInterval until closed. An interval of zero
+// means DefaultSnapshotInterval. dir stays locked until Close, so a second
+// Open of it fails.
+func Open(dir string, snapshotInterval time.Duration) (*DB, error) {
+	if snapshotInterval <= 0 {
+		snapshotInterval = DefaultSnapshotInterval
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	dirLock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	m, err := open(dir, snapshotInterval)
+	if err != nil {
+		dirLock.Close()
+		return nil, err
+	}
+	m.dirLock = dirLock
+	return m, nil
+}
+
+// open loads the database kept in the locked dir
+func open(dir string, snapshotInterval time.Duration) (*DB, error) {
+	st, err := load(dir)
+	if err != nil {
+		return nil, err
+	}
+	// The replayed log, with any torn tail, is folded into a fresh snapshot
+	// so that appends start from a clean log
+	if err := writeSnapshot(dir, st); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, logFile), os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	m := &DB{
+		state:       st,
+		dir:         dir,
+		log:         f,
+		snapshotSeq: st.Seq,
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	go m.snapshotLoop(snapshotInterval)
+	return m, nil
+}
+
+// IsDSN reports whether dsn names a memdb: "mem:" alone for one held only
+// in memory, or "mem:" followed by its data directory
+func IsDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, dsnPrefix)
+}
+
+// OpenDSN opens the memdb named by dsn
+func OpenDSN(dsn string) (*DB, error) {
+	if !IsDSN(dsn) {
+		return nil, errors.New("not a memdb DSN: " + dsn)
+	}
+	dir := strings.TrimPrefix(dsn, dsnPrefix)
+	if dir == "" {
+		return New(), nil
+	}
+	return Open(dir, 0)
+}
+
+// SetLogger sends the database's logs to logger
+func (m *DB) SetLogger(logger *slog.Logger) {
+	m.logger.Store(logger)
+}
+
+// Close stops snapshotting and writes a final snapshot. Writes fail after
+// Close; reads still succeed.
+func (m *DB) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	m.mu.Unlock()
+	if m.log == nil {
+		return nil
+	}
+
+	close(m.stop)
+	<-m.stopped
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := m.snapshotLocked()
+	if closeErr := m.log.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := m.dirLock.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Snapshot writes the whole state to the data directory and starts a new
+// log. It does nothing for a database held only in memory.
+func (m *DB) Snapshot() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return errClosed
+	}
+	return m.snapshotLocked()
+}
+
+func (m *DB) snapshotLocked() error {
+	if m.log == nil || m.state.Seq == m.snapshotSeq {
+		return nil
+	}
+	if err := writeSnapshot(m.dir, m.state); err != nil {
+		return err
+	}
+	m.snapshotSeq = m.state.Seq
+	// Records still in the log are skipped on replay if truncating fails
+	if err := m.log.Truncate(0); err != nil {
+		return err
+	}
+	m.logSize = 0
+	m.logErr = nil
+	return nil
+}
+
+func (m *DB) snapshotLoop(interval time.Duration) {
+	defer close(m.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+		if err := m.Snapshot(); err != nil && err != errClosed {
+			logger := m.logger.Load()
+			if logger == nil {
+				logger = slog.Default()
+			}
+			logger.Warn("Failed to snapshot", "dir", m.dir, "err", err)
+		}
+	}
+}
+
+// commit logs the rows written by one mutation, then applies them. The
+// caller holds the write lock.
+func (m *DB) commit(entries ...*entry) error {
+	if m.closed {
+		return errClosed
+	}
+	if m.logErr != nil {
+		return m.logErr
+	}
+	rec := &record{Seq: m.state.Seq + 1, Entries: entries}
+	if m.log != nil {
+		line, err := encodeRecord(rec)
+		if err != nil {
+			return err
+		}
+		if _, err := m.log.Write(line); err != nil {
+			m.discardAppend(err)
+			return err
+		}
+		if err := m.log.Sync(); err != nil {
+			m.discardAppend(err)
+			return err
+		}
+		m.logSize += int64(len(line))
+	}
+	m.state.apply(rec)
+	return nil
+}
+
+// discardAppend drops what a failed append left in the log, so that a
+// record that was never applied is not replayed, nor its Seq reused. If the
+// log cannot be restored, writes fail until a snapshot starts a new one.
+// The caller holds the write lock.
+func (m *DB) discardAppend(cause error) {
+	err := m.log.Truncate(m.logSize)
+	if err == nil {
+		err = m.log.Sync()
+	}
+	if err != nil {
+		m.logErr = fmt.Errorf("log is unusable after a failed append (%v): %w", cause, err)
+	}
+}
+
+// write runs fn under the write lock. When fn writes rows, change is
+// delivered to watchers once the lock is released.
+func (m *DB) write(change db.Change, fn func(s *state) ([]*entry, error)) error {
+	m.mu.Lock()
+	entries, err := fn(m.state)
+	if err == nil && len(entries) > 0 {
+		err = m.commit(entries...)
+	}
+	m.mu.Unlock()
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	if change.Entity != "" {
+		m.publish(change)
+	}
+	return nil
+}
+
+// read runs fn under the read lock
+func (m *DB) read(fn func(s *state)) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	fn(m.state)
+}
+
+// PingContext fails once the database is closed
+func (m *DB) PingContext(ctx context.Context) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.closed {
+		return errClosed
+	}
+	return nil
+}
+
+// WatchChanges calls fn with every change until ctx is cancelled. Changes
+// are delivered synchronously by the writing call, so fn must not block.
+func (m *DB) WatchChanges(ctx context.Context, fn func(db.Change)) error {
+	m.watchMu.Lock()
+	if m.watchers == nil {
+		m.watchers = make(map[int]func(db.Change))
+	}
+	m.watchSeq++
+	id := m.watchSeq
+	m.watchers[id] = fn
+	m.watchMu.Unlock()
+
+	<-ctx.Done()
+	m.watchMu.Lock()
+	delete(m.watchers, id)
+	m.watchMu.Unlock()
+	return nil
+}
+
+// publish delivers a change to every watcher
+func (m *DB) publish(change db.Change) {
+	m.watchMu.Lock()
+	fns := make([]func(db.Change), 0, len(m.watchers))
+	for _, fn := range m.watchers {
+		fns = append(fns, fn)
+	}
+	m.watchMu.Unlock()
+	for _, fn := range fns {
+		fn(change)
+	}
+}
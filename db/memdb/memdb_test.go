@@ -0,0 +1,202 @@
+package memdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecords(t *testing.T) {
+	ctx := context.Background()
+	var ops db.DBOperations = New()
+	require.NoError(t, ops.PingContext(ctx))
+	nodeID, shardID := uuid.New(), uuid.New()
+
+	t.Run("Policies", func(t *testing.T) {
+		require.NoError(t, ops.SetPolicy(ctx, &db.Policy{PolicyType: "placement", Parameters: json.RawMessage(`{"v":1}`)}))
+		require.NoError(t, ops.SetPolicy(ctx, &db.Policy{PolicyType: "placement", Parameters: json.RawMessage(`{"v":2}`)}))
+		require.NoError(t, ops.SetPolicy(ctx, &db.Policy{PolicyType: "migration", Parameters: json.RawMessage(`{}`)}))
+
+		got, err := ops.GetPolicy(ctx, "placement")
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"v":2}`, string(got.Parameters))
+		active, err := ops.ListActivePolicies(ctx)
+		require.NoError(t, err)
+		require.Len(t, active, 2)
+		assert.Equal(t, "migration", active[0].PolicyType)
+	})
+
+	t.Run("Failures", func(t *testing.T) {
+		require.NoError(t, ops.ReportFailure(ctx, "node_failure", nodeID, json.RawMessage(`{}`)))
+		require.NoError(t, ops.ReportFailure(ctx, "shard_failure", shardID, json.RawMessage(`{}`)))
+		reports, err := ops.ListFailureReports(ctx, 1)
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		assert.Equal(t, "shard_failure", reports[0].Type)
+	})
+
+	t.Run("Leases", func(t *testing.T) {
+		now := time.Now()
+		other := uuid.New()
+		granted, err := ops.AcquireShardLease(ctx, shardID, nodeID, now, now.Add(time.Minute))
+		require.NoError(t, err)
+		assert.True(t, granted)
+		granted, err = ops.AcquireShardLease(ctx, shardID, other, now, now.Add(time.Minute))
+		require.NoError(t, err)
+		assert.False(t, granted)
+		granted, err = ops.AcquireShardLease(ctx, shardID, other, now.Add(2*time.Minute), now.Add(3*time.Minute))
+		require.NoError(t, err)
+		assert.True(t, granted, "expired leases may be taken over")
+
+		require.NoError(t, ops.RevokeShardLease(ctx, shardID, other))
+		leases, err := ops.ListNodeLeases(ctx, other)
+		require.NoError(t, err)
+		assert.Empty(t, leases)
+		lease, err := ops.GetShardLease(ctx, shardID)
+		require.NoError(t, err)
+		assert.True(t, lease.Revoked)
+	})
+
+	t.Run("Leadership", func(t *testing.T) {
+		now := time.Now()
+		won, err := ops.AcquireLeadership(ctx, "a", "a:1", now, now.Add(time.Minute))
+		require.NoError(t, err)
+		assert.True(t, won)
+		won, err = ops.AcquireLeadership(ctx, "b", "b:1", now, now.Add(time.Minute))
+		require.NoError(t, err)
+		assert.False(t, won)
+		require.NoError(t, ops.ReleaseLeadership(ctx, "b"))
+		leader, err := ops.GetLeader(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "a", leader.HolderID, "only the holder can release")
+		require.NoError(t, ops.ReleaseLeadership(ctx, "a"))
+		leader, err = ops.GetLeader(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, leader)
+	})
+
+	t.Run("Reconciliation", func(t *testing.T) {
+		require.NoError(t, ops.RecordReconcileAction(ctx, &db.ReconcileAction{NodeID: nodeID, ShardID: shardID, Action: "add_shard"}))
+		require.NoError(t, ops.RecordReconcileAction(ctx, &db.ReconcileAction{NodeID: nodeID, ShardID: shardID, Action: "drop_shard"}))
+		actions, err := ops.ListReconcileActions(ctx, nodeID)
+		require.NoError(t, err)
+		require.Len(t, actions, 2)
+		assert.Equal(t, "drop_shard", actions[0].Action)
+	})
+
+	t.Run("Audit", func(t *testing.T) {
+		base := time.Now().Add(-time.Hour)
+		for i, actor := range []string{"alice", "bob", "alice"} {
+			require.NoError(t, ops.RecordAuditEvent(ctx, &db.AuditEvent{
+				Actor: actor, Method: fmt.Sprintf("/m%d", i), Result: "ok", CreatedAt: base.Add(time.Duration(i) * time.Minute),
+			}))
+		}
+		events, err := ops.ListAuditEvents(ctx, db.AuditFilter{Actor: "alice"})
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.Equal(t, "/m2", events[0].Method, "newest first")
+		events, err = ops.ListAuditEvents(ctx, db.AuditFilter{Since: base.Add(time.Minute), Until: base.Add(2 * time.Minute)})
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, "/m1", events[0].Method)
+		events, err = ops.ListAuditEvents(ctx, db.AuditFilter{Limit: 1})
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+	})
+
+	t.Run("ShardLoads", func(t *testing.T) {
+		require.NoError(t, ops.RecordShardLoads(ctx, []*db.ShardLoad{{ShardID: shardID, NodeID: nodeID, QPS: 1.5}}))
+		require.NoError(t, ops.RecordShardLoads(ctx, []*db.ShardLoad{{ShardID: shardID, NodeID: nodeID, QPS: 2.5}}))
+		loads, err := ops.ListShardLoads(ctx)
+		require.NoError(t, err)
+		require.Len(t, loads, 1)
+		assert.Equal(t, 2.5, loads[0].QPS)
+		assert.False(t, loads[0].ReportedAt.IsZero())
+	})
+}
+
+func TestWatchChanges(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := New()
+	changes := make(chan db.Change, 16)
+	done := make(chan error, 1)
+	go func() { done <- m.WatchChanges(ctx, func(c db.Change) { changes <- c }) }()
+	require.Eventually(t, func() bool {
+		m.watchMu.Lock()
+		defer m.watchMu.Unlock()
+		return len(m.watchers) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	shard := &db.Shard{Type: "kv", Status: "active"}
+	require.NoError(t, m.RegisterShard(ctx, shard))
+	assert.Equal(t, db.Change{Entity: db.ChangeShard, ID: shard.ID.String()}, <-changes)
+	require.NoError(t, m.UpdateShardStatus(ctx, uuid.New(), "active"))
+	require.NoError(t, m.SetPolicy(ctx, &db.Policy{PolicyType: "placement"}))
+	assert.Equal(t, db.Change{Entity: db.ChangePolicy, ID: "placement"}, <-changes, "writes that change nothing are not announced")
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+	shard := &db.Shard{Type: "kv", Status: "active", Version: 1}
+	require.NoError(t, m.RegisterShard(ctx, shard))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, m.AssignShard(ctx, shard.ID, uuid.New()))
+			_, err := m.ListShards(ctx)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	versions, err := m.ListShardVersions(ctx, shard.ID)
+	require.NoError(t, err)
+	assert.Len(t, versions, 20)
+	got, err := m.GetShardInfo(ctx, shard.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 21, got.Version)
+}
+
+func TestClose(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+	require.NoError(t, m.RegisterShard(ctx, &db.Shard{Type: "kv", Status: "active"}))
+	require.NoError(t, m.Close())
+	assert.Error(t, m.PingContext(ctx))
+	assert.ErrorIs(t, m.RegisterShard(ctx, &db.Shard{Type: "kv", Status: "active"}), errClosed)
+	shards, err := m.ListShards(ctx)
+	require.NoError(t, err)
+	assert.Len(t, shards, 1, "reads still work")
+	assert.NoError(t, m.Close())
+}
+
+func TestSetClock(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	m := New()
+	m.SetClock(func() time.Time { return now })
+	shard := &db.Shard{Type: "kv", Status: "active"}
+	require.NoError(t, m.RegisterShard(ctx, shard))
+	now = now.Add(time.Minute)
+	require.NoError(t, m.UpdateShardStatus(ctx, shard.ID, "migrating"))
+
+	got, err := m.GetShardInfo(ctx, shard.ID)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(-time.Minute), got.CreatedAt)
+	assert.Equal(t, now, got.UpdatedAt)
+}
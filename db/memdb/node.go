@@ -0,0 +1,179 @@
+package memdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+)
+
+// RegisterNode adds a node along with the load it reports, generating its
+// ID if it has none. A node without a LastHeartbeat is stamped with now.
+func (m *DB) RegisterNode(ctx context.Context, node *db.Node) error {
+	if !db.ValidNodeStatus(node.Status) {
+		return fmt.Errorf("%w: invalid node status %q", db.ErrInvalidStatus, node.Status)
+	}
+	if node.ID == uuid.Nil {
+		node.ID = uuid.New()
+	}
+	if node.AssignmentMode == "" {
+		node.AssignmentMode = "push"
+	}
+	return m.write(db.Change{Entity: db.ChangeNode, ID: node.ID.String()}, func(s *state) ([]*entry, error) {
+		if _, ok := s.Nodes[node.ID]; ok {
+			return nil, fmt.Errorf("node %s already exists", node.ID)
+		}
+		if err := checkNodeName(s, node); err != nil {
+			return nil, err
+		}
+		now := m.now()
+		if node.LastHeartbeat.IsZero() {
+			node.LastHeartbeat = now
+		}
+		stored := cloneNode(node)
+		stored.LastHeartbeat = node.LastHeartbeat.UTC()
+		stored.CreatedAt, stored.UpdatedAt = now, now
+		node.CreatedAt, node.UpdatedAt = now, now
+		return []*entry{{Node: stored}}, nil
+	})
+}
+
+// UpdateNode updates the registration details of an existing node,
+// returning sql.ErrNoRows if there is none
+func (m *DB) UpdateNode(ctx context.Context, node *db.Node) error {
+	if node.AssignmentMode == "" {
+		node.AssignmentMode = "push"
+	}
+	return m.write(db.Change{Entity: db.ChangeNode, ID: node.ID.String()}, func(s *state) ([]*entry, error) {
+		current, ok := s.Nodes[node.ID]
+		if !ok {
+			return nil, sql.ErrNoRows
+		}
+		if !db.ValidNodeStatus(node.Status) {
+			return nil, fmt.Errorf("%w: invalid node status %q", db.ErrInvalidStatus, node.Status)
+		}
+		if err := checkNodeName(s, node); err != nil {
+			return nil, err
+		}
+		stored := cloneNode(current)
+		stored.Name = node.Name
+		stored.Location = node.Location
+		stored.FaultDomain = node.FaultDomain
+		stored.Capacity = node.Capacity
+		stored.Status = node.Status
+		stored.AssignmentMode = node.AssignmentMode
+		stored.ResourceCapacity = cloneResources(node.ResourceCapacity)
+		stored.UpdatedAt = m.now()
+		node.CreatedAt, node.UpdatedAt = stored.CreatedAt, stored.UpdatedAt
+		return []*entry{{Node: stored}}, nil
+	})
+}
+
+// UpdateNodeHeartbeat records a heartbeat. A node an operator put into
+// maintenance keeps that status whatever the node reports. The heartbeat is
+// only announced to watchers as db.HeartbeatChangesNode decides.
+func (m *DB) UpdateNodeHeartbeat(ctx context.Context, nodeID uuid.UUID, status string, currentLoad int64, resourceLoad db.Resources) error {
+	var announce bool
+	err := m.write(db.Change{}, func(s *state) ([]*entry, error) {
+		current, ok := s.Nodes[nodeID]
+		if !ok {
+			return nil, nil
+		}
+		stored := cloneNode(current)
+		if stored.Status != "maintenance" {
+			if !db.ValidNodeStatus(status) {
+				return nil, fmt.Errorf("%w: invalid node status %q", db.ErrInvalidStatus, status)
+			}
+			stored.Status = status
+		}
+		stored.CurrentLoad = currentLoad
+		stored.ResourceLoad = cloneResources(resourceLoad)
+		stored.LastHeartbeat = m.now()
+		announce = db.HeartbeatChangesNode(current, status, currentLoad, resourceLoad, stored.LastHeartbeat)
+		return []*entry{{Node: stored}}, nil
+	})
+	if err == nil && announce {
+		m.publish(db.Change{Entity: db.ChangeNode, ID: nodeID.String()})
+	}
+	return err
+}
+
+// ListNodes returns every node, oldest first
+func (m *DB) ListNodes(ctx context.Context) ([]*db.Node, error) {
+	var nodes []*db.Node
+	m.read(func(s *state) {
+		for _, node := range s.Nodes {
+			nodes = append(nodes, cloneNode(node))
+		}
+	})
+	sort.Slice(nodes, func(i, j int) bool {
+		if !nodes[i].CreatedAt.Equal(nodes[j].CreatedAt) {
+			return nodes[i].CreatedAt.Before(nodes[j].CreatedAt)
+		}
+		return nodes[i].ID.String() < nodes[j].ID.String()
+	})
+	return nodes, nil
+}
+
+// GetNodeInfo returns a node, or nil if there is none with nodeID
+func (m *DB) GetNodeInfo(ctx context.Context, nodeID uuid.UUID) (*db.Node, error) {
+	var node *db.Node
+	m.read(func(s *state) {
+		if n, ok := s.Nodes[nodeID]; ok {
+			node = cloneNode(n)
+		}
+	})
+	return node, nil
+}
+
+// GetNodeByName looks a node up by its stable name, returning nil if none has it
+func (m *DB) GetNodeByName(ctx context.Context, name string) (*db.Node, error) {
+	var node *db.Node
+	m.read(func(s *state) {
+		if n := nodeByName(s, name); n != nil {
+			node = cloneNode(n)
+		}
+	})
+	return node, nil
+}
+
+// nodeByName finds the node named name; empty names match nothing
+func nodeByName(s *state, name string) *db.Node {
+	if name == "" {
+		return nil
+	}
+	for _, node := range s.Nodes {
+		if node.Name == name {
+			return node
+		}
+	}
+	return nil
+}
+
+// checkNodeName enforces that names are unique, as the schema does
+func checkNodeName(s *state, node *db.Node) error {
+	if other := nodeByName(s, node.Name); other != nil && other.ID != node.ID {
+		return fmt.Errorf("node name %q is already registered to node %s", node.Name, other.ID)
+	}
+	return nil
+}
+
+func cloneNode(node *db.Node) *db.Node {
+	copied := *node
+	copied.ResourceCapacity = cloneResources(node.ResourceCapacity)
+	copied.ResourceLoad = cloneResources(node.ResourceLoad)
+	return &copied
+}
+
+// cloneResources copies r, reading a missing vector as empty like the
+// SQL backends do
+func cloneResources(r db.Resources) db.Resources {
+	if r == nil {
+		return db.Resources{}
+	}
+	return maps.Clone(r)
+}
@@ -0,0 +1,83 @@
+package memdb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodes(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+
+	node := &db.Node{Name: "app-1", Location: "localhost:1", Capacity: 100, Status: "active",
+		ResourceCapacity: db.Resources{"cpu": 4}}
+	require.NoError(t, m.RegisterNode(ctx, node))
+	assert.NotEqual(t, uuid.Nil, node.ID)
+	assert.Equal(t, "push", node.AssignmentMode)
+	assert.False(t, node.CreatedAt.IsZero())
+
+	t.Run("Lookup", func(t *testing.T) {
+		got, err := m.GetNodeInfo(ctx, node.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "localhost:1", got.Location)
+		assert.Equal(t, db.Resources{}, got.ResourceLoad)
+		assert.False(t, got.LastHeartbeat.IsZero())
+
+		// Returned nodes are copies
+		got.ResourceCapacity["cpu"] = 1
+		again, err := m.GetNodeByName(ctx, "app-1")
+		require.NoError(t, err)
+		assert.Equal(t, 4.0, again.ResourceCapacity["cpu"])
+
+		missing, err := m.GetNodeInfo(ctx, uuid.New())
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+		missing, err = m.GetNodeByName(ctx, "")
+		require.NoError(t, err)
+		assert.Nil(t, missing)
+	})
+
+	t.Run("Constraints", func(t *testing.T) {
+		assert.Error(t, m.RegisterNode(ctx, &db.Node{ID: node.ID, Location: "x", Status: "active"}))
+		assert.Error(t, m.RegisterNode(ctx, &db.Node{Name: "app-1", Location: "x", Status: "active"}))
+		err := m.RegisterNode(ctx, &db.Node{Location: "x", Status: "sleeping"})
+		assert.True(t, errors.Is(err, db.ErrInvalidStatus), "got %v", err)
+		err = m.UpdateNode(ctx, &db.Node{ID: uuid.New(), Status: "active"})
+		assert.Equal(t, sql.ErrNoRows, err)
+	})
+
+	t.Run("Heartbeat", func(t *testing.T) {
+		require.NoError(t, m.UpdateNodeHeartbeat(ctx, node.ID, "inactive", 7, db.Resources{"cpu": 1}))
+		got, err := m.GetNodeInfo(ctx, node.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "inactive", got.Status)
+		assert.Equal(t, int64(7), got.CurrentLoad)
+
+		// Maintenance is kept whatever the node reports
+		got.Status = "maintenance"
+		require.NoError(t, m.UpdateNode(ctx, got))
+		require.NoError(t, m.UpdateNodeHeartbeat(ctx, node.ID, "active", 8, nil))
+		got, err = m.GetNodeInfo(ctx, node.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "maintenance", got.Status)
+		assert.Equal(t, int64(8), got.CurrentLoad, "UpdateNode keeps the reported load")
+
+		assert.NoError(t, m.UpdateNodeHeartbeat(ctx, uuid.New(), "active", 0, nil), "unknown nodes are ignored")
+	})
+
+	t.Run("List", func(t *testing.T) {
+		other := &db.Node{Name: "app-2", Location: "localhost:2", Capacity: 100, Status: "active"}
+		require.NoError(t, m.RegisterNode(ctx, other))
+		nodes, err := m.ListNodes(ctx)
+		require.NoError(t, err)
+		require.Len(t, nodes, 2)
+		assert.Equal(t, node.ID, nodes[0].ID, "oldest first")
+	})
+}
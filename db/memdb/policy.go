@@ -0,0 +1,87 @@
+package memdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+)
+
+// defaultFailureLimit caps ListFailureReports when no limit is given
+const defaultFailureLimit = 50
+
+// SetPolicy records a new policy of its type, superseding earlier ones
+func (m *DB) SetPolicy(ctx context.Context, policy *db.Policy) error {
+	if policy.ID == uuid.Nil {
+		policy.ID = uuid.New()
+	}
+	return m.write(db.Change{Entity: db.ChangePolicy, ID: policy.PolicyType}, func(s *state) ([]*entry, error) {
+		now := m.now()
+		stored := clonePolicy(policy)
+		stored.CreatedAt, stored.UpdatedAt = now, now
+		policy.CreatedAt, policy.UpdatedAt = now, now
+		return []*entry{{Policy: stored}}, nil
+	})
+}
+
+// GetPolicy returns the latest policy of a type, or nil if none was set
+func (m *DB) GetPolicy(ctx context.Context, policyType string) (*db.Policy, error) {
+	var policy *db.Policy
+	m.read(func(s *state) {
+		if history := s.Policies[policyType]; len(history) > 0 {
+			policy = clonePolicy(history[len(history)-1])
+		}
+	})
+	return policy, nil
+}
+
+// ListActivePolicies returns the latest policy of each type, ordered by type
+func (m *DB) ListActivePolicies(ctx context.Context) ([]*db.Policy, error) {
+	var policies []*db.Policy
+	m.read(func(s *state) {
+		for _, history := range s.Policies {
+			policies = append(policies, clonePolicy(history[len(history)-1]))
+		}
+	})
+	sort.Slice(policies, func(i, j int) bool { return policies[i].PolicyType < policies[j].PolicyType })
+	return policies, nil
+}
+
+// ReportFailure records a failure reported against a node or shard
+func (m *DB) ReportFailure(ctx context.Context, failureType string, entityID uuid.UUID, details json.RawMessage) error {
+	report := &db.FailureReport{
+		ID:        uuid.New(),
+		Type:      failureType,
+		EntityID:  entityID,
+		Details:   bytes.Clone(details),
+		CreatedAt: m.now(),
+	}
+	return m.write(db.Change{}, func(s *state) ([]*entry, error) {
+		return []*entry{{Failure: report}}, nil
+	})
+}
+
+// ListFailureReports returns the most recent failure reports, newest first
+func (m *DB) ListFailureReports(ctx context.Context, limit int) ([]*db.FailureReport, error) {
+	if limit <= 0 {
+		limit = defaultFailureLimit
+	}
+	var reports []*db.FailureReport
+	m.read(func(s *state) {
+		for i := len(s.Failures) - 1; i >= 0 && len(reports) < limit; i-- {
+			copied := *s.Failures[i]
+			copied.Details = bytes.Clone(copied.Details)
+			reports = append(reports, &copied)
+		}
+	})
+	return reports, nil
+}
+
+func clonePolicy(policy *db.Policy) *db.Policy {
+	copied := *policy
+	copied.Parameters = bytes.Clone(policy.Parameters)
+	return &copied
+}
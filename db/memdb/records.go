@@ -0,0 +1,118 @@
+package memdb
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+)
+
+// defaultAuditLimit caps ListAuditEvents when the filter sets no limit
+const defaultAuditLimit = 100
+
+// RecordReconcileAction stores a correction made by the reconciler
+func (m *DB) RecordReconcileAction(ctx context.Context, action *db.ReconcileAction) error {
+	if action.ID == uuid.Nil {
+		action.ID = uuid.New()
+	}
+	action.CreatedAt = m.now()
+	stored := *action
+	return m.write(db.Change{}, func(s *state) ([]*entry, error) {
+		return []*entry{{ReconcileAction: &stored}}, nil
+	})
+}
+
+// ListReconcileActions returns the corrections recorded for a node, newest first
+func (m *DB) ListReconcileActions(ctx context.Context, nodeID uuid.UUID) ([]*db.ReconcileAction, error) {
+	var actions []*db.ReconcileAction
+	m.read(func(s *state) {
+		for i := len(s.ReconcileActions) - 1; i >= 0; i-- {
+			if action := s.ReconcileActions[i]; action.NodeID == nodeID {
+				copied := *action
+				actions = append(actions, &copied)
+			}
+		}
+	})
+	return actions, nil
+}
+
+// RecordAuditEvent appends an event to the audit log
+func (m *DB) RecordAuditEvent(ctx context.Context, event *db.AuditEvent) error {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	event.CreatedAt = event.CreatedAt.UTC()
+	stored := *event
+	stored.Request = bytes.Clone(event.Request)
+	return m.write(db.Change{}, func(s *state) ([]*entry, error) {
+		return []*entry{{AuditEvent: &stored}}, nil
+	})
+}
+
+// ListAuditEvents returns audit events matching filter, newest first
+func (m *DB) ListAuditEvents(ctx context.Context, filter db.AuditFilter) ([]*db.AuditEvent, error) {
+	var events []*db.AuditEvent
+	m.read(func(s *state) {
+		for _, event := range s.AuditEvents {
+			switch {
+			case filter.EntityID != "" && event.EntityID != filter.EntityID:
+			case filter.Actor != "" && event.Actor != filter.Actor:
+			case !filter.Since.IsZero() && event.CreatedAt.Before(filter.Since):
+			case !filter.Until.IsZero() && !event.CreatedAt.Before(filter.Until):
+			default:
+				copied := *event
+				copied.Request = bytes.Clone(event.Request)
+				events = append(events, &copied)
+			}
+		}
+	})
+	// Callers may backdate events, so order by time rather than by insertion
+	sort.SliceStable(events, func(i, j int) bool { return events[i].CreatedAt.After(events[j].CreatedAt) })
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
+
+// RecordShardLoads stores the latest load sample for each shard, replacing
+// any earlier sample. Samples without a ReportedAt are stamped with now.
+func (m *DB) RecordShardLoads(ctx context.Context, loads []*db.ShardLoad) error {
+	if len(loads) == 0 {
+		return nil
+	}
+	now := m.now()
+	entries := make([]*entry, 0, len(loads))
+	for _, load := range loads {
+		if load.ReportedAt.IsZero() {
+			load.ReportedAt = now
+		}
+		stored := *load
+		stored.ReportedAt = load.ReportedAt.UTC()
+		entries = append(entries, &entry{ShardLoad: &stored})
+	}
+	return m.write(db.Change{}, func(s *state) ([]*entry, error) {
+		return entries, nil
+	})
+}
+
+// ListShardLoads returns the latest load sample of every shard that has one
+func (m *DB) ListShardLoads(ctx context.Context) ([]*db.ShardLoad, error) {
+	var loads []*db.ShardLoad
+	m.read(func(s *state) {
+		for _, load := range s.ShardLoads {
+			copied := *load
+			loads = append(loads, &copied)
+		}
+	})
+	return loads, nil
+}
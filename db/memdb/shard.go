@@ -0,0 +1,244 @@
+package memdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+)
+
+// RegisterShard adds a shard, generating its ID if it has none
+func (m *DB) RegisterShard(ctx context.Context, shard *db.Shard) error {
+	if !db.ValidShardStatus(shard.Status) {
+		return invalidShardStatus(shard.Status)
+	}
+	if shard.ID == uuid.Nil {
+		shard.ID = uuid.New()
+	}
+	return m.write(db.Change{Entity: db.ChangeShard, ID: shard.ID.String()}, func(s *state) ([]*entry, error) {
+		if _, ok := s.Shards[shard.ID]; ok {
+			return nil, fmt.Errorf("shard %s already exists", shard.ID)
+		}
+		now := m.now()
+		stored := cloneShard(shard)
+		stored.CreatedAt, stored.UpdatedAt = now, now
+		shard.CreatedAt, shard.UpdatedAt = now, now
+		return []*entry{{Shard: stored}}, nil
+	})
+}
+
+// ListShards returns every shard, oldest first
+func (m *DB) ListShards(ctx context.Context) ([]*db.Shard, error) {
+	var shards []*db.Shard
+	m.read(func(s *state) {
+		for _, shard := range s.Shards {
+			shards = append(shards, cloneShard(shard))
+		}
+	})
+	sort.Slice(shards, func(i, j int) bool {
+		if !shards[i].CreatedAt.Equal(shards[j].CreatedAt) {
+			return shards[i].CreatedAt.Before(shards[j].CreatedAt)
+		}
+		return shards[i].ID.String() < shards[j].ID.String()
+	})
+	return shards, nil
+}
+
+// GetShardInfo returns a shard, or nil if there is none with shardID
+func (m *DB) GetShardInfo(ctx context.Context, shardID uuid.UUID) (*db.Shard, error) {
+	var shard *db.Shard
+	m.read(func(s *state) {
+		if sh, ok := s.Shards[shardID]; ok {
+			shard = cloneShard(sh)
+		}
+	})
+	return shard, nil
+}
+
+// AssignShard moves a shard to a node, recording its previous state as a
+// version
+func (m *DB) AssignShard(ctx context.Context, shardID, nodeID uuid.UUID) error {
+	return m.updateShardVersioned(shardID, func(shard *db.Shard) error {
+		shard.NodeID = &nodeID
+		return nil
+	})
+}
+
+// MoveShard moves a shard from one node to another, recording its previous
+// state as a single version. It returns db.ErrShardMoved if the shard is not
+// on fromNodeID and sql.ErrNoRows if there is no such shard.
+func (m *DB) MoveShard(ctx context.Context, shardID, fromNodeID, toNodeID uuid.UUID) error {
+	var found bool
+	err := m.updateShardVersioned(shardID, func(shard *db.Shard) error {
+		found = true
+		if shard.NodeID == nil || *shard.NodeID != fromNodeID {
+			return db.ErrShardMoved
+		}
+		shard.NodeID = &toNodeID
+		return nil
+	})
+	if err == nil && !found {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// UpdateShardStatus sets a shard's status, recording its previous state as
+// a version
+func (m *DB) UpdateShardStatus(ctx context.Context, shardID uuid.UUID, status string) error {
+	return m.updateShardVersioned(shardID, func(shard *db.Shard) error {
+		if !db.ValidShardStatus(status) {
+			return invalidShardStatus(status)
+		}
+		shard.Status = status
+		shard.UpdatedAt = m.now()
+		return nil
+	})
+}
+
+// UpdateShardVersion replaces a shard's type, size, node, status and
+// metadata, recording its previous state as a version. It returns
+// sql.ErrNoRows if there is no such shard.
+func (m *DB) UpdateShardVersion(ctx context.Context, shard *db.Shard) error {
+	var found bool
+	err := m.updateShardVersioned(shard.ID, func(stored *db.Shard) error {
+		if !db.ValidShardStatus(shard.Status) {
+			return invalidShardStatus(shard.Status)
+		}
+		found = true
+		stored.Type = shard.Type
+		stored.Size = shard.Size
+		stored.NodeID = cloneID(shard.NodeID)
+		stored.Status = shard.Status
+		stored.Metadata = cloneMetadata(shard.Metadata)
+		shard.Version = stored.Version
+		return nil
+	})
+	if err == nil && !found {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// RollbackShardVersion restores a shard to a recorded version, recording
+// its current state as a version first. It returns sql.ErrNoRows if the
+// version was never recorded.
+func (m *DB) RollbackShardVersion(ctx context.Context, shardID uuid.UUID, version int) error {
+	var sv *db.ShardVersion
+	m.read(func(s *state) { sv = findVersion(s, shardID, version) })
+	if sv == nil {
+		return sql.ErrNoRows
+	}
+	return m.updateShardVersioned(shardID, func(shard *db.Shard) error {
+		shard.Type = sv.Type
+		shard.Size = sv.Size
+		shard.NodeID = cloneID(sv.NodeID)
+		shard.Status = sv.Status
+		shard.Metadata = cloneMetadata(sv.Metadata)
+		return nil
+	})
+}
+
+// GetShardVersion returns a recorded version of a shard, or nil if there is
+// none
+func (m *DB) GetShardVersion(ctx context.Context, shardID uuid.UUID, version int) (*db.ShardVersion, error) {
+	var sv *db.ShardVersion
+	m.read(func(s *state) {
+		if found := findVersion(s, shardID, version); found != nil {
+			sv = cloneVersion(found)
+		}
+	})
+	return sv, nil
+}
+
+// ListShardVersions returns every recorded version of a shard, newest first
+func (m *DB) ListShardVersions(ctx context.Context, shardID uuid.UUID) ([]*db.ShardVersion, error) {
+	var versions []*db.ShardVersion
+	m.read(func(s *state) {
+		recorded := s.ShardVersions[shardID]
+		for i := len(recorded) - 1; i >= 0; i-- {
+			versions = append(versions, cloneVersion(recorded[i]))
+		}
+	})
+	return versions, nil
+}
+
+// updateShardVersioned records a shard's current state as a version, then
+// applies update to a copy and bumps its version. A missing shard is left
+// alone without error, as an UPDATE matching no rows would be.
+func (m *DB) updateShardVersioned(shardID uuid.UUID, update func(shard *db.Shard) error) error {
+	return m.write(db.Change{Entity: db.ChangeShard, ID: shardID.String()}, func(s *state) ([]*entry, error) {
+		current, ok := s.Shards[shardID]
+		if !ok {
+			return nil, nil
+		}
+		snapshot := &db.ShardVersion{
+			ID:        uuid.New(),
+			ShardID:   current.ID,
+			Version:   current.Version,
+			Type:      current.Type,
+			Size:      current.Size,
+			NodeID:    cloneID(current.NodeID),
+			Status:    current.Status,
+			Metadata:  cloneMetadata(current.Metadata),
+			CreatedAt: m.now(),
+		}
+		stored := cloneShard(current)
+		stored.Version++
+		if err := update(stored); err != nil {
+			return nil, err
+		}
+		return []*entry{{ShardVersion: snapshot}, {Shard: stored}}, nil
+	})
+}
+
+// findVersion finds a recorded version of a shard
+func findVersion(s *state, shardID uuid.UUID, version int) *db.ShardVersion {
+	for _, sv := range s.ShardVersions[shardID] {
+		if sv.Version == version {
+			return sv
+		}
+	}
+	return nil
+}
+
+func invalidShardStatus(status string) error {
+	return fmt.Errorf("%w: invalid shard status %q", db.ErrInvalidStatus, status)
+}
+
+func cloneShard(shard *db.Shard) *db.Shard {
+	copied := *shard
+	copied.NodeID = cloneID(shard.NodeID)
+	copied.Metadata = cloneMetadata(shard.Metadata)
+	copied.ResourceDemand = cloneResources(shard.ResourceDemand)
+	return &copied
+}
+
+func cloneVersion(sv *db.ShardVersion) *db.ShardVersion {
+	copied := *sv
+	copied.NodeID = cloneID(sv.NodeID)
+	copied.Metadata = cloneMetadata(sv.Metadata)
+	return &copied
+}
+
+func cloneID(id *uuid.UUID) *uuid.UUID {
+	if id == nil {
+		return nil
+	}
+	copied := *id
+	return &copied
+}
+
+// cloneMetadata copies metadata, reading missing metadata as an empty object
+// like the SQL backends do
+func cloneMetadata(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return json.RawMessage("{}")
+	}
+	return bytes.Clone(raw)
+}
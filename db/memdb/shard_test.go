@@ -0,0 +1,78 @@
+package memdb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShards(t *testing.T) {
+	ctx := context.Background()
+	m := New()
+	node1, node2 := uuid.New(), uuid.New()
+
+	shard := &db.Shard{Type: "kv", Size: 10, NodeID: &node1, Status: "active", Version: 1}
+	require.NoError(t, m.RegisterShard(ctx, shard))
+	assert.NotEqual(t, uuid.Nil, shard.ID)
+
+	got, err := m.GetShardInfo(ctx, shard.ID)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(got.Metadata), "missing metadata reads as an empty object")
+
+	t.Run("Versions", func(t *testing.T) {
+		require.NoError(t, m.AssignShard(ctx, shard.ID, node2))
+		require.NoError(t, m.UpdateShardStatus(ctx, shard.ID, "migrating"))
+		err := m.UpdateShardStatus(ctx, shard.ID, "sleeping")
+		assert.True(t, errors.Is(err, db.ErrInvalidStatus), "got %v", err)
+
+		got, err := m.GetShardInfo(ctx, shard.ID)
+		require.NoError(t, err)
+		assert.Equal(t, node2, *got.NodeID)
+		assert.Equal(t, 3, got.Version)
+
+		versions, err := m.ListShardVersions(ctx, shard.ID)
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.Equal(t, 2, versions[0].Version, "newest first")
+		assert.Equal(t, node1, *versions[1].NodeID)
+	})
+
+	t.Run("UpdateAndRollback", func(t *testing.T) {
+		update := &db.Shard{ID: shard.ID, Type: "kv", Size: 20, Status: "active", Metadata: json.RawMessage(`{"a":1}`)}
+		require.NoError(t, m.UpdateShardVersion(ctx, update))
+		assert.Equal(t, 4, update.Version)
+
+		require.NoError(t, m.RollbackShardVersion(ctx, shard.ID, 1))
+		got, err := m.GetShardInfo(ctx, shard.ID)
+		require.NoError(t, err)
+		assert.Equal(t, node1, *got.NodeID)
+		assert.Equal(t, int64(10), got.Size)
+		assert.Equal(t, 5, got.Version)
+
+		v4, err := m.GetShardVersion(ctx, shard.ID, 4)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"a":1}`, string(v4.Metadata))
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		missing := uuid.New()
+		assert.NoError(t, m.AssignShard(ctx, missing, node1))
+		assert.NoError(t, m.UpdateShardStatus(ctx, missing, "active"))
+		assert.Equal(t, sql.ErrNoRows, m.UpdateShardVersion(ctx, &db.Shard{ID: missing, Status: "active"}))
+		assert.Equal(t, sql.ErrNoRows, m.RollbackShardVersion(ctx, shard.ID, 99))
+		sv, err := m.GetShardVersion(ctx, shard.ID, 99)
+		require.NoError(t, err)
+		assert.Nil(t, sv)
+
+		shards, err := m.ListShards(ctx)
+		require.NoError(t, err)
+		assert.Len(t, shards, 1)
+	})
+}
@@ -0,0 +1,256 @@
+package memdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+)
+
+const (
+	snapshotFile = "snapshot.json"
+	logFile      = "log.jsonl"
+)
+
+// formatVersion is the on-disk format written by this release. Data
+// directories of an older format are upgraded by upgrades when opened.
+const formatVersion = 1
+
+// upgrades[v-1] converts state of format v to format v+1
+var upgrades []func(s *state) error
+
+// state is everything the database holds, written whole as the snapshot.
+// Rows in it are replaced rather than modified, so they may be shared
+// with log records.
+type state struct {
+	Format int `json:"format"`
+	// Seq is the sequence number of the last record applied
+	Seq int64 `json:"seq"`
+
+	Nodes            map[uuid.UUID]*db.Node           `json:"nodes"`
+	Shards           map[uuid.UUID]*db.Shard          `json:"shards"`
+	ShardVersions    map[uuid.UUID][]*db.ShardVersion `json:"shard_versions"`
+	Policies         map[string][]*db.Policy          `json:"policies"`
+	Failures         []*db.FailureReport              `json:"failures"`
+	Leases           map[uuid.UUID]*db.ShardLease     `json:"leases"`
+	Leader           *db.LeaderLease                  `json:"leader"`
+	ReconcileActions []*db.ReconcileAction            `json:"reconcile_actions"`
+	AuditEvents      []*db.AuditEvent                 `json:"audit_events"`
+	ShardLoads       map[uuid.UUID]*db.ShardLoad      `json:"shard_loads"`
+}
+
+func newState() *state {
+	return &state{
+		Format:        formatVersion,
+		Nodes:         make(map[uuid.UUID]*db.Node),
+		Shards:        make(map[uuid.UUID]*db.Shard),
+		ShardVersions: make(map[uuid.UUID][]*db.ShardVersion),
+		Policies:      make(map[string][]*db.Policy),
+		Leases:        make(map[uuid.UUID]*db.ShardLease),
+		ShardLoads:    make(map[uuid.UUID]*db.ShardLoad),
+	}
+}
+
+// entry is one row written by a mutation. Exactly one field is set, and
+// rows are logged whole, so replaying an entry puts or appends that row.
+type entry struct {
+	Node            *db.Node            `json:"node,omitempty"`
+	Shard           *db.Shard           `json:"shard,omitempty"`
+	ShardVersion    *db.ShardVersion    `json:"shard_version,omitempty"`
+	Policy          *db.Policy          `json:"policy,omitempty"`
+	Failure         *db.FailureReport   `json:"failure,omitempty"`
+	Lease           *db.ShardLease      `json:"lease,omitempty"`
+	Leader          *db.LeaderLease     `json:"leader,omitempty"`
+	LeaderReleased  bool                `json:"leader_released,omitempty"`
+	ReconcileAction *db.ReconcileAction `json:"reconcile_action,omitempty"`
+	AuditEvent      *db.AuditEvent      `json:"audit_event,omitempty"`
+	ShardLoad       *db.ShardLoad       `json:"shard_load,omitempty"`
+}
+
+// record is one line of the log: the entries of a single mutation, which
+// are replayed together or not at all
+type record struct {
+	Seq     int64    `json:"seq"`
+	Entries []*entry `json:"entries"`
+}
+
+// apply writes a record's rows into the state
+func (s *state) apply(rec *record) {
+	for _, e := range rec.Entries {
+		switch {
+		case e.Node != nil:
+			s.Nodes[e.Node.ID] = e.Node
+		case e.Shard != nil:
+			s.Shards[e.Shard.ID] = e.Shard
+		case e.ShardVersion != nil:
+			id := e.ShardVersion.ShardID
+			s.ShardVersions[id] = append(s.ShardVersions[id], e.ShardVersion)
+		case e.Policy != nil:
+			s.Policies[e.Policy.PolicyType] = append(s.Policies[e.Policy.PolicyType], e.Policy)
+		case e.Failure != nil:
+			s.Failures = append(s.Failures, e.Failure)
+		case e.Lease != nil:
+			s.Leases[e.Lease.ShardID] = e.Lease
+		case e.Leader != nil:
+			s.Leader = e.Leader
+		case e.LeaderReleased:
+			s.Leader = nil
+		case e.ReconcileAction != nil:
+			s.ReconcileActions = append(s.ReconcileActions, e.ReconcileAction)
+		case e.AuditEvent != nil:
+			s.AuditEvents = append(s.AuditEvents, e.AuditEvent)
+		case e.ShardLoad != nil:
+			s.ShardLoads[e.ShardLoad.ShardID] = e.ShardLoad
+		}
+	}
+	s.Seq = rec.Seq
+}
+
+// normalize undoes what a JSON round trip changes: absent raw JSON comes
+// back as the literal null
+func (s *state) normalize() {
+	for _, shard := range s.Shards {
+		shard.Metadata = nullToNil(shard.Metadata)
+	}
+	for _, versions := range s.ShardVersions {
+		for _, sv := range versions {
+			sv.Metadata = nullToNil(sv.Metadata)
+		}
+	}
+	for _, policies := range s.Policies {
+		for _, policy := range policies {
+			policy.Parameters = nullToNil(policy.Parameters)
+		}
+	}
+	for _, report := range s.Failures {
+		report.Details = nullToNil(report.Details)
+	}
+	for _, event := range s.AuditEvents {
+		event.Request = nullToNil(event.Request)
+	}
+}
+
+func nullToNil(raw json.RawMessage) json.RawMessage {
+	if string(raw) == "null" {
+		return nil
+	}
+	return raw
+}
+
+// load reads the snapshot in dir and replays the log written after it,
+// upgrading the result to formatVersion. A missing snapshot is an empty
+// database.
+func load(dir string) (*state, error) {
+	s := newState()
+	b, err := os.ReadFile(filepath.Join(dir, snapshotFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(b, s); err != nil {
+			return nil, fmt.Errorf("reading %s: %w", snapshotFile, err)
+		}
+	}
+	if s.Format > formatVersion {
+		return nil, fmt.Errorf("%s has format %d, newer than the %d this release understands; upgrade the server",
+			dir, s.Format, formatVersion)
+	}
+	if err := replay(filepath.Join(dir, logFile), s); err != nil {
+		return nil, err
+	}
+	s.normalize()
+	for s.Format < formatVersion {
+		if err := upgrades[s.Format-1](s); err != nil {
+			return nil, fmt.Errorf("upgrading %s to format %d: %w", dir, s.Format+1, err)
+		}
+		s.Format++
+	}
+	return s, nil
+}
+
+// replay applies the records of the log at path newer than the state. A
+// torn last line, left by a crash in the middle of a write, is ignored.
+func replay(path string, s *state) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		b, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// Whatever follows the last newline was never completely written
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var rec record
+		if err := json.Unmarshal(b, &rec); err != nil {
+			return fmt.Errorf("%s line %d: %w", logFile, line, err)
+		}
+		if rec.Seq > s.Seq {
+			s.apply(&rec)
+		}
+	}
+}
+
+// writeSnapshot atomically replaces the snapshot in dir with s
+func writeSnapshot(dir string, s *state) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, snapshotFile+".tmp")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, snapshotFile)); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir makes a rename within dir durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// encodeRecord renders a record as one log line
+func encodeRecord(rec *record) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(rec); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
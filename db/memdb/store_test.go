@@ -0,0 +1,220 @@
+package memdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// crash abandons a persistent database without a final snapshot, as if the
+// process died
+func crash(t *testing.T, m *DB) {
+	close(m.stop)
+	<-m.stopped
+	require.NoError(t, m.log.Close())
+	require.NoError(t, m.dirLock.Close())
+}
+
+// syncFailingLog fails the next Sync while failSync is set
+type syncFailingLog struct {
+	*os.File
+	failSync bool
+}
+
+func (l *syncFailingLog) Sync() error {
+	if l.failSync {
+		l.failSync = false
+		return errors.New("input/output error")
+	}
+	return l.File.Sync()
+}
+
+// populate writes a node, a shard with a version, a policy and an audit
+// event, returning the shard
+func populate(t *testing.T, m *DB) *db.Shard {
+	ctx := context.Background()
+	node := &db.Node{Name: "app-1", Location: "localhost:1", Capacity: 10, Status: "active"}
+	require.NoError(t, m.RegisterNode(ctx, node))
+	shard := &db.Shard{Type: "kv", Size: 1, NodeID: &node.ID, Status: "active", Version: 1,
+		Metadata: json.RawMessage(`{"a":1}`)}
+	require.NoError(t, m.RegisterShard(ctx, shard))
+	require.NoError(t, m.UpdateShardStatus(ctx, shard.ID, "migrating"))
+	require.NoError(t, m.SetPolicy(ctx, &db.Policy{PolicyType: "placement", Parameters: json.RawMessage(`{"v":1}`)}))
+	require.NoError(t, m.RecordAuditEvent(ctx, &db.AuditEvent{Actor: "alice", Method: "/x", Result: "ok"}))
+	return shard
+}
+
+// assertPopulated checks that everything written by populate is there
+func assertPopulated(t *testing.T, m *DB, shard *db.Shard) {
+	ctx := context.Background()
+	nodes, err := m.ListNodes(ctx)
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, db.Resources{}, nodes[0].ResourceCapacity)
+
+	got, err := m.GetShardInfo(ctx, shard.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "migrating", got.Status)
+	assert.Equal(t, 2, got.Version)
+	assert.JSONEq(t, `{"a":1}`, string(got.Metadata))
+	assert.Equal(t, nodes[0].ID, *got.NodeID)
+
+	versions, err := m.ListShardVersions(ctx, shard.ID)
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+	policy, err := m.GetPolicy(ctx, "placement")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"v":1}`, string(policy.Parameters))
+	events, err := m.ListAuditEvents(ctx, db.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Nil(t, events[0].Request)
+}
+
+func TestPersistence(t *testing.T) {
+	t.Run("Snapshot", func(t *testing.T) {
+		dir := t.TempDir()
+		m, err := Open(dir, time.Hour)
+		require.NoError(t, err)
+		shard := populate(t, m)
+		require.NoError(t, m.Close())
+
+		info, err := os.Stat(filepath.Join(dir, logFile))
+		require.NoError(t, err)
+		assert.Zero(t, info.Size(), "the log starts over after a snapshot")
+
+		m, err = Open(dir, time.Hour)
+		require.NoError(t, err)
+		defer m.Close()
+		assertPopulated(t, m, shard)
+	})
+
+	t.Run("LogReplay", func(t *testing.T) {
+		dir := t.TempDir()
+		m, err := Open(dir, time.Hour)
+		require.NoError(t, err)
+		shard := populate(t, m)
+		crash(t, m)
+
+		m, err = Open(dir, time.Hour)
+		require.NoError(t, err)
+		defer m.Close()
+		assertPopulated(t, m, shard)
+	})
+
+	t.Run("LogAfterSnapshot", func(t *testing.T) {
+		dir := t.TempDir()
+		m, err := Open(dir, time.Hour)
+		require.NoError(t, err)
+		shard := populate(t, m)
+		require.NoError(t, m.Snapshot())
+		require.NoError(t, m.AssignShard(context.Background(), shard.ID, uuid.New()))
+		crash(t, m)
+
+		m, err = Open(dir, time.Hour)
+		require.NoError(t, err)
+		defer m.Close()
+		got, err := m.GetShardInfo(context.Background(), shard.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, got.Version)
+	})
+
+	t.Run("TornLog", func(t *testing.T) {
+		dir := t.TempDir()
+		m, err := Open(dir, time.Hour)
+		require.NoError(t, err)
+		shard := populate(t, m)
+		crash(t, m)
+		f, err := os.OpenFile(filepath.Join(dir, logFile), os.O_WRONLY|os.O_APPEND, 0)
+		require.NoError(t, err)
+		_, err = f.WriteString(`{"seq":99,"entries":[{"shard":`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		m, err = Open(dir, time.Hour)
+		require.NoError(t, err)
+		defer m.Close()
+		assertPopulated(t, m, shard)
+	})
+
+	t.Run("Periodic", func(t *testing.T) {
+		dir := t.TempDir()
+		m, err := Open(dir, 10*time.Millisecond)
+		require.NoError(t, err)
+		defer m.Close()
+		require.NoError(t, m.SetPolicy(context.Background(), &db.Policy{PolicyType: "placement"}))
+		assert.Eventually(t, func() bool {
+			info, err := os.Stat(filepath.Join(dir, logFile))
+			return err == nil && info.Size() == 0
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("FailedSync", func(t *testing.T) {
+		ctx := context.Background()
+		dir := t.TempDir()
+		m, err := Open(dir, time.Hour)
+		require.NoError(t, err)
+		log := &syncFailingLog{File: m.log.(*os.File), failSync: true}
+		m.log = log
+		assert.Error(t, m.SetPolicy(ctx, &db.Policy{PolicyType: "lost"}))
+		require.NoError(t, m.SetPolicy(ctx, &db.Policy{PolicyType: "kept"}))
+		crash(t, m)
+
+		// The failed write is neither replayed nor shadows the next one
+		m, err = Open(dir, time.Hour)
+		require.NoError(t, err)
+		defer m.Close()
+		lost, err := m.GetPolicy(ctx, "lost")
+		require.NoError(t, err)
+		assert.Nil(t, lost)
+		kept, err := m.GetPolicy(ctx, "kept")
+		require.NoError(t, err)
+		assert.NotNil(t, kept)
+	})
+
+	t.Run("Locked", func(t *testing.T) {
+		dir := t.TempDir()
+		m, err := Open(dir, time.Hour)
+		require.NoError(t, err)
+		_, err = Open(dir, time.Hour)
+		assert.ErrorContains(t, err, "in use")
+		require.NoError(t, m.Close())
+
+		m, err = Open(dir, time.Hour)
+		require.NoError(t, err, "Close releases the lock")
+		require.NoError(t, m.Close())
+	})
+
+	t.Run("NewerFormat", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, snapshotFile), []byte(`{"format":99}`), 0o600))
+		_, err := Open(dir, time.Hour)
+		assert.ErrorContains(t, err, "newer")
+	})
+}
+
+func TestOpenDSN(t *testing.T) {
+	assert.True(t, IsDSN("mem:"))
+	assert.False(t, IsDSN("file:test.db"))
+
+	m, err := OpenDSN("mem:")
+	require.NoError(t, err)
+	assert.Nil(t, m.log, "mem: alone is not persisted")
+	require.NoError(t, m.Close())
+
+	dir := filepath.Join(t.TempDir(), "data")
+	m, err = OpenDSN("mem:" + dir)
+	require.NoError(t, err)
+	require.NoError(t, m.Close())
+	assert.FileExists(t, filepath.Join(dir, snapshotFile))
+}
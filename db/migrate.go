@@ -0,0 +1,292 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// migrationFiles holds one directory of numbered migrations per dialect,
+// named like 0002_add_widgets.up.sql and 0002_add_widgets.down.sql. Every
+// version must exist for every dialect.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// migrationLock keys the Postgres advisory lock that serializes replicas
+// migrating at the same time
+const migrationLock = 7427_0001
+
+// migration is a numbered schema change and its undo
+type migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// loadMigrations reads a dialect's migrations, ordered by version. Versions
+// must run from 1 without gaps and each needs an up and a down script.
+func loadMigrations(dialect string) ([]migration, error) {
+	dir := "migrations/" + dialect
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*migration)
+	for _, entry := range entries {
+		m := migrationName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file %s/%s", dir, entry.Name())
+		}
+		version, _ := strconv.Atoi(m[1])
+		data, err := migrationFiles.ReadFile(dir + "/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d of %s is named both %s and %s", version, dialect, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(data)
+		} else {
+			mig.Down = string(data)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, mig := range migrations {
+		if mig.Version != i+1 {
+			return nil, fmt.Errorf("%s migrations skip from version %d to %d", dialect, i, mig.Version)
+		}
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("%s migration %d_%s needs both an up and a down script", dialect, mig.Version, mig.Name)
+		}
+	}
+	return migrations, nil
+}
+
+// Migrate brings the schema up to the latest embedded migration. Migration 1
+// is the schema.sql and InitSQLiteSchema of releases before migrations, so a
+// database created from them, which has tables but no schema_migrations, is
+// recorded as being at version 1 and upgraded from there. Migrate fails if
+// the database has a version this build does not know, which means a newer
+// release migrated it.
+func (db *DB) Migrate(ctx context.Context) error {
+	migrations, err := loadMigrations(db.dialect().String())
+	if err != nil {
+		return err
+	}
+	if err := db.checkServerVersion(ctx); err != nil {
+		return err
+	}
+	if err := db.prepareMigrations(ctx); err != nil {
+		return err
+	}
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkKnownVersions(applied, len(migrations)); err != nil {
+		return err
+	}
+
+	for _, mig := range migrations {
+		if applied[mig.Version] {
+			continue
+		}
+		err := db.inMigrationTx(ctx, mig.Version, true, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, mig.Version, mig.Name)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		db.log().InfoContext(ctx, "Applied schema migration", "version", mig.Version, "name", mig.Name)
+	}
+	return nil
+}
+
+// MigrateDown undoes applied migrations, newest first, until the schema is
+// at version target. Target 0 drops the whole schema.
+func (db *DB) MigrateDown(ctx context.Context, target int) error {
+	migrations, err := loadMigrations(db.dialect().String())
+	if err != nil {
+		return err
+	}
+	if err := db.prepareMigrations(ctx); err != nil {
+		return err
+	}
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkKnownVersions(applied, len(migrations)); err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0 && migrations[i].Version > target; i-- {
+		mig := migrations[i]
+		if !applied[mig.Version] {
+			continue
+		}
+		err := db.inMigrationTx(ctx, mig.Version, false, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("reverting migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+		db.log().InfoContext(ctx, "Reverted schema migration", "version", mig.Version, "name", mig.Name)
+	}
+	return nil
+}
+
+// SchemaVersion returns the newest applied migration, or 0 for an empty
+// database
+func (db *DB) SchemaVersion(ctx context.Context) (int, error) {
+	exists, err := db.tableExists(ctx, "schema_migrations")
+	if err != nil || !exists {
+		return 0, err
+	}
+	var version int
+	err = db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// minPostgresVersion is the oldest Postgres, as server_version_num, that
+// the migrations run on. Migration 4 adds an enum value inside its
+// transaction, which Postgres 12 first allowed.
+const minPostgresVersion = 120000
+
+// checkServerVersion refuses a Postgres server older than minPostgresVersion
+func (db *DB) checkServerVersion(ctx context.Context) error {
+	if db.dialect() != dialectPostgres {
+		return nil
+	}
+	var version int
+	if err := db.QueryRowContext(ctx, `SELECT current_setting('server_version_num')::int`).Scan(&version); err != nil {
+		return err
+	}
+	if version < minPostgresVersion {
+		return fmt.Errorf("postgres server_version_num %d is too old, migrations need Postgres 12 or later", version)
+	}
+	return nil
+}
+
+// prepareMigrations creates schema_migrations, baselining a database whose
+// schema predates it
+func (db *DB) prepareMigrations(ctx context.Context) error {
+	exists, err := db.tableExists(ctx, "schema_migrations")
+	if err != nil || exists {
+		return err
+	}
+	legacy, err := db.tableExists(ctx, "nodes")
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`)
+	if err != nil {
+		return err
+	}
+	if legacy {
+		db.log().WarnContext(ctx, "Found a schema without schema_migrations; recording it as version 1")
+		_, err = db.ExecContext(ctx, `
+			INSERT INTO schema_migrations (version, name) VALUES (1, 'initial')
+			ON CONFLICT (version) DO NOTHING`)
+	}
+	return err
+}
+
+func (db *DB) appliedMigrations(ctx context.Context) (map[int]bool, error) {
+	rows, err := db.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// checkKnownVersions refuses to touch a schema migrated by a newer build
+func checkKnownVersions(applied map[int]bool, latest int) error {
+	for version := range applied {
+		if version > latest {
+			return fmt.Errorf("database schema has migration %d but this build only knows migrations up to %d; "+
+				"it was migrated by a newer shardmanager, so upgrade instead of running this one", version, latest)
+		}
+	}
+	return nil
+}
+
+// inMigrationTx runs fn in a transaction unless another replica already
+// applied (up) or reverted (down) version while this one waited for the lock
+func (db *DB) inMigrationTx(ctx context.Context, version int, up bool, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if db.dialect() == dialectPostgres {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+			return err
+		}
+	}
+	var n int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = $1`, version).Scan(&n); err != nil {
+		return err
+	}
+	if (n > 0) == up {
+		return nil
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// tableExists reports whether the current schema has a table called name
+func (db *DB) tableExists(ctx context.Context, name string) (bool, error) {
+	var n int
+	if err := db.QueryRowContext(ctx, db.dialect().tableExistsQuery(), name).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
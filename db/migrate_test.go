@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationsMatchAcrossDialects(t *testing.T) {
+	postgres, err := loadMigrations("postgres")
+	require.NoError(t, err)
+	sqlite, err := loadMigrations("sqlite")
+	require.NoError(t, err)
+
+	require.Equal(t, len(postgres), len(sqlite), "every migration must exist for both dialects")
+	for i := range postgres {
+		assert.Equal(t, postgres[i].Version, sqlite[i].Version)
+		assert.Equal(t, postgres[i].Name, sqlite[i].Name)
+	}
+}
+
+func openEmptyDB(t *testing.T) *DB {
+	database, err := Open("file:" + filepath.Join(t.TempDir(), "shardmanager.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	db := openEmptyDB(t)
+	migrations, err := loadMigrations("sqlite")
+	require.NoError(t, err)
+	latest := len(migrations)
+
+	version, err := db.SchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	require.NoError(t, db.Migrate(ctx))
+	version, err = db.SchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, latest, version)
+
+	// Migrating again is a no-op that keeps data
+	node := &Node{ID: uuid.New(), Location: "localhost:1", Capacity: 1, Status: "active"}
+	require.NoError(t, db.RegisterNode(ctx, node))
+	require.NoError(t, db.Migrate(ctx))
+	got, err := db.GetNodeInfo(ctx, node.ID)
+	require.NoError(t, err)
+	assert.Equal(t, node.ID, got.ID)
+
+	// Down to nothing and back up again
+	require.NoError(t, db.MigrateDown(ctx, 0))
+	version, err = db.SchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+	exists, err := db.tableExists(ctx, "nodes")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, db.Migrate(ctx))
+	version, err = db.SchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, latest, version)
+}
+
+func TestMigrateUpgradesLegacySchema(t *testing.T) {
+	ctx := context.Background()
+	db := openEmptyDB(t)
+	migrations, err := loadMigrations("sqlite")
+	require.NoError(t, err)
+
+	// A database created by InitSQLiteSchema before migrations existed
+	_, err = db.ExecContext(ctx, migrations[0].Up)
+	require.NoError(t, err)
+	node := &Node{ID: uuid.New()}
+	_, err = db.ExecContext(ctx, `INSERT INTO nodes (id, location, capacity, status) VALUES ($1, 'localhost:1', 1, 'active')`, node.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, db.Migrate(ctx))
+	version, err := db.SchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, len(migrations), version)
+	var baseline string
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT name FROM schema_migrations WHERE version = 1`).Scan(&baseline))
+	assert.Equal(t, "initial", baseline)
+
+	// The existing row gains the defaults of the columns added since
+	got, err := db.GetNodeInfo(ctx, node.ID)
+	require.NoError(t, err)
+	assert.Equal(t, node.ID, got.ID)
+	assert.Equal(t, "push", got.AssignmentMode)
+	assert.Empty(t, got.ResourceCapacity)
+
+	// Names added by the upgrade stay unique
+	named := &Node{ID: uuid.New(), Name: "app-1", Location: "localhost:2", Capacity: 1, Status: "active"}
+	require.NoError(t, db.RegisterNode(ctx, named))
+	named.ID = uuid.New()
+	assert.Error(t, db.RegisterNode(ctx, named))
+}
+
+func TestMigrateRejectsNewerSchema(t *testing.T) {
+	ctx := context.Background()
+	db := openEmptyDB(t)
+	require.NoError(t, db.Migrate(ctx))
+
+	_, err := db.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES (9999, 'from_the_future')`)
+	require.NoError(t, err)
+
+	err = db.Migrate(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "9999")
+	assert.Error(t, db.MigrateDown(ctx, 0))
+}
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS failure_reports;
+DROP TABLE IF EXISTS policies;
+DROP TABLE IF EXISTS shard_migrations;
+DROP TABLE IF EXISTS shard_versions;
+DROP TABLE IF EXISTS shards;
+DROP TABLE IF EXISTS nodes;
+
+DROP FUNCTION IF EXISTS update_updated_at_column();
+DROP TYPE IF EXISTS shard_status;
+DROP TYPE IF EXISTS node_status;
@@ -0,0 +1,5 @@
+ALTER TABLE shards DROP COLUMN IF EXISTS resource_demand;
+ALTER TABLE nodes DROP COLUMN IF EXISTS resource_load;
+ALTER TABLE nodes DROP COLUMN IF EXISTS resource_capacity;
+ALTER TABLE nodes DROP COLUMN IF EXISTS assignment_mode;
+ALTER TABLE nodes DROP COLUMN IF EXISTS name;
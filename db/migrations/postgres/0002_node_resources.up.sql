@@ -0,0 +1,8 @@
+-- Node names, assignment modes and resource vectors. IF NOT EXISTS lets a
+-- schema created from an unreleased schema.sql that already has some of
+-- them upgrade too.
+ALTER TABLE nodes ADD COLUMN IF NOT EXISTS name VARCHAR(255) UNIQUE;
+ALTER TABLE nodes ADD COLUMN IF NOT EXISTS assignment_mode VARCHAR(10) NOT NULL DEFAULT 'push';
+ALTER TABLE nodes ADD COLUMN IF NOT EXISTS resource_capacity JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE nodes ADD COLUMN IF NOT EXISTS resource_load JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE shards ADD COLUMN IF NOT EXISTS resource_demand JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,79 @@
+-- Tables for leases, leader election, change propagation, load reports,
+-- the audit log and reconciler corrections. IF NOT EXISTS lets a schema
+-- created from an unreleased schema.sql that already has some of them
+-- upgrade too.
+
+-- Shard ownership leases
+CREATE TABLE IF NOT EXISTS shard_leases (
+    shard_id UUID PRIMARY KEY REFERENCES shards(id) ON DELETE CASCADE,
+    node_id UUID NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Manager leader election, one row shared by every replica
+CREATE TABLE IF NOT EXISTS leader_leases (
+    name VARCHAR(255) PRIMARY KEY,
+    holder_id VARCHAR(255) NOT NULL,
+    address VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Change events for instances that cannot LISTEN; Postgres instances are
+-- notified on the shardmanager_changes channel instead
+CREATE TABLE IF NOT EXISTS change_log (
+    id BIGSERIAL PRIMARY KEY,
+    entity VARCHAR(50) NOT NULL,
+    entity_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Latest per-shard load reported by heartbeat
+CREATE TABLE IF NOT EXISTS shard_loads (
+    shard_id UUID PRIMARY KEY,
+    node_id UUID NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+    qps DOUBLE PRECISION NOT NULL DEFAULT 0,
+    bytes_per_second BIGINT NOT NULL DEFAULT 0,
+    cpu_share DOUBLE PRECISION NOT NULL DEFAULT 0,
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    reported_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Audit log of mutating calls
+CREATE TABLE IF NOT EXISTS audit_log (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    actor VARCHAR(255) NOT NULL,
+    method VARCHAR(255) NOT NULL,
+    entity_type VARCHAR(50),
+    entity_id VARCHAR(255),
+    request JSONB,
+    result VARCHAR(50) NOT NULL,
+    error_message TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Reconciler corrections
+CREATE TABLE IF NOT EXISTS reconcile_actions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    node_id UUID NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+    shard_id UUID NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    role VARCHAR(50),
+    error_message TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reconcile_actions_node_id ON reconcile_actions(node_id);
+CREATE INDEX IF NOT EXISTS idx_shard_leases_node_id ON shard_leases(node_id);
+CREATE INDEX IF NOT EXISTS idx_shard_loads_node_id ON shard_loads(node_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity_id ON audit_log(entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+
+DROP TRIGGER IF EXISTS update_shard_leases_updated_at ON shard_leases;
+CREATE TRIGGER update_shard_leases_updated_at
+    BEFORE UPDATE ON shard_leases
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
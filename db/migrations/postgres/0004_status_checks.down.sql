@@ -0,0 +1,19 @@
+-- Postgres cannot drop an enum value, so shard_status is rebuilt without
+-- 'pending'. Releases before this migration do not know pending shards, so
+-- reverting fails while any shard or shard version is still pending.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM shards WHERE status = 'pending')
+        OR EXISTS (SELECT 1 FROM shard_versions WHERE status = 'pending') THEN
+        RAISE EXCEPTION 'cannot revert status checks while shards are pending; assign or remove them first';
+    END IF;
+END
+$$;
+
+ALTER TYPE shard_status RENAME TO shard_status_with_pending;
+CREATE TYPE shard_status AS ENUM ('active', 'migrating', 'failed', 'maintenance');
+ALTER TABLE shards ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE shards ALTER COLUMN status TYPE shard_status USING status::text::shard_status;
+ALTER TABLE shards ALTER COLUMN status SET DEFAULT 'active';
+ALTER TABLE shard_versions ALTER COLUMN status TYPE shard_status USING status::text::shard_status;
+DROP TYPE shard_status_with_pending;
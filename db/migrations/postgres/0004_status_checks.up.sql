@@ -0,0 +1,4 @@
+-- Shards registered without a node may be reported as pending; the SQLite
+-- migration adds checks matching these enums. Adding an enum value inside
+-- the migration's transaction needs Postgres 12 or later.
+ALTER TYPE shard_status ADD VALUE IF NOT EXISTS 'pending';
@@ -0,0 +1 @@
+ALTER TABLE nodes DROP COLUMN fault_domain;
@@ -0,0 +1,3 @@
+-- Nodes name the fault domain (rack, zone) they fail with; health checks
+-- that a shard's replicas are spread across them
+ALTER TABLE nodes ADD COLUMN fault_domain VARCHAR(255) NOT NULL DEFAULT '';
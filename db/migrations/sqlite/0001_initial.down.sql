@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS failure_reports;
+DROP TABLE IF EXISTS policies;
+DROP TABLE IF EXISTS shard_migrations;
+DROP TABLE IF EXISTS shard_versions;
+DROP TABLE IF EXISTS shards;
+DROP TABLE IF EXISTS nodes;
@@ -0,0 +1,6 @@
+ALTER TABLE shards DROP COLUMN resource_demand;
+ALTER TABLE nodes DROP COLUMN resource_load;
+ALTER TABLE nodes DROP COLUMN resource_capacity;
+ALTER TABLE nodes DROP COLUMN assignment_mode;
+DROP INDEX IF EXISTS idx_nodes_name;
+ALTER TABLE nodes DROP COLUMN name;
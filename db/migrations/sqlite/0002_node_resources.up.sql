@@ -0,0 +1,7 @@
+-- SQLite cannot add a UNIQUE column, so names are unique by index
+ALTER TABLE nodes ADD COLUMN name TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_nodes_name ON nodes(name);
+ALTER TABLE nodes ADD COLUMN assignment_mode TEXT NOT NULL DEFAULT 'push';
+ALTER TABLE nodes ADD COLUMN resource_capacity TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE nodes ADD COLUMN resource_load TEXT NOT NULL DEFAULT '{}';
+ALTER TABLE shards ADD COLUMN resource_demand TEXT NOT NULL DEFAULT '{}';
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS reconcile_actions;
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS shard_loads;
+DROP TABLE IF EXISTS change_log;
+DROP TABLE IF EXISTS leader_leases;
+DROP TABLE IF EXISTS shard_leases;
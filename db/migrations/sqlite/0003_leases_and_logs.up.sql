@@ -0,0 +1,57 @@
+-- Tables for leases, leader election, change propagation, load reports,
+-- the audit log and reconciler corrections
+CREATE TABLE IF NOT EXISTS shard_leases (
+    shard_id TEXT PRIMARY KEY,
+    node_id TEXT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS leader_leases (
+    name TEXT PRIMARY KEY,
+    holder_id TEXT NOT NULL,
+    address TEXT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS change_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    entity TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS shard_loads (
+    shard_id TEXT PRIMARY KEY,
+    node_id TEXT NOT NULL,
+    qps REAL NOT NULL DEFAULT 0,
+    bytes_per_second INTEGER NOT NULL DEFAULT 0,
+    cpu_share REAL NOT NULL DEFAULT 0,
+    size_bytes INTEGER NOT NULL DEFAULT 0,
+    reported_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS audit_log (
+    id TEXT PRIMARY KEY,
+    actor TEXT NOT NULL,
+    method TEXT NOT NULL,
+    entity_type TEXT,
+    entity_id TEXT,
+    request TEXT,
+    result TEXT NOT NULL,
+    error_message TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS reconcile_actions (
+    id TEXT PRIMARY KEY,
+    node_id TEXT NOT NULL,
+    shard_id TEXT NOT NULL,
+    action TEXT NOT NULL,
+    role TEXT,
+    error_message TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_reconcile_actions_node_id ON reconcile_actions(node_id);
+CREATE INDEX IF NOT EXISTS idx_shard_leases_node_id ON shard_leases(node_id);
+CREATE INDEX IF NOT EXISTS idx_shard_loads_node_id ON shard_loads(node_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity_id ON audit_log(entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
@@ -0,0 +1,17 @@
+-- Releases before this migration do not know pending shards, so reverting
+-- fails while any shard or shard version is still pending, as on Postgres
+CREATE TEMP TABLE revert_status_checks (pending BOOLEAN NOT NULL);
+CREATE TEMP TRIGGER revert_status_checks_pending BEFORE INSERT ON revert_status_checks
+WHEN NEW.pending
+BEGIN
+    SELECT RAISE(ABORT, 'cannot revert status checks while shards are pending; assign or remove them first');
+END;
+INSERT INTO revert_status_checks (pending)
+SELECT EXISTS (SELECT 1 FROM shards WHERE status = 'pending')
+    OR EXISTS (SELECT 1 FROM shard_versions WHERE status = 'pending');
+DROP TABLE revert_status_checks;
+
+DROP TRIGGER IF EXISTS shards_status_update;
+DROP TRIGGER IF EXISTS shards_status_insert;
+DROP TRIGGER IF EXISTS nodes_status_update;
+DROP TRIGGER IF EXISTS nodes_status_insert;
@@ -0,0 +1,25 @@
+-- Postgres restricts statuses with the node_status and shard_status enums;
+-- these triggers give SQLite the same rules
+CREATE TRIGGER nodes_status_insert BEFORE INSERT ON nodes
+WHEN NEW.status NOT IN ('active', 'inactive', 'maintenance', 'failed')
+BEGIN
+    SELECT RAISE(ABORT, 'invalid node status');
+END;
+
+CREATE TRIGGER nodes_status_update BEFORE UPDATE OF status ON nodes
+WHEN NEW.status NOT IN ('active', 'inactive', 'maintenance', 'failed')
+BEGIN
+    SELECT RAISE(ABORT, 'invalid node status');
+END;
+
+CREATE TRIGGER shards_status_insert BEFORE INSERT ON shards
+WHEN NEW.status NOT IN ('active', 'pending', 'migrating', 'failed', 'maintenance')
+BEGIN
+    SELECT RAISE(ABORT, 'invalid shard status');
+END;
+
+CREATE TRIGGER shards_status_update BEFORE UPDATE OF status ON shards
+WHEN NEW.status NOT IN ('active', 'pending', 'migrating', 'failed', 'maintenance')
+BEGIN
+    SELECT RAISE(ABORT, 'invalid shard status');
+END;
@@ -9,27 +9,36 @@ import (
 
 // Node represents a storage node
 type Node struct {
-	ID            uuid.UUID
-	Location      string
-	Capacity      int64
-	Status        string
-	LastHeartbeat time.Time
-	CurrentLoad   int64
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
+	ID             uuid.UUID
+	Name           string
+	Location       string
+	Capacity       int64
+	Status         string
+	AssignmentMode string
+	LastHeartbeat  time.Time
+	CurrentLoad    int64
+	// FaultDomain names what the node fails together with, e.g. a rack or
+	// zone; empty when unknown
+	FaultDomain string
+	// Multi-dimensional capacity and the load last reported by heartbeat
+	ResourceCapacity Resources
+	ResourceLoad     Resources
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
 
 // Shard represents a data shard
 type Shard struct {
-	ID        uuid.UUID
-	Type      string
-	Size      int64
-	NodeID    *uuid.UUID
-	Status    string
-	Version   int
-	Metadata  json.RawMessage
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID             uuid.UUID
+	Type           string
+	Size           int64
+	NodeID         *uuid.UUID
+	Status         string
+	Version        int
+	Metadata       json.RawMessage
+	ResourceDemand Resources
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
 }
 
 // ShardVersion represents a historical version of a shard
@@ -53,3 +62,75 @@ type Policy struct {
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 }
+
+// FailureReport is a failure reported against a node or shard
+type FailureReport struct {
+	ID        uuid.UUID
+	Type      string
+	EntityID  uuid.UUID
+	Details   json.RawMessage
+	CreatedAt time.Time
+}
+
+// ReconcileAction records a correction made by the reconciler to bring a
+// node's reported inventory in line with the shard map
+type ReconcileAction struct {
+	ID        uuid.UUID
+	NodeID    uuid.UUID
+	ShardID   uuid.UUID
+	Action    string
+	Role      string
+	Error     string
+	CreatedAt time.Time
+}
+
+// ShardLease grants a node time-bounded ownership of a shard
+type ShardLease struct {
+	ShardID   uuid.UUID
+	NodeID    uuid.UUID
+	ExpiresAt time.Time
+	Revoked   bool
+	UpdatedAt time.Time
+}
+
+// LeaderLease records which manager replica is the leader
+type LeaderLease struct {
+	HolderID  string
+	Address   string
+	ExpiresAt time.Time
+	UpdatedAt time.Time
+}
+
+// ShardLoad is the most recent load a node reported for one of its shards
+type ShardLoad struct {
+	ShardID        uuid.UUID
+	NodeID         uuid.UUID
+	QPS            float64
+	BytesPerSecond int64
+	CPUShare       float64
+	SizeBytes      int64
+	ReportedAt     time.Time
+}
+
+// AuditEvent records a mutating call made against the cluster
+type AuditEvent struct {
+	ID         uuid.UUID
+	Actor      string
+	Method     string
+	EntityType string
+	EntityID   string
+	Request    json.RawMessage
+	Result     string
+	Error      string
+	CreatedAt  time.Time
+}
+
+// AuditFilter narrows ListAuditEvents. Zero values match everything; Until
+// is exclusive.
+type AuditFilter struct {
+	EntityID string
+	Actor    string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+}
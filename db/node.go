@@ -3,18 +3,22 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 // CreateNode creates a new node in the database
 func CreateNode(db *sql.DB, node *Node) error {
+	if node.ID == uuid.Nil {
+		node.ID = uuid.New()
+	}
 	query := `
 		INSERT INTO nodes (id, location, capacity, status)
 		VALUES (?, ?, ?, ?)
 		RETURNING created_at, updated_at
 	`
-	return db.QueryRow(query, node.ID, node.Location, node.Capacity, node.Status).Scan(&node.CreatedAt, &node.UpdatedAt)
+	return db.QueryRow(dialectOf(db).rebind(query), node.ID, node.Location, node.Capacity, node.Status).Scan(&node.CreatedAt, &node.UpdatedAt)
 }
 
 // GetNode retrieves a node by ID
@@ -26,7 +30,7 @@ func GetNode(db *sql.DB, id uuid.UUID) (*Node, error) {
 	`
 	node := &Node{}
 	var lastHeartbeat sql.NullTime
-	err := db.QueryRow(query, id).Scan(
+	err := db.QueryRow(dialectOf(db).rebind(query), id).Scan(
 		&node.ID,
 		&node.Location,
 		&node.Capacity,
@@ -52,7 +56,7 @@ func UpdateNode(db *sql.DB, node *Node) error {
 		SET location = ?, capacity = ?, status = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`
-	_, err := db.Exec(query, node.Location, node.Capacity, node.Status, node.ID)
+	_, err := db.Exec(dialectOf(db).rebind(query), node.Location, node.Capacity, node.Status, node.ID)
 	return err
 }
 
@@ -97,80 +101,169 @@ func ListNodes(db *sql.DB) ([]*Node, error) {
 // DeleteNode removes a node by ID
 func DeleteNode(db *sql.DB, id uuid.UUID) error {
 	query := `DELETE FROM nodes WHERE id = ?`
-	_, err := db.Exec(query, id)
+	_, err := db.Exec(dialectOf(db).rebind(query), id)
 	return err
 }
 
-// Node operations
+// RegisterNode adds a node along with the load it reports. A node without
+// a LastHeartbeat is stamped with now.
 func (db *DB) RegisterNode(ctx context.Context, node *Node) error {
+	if node.ID == uuid.Nil {
+		node.ID = uuid.New()
+	}
+	if node.AssignmentMode == "" {
+		node.AssignmentMode = "push"
+	}
+	if node.LastHeartbeat.IsZero() {
+		node.LastHeartbeat = time.Now()
+	}
 	query := `
-		INSERT INTO nodes (id, location, capacity, status)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO nodes (id, name, location, capacity, status, assignment_mode, resource_capacity,
+			last_heartbeat, current_load, resource_load, fault_domain)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING created_at, updated_at`
 
-	return db.QueryRowContext(ctx, query,
-		node.ID, node.Location, node.Capacity, node.Status,
-	).Scan(&node.CreatedAt, &node.UpdatedAt)
+	return db.writeWithChange(ctx, Change{Entity: ChangeNode, ID: node.ID.String()}, func(ctx context.Context, tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, query,
+			node.ID, nullString(node.Name), node.Location, node.Capacity, node.Status, node.AssignmentMode,
+			node.ResourceCapacity, node.LastHeartbeat.UTC(), node.CurrentLoad, node.ResourceLoad, node.FaultDomain,
+		).Scan(&node.CreatedAt, &node.UpdatedAt)
+	})
 }
 
-func (db *DB) UpdateNodeHeartbeat(ctx context.Context, nodeID uuid.UUID, status string, load int64) error {
+// UpdateNode updates the registration details of an existing node
+func (db *DB) UpdateNode(ctx context.Context, node *Node) error {
+	if node.AssignmentMode == "" {
+		node.AssignmentMode = "push"
+	}
 	query := `
-		UPDATE nodes 
-		SET last_heartbeat = CURRENT_TIMESTAMP, status = $1, current_load = $2
-		WHERE id = $3`
+		UPDATE nodes
+		SET name = $1, location = $2, capacity = $3, status = $4, assignment_mode = $5,
+			resource_capacity = $6, fault_domain = $7, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $8
+		RETURNING created_at, updated_at`
 
-	_, err := db.ExecContext(ctx, query, status, load, nodeID)
-	return err
+	return db.writeWithChange(ctx, Change{Entity: ChangeNode, ID: node.ID.String()}, func(ctx context.Context, tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, query,
+			nullString(node.Name), node.Location, node.Capacity, node.Status, node.AssignmentMode,
+			node.ResourceCapacity, node.FaultDomain, node.ID,
+		).Scan(&node.CreatedAt, &node.UpdatedAt)
+	})
 }
 
-func (db *DB) ListNodes(ctx context.Context) ([]*Node, error) {
+// UpdateNodeHeartbeat records a heartbeat. A node an operator put into
+// maintenance keeps that status whatever the node reports. The heartbeat is
+// only announced on the change feed as HeartbeatChangesNode decides.
+func (db *DB) UpdateNodeHeartbeat(ctx context.Context, nodeID uuid.UUID, status string, load int64, resourceLoad Resources) error {
 	query := `
-		SELECT id, location, capacity, status, last_heartbeat, current_load, created_at, updated_at
-		FROM nodes`
-
-	rows, err := db.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+		UPDATE nodes
+		SET last_heartbeat = $1,
+			status = CASE WHEN status = 'maintenance' THEN status ELSE $2 END,
+			current_load = $3, resource_load = $4
+		WHERE id = $5`
 
-	var nodes []*Node
-	for rows.Next() {
-		node := &Node{}
-		err := rows.Scan(
-			&node.ID, &node.Location, &node.Capacity, &node.Status,
-			&node.LastHeartbeat, &node.CurrentLoad, &node.CreatedAt, &node.UpdatedAt,
-		)
+	return db.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		before, err := scanNode(tx.QueryRowContext(ctx, `SELECT `+nodeColumns+` FROM nodes WHERE id = $1`, nodeID))
+		if err == sql.ErrNoRows {
+			return nil
+		}
 		if err != nil {
-			return nil, err
+			return err
 		}
-		nodes = append(nodes, node)
-	}
-	return nodes, rows.Err()
+		now := time.Now().UTC()
+		if _, err := tx.ExecContext(ctx, query, now, status, load, resourceLoad, nodeID); err != nil {
+			return db.dialect().translateError(err)
+		}
+		if !HeartbeatChangesNode(before, status, load, resourceLoad, now) {
+			return nil
+		}
+		return db.recordChange(ctx, tx, Change{Entity: ChangeNode, ID: nodeID.String()})
+	})
 }
 
-func (db *DB) GetNodeInfo(ctx context.Context, nodeID uuid.UUID) (*Node, error) {
-	query := `
-		SELECT id, location, capacity, status, last_heartbeat, current_load, created_at, updated_at
-		FROM nodes
-		WHERE id = $1`
+const nodeColumns = `id, name, location, capacity, status, assignment_mode, last_heartbeat, current_load, resource_capacity, resource_load, fault_domain, created_at, updated_at`
+
+// scanNode scans a row selected with nodeColumns
+func scanNode(row interface{ Scan(dest ...any) error }) (*Node, error) {
 	node := &Node{}
+	var name sql.NullString
 	var lastHeartbeat sql.NullTime
-	err := db.QueryRowContext(ctx, query, nodeID).Scan(
+	err := row.Scan(
 		&node.ID,
+		&name,
 		&node.Location,
 		&node.Capacity,
 		&node.Status,
+		&node.AssignmentMode,
 		&lastHeartbeat,
 		&node.CurrentLoad,
+		&node.ResourceCapacity,
+		&node.ResourceLoad,
+		&node.FaultDomain,
 		&node.CreatedAt,
 		&node.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
 	}
+	node.Name = name.String
 	if lastHeartbeat.Valid {
 		node.LastHeartbeat = lastHeartbeat.Time
 	}
 	return node, nil
 }
+
+func (db *DB) ListNodes(ctx context.Context) ([]*Node, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + nodeColumns + ` FROM nodes`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodes []*Node
+	for rows.Next() {
+		node, err := scanNode(rows)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, rows.Err()
+}
+
+func (db *DB) GetNodeInfo(ctx context.Context, nodeID uuid.UUID) (*Node, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + nodeColumns + ` FROM nodes WHERE id = $1`
+
+	node, err := scanNode(db.QueryRowContext(ctx, query, nodeID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return node, err
+}
+
+// GetNodeByName looks a node up by its stable name, returning nil if none has it
+func (db *DB) GetNodeByName(ctx context.Context, name string) (*Node, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT ` + nodeColumns + ` FROM nodes WHERE name = $1`
+
+	node, err := scanNode(db.QueryRowContext(ctx, query, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return node, err
+}
+
+// nullString stores empty strings as NULL so optional unique columns don't collide
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
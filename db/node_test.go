@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"testing"
 
 	"github.com/google/uuid"
@@ -89,3 +90,69 @@ func TestNodeConcurrentOperations(t *testing.T) {
 	require.NoError(t, err)
 	assert.Len(t, nodes, numNodes)
 }
+
+func TestNodeRegistration(t *testing.T) {
+	ctx := context.Background()
+	db := setupSchemaDB(t)
+
+	named := &Node{ID: uuid.New(), Name: "appserver-1", Location: "localhost:5001", Capacity: 100, Status: "active"}
+	require.NoError(t, db.RegisterNode(ctx, named))
+	assert.Equal(t, "push", named.AssignmentMode)
+
+	// Unnamed nodes don't collide on the unique name column
+	require.NoError(t, db.RegisterNode(ctx, &Node{ID: uuid.New(), Location: "localhost:5002", Status: "active"}))
+	require.NoError(t, db.RegisterNode(ctx, &Node{ID: uuid.New(), Location: "localhost:5003", Status: "active"}))
+
+	// Names are unique
+	err := db.RegisterNode(ctx, &Node{ID: uuid.New(), Name: "appserver-1", Location: "localhost:5004", Status: "active"})
+	assert.Error(t, err)
+
+	found, err := db.GetNodeByName(ctx, "appserver-1")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, named.ID, found.ID)
+
+	missing, err := db.GetNodeByName(ctx, "appserver-2")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	missing, err = db.GetNodeInfo(ctx, uuid.New())
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	found.Location = "localhost:6001"
+	found.Capacity = 200
+	found.AssignmentMode = "pull"
+	require.NoError(t, db.UpdateNode(ctx, found))
+
+	updated, err := db.GetNodeInfo(ctx, named.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "appserver-1", updated.Name)
+	assert.Equal(t, "localhost:6001", updated.Location)
+	assert.Equal(t, int64(200), updated.Capacity)
+	assert.Equal(t, "pull", updated.AssignmentMode)
+
+	nodes, err := db.ListNodes(ctx)
+	require.NoError(t, err)
+	assert.Len(t, nodes, 3)
+}
+
+func TestHeartbeatKeepsMaintenance(t *testing.T) {
+	ctx := context.Background()
+	db := setupSchemaDB(t)
+
+	node := &Node{ID: uuid.New(), Location: "localhost:5001", Status: "active"}
+	require.NoError(t, db.RegisterNode(ctx, node))
+	require.NoError(t, db.UpdateNodeHeartbeat(ctx, node.ID, "inactive", 5, nil))
+	got, err := db.GetNodeInfo(ctx, node.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "inactive", got.Status)
+
+	got.Status = "maintenance"
+	require.NoError(t, db.UpdateNode(ctx, got))
+	require.NoError(t, db.UpdateNodeHeartbeat(ctx, node.ID, "active", 7, nil))
+	got, err = db.GetNodeInfo(ctx, node.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "maintenance", got.Status)
+	assert.Equal(t, int64(7), got.CurrentLoad)
+}
@@ -11,17 +11,27 @@ import (
 
 // Policy operations
 func (db *DB) SetPolicy(ctx context.Context, policy *Policy) error {
+	if policy.ID == uuid.Nil {
+		policy.ID = uuid.New()
+	}
+	// Timestamps come from Go so that policies set within the same second
+	// still order correctly
 	query := `
-		INSERT INTO policies (id, policy_type, parameters)
-		VALUES ($1, $2, $3)
+		INSERT INTO policies (id, policy_type, parameters, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4)
 		RETURNING created_at, updated_at`
 
-	return db.QueryRowContext(ctx, query,
-		policy.ID, policy.PolicyType, policy.Parameters,
-	).Scan(&policy.CreatedAt, &policy.UpdatedAt)
+	return db.writeWithChange(ctx, Change{Entity: ChangePolicy, ID: policy.PolicyType}, func(ctx context.Context, tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, query,
+			policy.ID, policy.PolicyType, policy.Parameters, time.Now().UTC(),
+		).Scan(&policy.CreatedAt, &policy.UpdatedAt)
+	})
 }
 
 func (db *DB) GetPolicy(ctx context.Context, policyType string) (*Policy, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
 	query := `
 		SELECT id, policy_type, parameters, created_at, updated_at
 		FROM policies
@@ -43,42 +53,78 @@ func (db *DB) GetPolicy(ctx context.Context, policyType string) (*Policy, error)
 	return policy, nil
 }
 
+// ListActivePolicies retrieves the latest policy of each type, ordered by type
+func (db *DB) ListActivePolicies(ctx context.Context) ([]*Policy, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, policy_type, parameters, created_at, updated_at
+		FROM policies
+		ORDER BY policy_type, created_at DESC`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []*Policy
+	for rows.Next() {
+		policy := &Policy{}
+		err := rows.Scan(
+			&policy.ID, &policy.PolicyType, &policy.Parameters,
+			&policy.CreatedAt, &policy.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		// Older rows of a type are superseded by the first one seen
+		if n := len(policies); n > 0 && policies[n-1].PolicyType == policy.PolicyType {
+			continue
+		}
+		policies = append(policies, policy)
+	}
+	return policies, rows.Err()
+}
+
 // CreatePolicy inserts a new policy into the database
 func CreatePolicy(db *sql.DB, policy *Policy) error {
+	if policy.ID == uuid.Nil {
+		policy.ID = uuid.New()
+	}
 	query := `
-		INSERT INTO policies (id, name, description, type, conditions, actions, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		INSERT INTO policies (id, policy_type, parameters, created_at, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		RETURNING created_at, updated_at
 	`
-	_, err := db.Exec(query, policy.ID.String(), "", "", policy.PolicyType, string(policy.Parameters), "[]")
-	return err
+	return db.QueryRow(dialectOf(db).rebind(query), policy.ID, policy.PolicyType, policy.Parameters).
+		Scan(&policy.CreatedAt, &policy.UpdatedAt)
 }
 
 // GetPolicy retrieves a policy by ID
 func GetPolicy(db *sql.DB, id uuid.UUID) (*Policy, error) {
 	query := `
-		SELECT id, type, conditions, created_at, updated_at
+		SELECT id, policy_type, parameters, created_at, updated_at
 		FROM policies
 		WHERE id = ?
 	`
-	var pid, ptype, params string
-	var createdAt, updatedAt time.Time
-	err := db.QueryRow(query, id.String()).Scan(&pid, &ptype, &params, &createdAt, &updatedAt)
+	policy := &Policy{}
+	var params []byte
+	err := db.QueryRow(dialectOf(db).rebind(query), id).Scan(
+		&policy.ID, &policy.PolicyType, &params, &policy.CreatedAt, &policy.UpdatedAt,
+	)
 	if err != nil {
 		return nil, err
 	}
-	return &Policy{
-		ID:         uuid.MustParse(pid),
-		PolicyType: ptype,
-		Parameters: json.RawMessage(params),
-		CreatedAt:  createdAt,
-		UpdatedAt:  updatedAt,
-	}, nil
+	policy.Parameters = json.RawMessage(params)
+	return policy, nil
 }
 
 // ListPolicies retrieves all policies
 func ListPolicies(db *sql.DB) ([]*Policy, error) {
 	query := `
-		SELECT id, type, conditions, created_at, updated_at
+		SELECT id, policy_type, parameters, created_at, updated_at
 		FROM policies
 		ORDER BY created_at DESC
 	`
@@ -90,19 +136,14 @@ func ListPolicies(db *sql.DB) ([]*Policy, error) {
 
 	var policies []*Policy
 	for rows.Next() {
-		var pid, ptype, params string
-		var createdAt, updatedAt time.Time
-		err := rows.Scan(&pid, &ptype, &params, &createdAt, &updatedAt)
+		policy := &Policy{}
+		var params []byte
+		err := rows.Scan(&policy.ID, &policy.PolicyType, &params, &policy.CreatedAt, &policy.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
-		policies = append(policies, &Policy{
-			ID:         uuid.MustParse(pid),
-			PolicyType: ptype,
-			Parameters: json.RawMessage(params),
-			CreatedAt:  createdAt,
-			UpdatedAt:  updatedAt,
-		})
+		policy.Parameters = json.RawMessage(params)
+		policies = append(policies, policy)
 	}
 	return policies, rows.Err()
 }
@@ -110,6 +151,6 @@ func ListPolicies(db *sql.DB) ([]*Policy, error) {
 // DeletePolicy removes a policy by ID
 func DeletePolicy(db *sql.DB, id uuid.UUID) error {
 	query := `DELETE FROM policies WHERE id = ?`
-	_, err := db.Exec(query, id.String())
+	_, err := db.Exec(dialectOf(db).rebind(query), id)
 	return err
 }
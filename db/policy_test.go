@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
 
@@ -43,3 +44,23 @@ func TestPolicyCRUD(t *testing.T) {
 	_, err = GetPolicy(db, policyID)
 	assert.Error(t, err)
 }
+
+func TestListActivePolicies(t *testing.T) {
+	ctx := context.Background()
+	db := setupSchemaDB(t)
+
+	for _, p := range []struct{ policyType, params string }{
+		{"placement", `{"v": 1}`},
+		{"migration", `{"v": 1}`},
+		{"placement", `{"v": 2}`},
+	} {
+		require.NoError(t, db.SetPolicy(ctx, &Policy{ID: uuid.New(), PolicyType: p.policyType, Parameters: json.RawMessage(p.params)}))
+	}
+
+	policies, err := db.ListActivePolicies(ctx)
+	require.NoError(t, err)
+	require.Len(t, policies, 2)
+	assert.Equal(t, "migration", policies[0].PolicyType)
+	assert.Equal(t, "placement", policies[1].PolicyType)
+	assert.JSONEq(t, `{"v": 2}`, string(policies[1].Parameters))
+}
@@ -0,0 +1,199 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// PoolOptions tunes the connection pool and bounds and retries queries.
+// Zero values keep database/sql's defaults: no pool limits, no timeout and
+// no retries.
+type PoolOptions struct {
+	// MaxOpenConns caps the connections open at once
+	MaxOpenConns int `yaml:"max_open_conns" json:"max_open_conns"`
+	// MaxIdleConns caps the connections kept open while idle
+	MaxIdleConns int `yaml:"max_idle_conns" json:"max_idle_conns"`
+	// ConnMaxLifetime closes connections once they are this old
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" json:"conn_max_lifetime"`
+	// ConnMaxIdleTime closes connections idle for this long
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" json:"conn_max_idle_time"`
+	// QueryTimeout bounds each query, or each attempt at a transaction
+	QueryTimeout time.Duration `yaml:"query_timeout" json:"query_timeout"`
+	// MaxRetries is how many times a transaction is retried after a
+	// transient error
+	MaxRetries int `yaml:"max_retries" json:"max_retries"`
+	// RetryBackoff is the wait before the first retry; it doubles after each
+	RetryBackoff time.Duration `yaml:"retry_backoff" json:"retry_backoff"`
+}
+
+// DefaultPoolOptions returns the options NewDB, NewDBWithDriver and Open use
+func DefaultPoolOptions() PoolOptions {
+	return PoolOptions{
+		MaxOpenConns:    25,
+		MaxIdleConns:    25,
+		ConnMaxLifetime: 30 * time.Minute,
+		ConnMaxIdleTime: 5 * time.Minute,
+		QueryTimeout:    10 * time.Second,
+		MaxRetries:      3,
+		RetryBackoff:    50 * time.Millisecond,
+	}
+}
+
+// Validate reports every option that is out of range
+func (o PoolOptions) Validate() error {
+	var negative []string
+	for name, v := range map[string]int64{
+		"max_open_conns":     int64(o.MaxOpenConns),
+		"max_idle_conns":     int64(o.MaxIdleConns),
+		"conn_max_lifetime":  int64(o.ConnMaxLifetime),
+		"conn_max_idle_time": int64(o.ConnMaxIdleTime),
+		"query_timeout":      int64(o.QueryTimeout),
+		"max_retries":        int64(o.MaxRetries),
+		"retry_backoff":      int64(o.RetryBackoff),
+	} {
+		if v < 0 {
+			negative = append(negative, name)
+		}
+	}
+	if len(negative) > 0 {
+		sort.Strings(negative)
+		return fmt.Errorf("%s must not be negative", strings.Join(negative, ", "))
+	}
+	return nil
+}
+
+// apply sets the pool limits on sqlDB, leaving the defaults for zero values.
+// An in-memory SQLite database lives only as long as its connection, so
+// its pool is pinned to one connection that is never closed.
+func (o PoolOptions) apply(sqlDB *sql.DB, inMemory bool) {
+	if inMemory {
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		return
+	}
+	if o.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(o.MaxOpenConns)
+	}
+	if o.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(o.MaxIdleConns)
+	}
+	if o.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(o.ConnMaxLifetime)
+	}
+	if o.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(o.ConnMaxIdleTime)
+	}
+}
+
+// withTimeout bounds ctx by the query timeout, if there is one
+func (db *DB) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.pool.QueryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, db.pool.QueryTimeout)
+}
+
+// inTx runs fn in a transaction at the driver's default isolation and
+// commits it. A transient failure rolls the transaction back and runs fn
+// again from the start, after a backoff, up to MaxRetries times, so fn must
+// not depend on an earlier attempt.
+func (db *DB) inTx(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	return db.retryTx(ctx, nil, fn)
+}
+
+// inSerializableTx is inTx at SERIALIZABLE isolation, for the writes that
+// decide where a shard lives. Postgres aborts one of two such transactions
+// that conflict with a serialization failure, which is retried.
+func (db *DB) inSerializableTx(ctx context.Context, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	return db.retryTx(ctx, db.dialect().serializableTxOptions(), fn)
+}
+
+// retryTx runs fn in transactions started with opts as inTx describes
+func (db *DB) retryTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context, tx *sql.Tx) error) error {
+	backoff := db.pool.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := db.tryTx(ctx, opts, fn)
+		if err == nil || !retry || attempt >= db.pool.MaxRetries {
+			return err
+		}
+		// Jitter keeps writers that collided from colliding again
+		wait := backoff + rand.N(backoff/2+1)
+		db.log().DebugContext(ctx, "Retrying transaction", "attempt", attempt+1, "wait", wait, "err", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// tryTx makes one attempt at a transaction, reporting whether a failure
+// may be retried
+func (db *DB) tryTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context, tx *sql.Tx) error) (retry bool, err error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return retryable(err), err
+	}
+	defer tx.Rollback()
+
+	if err := fn(ctx, tx); err != nil {
+		return retryable(err), err
+	}
+	if err := tx.Commit(); err != nil {
+		// A connection lost during commit may have lost it after the
+		// commit was applied, so only retry conflicts the server reported
+		var pqErr *pq.Error
+		return errors.As(err, &pqErr) && isConflict(pqErr) || isBusy(err), err
+	}
+	return false, nil
+}
+
+// retryable reports whether err is transient: a serialization failure or
+// deadlock on Postgres, a locked database on SQLite, or a dropped connection
+func retryable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Class 08 is connection exceptions
+		return isConflict(pqErr) || pqErr.Code.Class() == "08"
+	}
+	return isBusy(err) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// isConflict reports whether Postgres aborted a transaction because it
+// conflicted with another: a serialization failure or a deadlock
+func isConflict(err *pq.Error) bool {
+	return err.Code == "40001" || err.Code == "40P01"
+}
+
+// isMemoryDSN reports whether dsn names an in-memory SQLite database
+func isMemoryDSN(driver, dsn string) bool {
+	return dialectFor(driver) == dialectSQLite &&
+		(strings.Contains(dsn, ":memory:") || strings.Contains(dsn, "mode=memory"))
+}
+
+// isBusy reports whether SQLite gave up waiting for another writer
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
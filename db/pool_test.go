@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenWithOptions(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "shardmanager.db")
+	database, err := OpenWithOptions(dsn, PoolOptions{MaxOpenConns: 3, QueryTimeout: time.Second})
+	require.NoError(t, err)
+	defer database.Close()
+	assert.Equal(t, 3, database.Stats().MaxOpenConnections)
+
+	_, err = OpenWithOptions(dsn, PoolOptions{MaxRetries: -1, QueryTimeout: -time.Second})
+	assert.EqualError(t, err, "max_retries, query_timeout must not be negative")
+}
+
+func TestOpenInMemory(t *testing.T) {
+	opts := DefaultPoolOptions()
+	opts.ConnMaxIdleTime = time.Millisecond
+	database, err := OpenWithOptions("file::memory:", opts)
+	require.NoError(t, err)
+	defer database.Close()
+	assert.Equal(t, 1, database.Stats().MaxOpenConnections)
+
+	ctx := context.Background()
+	_, err = database.ExecContext(ctx, "CREATE TABLE kept (id INTEGER)")
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+	_, err = database.ExecContext(ctx, "INSERT INTO kept (id) VALUES (1)")
+	assert.NoError(t, err, "the database outlives an idle connection")
+}
+
+func TestSerializableTxOptions(t *testing.T) {
+	assert.Equal(t, &sql.TxOptions{Isolation: sql.LevelSerializable}, dialectPostgres.serializableTxOptions())
+	assert.Nil(t, dialectSQLite.serializableTxOptions())
+}
+
+func TestRetryable(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{&pq.Error{Code: "40001"}, true},
+		{&pq.Error{Code: "40P01"}, true},
+		{&pq.Error{Code: "08006"}, true},
+		{&pq.Error{Code: "23505"}, false},
+		{sqlite3.Error{Code: sqlite3.ErrBusy}, true},
+		{sqlite3.Error{Code: sqlite3.ErrConstraint}, false},
+		{driver.ErrBadConn, true},
+		{sql.ErrNoRows, false},
+		{context.DeadlineExceeded, false},
+	} {
+		assert.Equal(t, tc.want, retryable(tc.err), "%v", tc.err)
+	}
+}
+
+func TestInTx(t *testing.T) {
+	ctx := context.Background()
+	database := setupSchemaDB(t)
+	database.pool = PoolOptions{MaxRetries: 2, RetryBackoff: time.Millisecond}
+
+	// failing fails the first n attempts with err
+	failing := func(n int, err error) (func(context.Context, *sql.Tx) error, *int) {
+		attempts := 0
+		return func(ctx context.Context, tx *sql.Tx) error {
+			attempts++
+			if attempts <= n {
+				return err
+			}
+			return nil
+		}, &attempts
+	}
+
+	fn, attempts := failing(2, &pq.Error{Code: "40001"})
+	assert.NoError(t, database.inTx(ctx, fn))
+	assert.Equal(t, 3, *attempts)
+
+	fn, attempts = failing(3, &pq.Error{Code: "40P01"})
+	assert.Error(t, database.inTx(ctx, fn))
+	assert.Equal(t, 3, *attempts, "gives up after MaxRetries")
+
+	fn, attempts = failing(1, errors.New("boom"))
+	assert.EqualError(t, database.inTx(ctx, fn), "boom")
+	assert.Equal(t, 1, *attempts, "other errors are not retried")
+}
+
+func TestQueryTimeout(t *testing.T) {
+	database := setupSchemaDB(t)
+	database.pool.QueryTimeout = time.Nanosecond
+	_, err := database.ListNodes(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = database.GetNodeInfo(context.Background(), uuid.Nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RecordReconcileAction stores a correction made by the reconciler
+func (db *DB) RecordReconcileAction(ctx context.Context, action *ReconcileAction) error {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	if action.ID == uuid.Nil {
+		action.ID = uuid.New()
+	}
+	// Stamped here rather than by the column default, which SQLite only
+	// keeps to the second
+	action.CreatedAt = time.Now().UTC()
+	query := `
+		INSERT INTO reconcile_actions (id, node_id, shard_id, action, role, error_message, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := db.ExecContext(ctx, query,
+		action.ID, action.NodeID, action.ShardID, action.Action, action.Role, action.Error, action.CreatedAt,
+	)
+	return err
+}
+
+// ListReconcileActions retrieves the corrections recorded for a node, newest first
+func (db *DB) ListReconcileActions(ctx context.Context, nodeID uuid.UUID) ([]*ReconcileAction, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT id, node_id, shard_id, action, role, error_message, created_at
+		FROM reconcile_actions
+		WHERE node_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := db.QueryContext(ctx, query, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var actions []*ReconcileAction
+	for rows.Next() {
+		action := &ReconcileAction{}
+		var role, errMsg sql.NullString
+		err := rows.Scan(
+			&action.ID, &action.NodeID, &action.ShardID, &action.Action,
+			&role, &errMsg, &action.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		action.Role = role.String
+		action.Error = errMsg.String
+		actions = append(actions, action)
+	}
+	return actions, rows.Err()
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileActions(t *testing.T) {
+	ctx := context.Background()
+	db := setupSchemaDB(t)
+
+	nodeID := uuid.New()
+	err := db.RegisterNode(ctx, &Node{ID: nodeID, Location: "localhost:5000", Capacity: 10, Status: "active"})
+	require.NoError(t, err)
+
+	shardID := uuid.New()
+	added := &ReconcileAction{NodeID: nodeID, ShardID: shardID, Action: "add_shard", Role: "primary"}
+	require.NoError(t, db.RecordReconcileAction(ctx, added))
+	assert.NotEqual(t, uuid.Nil, added.ID)
+	assert.NotZero(t, added.CreatedAt)
+
+	failed := &ReconcileAction{NodeID: nodeID, ShardID: shardID, Action: "drop_shard", Error: "unavailable"}
+	require.NoError(t, db.RecordReconcileAction(ctx, failed))
+
+	actions, err := db.ListReconcileActions(ctx, nodeID)
+	require.NoError(t, err)
+	require.Len(t, actions, 2)
+
+	byAction := make(map[string]*ReconcileAction)
+	for _, action := range actions {
+		assert.Equal(t, nodeID, action.NodeID)
+		assert.Equal(t, shardID, action.ShardID)
+		byAction[action.Action] = action
+	}
+	assert.Equal(t, "primary", byAction["add_shard"].Role)
+	assert.Empty(t, byAction["add_shard"].Error)
+	assert.Equal(t, "unavailable", byAction["drop_shard"].Error)
+
+	others, err := db.ListReconcileActions(ctx, uuid.New())
+	require.NoError(t, err)
+	assert.Empty(t, others)
+}
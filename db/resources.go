@@ -0,0 +1,94 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Resources is a vector of named resource quantities such as "cpu",
+// "memory", "disk" or "qps". It is stored as a JSON object.
+type Resources map[string]float64
+
+// Value implements driver.Valuer
+func (r Resources) Value() (driver.Value, error) {
+	if r == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(map[string]float64(r))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner
+func (r *Resources) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*r = Resources{}
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Resources", src)
+	}
+	res := Resources{}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &res); err != nil {
+			return err
+		}
+	}
+	*r = res
+	return nil
+}
+
+// Add returns the per-dimension sum of r and other
+func (r Resources) Add(other Resources) Resources {
+	sum := make(Resources, len(r))
+	for name, v := range r {
+		sum[name] = v
+	}
+	for name, v := range other {
+		sum[name] += v
+	}
+	return sum
+}
+
+// Headroom returns what is left of capacity in r once usage is taken out.
+// Only the dimensions present in r are reported.
+func (r Resources) Headroom(usage Resources) Resources {
+	headroom := make(Resources, len(r))
+	for name, capacity := range r {
+		headroom[name] = capacity - usage[name]
+	}
+	return headroom
+}
+
+// Max returns the per-dimension maximum of r and other
+func (r Resources) Max(other Resources) Resources {
+	max := make(Resources, len(r))
+	for name, v := range r {
+		max[name] = v
+	}
+	for name, v := range other {
+		if cur, ok := max[name]; !ok || v > cur {
+			max[name] = v
+		}
+	}
+	return max
+}
+
+// Fits reports whether demand fits within the headroom r in every dimension.
+// Dimensions r does not declare are unconstrained.
+func (r Resources) Fits(demand Resources) bool {
+	for name, need := range demand {
+		if avail, ok := r[name]; ok && need > avail {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResources(t *testing.T) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		r := Resources{"cpu": 2.5, "memory": 8}
+		v, err := r.Value()
+		require.NoError(t, err)
+
+		var scanned Resources
+		require.NoError(t, scanned.Scan(v))
+		assert.Equal(t, r, scanned)
+
+		require.NoError(t, scanned.Scan(nil))
+		assert.Empty(t, scanned)
+	})
+
+	t.Run("Arithmetic", func(t *testing.T) {
+		capacity := Resources{"cpu": 4, "memory": 16}
+		usage := Resources{"cpu": 1}.Add(Resources{"cpu": 1, "qps": 50})
+		assert.Equal(t, Resources{"cpu": 2, "qps": 50}, usage)
+
+		headroom := capacity.Headroom(usage.Max(Resources{"memory": 4}))
+		assert.Equal(t, Resources{"cpu": 2, "memory": 12}, headroom)
+
+		assert.True(t, headroom.Fits(Resources{"cpu": 2, "disk": 100}))
+		assert.False(t, headroom.Fits(Resources{"memory": 13}))
+	})
+}
+
+func TestNodeResources(t *testing.T) {
+	db := setupSchemaDB(t)
+	ctx := context.Background()
+
+	node := &Node{ID: uuid.New(), Location: "localhost:5001", Capacity: 1, Status: "active",
+		ResourceCapacity: Resources{"cpu": 8}}
+	require.NoError(t, db.RegisterNode(ctx, node))
+	require.NoError(t, db.UpdateNodeHeartbeat(ctx, node.ID, "active", 0, Resources{"cpu": 3}))
+
+	got, err := db.GetNodeInfo(ctx, node.ID)
+	require.NoError(t, err)
+	assert.Equal(t, Resources{"cpu": 8}, got.ResourceCapacity)
+	assert.Equal(t, Resources{"cpu": 3}, got.ResourceLoad)
+
+	shard := &Shard{ID: uuid.New(), Type: "kv", Size: 1, NodeID: &node.ID, Status: "active", Version: 1,
+		ResourceDemand: Resources{"cpu": 2}}
+	require.NoError(t, db.RegisterShard(ctx, shard))
+	gotShard, err := db.GetShardInfo(ctx, shard.ID)
+	require.NoError(t, err)
+	assert.Equal(t, Resources{"cpu": 2}, gotShard.ResourceDemand)
+}
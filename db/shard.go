@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,19 +12,28 @@ import (
 
 // Shard operations
 func (db *DB) RegisterShard(ctx context.Context, shard *Shard) error {
+	if shard.ID == uuid.Nil {
+		shard.ID = uuid.New()
+	}
 	query := `
-		INSERT INTO shards (id, type, size, node_id, status, version, metadata)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO shards (id, type, size, node_id, status, version, metadata, resource_demand)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING created_at, updated_at`
 
-	return db.QueryRowContext(ctx, query,
-		shard.ID, shard.Type, shard.Size, shard.NodeID, shard.Status, shard.Version, shard.Metadata,
-	).Scan(&shard.CreatedAt, &shard.UpdatedAt)
+	return db.writeWithChange(ctx, Change{Entity: ChangeShard, ID: shard.ID.String()}, func(ctx context.Context, tx *sql.Tx) error {
+		return tx.QueryRowContext(ctx, query,
+			shard.ID, shard.Type, shard.Size, shard.NodeID, shard.Status, shard.Version, shard.Metadata,
+			shard.ResourceDemand,
+		).Scan(&shard.CreatedAt, &shard.UpdatedAt)
+	})
 }
 
 func (db *DB) ListShards(ctx context.Context) ([]*Shard, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
 	query := `
-		SELECT id, type, size, node_id, status, version, metadata, created_at, updated_at
+		SELECT id, type, size, node_id, status, version, metadata, resource_demand, created_at, updated_at
 		FROM shards`
 
 	rows, err := db.QueryContext(ctx, query)
@@ -39,7 +49,7 @@ func (db *DB) ListShards(ctx context.Context) ([]*Shard, error) {
 		var nodeID sql.NullString
 		err := rows.Scan(
 			&shard.ID, &shard.Type, &shard.Size, &nodeID,
-			&shard.Status, &shard.Version, &metadata,
+			&shard.Status, &shard.Version, &metadata, &shard.ResourceDemand,
 			&shard.CreatedAt, &shard.UpdatedAt,
 		)
 		if err != nil {
@@ -62,8 +72,11 @@ func (db *DB) ListShards(ctx context.Context) ([]*Shard, error) {
 }
 
 func (db *DB) GetShardInfo(ctx context.Context, shardID uuid.UUID) (*Shard, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
 	query := `
-		SELECT id, type, size, node_id, status, version, metadata, created_at, updated_at
+		SELECT id, type, size, node_id, status, version, metadata, resource_demand, created_at, updated_at
 		FROM shards
 		WHERE id = $1`
 
@@ -72,7 +85,7 @@ func (db *DB) GetShardInfo(ctx context.Context, shardID uuid.UUID) (*Shard, erro
 	var nodeID sql.NullString
 	err := db.QueryRowContext(ctx, query, shardID).Scan(
 		&shard.ID, &shard.Type, &shard.Size, &nodeID,
-		&shard.Status, &shard.Version, &metadata,
+		&shard.Status, &shard.Version, &metadata, &shard.ResourceDemand,
 		&shard.CreatedAt, &shard.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
@@ -95,35 +108,76 @@ func (db *DB) GetShardInfo(ctx context.Context, shardID uuid.UUID) (*Shard, erro
 	return shard, nil
 }
 
+// AssignShard moves a shard to a node, recording its previous state as a
+// version. It runs at SERIALIZABLE isolation, like MoveShard.
 func (db *DB) AssignShard(ctx context.Context, shardID, nodeID uuid.UUID) error {
-	query := `
-		UPDATE shards
-		SET node_id = $1, version = version + 1
-		WHERE id = $2`
+	return db.serializableWriteWithChange(ctx, Change{Entity: ChangeShard, ID: shardID.String()}, func(ctx context.Context, tx *sql.Tx) error {
+		if err := snapshotShard(ctx, tx, shardID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE shards SET node_id = $1, version = version + 1 WHERE id = $2`, nodeID, shardID)
+		return err
+	})
+}
 
-	_, err := db.ExecContext(ctx, query, nodeID, shardID)
-	return err
+// ErrShardMoved is returned by MoveShard when the shard is not on the node
+// it is being moved from
+var ErrShardMoved = errors.New("shard is not on the source node")
+
+// MoveShard moves a shard from one node to another, recording its previous
+// state as a single version. It returns ErrShardMoved if the shard is not
+// on fromNodeID and sql.ErrNoRows if there is no such shard. Handoffs
+// rely on it running at SERIALIZABLE isolation.
+func (db *DB) MoveShard(ctx context.Context, shardID, fromNodeID, toNodeID uuid.UUID) error {
+	return db.serializableWriteWithChange(ctx, Change{Entity: ChangeShard, ID: shardID.String()}, func(ctx context.Context, tx *sql.Tx) error {
+		if err := snapshotShard(ctx, tx, shardID); err != nil {
+			return err
+		}
+		var nodeID sql.NullString
+		if err := tx.QueryRowContext(ctx, `SELECT node_id FROM shards WHERE id = $1`, shardID).Scan(&nodeID); err != nil {
+			return err
+		}
+		if owner, err := uuid.Parse(nodeID.String); err != nil || owner != fromNodeID {
+			return ErrShardMoved
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE shards SET node_id = $1, version = version + 1 WHERE id = $2`, toNodeID, shardID)
+		return err
+	})
 }
 
+// UpdateShardStatus sets a shard's status, recording its previous state as
+// a version
 func (db *DB) UpdateShardStatus(ctx context.Context, shardID uuid.UUID, status string) error {
-	query := `
+	return db.updateShardVersioned(ctx, shardID, `
 		UPDATE shards
-		SET status = $1, version = version + 1
-		WHERE id = $2`
+		SET status = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2`, status)
+}
 
-	_, err := db.ExecContext(ctx, query, status, shardID)
-	return err
+// updateShardVersioned snapshots a shard, then runs query with value as $1
+// and the shard ID as $2 in the same transaction
+func (db *DB) updateShardVersioned(ctx context.Context, shardID uuid.UUID, query string, value interface{}) error {
+	return db.writeWithChange(ctx, Change{Entity: ChangeShard, ID: shardID.String()}, func(ctx context.Context, tx *sql.Tx) error {
+		if err := snapshotShard(ctx, tx, shardID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, query, value, shardID)
+		return err
+	})
 }
 
 // CreateShard inserts a new shard into the database
 func CreateShard(db *sql.DB, shard *Shard) error {
+	if shard.ID == uuid.Nil {
+		shard.ID = uuid.New()
+	}
 	query := `
 		INSERT INTO shards (id, node_id, type, size, status, version, metadata, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
 		RETURNING created_at, updated_at
 	`
-	return db.QueryRow(query,
-		shard.ID.String(), shard.NodeID.String(), shard.Type, shard.Size,
+	return db.QueryRow(dialectOf(db).rebind(query),
+		shard.ID, shard.NodeID, shard.Type, shard.Size,
 		shard.Status, shard.Version, shard.Metadata,
 	).Scan(&shard.CreatedAt, &shard.UpdatedAt)
 }
@@ -135,12 +189,13 @@ func GetShard(db *sql.DB, id uuid.UUID) (*Shard, error) {
 		FROM shards
 		WHERE id = ?
 	`
-	var sid, nodeID, stype, status string
+	var sid, stype, status string
+	var nodeID sql.NullString
 	var size int64
 	var version int
 	var metadata []byte
 	var createdAt, updatedAt time.Time
-	err := db.QueryRow(query, id.String()).Scan(
+	err := db.QueryRow(dialectOf(db).rebind(query), id).Scan(
 		&sid, &nodeID, &stype, &size, &status,
 		&version, &metadata,
 		&createdAt, &updatedAt,
@@ -148,10 +203,9 @@ func GetShard(db *sql.DB, id uuid.UUID) (*Shard, error) {
 	if err != nil {
 		return nil, err
 	}
-	parsedNodeID := uuid.MustParse(nodeID)
 	return &Shard{
 		ID:        uuid.MustParse(sid),
-		NodeID:    &parsedNodeID,
+		NodeID:    parseNullUUID(nodeID),
 		Type:      stype,
 		Size:      size,
 		Status:    status,
@@ -177,7 +231,8 @@ func ListShards(db *sql.DB) ([]*Shard, error) {
 
 	var shards []*Shard
 	for rows.Next() {
-		var sid, nodeID, stype, status string
+		var sid, stype, status string
+		var nodeID sql.NullString
 		var size int64
 		var version int
 		var metadata []byte
@@ -190,10 +245,9 @@ func ListShards(db *sql.DB) ([]*Shard, error) {
 		if err != nil {
 			return nil, err
 		}
-		parsedNodeID := uuid.MustParse(nodeID)
 		shards = append(shards, &Shard{
 			ID:        uuid.MustParse(sid),
-			NodeID:    &parsedNodeID,
+			NodeID:    parseNullUUID(nodeID),
 			Type:      stype,
 			Size:      size,
 			Status:    status,
@@ -213,9 +267,9 @@ func UpdateShard(db *sql.DB, shard *Shard) error {
 		SET node_id = ?, type = ?, size = ?, status = ?, version = version + 1, metadata = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`
-	_, err := db.Exec(query,
-		shard.NodeID.String(), shard.Type, shard.Size,
-		shard.Status, shard.Metadata, shard.ID.String(),
+	_, err := db.Exec(dialectOf(db).rebind(query),
+		shard.NodeID, shard.Type, shard.Size,
+		shard.Status, shard.Metadata, shard.ID,
 	)
 	return err
 }
@@ -223,7 +277,7 @@ func UpdateShard(db *sql.DB, shard *Shard) error {
 // DeleteShard removes a shard by ID
 func DeleteShard(db *sql.DB, id uuid.UUID) error {
 	query := `DELETE FROM shards WHERE id = ?`
-	_, err := db.Exec(query, id.String())
+	_, err := db.Exec(dialectOf(db).rebind(query), id)
 	return err
 }
 
@@ -234,6 +288,18 @@ func AssignShard(db *sql.DB, shardID uuid.UUID, nodeID uuid.UUID) error {
 		SET node_id = ?, version = version + 1, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`
-	_, err := db.Exec(query, nodeID.String(), shardID.String())
+	_, err := db.Exec(dialectOf(db).rebind(query), nodeID, shardID)
 	return err
 }
+
+// parseNullUUID parses a nullable UUID column, returning nil for NULL
+func parseNullUUID(s sql.NullString) *uuid.UUID {
+	if !s.Valid {
+		return nil
+	}
+	id, err := uuid.Parse(s.String)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// RecordShardLoads stores the latest load sample for each shard, replacing
+// any earlier sample. Samples without a ReportedAt are stamped with now.
+func (db *DB) RecordShardLoads(ctx context.Context, loads []*ShardLoad) error {
+	if len(loads) == 0 {
+		return nil
+	}
+	query := `
+		INSERT INTO shard_loads (shard_id, node_id, qps, bytes_per_second, cpu_share, size_bytes, reported_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (shard_id) DO UPDATE
+		SET node_id = excluded.node_id, qps = excluded.qps, bytes_per_second = excluded.bytes_per_second,
+			cpu_share = excluded.cpu_share, size_bytes = excluded.size_bytes, reported_at = excluded.reported_at`
+
+	now := time.Now().UTC()
+	for _, load := range loads {
+		if load.ReportedAt.IsZero() {
+			load.ReportedAt = now
+		}
+	}
+	return db.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		for _, load := range loads {
+			_, err := tx.ExecContext(ctx, query,
+				load.ShardID, load.NodeID, load.QPS, load.BytesPerSecond, load.CPUShare, load.SizeBytes,
+				load.ReportedAt.UTC(),
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListShardLoads returns the latest load sample of every shard that has one
+func (db *DB) ListShardLoads(ctx context.Context) ([]*ShardLoad, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT shard_id, node_id, qps, bytes_per_second, cpu_share, size_bytes, reported_at
+		FROM shard_loads`
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var loads []*ShardLoad
+	for rows.Next() {
+		load := &ShardLoad{}
+		err := rows.Scan(
+			&load.ShardID, &load.NodeID, &load.QPS, &load.BytesPerSecond,
+			&load.CPUShare, &load.SizeBytes, &load.ReportedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		loads = append(loads, load)
+	}
+	return loads, rows.Err()
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardLoads(t *testing.T) {
+	ctx := context.Background()
+	db := setupSchemaDB(t)
+
+	shardID := uuid.New()
+	nodeID := uuid.New()
+	first := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+
+	require.NoError(t, db.RecordShardLoads(ctx, []*ShardLoad{
+		{ShardID: shardID, NodeID: nodeID, QPS: 10, BytesPerSecond: 100, CPUShare: 0.1, SizeBytes: 1000, ReportedAt: first},
+	}))
+
+	// A newer sample replaces the old one
+	require.NoError(t, db.RecordShardLoads(ctx, []*ShardLoad{
+		{ShardID: shardID, NodeID: nodeID, QPS: 50, BytesPerSecond: 500, CPUShare: 0.4, SizeBytes: 2000},
+	}))
+
+	loads, err := db.ListShardLoads(ctx)
+	require.NoError(t, err)
+	require.Len(t, loads, 1)
+	assert.Equal(t, shardID, loads[0].ShardID)
+	assert.Equal(t, nodeID, loads[0].NodeID)
+	assert.Equal(t, 50.0, loads[0].QPS)
+	assert.Equal(t, int64(500), loads[0].BytesPerSecond)
+	assert.Equal(t, 0.4, loads[0].CPUShare)
+	assert.Equal(t, int64(2000), loads[0].SizeBytes)
+	assert.True(t, loads[0].ReportedAt.After(first))
+}
@@ -59,7 +59,7 @@ func TestShardOperations(t *testing.T) {
 			Type:   "type-2",
 			Size:   200,
 			NodeID: &nodeID,
-			Status: "inactive",
+			Status: "maintenance",
 		}
 
 		err := CreateShard(db, shard1)
@@ -83,7 +83,7 @@ func TestShardOperations(t *testing.T) {
 				found["type-1"] = true
 			} else if shard.Type == "type-2" {
 				assert.Equal(t, int64(200), shard.Size)
-				assert.Equal(t, "inactive", shard.Status)
+				assert.Equal(t, "maintenance", shard.Status)
 				if shard.NodeID != nil {
 					assert.Equal(t, nodeID, *shard.NodeID)
 				}
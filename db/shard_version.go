@@ -3,19 +3,23 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 
 	"github.com/google/uuid"
 )
 
 // GetShardVersion retrieves a specific version of a shard
 func (db *DB) GetShardVersion(ctx context.Context, shardID uuid.UUID, version int) (*ShardVersion, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
 	query := `
 		SELECT id, shard_id, version, type, size, node_id, status, metadata, created_at
 		FROM shard_versions
 		WHERE shard_id = $1 AND version = $2
 	`
 	var sv ShardVersion
-	var nodeID sql.NullString
+	var nodeID, metadata sql.NullString
 	err := db.QueryRowContext(ctx, query, shardID, version).Scan(
 		&sv.ID,
 		&sv.ShardID,
@@ -24,7 +28,7 @@ func (db *DB) GetShardVersion(ctx context.Context, shardID uuid.UUID, version in
 		&sv.Size,
 		&nodeID,
 		&sv.Status,
-		&sv.Metadata,
+		&metadata,
 		&sv.CreatedAt,
 	)
 	if err != nil {
@@ -40,11 +44,15 @@ func (db *DB) GetShardVersion(ctx context.Context, shardID uuid.UUID, version in
 		}
 		sv.NodeID = &parsedID
 	}
+	sv.Metadata = versionMetadata(metadata)
 	return &sv, nil
 }
 
 // ListShardVersions retrieves all versions of a shard
 func (db *DB) ListShardVersions(ctx context.Context, shardID uuid.UUID) ([]*ShardVersion, error) {
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+
 	query := `
 		SELECT id, shard_id, version, type, size, node_id, status, metadata, created_at
 		FROM shard_versions
@@ -60,7 +68,7 @@ func (db *DB) ListShardVersions(ctx context.Context, shardID uuid.UUID) ([]*Shar
 	var versions []*ShardVersion
 	for rows.Next() {
 		var sv ShardVersion
-		var nodeID sql.NullString
+		var nodeID, metadata sql.NullString
 		err := rows.Scan(
 			&sv.ID,
 			&sv.ShardID,
@@ -69,7 +77,7 @@ func (db *DB) ListShardVersions(ctx context.Context, shardID uuid.UUID) ([]*Shar
 			&sv.Size,
 			&nodeID,
 			&sv.Status,
-			&sv.Metadata,
+			&metadata,
 			&sv.CreatedAt,
 		)
 		if err != nil {
@@ -82,119 +90,125 @@ func (db *DB) ListShardVersions(ctx context.Context, shardID uuid.UUID) ([]*Shar
 			}
 			sv.NodeID = &parsedID
 		}
+		sv.Metadata = versionMetadata(metadata)
 		versions = append(versions, &sv)
 	}
 	return versions, rows.Err()
 }
 
-// UpdateShardVersion updates a shard and creates a new version
+// UpdateShardVersion updates a shard and creates a new version, retried as
+// inTx describes
 func (db *DB) UpdateShardVersion(ctx context.Context, shard *Shard) error {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
+	return db.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		// Get current version
+		var currentVersion int
+		err := tx.QueryRowContext(ctx, "SELECT version FROM shards WHERE id = $1", shard.ID).Scan(&currentVersion)
+		if err != nil {
+			return err
+		}
 
-	// Get current version
-	var currentVersion int
-	err = tx.QueryRowContext(ctx, "SELECT version FROM shards WHERE id = $1", shard.ID).Scan(&currentVersion)
-	if err != nil {
-		return err
-	}
+		// Create new version record
+		if err := snapshotShard(ctx, tx, shard.ID); err != nil {
+			return err
+		}
 
-	// Create new version record
-	versionQuery := `
-		INSERT INTO shard_versions (shard_id, version, type, size, node_id, status, metadata)
-		SELECT id, version, type, size, node_id, status, metadata
-		FROM shards
-		WHERE id = $1
-	`
-	_, err = tx.ExecContext(ctx, versionQuery, shard.ID)
-	if err != nil {
-		return err
-	}
+		// Update shard with new version
+		updateQuery := `
+			UPDATE shards
+			SET type = $1, size = $2, node_id = $3, status = $4, metadata = $5, version = version + 1
+			WHERE id = $6
+			RETURNING version
+		`
+		err = tx.QueryRowContext(ctx, updateQuery,
+			shard.Type,
+			shard.Size,
+			shard.NodeID,
+			shard.Status,
+			shard.Metadata,
+			shard.ID,
+		).Scan(&shard.Version)
+		if err != nil {
+			return db.dialect().translateError(err)
+		}
+		return db.recordChange(ctx, tx, Change{Entity: ChangeShard, ID: shard.ID.String()})
+	})
+}
 
-	// Update shard with new version
-	updateQuery := `
-		UPDATE shards
-		SET type = $1, size = $2, node_id = $3, status = $4, metadata = $5, version = version + 1
-		WHERE id = $6
-		RETURNING version
-	`
-	err = tx.QueryRowContext(ctx, updateQuery,
-		shard.Type,
-		shard.Size,
-		shard.NodeID,
-		shard.Status,
-		shard.Metadata,
-		shard.ID,
-	).Scan(&shard.Version)
-	if err != nil {
-		return err
-	}
+// RollbackShardVersion rolls back a shard to a specific version, retried as
+// inTx describes
+func (db *DB) RollbackShardVersion(ctx context.Context, shardID uuid.UUID, version int) error {
+	return db.inTx(ctx, func(ctx context.Context, tx *sql.Tx) error {
+		// Get the version to rollback to
+		var sv ShardVersion
+		var nodeID, metadata sql.NullString
+		err := tx.QueryRowContext(ctx, `
+			SELECT id, shard_id, version, type, size, node_id, status, metadata, created_at
+			FROM shard_versions
+			WHERE shard_id = $1 AND version = $2
+		`, shardID, version).Scan(
+			&sv.ID,
+			&sv.ShardID,
+			&sv.Version,
+			&sv.Type,
+			&sv.Size,
+			&nodeID,
+			&sv.Status,
+			&metadata,
+			&sv.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		sv.Metadata = versionMetadata(metadata)
+
+		// Create new version record of current state
+		if err := snapshotShard(ctx, tx, shardID); err != nil {
+			return err
+		}
 
-	return tx.Commit()
+		// Update shard with rolled back state
+		updateQuery := `
+			UPDATE shards
+			SET type = $1, size = $2, node_id = $3, status = $4, metadata = $5, version = version + 1
+			WHERE id = $6
+		`
+		_, err = tx.ExecContext(ctx, updateQuery,
+			sv.Type,
+			sv.Size,
+			nodeID,
+			sv.Status,
+			sv.Metadata,
+			shardID,
+		)
+		if err != nil {
+			return err
+		}
+		return db.recordChange(ctx, tx, Change{Entity: ChangeShard, ID: shardID.String()})
+	})
 }
 
-// RollbackShardVersion rolls back a shard to a specific version
-func (db *DB) RollbackShardVersion(ctx context.Context, shardID uuid.UUID, version int) error {
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
+// versionMetadata reads a nullable metadata column the way shards are read
+func versionMetadata(metadata sql.NullString) json.RawMessage {
+	if metadata.Valid {
+		return json.RawMessage(metadata.String)
 	}
-	defer tx.Rollback()
+	return json.RawMessage("{}")
+}
 
-	// Get the version to rollback to
-	var sv ShardVersion
-	var nodeID sql.NullString
-	err = tx.QueryRowContext(ctx, `
-		SELECT id, shard_id, version, type, size, node_id, status, metadata, created_at
-		FROM shard_versions
-		WHERE shard_id = $1 AND version = $2
-	`, shardID, version).Scan(
-		&sv.ID,
-		&sv.ShardID,
-		&sv.Version,
-		&sv.Type,
-		&sv.Size,
-		&nodeID,
-		&sv.Status,
-		&sv.Metadata,
-		&sv.CreatedAt,
-	)
-	if err != nil {
+// snapshotShard records the current state of a shard as a version, before
+// the caller changes it and bumps the version within tx
+func snapshotShard(ctx context.Context, tx *sql.Tx, shardID uuid.UUID) error {
+	// The no-op update locks the row, so concurrent changes never snapshot
+	// the same version twice
+	if _, err := tx.ExecContext(ctx, `UPDATE shards SET version = version WHERE id = $1`, shardID); err != nil {
 		return err
 	}
-
-	// Create new version record of current state
 	versionQuery := `
 		INSERT INTO shard_versions (shard_id, version, type, size, node_id, status, metadata)
 		SELECT id, version, type, size, node_id, status, metadata
 		FROM shards
 		WHERE id = $1
 	`
-	_, err = tx.ExecContext(ctx, versionQuery, shardID)
-	if err != nil {
-		return err
-	}
-
-	// Update shard with rolled back state
-	updateQuery := `
-		UPDATE shards
-		SET type = $1, size = $2, node_id = $3, status = $4, metadata = $5, version = version + 1
-		WHERE id = $6
-	`
-	_, err = tx.ExecContext(ctx, updateQuery,
-		sv.Type,
-		sv.Size,
-		sv.NodeID,
-		sv.Status,
-		sv.Metadata,
-		shardID,
-	)
-	if err != nil {
-		return err
-	}
-
-	return tx.Commit()
+	_, err := tx.ExecContext(ctx, versionQuery, shardID)
+	return err
 }
@@ -114,3 +114,73 @@ func TestShardVersioning(t *testing.T) {
 	assert.Equal(t, int64(100), versions[1].Size)
 	assert.Equal(t, `{"key": "value"}`, string(versions[1].Metadata))
 }
+
+func TestShardChangesRecordVersions(t *testing.T) {
+	ctx := context.Background()
+	db := setupSchemaDB(t)
+
+	first := &Node{ID: uuid.New(), Location: "localhost:5001", Status: "active"}
+	second := &Node{ID: uuid.New(), Location: "localhost:5002", Status: "active"}
+	require.NoError(t, db.RegisterNode(ctx, first))
+	require.NoError(t, db.RegisterNode(ctx, second))
+	shard := &Shard{ID: uuid.New(), Type: "range", Size: 1, NodeID: &first.ID, Status: "active", Version: 1}
+	require.NoError(t, db.RegisterShard(ctx, shard))
+
+	require.NoError(t, db.UpdateShardStatus(ctx, shard.ID, "migrating"))
+	require.NoError(t, db.AssignShard(ctx, shard.ID, second.ID))
+
+	versions, err := db.ListShardVersions(ctx, shard.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, 2, versions[0].Version)
+	assert.Equal(t, "migrating", versions[0].Status)
+	assert.Equal(t, first.ID, *versions[0].NodeID)
+	assert.Equal(t, 1, versions[1].Version)
+	assert.Equal(t, "active", versions[1].Status)
+
+	current, err := db.GetShardInfo(ctx, shard.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, current.Version)
+	assert.Equal(t, second.ID, *current.NodeID)
+
+	// Rolling back restores the node as well
+	require.NoError(t, db.RollbackShardVersion(ctx, shard.ID, 1))
+	current, err = db.GetShardInfo(ctx, shard.ID)
+	require.NoError(t, err)
+	require.NotNil(t, current.NodeID)
+	assert.Equal(t, first.ID, *current.NodeID)
+	assert.Equal(t, "active", current.Status)
+}
+
+func TestShardVersionNullMetadataAndRollbackNode(t *testing.T) {
+	ctx := context.Background()
+	db := setupSchemaDB(t)
+
+	node := &Node{ID: uuid.New(), Location: "localhost:5001", Status: "active"}
+	require.NoError(t, db.RegisterNode(ctx, node))
+	shard := &Shard{ID: uuid.New(), Type: "range", Size: 1, NodeID: &node.ID, Status: "active", Version: 1}
+	require.NoError(t, db.RegisterShard(ctx, shard))
+	_, err := db.ExecContext(ctx, `UPDATE shards SET metadata = NULL WHERE id = $1`, shard.ID)
+	require.NoError(t, err)
+
+	moved := *shard
+	moved.NodeID = nil
+	moved.Metadata = json.RawMessage(`{}`)
+	require.NoError(t, db.UpdateShardVersion(ctx, &moved))
+
+	// A version without metadata reads like a shard without metadata
+	version, err := db.GetShardVersion(ctx, shard.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(version.Metadata))
+	versions, err := db.ListShardVersions(ctx, shard.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, "{}", string(versions[0].Metadata))
+
+	// Rolling back restores the version's node
+	require.NoError(t, db.RollbackShardVersion(ctx, shard.ID, 1))
+	current, err := db.GetShardInfo(ctx, shard.ID)
+	require.NoError(t, err)
+	require.NotNil(t, current.NodeID)
+	assert.Equal(t, node.ID, *current.NodeID)
+}
@@ -53,7 +53,7 @@ func startTestAppServer(t *testing.T, wg *sync.WaitGroup, port int, nodeID strin
 	}
 	defer conn.Close()
 	client := shardmanagerpb.NewNodeServiceClient(conn)
-	_, err = client.RegisterNode(context.Background(), &shardmanagerpb.RegisterNodeRequest{
+	resp, err := client.RegisterNode(context.Background(), &shardmanagerpb.RegisterNodeRequest{
 		Node: &shardmanagerpb.Node{
 			Id:       nodeID,
 			Location: fmt.Sprintf("localhost:%d", port),
@@ -64,6 +64,9 @@ func startTestAppServer(t *testing.T, wg *sync.WaitGroup, port int, nodeID strin
 	if err != nil {
 		t.Fatalf("failed to register node: %v", err)
 	}
+	if resp.NodeId == "" {
+		t.Fatalf("RegisterNode did not return the node ID for %s", nodeID)
+	}
 	return server, shards
 }
 
@@ -79,9 +82,12 @@ func TestShardBalancing(t *testing.T) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		err := server.StartShardManagerServer(dbPath, fmt.Sprintf(":%d", shardManagerPort), stopCh)
+		opts := server.DefaultOptions()
+		opts.Database = dbPath
+		opts.GRPCAddr = fmt.Sprintf(":%d", shardManagerPort)
+		err := server.StartShardManagerServer(opts, stopCh)
 		if err != nil {
-			t.Fatalf("failed to start real shardmanager: %v", err)
+			t.Errorf("failed to start real shardmanager: %v", err)
 		}
 	}()
 	time.Sleep(500 * time.Millisecond) // Give time for server to start
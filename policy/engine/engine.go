@@ -3,6 +3,7 @@ package engine
 import (
 	"context"
 	"fmt"
+	"log/slog"
 
 	"github.com/seaweedfs/shardmanager/policy"
 )
@@ -21,6 +22,7 @@ type ActionExecutor interface {
 type Engine struct {
 	metricProvider MetricProvider
 	actionExecutor ActionExecutor
+	logger         *slog.Logger // nil logs to slog.Default
 }
 
 // NewEngine creates a new policy engine
@@ -31,6 +33,19 @@ func NewEngine(metricProvider MetricProvider, actionExecutor ActionExecutor) *En
 	}
 }
 
+// SetLogger sends the engine's logs to logger
+func (e *Engine) SetLogger(logger *slog.Logger) {
+	e.logger = logger
+}
+
+// log returns the engine's logger
+func (e *Engine) log() *slog.Logger {
+	if e.logger == nil {
+		return slog.Default()
+	}
+	return e.logger
+}
+
 // EvaluatePolicy evaluates a policy's conditions and executes its actions if conditions are met
 func (e *Engine) EvaluatePolicy(ctx context.Context, p *policy.Policy) (bool, error) {
 	// Evaluate conditions
@@ -40,6 +55,7 @@ func (e *Engine) EvaluatePolicy(ctx context.Context, p *policy.Policy) (bool, er
 	}
 
 	if !conditionsMet {
+		e.log().DebugContext(ctx, "Policy conditions not met", "policy", p.ID)
 		return false, nil
 	}
 
@@ -48,6 +64,7 @@ func (e *Engine) EvaluatePolicy(ctx context.Context, p *policy.Policy) (bool, er
 		if err := e.actionExecutor.ExecuteAction(ctx, action); err != nil {
 			return true, fmt.Errorf("failed to execute action %s: %w", action.Type, err)
 		}
+		e.log().InfoContext(ctx, "Executed policy action", "policy", p.ID, "action", action.Type)
 	}
 
 	return true, nil
@@ -133,6 +150,7 @@ func (e *Engine) EvaluatePolicies(ctx context.Context, policies []*policy.Policy
 	for _, p := range sortedPolicies {
 		_, err := e.EvaluatePolicy(ctx, p)
 		if err != nil {
+			e.log().WarnContext(ctx, "Policy evaluation failed", "policy", p.ID, "err", err)
 			if firstErr == nil {
 				firstErr = fmt.Errorf("failed to evaluate policy %s: %w", p.ID, err)
 			}
@@ -2,7 +2,7 @@ package manager
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 
@@ -20,6 +20,7 @@ type PolicyManager struct {
 	wg           sync.WaitGroup
 	mu           sync.RWMutex
 	isRunning    bool
+	logger       *slog.Logger // nil logs to slog.Default
 }
 
 // NewPolicyManager creates a new policy manager
@@ -38,6 +39,21 @@ func NewPolicyManager(
 	}
 }
 
+// SetLogger sends the manager's and its engine's logs to logger. It must be
+// called before Start.
+func (pm *PolicyManager) SetLogger(logger *slog.Logger) {
+	pm.logger = logger
+	pm.engine.SetLogger(logger)
+}
+
+// log returns the manager's logger
+func (pm *PolicyManager) log() *slog.Logger {
+	if pm.logger == nil {
+		return slog.Default()
+	}
+	return pm.logger
+}
+
 // Start begins periodic policy evaluation
 func (pm *PolicyManager) Start(ctx context.Context) error {
 	pm.mu.Lock()
@@ -101,11 +117,11 @@ func (pm *PolicyManager) run(ctx context.Context) {
 func (pm *PolicyManager) evaluatePolicies(ctx context.Context) {
 	policies, err := pm.store.List(ctx)
 	if err != nil {
-		log.Printf("Failed to list policies: %v", err)
+		pm.log().ErrorContext(ctx, "Failed to list policies", "err", err)
 		return
 	}
 
 	if err := pm.engine.EvaluatePolicies(ctx, policies); err != nil {
-		log.Printf("Failed to evaluate policies: %v", err)
+		pm.log().ErrorContext(ctx, "Failed to evaluate policies", "err", err)
 	}
 }
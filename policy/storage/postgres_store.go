@@ -32,13 +32,7 @@ func (s *PostgresPolicyStore) Store(ctx context.Context, p *policy.Policy) error
 		INSERT INTO policies (id, policy_type, parameters, created_at, updated_at)
 		VALUES (?, ?, ?, ?, ?)
 	`
-	if s.db.Driver() == "postgres" {
-		query = `
-			INSERT INTO policies (id, policy_type, parameters, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5)
-		`
-	}
-	_, err = s.db.ExecContext(ctx, query, p.ID.String(), string(p.Type), params, p.CreatedAt, p.UpdatedAt)
+	_, err = s.db.ExecContext(ctx, s.db.Rebind(query), p.ID.String(), string(p.Type), params, p.CreatedAt, p.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to insert policy: %w", err)
 	}
@@ -48,10 +42,7 @@ func (s *PostgresPolicyStore) Store(ctx context.Context, p *policy.Policy) error
 // Get implements policy.PolicyStore
 func (s *PostgresPolicyStore) Get(ctx context.Context, id string) (*policy.Policy, error) {
 	query := `SELECT parameters FROM policies WHERE id = ?`
-	if s.db.Driver() == "postgres" {
-		query = `SELECT parameters FROM policies WHERE id = $1`
-	}
-	row := s.db.QueryRowContext(ctx, query, id)
+	row := s.db.QueryRowContext(ctx, s.db.Rebind(query), id)
 	var params []byte
 	if err := row.Scan(&params); err != nil {
 		if err == sql.ErrNoRows {
@@ -92,10 +83,7 @@ func (s *PostgresPolicyStore) List(ctx context.Context) ([]*policy.Policy, error
 // ListByType implements policy.PolicyStore
 func (s *PostgresPolicyStore) ListByType(ctx context.Context, policyType policy.PolicyType) ([]*policy.Policy, error) {
 	query := `SELECT parameters FROM policies WHERE policy_type = ? ORDER BY created_at DESC`
-	if s.db.Driver() == "postgres" {
-		query = `SELECT parameters FROM policies WHERE policy_type = $1 ORDER BY created_at DESC`
-	}
-	rows, err := s.db.QueryContext(ctx, query, string(policyType))
+	rows, err := s.db.QueryContext(ctx, s.db.Rebind(query), string(policyType))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query policies: %w", err)
 	}
@@ -122,10 +110,7 @@ func (s *PostgresPolicyStore) Update(ctx context.Context, p *policy.Policy) erro
 		return fmt.Errorf("failed to marshal policy: %w", err)
 	}
 	query := `UPDATE policies SET parameters = ?, updated_at = ? WHERE id = ?`
-	if s.db.Driver() == "postgres" {
-		query = `UPDATE policies SET parameters = $1, updated_at = $2 WHERE id = $3`
-	}
-	_, err = s.db.ExecContext(ctx, query, params, time.Now().UTC(), p.ID.String())
+	_, err = s.db.ExecContext(ctx, s.db.Rebind(query), params, time.Now().UTC(), p.ID.String())
 	if err != nil {
 		return fmt.Errorf("failed to update policy: %w", err)
 	}
@@ -135,10 +120,7 @@ func (s *PostgresPolicyStore) Update(ctx context.Context, p *policy.Policy) erro
 // Delete implements policy.PolicyStore
 func (s *PostgresPolicyStore) Delete(ctx context.Context, id string) error {
 	query := `DELETE FROM policies WHERE id = ?`
-	if s.db.Driver() == "postgres" {
-		query = `DELETE FROM policies WHERE id = $1`
-	}
-	_, err := s.db.ExecContext(ctx, query, id)
+	_, err := s.db.ExecContext(ctx, s.db.Rebind(query), id)
 	if err != nil {
 		return fmt.Errorf("failed to delete policy: %w", err)
 	}
@@ -0,0 +1,18 @@
+package server
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// dialAppServer opens a connection to the AppShardService hosted at
+// location, using mutual TLS when the server has TLS configured. Calls
+// carry the request ID of the context they are made with.
+func (s *Server) dialAppServer(location string) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if s.tls != nil {
+		creds = credentials.NewTLS(s.tls.clientTLSConfig())
+	}
+	return grpc.Dial(location, grpc.WithTransportCredentials(creds), grpc.WithUnaryInterceptor(propagateRequestID), grpc.WithBlock(), grpc.WithTimeout(s.notificationTimeout))
+}
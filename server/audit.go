@@ -0,0 +1,183 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// auditTarget describes the entity a mutating RPC acts on
+type auditTarget struct {
+	entityType string
+	entityID   func(req, resp interface{}) string
+}
+
+// auditedMethods lists the RPCs recorded in the audit log. Heartbeats are
+// left out: they are liveness signals sent every few seconds, not changes an
+// operator makes to the cluster.
+var auditedMethods = map[string]auditTarget{
+	shardmanagerpb.NodeService_RegisterNode_FullMethodName: {"node", func(req, resp interface{}) string {
+		if r, ok := resp.(*shardmanagerpb.RegisterNodeResponse); ok && r.NodeId != "" {
+			return r.NodeId
+		}
+		n := requestField((*shardmanagerpb.RegisterNodeRequest).GetNode)(req, resp)
+		if n.GetId() != "" {
+			return n.GetId()
+		}
+		return n.GetName()
+	}},
+	shardmanagerpb.ShardService_RegisterShard_FullMethodName: {"shard", func(req, resp interface{}) string {
+		return requestField((*shardmanagerpb.RegisterShardRequest).GetShard)(req, resp).GetId()
+	}},
+	shardmanagerpb.ShardService_AssignShard_FullMethodName:       {"shard", requestField((*shardmanagerpb.AssignShardRequest).GetShardId)},
+	shardmanagerpb.ShardService_MigrateShard_FullMethodName:      {"shard", requestField((*shardmanagerpb.MigrateShardRequest).GetShardId)},
+	shardmanagerpb.ShardService_UpdateShardStatus_FullMethodName: {"shard", requestField((*shardmanagerpb.UpdateShardStatusRequest).GetShardId)},
+	shardmanagerpb.NodeService_DrainNode_FullMethodName:          {"node", requestField((*shardmanagerpb.DrainNodeRequest).GetNodeId)},
+	shardmanagerpb.NodeService_UndrainNode_FullMethodName:        {"node", requestField((*shardmanagerpb.UndrainNodeRequest).GetNodeId)},
+	shardmanagerpb.ShardService_RollbackShard_FullMethodName:     {"shard", requestField((*shardmanagerpb.RollbackShardRequest).GetShardId)},
+	shardmanagerpb.PolicyService_SetPolicy_FullMethodName:        {"policy", requestField((*shardmanagerpb.SetPolicyRequest).GetPolicyType)},
+	shardmanagerpb.FailureService_ReportFailure_FullMethodName:   {"failure", requestField((*shardmanagerpb.ReportFailureRequest).GetId)},
+}
+
+// requestField reads a field of requests of type R, giving the zero value
+// for any other request
+func requestField[R, T any](get func(R) T) func(req, resp interface{}) T {
+	return func(req, resp interface{}) T {
+		if r, ok := req.(R); ok {
+			return get(r)
+		}
+		var zero T
+		return zero
+	}
+}
+
+// auditInterceptor records every audited RPC, successful or not, once the
+// handler has returned. Calls rejected before reaching it, by auth or by a
+// follower, are recorded by the interceptor that rejected them.
+func (s *Server) auditInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if _, ok := auditedMethods[info.FullMethod]; !ok {
+		return handler(ctx, req)
+	}
+	resp, err := handler(ctx, req)
+	s.auditCall(ctx, callerIdentity(ctx), info.FullMethod, req, resp, err)
+	return resp, err
+}
+
+// auditCall records a call to an audited RPC made by actor. Calls to other
+// RPCs are ignored.
+func (s *Server) auditCall(ctx context.Context, actor, method string, req, resp interface{}, err error) {
+	target, ok := auditedMethods[method]
+	if !ok {
+		return
+	}
+	event := &db.AuditEvent{
+		Actor:      actor,
+		Method:     method,
+		EntityType: target.entityType,
+		EntityID:   target.entityID(req, resp),
+		Result:     status.Code(err).String(),
+	}
+	if err != nil {
+		event.Error = status.Convert(err).Message()
+	}
+	if msg, ok := req.(proto.Message); ok {
+		if payload, merr := protojson.Marshal(msg); merr == nil {
+			event.Request = payload
+		}
+	}
+	s.recordAuditEvent(ctx, event)
+}
+
+// SystemActor is the audit log actor of changes the server makes by itself
+const SystemActor = "system"
+
+// Audit log methods of changes the server makes by itself
+const (
+	AuditReconcile   = "system/Reconcile"
+	AuditRevokeLease = "system/RevokeLease"
+	AuditHandoff     = "system/Handoff"
+	AuditRebalance   = "system/Rebalance"
+)
+
+// auditSystem records a change the server made by itself, with details as
+// the request payload. It is recorded even if ctx has been cancelled.
+func (s *Server) auditSystem(ctx context.Context, method, entityType, entityID string, details map[string]string, err error) {
+	event := &db.AuditEvent{
+		Actor:      SystemActor,
+		Method:     method,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Result:     status.Code(err).String(),
+	}
+	if err != nil {
+		event.Error = status.Convert(err).Message()
+	}
+	if payload, merr := json.Marshal(details); merr == nil {
+		event.Request = payload
+	}
+	s.recordAuditEvent(context.WithoutCancel(ctx), event)
+}
+
+// recordAuditEvent writes an audit event. A failure is logged but does not
+// fail the change, which has already taken effect.
+func (s *Server) recordAuditEvent(ctx context.Context, event *db.AuditEvent) {
+	if err := s.db.RecordAuditEvent(ctx, event); err != nil {
+		s.log().WarnContext(ctx, "Could not record audit event", "method", event.Method, "err", err)
+	}
+}
+
+// callerIdentity names the caller of an RPC for the audit log: the
+// authenticated principal if there is one, otherwise its address
+func callerIdentity(ctx context.Context) string {
+	if p, ok := principalFromContext(ctx); ok {
+		return p.Name
+	}
+	return peerAddress(ctx)
+}
+
+// AuditService implementation
+func (s *Server) ListAuditEvents(ctx context.Context, req *shardmanagerpb.ListAuditEventsRequest) (*shardmanagerpb.ListAuditEventsResponse, error) {
+	if req.Limit < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	}
+	filter := db.AuditFilter{
+		EntityID: req.EntityId,
+		Actor:    req.Actor,
+		Limit:    int(req.Limit),
+	}
+	if req.SinceUnixMs != 0 {
+		filter.Since = time.UnixMilli(req.SinceUnixMs)
+	}
+	if req.UntilUnixMs != 0 {
+		filter.Until = time.UnixMilli(req.UntilUnixMs)
+	}
+
+	events, err := s.db.ListAuditEvents(ctx, filter)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	pbEvents := make([]*shardmanagerpb.AuditEvent, len(events))
+	for i, event := range events {
+		pbEvents[i] = &shardmanagerpb.AuditEvent{
+			Id:              event.ID.String(),
+			Actor:           event.Actor,
+			Method:          event.Method,
+			EntityType:      event.EntityType,
+			EntityId:        event.EntityID,
+			Request:         string(event.Request),
+			Result:          event.Result,
+			Error:           event.Error,
+			CreatedAtUnixMs: event.CreatedAt.UnixMilli(),
+		}
+	}
+	return &shardmanagerpb.ListAuditEventsResponse{Events: pbEvents}, nil
+}
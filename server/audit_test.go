@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/server/testutil"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+func TestAuditLog(t *testing.T) {
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 7), Port: 4242},
+	})
+
+	call := func(method string, req interface{}, handler grpc.UnaryHandler) error {
+		_, err := server.auditInterceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+
+	shardID := uuid.New().String()
+	nodeID := uuid.New().String()
+
+	t.Run("RecordsMutations", func(t *testing.T) {
+		err := call(shardmanagerpb.ShardService_AssignShard_FullMethodName,
+			&shardmanagerpb.AssignShardRequest{ShardId: shardID, NodeId: nodeID},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return &shardmanagerpb.AssignShardResponse{Success: true}, nil
+			})
+		require.NoError(t, err)
+
+		err = call(shardmanagerpb.ShardService_MigrateShard_FullMethodName,
+			&shardmanagerpb.MigrateShardRequest{ShardId: shardID},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, status.Error(codes.FailedPrecondition, "shard is not on the source node")
+			})
+		require.Error(t, err)
+
+		resp, err := server.ListAuditEvents(ctx, &shardmanagerpb.ListAuditEventsRequest{EntityId: shardID})
+		require.NoError(t, err)
+		require.Len(t, resp.Events, 2)
+
+		migrate, assign := resp.Events[0], resp.Events[1]
+		assert.Equal(t, shardmanagerpb.ShardService_MigrateShard_FullMethodName, migrate.Method)
+		assert.Equal(t, codes.FailedPrecondition.String(), migrate.Result)
+		assert.Equal(t, "shard is not on the source node", migrate.Error)
+
+		assert.Equal(t, "shard", assign.EntityType)
+		assert.Equal(t, "10.0.0.7:4242", assign.Actor)
+		assert.Equal(t, codes.OK.String(), assign.Result)
+		assert.Contains(t, assign.Request, nodeID)
+	})
+
+	t.Run("SkipsReadsAndHeartbeats", func(t *testing.T) {
+		for _, method := range []string{
+			shardmanagerpb.ShardService_ListShards_FullMethodName,
+			shardmanagerpb.NodeService_Heartbeat_FullMethodName,
+		} {
+			require.NoError(t, call(method, nil, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			}))
+		}
+		resp, err := server.ListAuditEvents(ctx, &shardmanagerpb.ListAuditEventsRequest{})
+		require.NoError(t, err)
+		assert.Len(t, resp.Events, 2)
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		resp, err := server.ListAuditEvents(ctx, &shardmanagerpb.ListAuditEventsRequest{Actor: "someone-else"})
+		require.NoError(t, err)
+		assert.Empty(t, resp.Events)
+
+		resp, err = server.ListAuditEvents(ctx, &shardmanagerpb.ListAuditEventsRequest{
+			SinceUnixMs: time.Now().Add(time.Hour).UnixMilli(),
+		})
+		require.NoError(t, err)
+		assert.Empty(t, resp.Events)
+
+		resp, err = server.ListAuditEvents(ctx, &shardmanagerpb.ListAuditEventsRequest{Limit: 1})
+		require.NoError(t, err)
+		assert.Len(t, resp.Events, 1)
+
+		_, err = server.ListAuditEvents(ctx, &shardmanagerpb.ListAuditEventsRequest{Limit: -1})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
+
+func TestAuditRejectedCalls(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+
+	path := filepath.Join(t.TempDir(), "auth.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testAuthConfig), 0o600))
+	cfg, err := LoadAuthConfig(path)
+	require.NoError(t, err)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		t.Fatal("rejected calls must not reach the handler")
+		return nil, nil
+	}
+	assign := &shardmanagerpb.AssignShardRequest{ShardId: uuid.New().String(), NodeId: uuid.New().String()}
+	info := &grpc.UnaryServerInfo{FullMethod: shardmanagerpb.ShardService_AssignShard_FullMethodName}
+
+	asReader := metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer reader-token"))
+	_, err = server.authInterceptor(cfg)(asReader, assign, info, handler)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = server.authInterceptor(cfg)(ctx, assign, info, handler)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// A follower turns writes away
+	opts := DefaultOptions()
+	opts.LeaderElection = true
+	opts.AdvertiseAddr = "b:7427"
+	follower := NewServerWithOptions(mockDB, opts)
+	_, err = follower.leaderInterceptor(ctx, assign, info, handler)
+	require.Equal(t, codes.Unavailable, status.Code(err))
+
+	resp, err := server.ListAuditEvents(ctx, &shardmanagerpb.ListAuditEventsRequest{EntityId: assign.ShardId})
+	require.NoError(t, err)
+	require.Len(t, resp.Events, 3)
+	results := make(map[string]string)
+	for _, event := range resp.Events {
+		assert.Equal(t, info.FullMethod, event.Method)
+		assert.Contains(t, event.Request, assign.NodeId)
+		results[event.Result] = event.Actor
+	}
+	assert.Equal(t, "dashboard", results[codes.PermissionDenied.String()])
+	assert.Contains(t, results, codes.Unauthenticated.String())
+	assert.Contains(t, results, codes.Unavailable.String())
+}
+
+func TestAuditSystemChanges(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+
+	from := &db.Node{ID: uuid.New(), Status: "active", AssignmentMode: AssignmentModePull}
+	to := &db.Node{ID: uuid.New(), Status: "active", AssignmentMode: AssignmentModePull}
+	require.NoError(t, mockDB.RegisterNode(ctx, from))
+	require.NoError(t, mockDB.RegisterNode(ctx, to))
+	shard := &db.Shard{ID: uuid.New(), NodeID: &to.ID, Status: "active"}
+	require.NoError(t, mockDB.RegisterShard(ctx, shard))
+
+	require.True(t, server.handoffShard(ctx, shard.ID, &from.ID, to.ID))
+	require.NoError(t, server.revokeLease(ctx, shard.ID, to.ID, "test"))
+	server.applyCorrection(ctx, from, shard.ID, ReconcileActionDrop, "primary", func(ctx context.Context) error {
+		return errors.New("app server refused: busy")
+	})
+
+	resp, err := server.ListAuditEvents(ctx, &shardmanagerpb.ListAuditEventsRequest{Actor: SystemActor})
+	require.NoError(t, err)
+	require.Len(t, resp.Events, 3)
+	reconcile, revoke, handoff := resp.Events[0], resp.Events[1], resp.Events[2]
+
+	assert.Equal(t, AuditHandoff, handoff.Method)
+	assert.Equal(t, shard.ID.String(), handoff.EntityId)
+	assert.Equal(t, codes.OK.String(), handoff.Result)
+	assert.Contains(t, handoff.Request, from.ID.String())
+
+	assert.Equal(t, AuditRevokeLease, revoke.Method)
+	assert.Contains(t, revoke.Request, to.ID.String())
+
+	assert.Equal(t, AuditReconcile, reconcile.Method)
+	assert.Contains(t, reconcile.Request, ReconcileActionDrop)
+	assert.Equal(t, codes.Unknown.String(), reconcile.Result)
+	assert.Equal(t, "app server refused: busy", reconcile.Error)
+}
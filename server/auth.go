@@ -0,0 +1,295 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"gopkg.in/yaml.v3"
+)
+
+// Roles a caller can be granted
+const (
+	RoleAdmin  = "admin"  // may call every RPC
+	RoleReader = "reader" // may call List and Get RPCs
+	RoleNode   = "node"   // node agent, may only act for its own node
+)
+
+// AuthConfig maps credentials to principals. It is loaded from a YAML or
+// JSON file:
+//
+//	tokens:
+//	  - token: "s3cret"
+//	    name: ops
+//	    role: admin
+//	certificates:
+//	  - subject: node-a.example.com   # client certificate CN or DNS SAN
+//	    role: node
+//	    node: node-a                  # node name or ID, defaults to subject
+type AuthConfig struct {
+	Tokens       []CredentialBinding `yaml:"tokens" json:"tokens"`
+	Certificates []CredentialBinding `yaml:"certificates" json:"certificates"`
+}
+
+// CredentialBinding grants a role to the holder of a token or certificate
+type CredentialBinding struct {
+	Token   string `yaml:"token,omitempty" json:"token,omitempty"`
+	Subject string `yaml:"subject,omitempty" json:"subject,omitempty"`
+	Name    string `yaml:"name,omitempty" json:"name,omitempty"`
+	Role    string `yaml:"role" json:"role"`
+	Node    string `yaml:"node,omitempty" json:"node,omitempty"`
+}
+
+// Principal is an authenticated caller
+type Principal struct {
+	Name string
+	Role string
+	Node string // node name or ID for RoleNode
+}
+
+type principalKey struct{}
+
+// principalFromContext returns the caller authenticated by authInterceptor
+func principalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok
+}
+
+// LoadAuthConfig reads and validates an auth config file
+func LoadAuthConfig(path string) (*AuthConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &AuthConfig{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse auth config %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("auth config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks that every binding has a credential and a known role
+func (c *AuthConfig) Validate() error {
+	for i, b := range c.Tokens {
+		if b.Token == "" {
+			return fmt.Errorf("tokens[%d]: token is required", i)
+		}
+		if err := validateBinding(b); err != nil {
+			return fmt.Errorf("tokens[%d]: %w", i, err)
+		}
+	}
+	for i, b := range c.Certificates {
+		if b.Subject == "" {
+			return fmt.Errorf("certificates[%d]: subject is required", i)
+		}
+		if err := validateBinding(b); err != nil {
+			return fmt.Errorf("certificates[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func validateBinding(b CredentialBinding) error {
+	switch b.Role {
+	case RoleAdmin, RoleReader:
+	case RoleNode:
+		if b.Node == "" && b.Name == "" && b.Subject == "" {
+			return fmt.Errorf("node role needs a node")
+		}
+	default:
+		return fmt.Errorf("unknown role %q", b.Role)
+	}
+	return nil
+}
+
+// authenticate identifies the caller from a bearer token or a verified
+// client certificate
+func (c *AuthConfig) authenticate(ctx context.Context) (*Principal, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, v := range md.Get("authorization") {
+			token, found := strings.CutPrefix(v, "Bearer ")
+			if !found {
+				continue
+			}
+			for _, b := range c.Tokens {
+				if subtle.ConstantTimeCompare([]byte(token), []byte(b.Token)) == 1 {
+					return b.principal(b.Name), nil
+				}
+			}
+			return nil, status.Error(codes.Unauthenticated, "unknown bearer token")
+		}
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.VerifiedChains) > 0 {
+			leaf := tlsInfo.State.VerifiedChains[0][0]
+			subjects := append([]string{leaf.Subject.CommonName}, leaf.DNSNames...)
+			for _, b := range c.Certificates {
+				for _, subject := range subjects {
+					if subject != "" && subject == b.Subject {
+						return b.principal(subject), nil
+					}
+				}
+			}
+			return nil, status.Errorf(codes.Unauthenticated, "client certificate %q is not authorized", leaf.Subject.CommonName)
+		}
+	}
+	return nil, status.Error(codes.Unauthenticated, "missing credentials")
+}
+
+func (b CredentialBinding) principal(fallbackName string) *Principal {
+	p := &Principal{Name: b.Name, Role: b.Role, Node: b.Node}
+	if p.Name == "" {
+		p.Name = fallbackName
+	}
+	if p.Role == RoleNode && p.Node == "" {
+		p.Node = p.Name
+	}
+	return p
+}
+
+// readMethods may be called by readers as well as admins
+var readMethods = map[string]bool{
+	shardmanagerpb.NodeService_ListNodes_FullMethodName:             true,
+	shardmanagerpb.ShardService_ListShards_FullMethodName:           true,
+	shardmanagerpb.ShardService_GetShardInfo_FullMethodName:         true,
+	shardmanagerpb.ShardService_GetShardHistory_FullMethodName:      true,
+	shardmanagerpb.PolicyService_GetPolicy_FullMethodName:           true,
+	shardmanagerpb.MonitoringService_GetDistribution_FullMethodName: true,
+	shardmanagerpb.MonitoringService_GetHealth_FullMethodName:       true,
+	shardmanagerpb.AuditService_ListAuditEvents_FullMethodName:      true,
+}
+
+// authInterceptor authenticates every call and authorizes it against the
+// caller's role. Methods it does not know are reserved for admins.
+func (s *Server) authInterceptor(cfg *AuthConfig) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		p, err := cfg.authenticate(ctx)
+		if err != nil {
+			s.log().WarnContext(ctx, "Rejected unauthenticated call", "method", info.FullMethod, "peer", peerAddress(ctx), "err", err)
+			s.auditCall(ctx, peerAddress(ctx), info.FullMethod, req, nil, err)
+			return nil, err
+		}
+		if err := s.authorize(ctx, p, info.FullMethod, req); err != nil {
+			s.log().WarnContext(ctx, "Denied call", "method", info.FullMethod, "principal", p.Name, "role", p.Role, "err", err)
+			s.auditCall(ctx, p.Name, info.FullMethod, req, nil, err)
+			return nil, err
+		}
+		return handler(context.WithValue(ctx, principalKey{}, p), req)
+	}
+}
+
+// authorize decides whether p may call method with req
+func (s *Server) authorize(ctx context.Context, p *Principal, method string, req interface{}) error {
+	switch p.Role {
+	case RoleAdmin:
+		return nil
+	case RoleReader:
+		if readMethods[method] {
+			return nil
+		}
+	case RoleNode:
+		return s.authorizeNode(ctx, p, method, req)
+	}
+	return status.Errorf(codes.PermissionDenied, "role %s may not call %s", p.Role, method)
+}
+
+// authorizeNode lets a node agent register, heartbeat and report failures
+// for its own node only. Registration is allowed so that agents can come up
+// without an admin pre-registering them.
+func (s *Server) authorizeNode(ctx context.Context, p *Principal, method string, req interface{}) error {
+	var own bool
+	var err error
+	switch method {
+	case shardmanagerpb.NodeService_RegisterNode_FullMethodName:
+		// A node may not take on another node's name or ID
+		if n := req.(*shardmanagerpb.RegisterNodeRequest).Node; n != nil && (n.Name == "" || n.Name == p.Node) {
+			own = n.Name == p.Node
+			if n.Id != "" {
+				own, err = s.isOwnNode(ctx, p, n.Id)
+				if !own && err == nil && n.Name == p.Node {
+					own, err = s.isUnusedNodeID(ctx, n.Id)
+				}
+			}
+		}
+	case shardmanagerpb.NodeService_Heartbeat_FullMethodName:
+		own, err = s.isOwnNode(ctx, p, req.(*shardmanagerpb.HeartbeatRequest).NodeId)
+	case shardmanagerpb.FailureService_ReportFailure_FullMethodName:
+		own, err = s.isOwnEntity(ctx, p, req.(*shardmanagerpb.ReportFailureRequest).Id)
+	default:
+		return status.Errorf(codes.PermissionDenied, "role %s may not call %s", p.Role, method)
+	}
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if !own {
+		return status.Errorf(codes.PermissionDenied, "node %s may only act for itself", p.Node)
+	}
+	return nil
+}
+
+// isOwnNode reports whether nodeID is the node bound to p, which may be
+// bound by ID or by name
+func (s *Server) isOwnNode(ctx context.Context, p *Principal, nodeID string) (bool, error) {
+	if nodeID == "" {
+		return false, nil
+	}
+	if nodeID == p.Node {
+		return true, nil
+	}
+	node, err := s.db.GetNodeByName(ctx, p.Node)
+	if err != nil || node == nil {
+		return false, err
+	}
+	return node.ID.String() == nodeID, nil
+}
+
+// isUnusedNodeID reports whether nodeID names no registered node, so that a
+// node registering under its own name may choose it
+func (s *Server) isUnusedNodeID(ctx context.Context, nodeID string) (bool, error) {
+	id, err := uuid.Parse(nodeID)
+	if err != nil {
+		// RegisterNode rejects it along with the name
+		return true, nil
+	}
+	node, err := s.db.GetNodeInfo(ctx, id)
+	return node == nil, err
+}
+
+// isOwnEntity reports whether entityID is p's node or a shard assigned to it
+func (s *Server) isOwnEntity(ctx context.Context, p *Principal, entityID string) (bool, error) {
+	own, err := s.isOwnNode(ctx, p, entityID)
+	if err != nil || own {
+		return own, err
+	}
+	shardID, err := uuid.Parse(entityID)
+	if err != nil {
+		return false, nil
+	}
+	shard, err := s.db.GetShardInfo(ctx, shardID)
+	if err != nil || shard == nil || shard.NodeID == nil {
+		return false, err
+	}
+	return s.isOwnNode(ctx, p, shard.NodeID.String())
+}
+
+// peerAddress returns the network address of the caller
+func peerAddress(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return "unknown"
+}
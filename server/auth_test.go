@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/server/testutil"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+const testAuthConfig = `
+tokens:
+  - token: admin-token
+    name: ops
+    role: admin
+  - token: reader-token
+    name: dashboard
+    role: reader
+  - token: node-token
+    role: node
+    node: node-a
+certificates:
+  - subject: node-b.example.com
+    role: node
+    node: node-b
+`
+
+func TestLoadAuthConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "auth.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testAuthConfig), 0o600))
+	cfg, err := LoadAuthConfig(path)
+	require.NoError(t, err)
+	assert.Len(t, cfg.Tokens, 3)
+	assert.Equal(t, "node-b", cfg.Certificates[0].Node)
+
+	path = filepath.Join(dir, "auth.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"tokens": [{"token": "t", "role": "reader"}]}`), 0o600))
+	cfg, err = LoadAuthConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, RoleReader, cfg.Tokens[0].Role)
+
+	path = filepath.Join(dir, "bad.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("tokens:\n  - token: t\n    role: superuser\n"), 0o600))
+	_, err = LoadAuthConfig(path)
+	assert.ErrorContains(t, err, "unknown role")
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+
+	path := filepath.Join(t.TempDir(), "auth.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testAuthConfig), 0o600))
+	cfg, err := LoadAuthConfig(path)
+	require.NoError(t, err)
+	intercept := server.authInterceptor(cfg)
+
+	nodeA := &db.Node{ID: uuid.New(), Name: "node-a", Status: "active"}
+	nodeB := &db.Node{ID: uuid.New(), Name: "node-b", Status: "active"}
+	require.NoError(t, mockDB.RegisterNode(ctx, nodeA))
+	require.NoError(t, mockDB.RegisterNode(ctx, nodeB))
+	shardOnA := &db.Shard{ID: uuid.New(), NodeID: &nodeA.ID, Status: "active"}
+	require.NoError(t, mockDB.RegisterShard(ctx, shardOnA))
+
+	withToken := func(token string) context.Context {
+		return metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer "+token))
+	}
+	withCert := func(cn string) context.Context {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		return peer.NewContext(ctx, &peer.Peer{AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+		}})
+	}
+	call := func(ctx context.Context, method string, req interface{}) (*Principal, error) {
+		var got *Principal
+		_, err := intercept(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			got, _ = principalFromContext(ctx)
+			return nil, nil
+		})
+		return got, err
+	}
+
+	listNodes := shardmanagerpb.NodeService_ListNodes_FullMethodName
+	migrate := shardmanagerpb.ShardService_MigrateShard_FullMethodName
+	heartbeat := shardmanagerpb.NodeService_Heartbeat_FullMethodName
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		_, err := call(ctx, listNodes, &shardmanagerpb.ListNodesRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		_, err = call(withToken("wrong"), listNodes, &shardmanagerpb.ListNodesRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		_, err = call(withCert("stranger.example.com"), listNodes, &shardmanagerpb.ListNodesRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Admin", func(t *testing.T) {
+		p, err := call(withToken("admin-token"), migrate, &shardmanagerpb.MigrateShardRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, "ops", p.Name)
+	})
+
+	t.Run("Reader", func(t *testing.T) {
+		_, err := call(withToken("reader-token"), listNodes, &shardmanagerpb.ListNodesRequest{})
+		assert.NoError(t, err)
+
+		_, err = call(withToken("reader-token"), migrate, &shardmanagerpb.MigrateShardRequest{})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("NodeActsOnlyForItself", func(t *testing.T) {
+		_, err := call(withToken("node-token"), heartbeat, &shardmanagerpb.HeartbeatRequest{NodeId: nodeA.ID.String()})
+		assert.NoError(t, err)
+
+		_, err = call(withToken("node-token"), heartbeat, &shardmanagerpb.HeartbeatRequest{NodeId: nodeB.ID.String()})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		_, err = call(withToken("node-token"), listNodes, &shardmanagerpb.ListNodesRequest{})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		report := shardmanagerpb.FailureService_ReportFailure_FullMethodName
+		_, err = call(withToken("node-token"), report, &shardmanagerpb.ReportFailureRequest{Id: shardOnA.ID.String()})
+		assert.NoError(t, err)
+
+		_, err = call(withToken("node-token"), report, &shardmanagerpb.ReportFailureRequest{Id: nodeB.ID.String()})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		register := shardmanagerpb.NodeService_RegisterNode_FullMethodName
+		_, err = call(withToken("node-token"), register, &shardmanagerpb.RegisterNodeRequest{Node: &shardmanagerpb.Node{Name: "node-a"}})
+		assert.NoError(t, err)
+
+		_, err = call(withToken("node-token"), register, &shardmanagerpb.RegisterNodeRequest{Node: &shardmanagerpb.Node{Name: "node-b"}})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		// Its own name does not let it take over another node's record
+		_, err = call(withToken("node-token"), register, &shardmanagerpb.RegisterNodeRequest{Node: &shardmanagerpb.Node{Name: "node-a", Id: nodeB.ID.String()}})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+		_, err = call(withToken("node-token"), register, &shardmanagerpb.RegisterNodeRequest{Node: &shardmanagerpb.Node{Name: "node-a", Id: nodeA.ID.String()}})
+		assert.NoError(t, err)
+
+		_, err = call(withToken("node-token"), register, &shardmanagerpb.RegisterNodeRequest{Node: &shardmanagerpb.Node{Name: "node-a", Id: uuid.New().String()}})
+		assert.NoError(t, err, "a fresh ID is the node's to choose")
+	})
+
+	t.Run("ClientCertificate", func(t *testing.T) {
+		p, err := call(withCert("node-b.example.com"), heartbeat, &shardmanagerpb.HeartbeatRequest{NodeId: nodeB.ID.String()})
+		require.NoError(t, err)
+		assert.Equal(t, RoleNode, p.Role)
+		assert.Equal(t, "node-b", p.Node)
+
+		_, err = call(withCert("node-b.example.com"), heartbeat, &shardmanagerpb.HeartbeatRequest{NodeId: nodeA.ID.String()})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+}
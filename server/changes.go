@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/seaweedfs/shardmanager/db"
+)
+
+const (
+	// changeBuffer is how many changes a subscriber may fall behind by
+	changeBuffer = 256
+	// changeFeedRetry is how long to wait before watching again after the
+	// change feed fails
+	changeFeedRetry = 5 * time.Second
+)
+
+// changeHub fans database changes made by any manager instance out to the
+// local subscribers that keep in-memory state
+type changeHub struct {
+	mu   sync.Mutex
+	subs map[chan db.Change]struct{}
+}
+
+func newChangeHub() *changeHub {
+	return &changeHub{subs: make(map[chan db.Change]struct{})}
+}
+
+// subscribe returns a channel of changes and a func that ends the
+// subscription
+func (h *changeHub) subscribe() (<-chan db.Change, func()) {
+	ch := make(chan db.Change, changeBuffer)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
+
+// publish delivers change to every subscriber without blocking. A
+// subscriber that has fallen behind loses its oldest change and is sent a
+// resync instead, since it can no longer trust its state.
+func (h *changeHub) publish(change db.Change) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- change:
+		default:
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- db.Change{Entity: db.ChangeResync}
+		}
+	}
+}
+
+// runChangeFeed relays every database change to the hub until ctx is
+// cancelled, watching again after failures
+func (s *Server) runChangeFeed(ctx context.Context) {
+	for {
+		err := s.db.WatchChanges(ctx, s.changes.publish)
+		if ctx.Err() != nil {
+			return
+		}
+		s.log().WarnContext(ctx, "Change feed stopped, retrying", "retry_in", changeFeedRetry, "err", err)
+		// Changes made while not watching are lost
+		s.changes.publish(db.Change{Entity: db.ChangeResync})
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(changeFeedRetry):
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/server/testutil"
+)
+
+func TestChangeFeed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+
+	changes, unsubscribe := server.changes.subscribe()
+	defer unsubscribe()
+	go server.runChangeFeed(ctx)
+
+	node := &db.Node{ID: uuid.New(), Name: "app-1", Location: "localhost:1", Status: "active"}
+	// Wait for the feed to start watching
+	require.Eventually(t, func() bool {
+		require.NoError(t, mockDB.RegisterNode(ctx, node))
+		select {
+		case c := <-changes:
+			return c == db.Change{Entity: db.ChangeNode, ID: node.ID.String()}
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, mockDB.SetPolicy(ctx, &db.Policy{ID: uuid.New(), PolicyType: "placement"}))
+	select {
+	case c := <-changes:
+		assert.Equal(t, db.Change{Entity: db.ChangePolicy, ID: "placement"}, c)
+	case <-time.After(time.Second):
+		t.Fatal("policy change not delivered")
+	}
+}
+
+func TestChangeHubOverflow(t *testing.T) {
+	hub := newChangeHub()
+	changes, unsubscribe := hub.subscribe()
+
+	for i := 0; i < changeBuffer+1; i++ {
+		hub.publish(db.Change{Entity: db.ChangeShard, ID: "s"})
+	}
+	// The subscriber fell behind, so its last change says to resync
+	require.Len(t, changes, changeBuffer)
+	var last db.Change
+	for len(changes) > 0 {
+		last = <-changes
+	}
+	assert.Equal(t, db.ChangeResync, last.Entity)
+
+	unsubscribe()
+	hub.publish(db.Change{Entity: db.ChangeNode})
+	assert.Empty(t, changes)
+}
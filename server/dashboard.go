@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+var dashboardTemplate = template.Must(template.ParseFS(dashboardFiles, "dashboard/index.html"))
+
+const (
+	// dashboardRefresh is how often the page reloads itself
+	dashboardRefresh = 10 * time.Second
+	// dashboardFailures is how many recent failures the page lists
+	dashboardFailures = 20
+)
+
+// dashboardNode is a row of the nodes table
+type dashboardNode struct {
+	Node         *shardmanagerpb.Node
+	Shards       int
+	HeartbeatAge string
+	Stale        bool
+}
+
+// dashboardPage is the data rendered by dashboard/index.html
+type dashboardPage struct {
+	GeneratedAt    time.Time
+	RefreshSeconds int
+	Nodes          []dashboardNode
+	TotalShards    int
+	InactiveShards []*shardmanagerpb.Shard
+	Failures       []*db.FailureReport
+	Policies       []*db.Policy
+}
+
+// dashboardHandler serves a read-only status page. Its data is fetched
+// through the ListNodes, ListShards and GetDistribution handlers wrapped in
+// interceptor, so the page is subject to the same auth as the API.
+func (s *Server) dashboardHandler(interceptor grpc.UnaryServerInterceptor) http.Handler {
+	static, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		panic(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.FS(static))))
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		page, err := s.dashboardPage(gatewayContext(w, r), interceptor, s.now())
+		if err != nil {
+			st := status.Convert(err)
+			if st.Code() == codes.Unauthenticated {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			http.Error(w, st.Message(), httpStatusFromCode(st.Code()))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := dashboardTemplate.Execute(w, page); err != nil {
+			s.log().WarnContext(r.Context(), "Could not render dashboard", "err", err)
+		}
+	})
+	return mux
+}
+
+// dashboardPage gathers everything the status page shows
+func (s *Server) dashboardPage(ctx context.Context, interceptor grpc.UnaryServerInterceptor, now time.Time) (*dashboardPage, error) {
+	nodes, err := invokeUnary(ctx, s, interceptor, shardmanagerpb.NodeService_ListNodes_FullMethodName,
+		&shardmanagerpb.ListNodesRequest{}, s.ListNodes)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := invokeUnary(ctx, s, interceptor, shardmanagerpb.ShardService_ListShards_FullMethodName,
+		&shardmanagerpb.ListShardsRequest{}, s.ListShards)
+	if err != nil {
+		return nil, err
+	}
+	distribution, err := invokeUnary(ctx, s, interceptor, shardmanagerpb.MonitoringService_GetDistribution_FullMethodName,
+		&shardmanagerpb.GetDistributionRequest{}, s.GetDistribution)
+	if err != nil {
+		return nil, err
+	}
+	failures, err := s.db.ListFailureReports(ctx, dashboardFailures)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	policies, err := s.db.ListActivePolicies(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	page := &dashboardPage{
+		GeneratedAt:    now,
+		RefreshSeconds: int(dashboardRefresh / time.Second),
+		TotalShards:    len(shards.Shards),
+		Failures:       failures,
+		Policies:       policies,
+	}
+	for _, node := range nodes.Nodes {
+		row := dashboardNode{Node: node, HeartbeatAge: "never"}
+		if list, ok := distribution.NodeShards[node.Id]; ok {
+			row.Shards = len(list.ShardIds)
+		}
+		if node.LastHeartbeatUnixMs != 0 {
+			age := now.Sub(time.UnixMilli(node.LastHeartbeatUnixMs))
+			row.HeartbeatAge = age.Truncate(time.Second).String() + " ago"
+			row.Stale = node.Status == "active" && age > s.heartbeatTimeout
+		}
+		page.Nodes = append(page.Nodes, row)
+	}
+	sort.Slice(page.Nodes, func(i, j int) bool { return page.Nodes[i].Node.Location < page.Nodes[j].Node.Location })
+	for _, shard := range shards.Shards {
+		if shard.Status != "active" || shard.NodeId == "" {
+			page.InactiveShards = append(page.InactiveShards, shard)
+		}
+	}
+	sort.Slice(page.InactiveShards, func(i, j int) bool { return page.InactiveShards[i].Id < page.InactiveShards[j].Id })
+	return page, nil
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/server/testutil"
+)
+
+func TestDashboard(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+
+	live := &db.Node{ID: uuid.New(), Name: "app-live", Location: "localhost:1", Status: "active", CurrentLoad: 42}
+	stale := &db.Node{ID: uuid.New(), Name: "app-stale", Location: "localhost:2", Status: "active",
+		LastHeartbeat: time.Now().Add(-time.Hour)}
+	require.NoError(t, mockDB.RegisterNode(ctx, live))
+	require.NoError(t, mockDB.RegisterNode(ctx, stale))
+	require.NoError(t, mockDB.RegisterShard(ctx, &db.Shard{ID: uuid.New(), NodeID: &live.ID, Status: "active"}))
+	migrating := uuid.New()
+	require.NoError(t, mockDB.RegisterShard(ctx, &db.Shard{ID: migrating, NodeID: &live.ID, Status: "migrating"}))
+	require.NoError(t, mockDB.ReportFailure(ctx, "node_failure", stale.ID, json.RawMessage(`{"error":"timeout"}`)))
+	require.NoError(t, mockDB.SetPolicy(ctx, &db.Policy{ID: uuid.New(), PolicyType: "placement", Parameters: json.RawMessage(`{"spread":true}`)}))
+
+	t.Run("Page", func(t *testing.T) {
+		page, err := server.dashboardPage(ctx, nil, time.Now())
+		require.NoError(t, err)
+		require.Len(t, page.Nodes, 2)
+		assert.Equal(t, "app-live", page.Nodes[0].Node.Name)
+		assert.Equal(t, 2, page.Nodes[0].Shards)
+		assert.False(t, page.Nodes[0].Stale)
+		assert.True(t, page.Nodes[1].Stale)
+		require.Len(t, page.InactiveShards, 1)
+		assert.Equal(t, migrating.String(), page.InactiveShards[0].Id)
+		assert.Len(t, page.Failures, 1)
+		assert.Len(t, page.Policies, 1)
+	})
+
+	t.Run("HTML", func(t *testing.T) {
+		ts := httptest.NewServer(server.dashboardHandler(nil))
+		defer ts.Close()
+
+		resp, err := http.Get(ts.URL + "/")
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+		for _, want := range []string{"app-live", "42", migrating.String(), "node_failure", "placement", "{&#34;spread&#34;:true}"} {
+			assert.Contains(t, string(body), want)
+		}
+
+		resp, err = http.Get(ts.URL + "/static/style.css")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = http.Get(ts.URL + "/nodes")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("RequiresCredentials", func(t *testing.T) {
+		var cfg AuthConfig
+		require.NoError(t, yaml.Unmarshal([]byte(testAuthConfig), &cfg))
+		ts := httptest.NewServer(server.dashboardHandler(server.authInterceptor(&cfg)))
+		defer ts.Close()
+
+		resp, err := http.Get(ts.URL + "/")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Equal(t, "Bearer", resp.Header.Get("WWW-Authenticate"))
+
+		req, _ := http.NewRequest("GET", ts.URL+"/", nil)
+		req.Header.Set("Authorization", "Bearer reader-token")
+		resp, err = http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
@@ -0,0 +1,366 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// gatewayRoute maps an HTTP endpoint onto a gRPC handler. The request
+// message is decoded from the JSON body, then the query string, then the
+// path parameters named in pathFields, each overriding the last.
+type gatewayRoute struct {
+	pattern    string
+	fullMethod string
+	pathFields map[string]string // path wildcard -> request field
+	newRequest func() proto.Message
+	call       func(s *Server, ctx context.Context, req proto.Message) (proto.Message, error)
+}
+
+// route builds a gatewayRoute for a handler taking and returning typed messages
+func route[Req, Resp proto.Message](pattern, fullMethod string, pathFields map[string]string, newRequest func() Req, call func(s *Server, ctx context.Context, req Req) (Resp, error)) gatewayRoute {
+	return gatewayRoute{
+		pattern:    pattern,
+		fullMethod: fullMethod,
+		pathFields: pathFields,
+		newRequest: func() proto.Message { return newRequest() },
+		call: func(s *Server, ctx context.Context, req proto.Message) (proto.Message, error) {
+			return call(s, ctx, req.(Req))
+		},
+	}
+}
+
+// gatewayRoutes lists every endpoint served by the HTTP gateway
+var gatewayRoutes = []gatewayRoute{
+	// NodeService
+	route("POST /v1/nodes", shardmanagerpb.NodeService_RegisterNode_FullMethodName, nil,
+		func() *shardmanagerpb.RegisterNodeRequest { return &shardmanagerpb.RegisterNodeRequest{} }, (*Server).RegisterNode),
+	route("GET /v1/nodes", shardmanagerpb.NodeService_ListNodes_FullMethodName, nil,
+		func() *shardmanagerpb.ListNodesRequest { return &shardmanagerpb.ListNodesRequest{} }, (*Server).ListNodes),
+	route("POST /v1/nodes/{id}/heartbeat", shardmanagerpb.NodeService_Heartbeat_FullMethodName, map[string]string{"id": "node_id"},
+		func() *shardmanagerpb.HeartbeatRequest { return &shardmanagerpb.HeartbeatRequest{} }, (*Server).Heartbeat),
+	route("POST /v1/nodes/{id}/drain", shardmanagerpb.NodeService_DrainNode_FullMethodName, map[string]string{"id": "node_id"},
+		func() *shardmanagerpb.DrainNodeRequest { return &shardmanagerpb.DrainNodeRequest{} }, (*Server).DrainNode),
+	route("POST /v1/nodes/{id}/undrain", shardmanagerpb.NodeService_UndrainNode_FullMethodName, map[string]string{"id": "node_id"},
+		func() *shardmanagerpb.UndrainNodeRequest { return &shardmanagerpb.UndrainNodeRequest{} }, (*Server).UndrainNode),
+
+	// ShardService
+	route("POST /v1/shards", shardmanagerpb.ShardService_RegisterShard_FullMethodName, nil,
+		func() *shardmanagerpb.RegisterShardRequest { return &shardmanagerpb.RegisterShardRequest{} }, (*Server).RegisterShard),
+	route("GET /v1/shards", shardmanagerpb.ShardService_ListShards_FullMethodName, nil,
+		func() *shardmanagerpb.ListShardsRequest { return &shardmanagerpb.ListShardsRequest{} }, (*Server).ListShards),
+	route("GET /v1/shards/{id}", shardmanagerpb.ShardService_GetShardInfo_FullMethodName, map[string]string{"id": "shard_id"},
+		func() *shardmanagerpb.GetShardInfoRequest { return &shardmanagerpb.GetShardInfoRequest{} }, (*Server).GetShardInfo),
+	route("POST /v1/shards/{id}/assign", shardmanagerpb.ShardService_AssignShard_FullMethodName, map[string]string{"id": "shard_id"},
+		func() *shardmanagerpb.AssignShardRequest { return &shardmanagerpb.AssignShardRequest{} }, (*Server).AssignShard),
+	route("POST /v1/shards/{id}/migrate", shardmanagerpb.ShardService_MigrateShard_FullMethodName, map[string]string{"id": "shard_id"},
+		func() *shardmanagerpb.MigrateShardRequest { return &shardmanagerpb.MigrateShardRequest{} }, (*Server).MigrateShard),
+	route("PUT /v1/shards/{id}/status", shardmanagerpb.ShardService_UpdateShardStatus_FullMethodName, map[string]string{"id": "shard_id"},
+		func() *shardmanagerpb.UpdateShardStatusRequest { return &shardmanagerpb.UpdateShardStatusRequest{} }, (*Server).UpdateShardStatus),
+	route("GET /v1/shards/{id}/history", shardmanagerpb.ShardService_GetShardHistory_FullMethodName, map[string]string{"id": "shard_id"},
+		func() *shardmanagerpb.GetShardHistoryRequest { return &shardmanagerpb.GetShardHistoryRequest{} }, (*Server).GetShardHistory),
+	route("POST /v1/shards/{id}/rollback", shardmanagerpb.ShardService_RollbackShard_FullMethodName, map[string]string{"id": "shard_id"},
+		func() *shardmanagerpb.RollbackShardRequest { return &shardmanagerpb.RollbackShardRequest{} }, (*Server).RollbackShard),
+
+	// PolicyService
+	route("PUT /v1/policies/{type}", shardmanagerpb.PolicyService_SetPolicy_FullMethodName, map[string]string{"type": "policy_type"},
+		func() *shardmanagerpb.SetPolicyRequest { return &shardmanagerpb.SetPolicyRequest{} }, (*Server).SetPolicy),
+	route("GET /v1/policies/{type}", shardmanagerpb.PolicyService_GetPolicy_FullMethodName, map[string]string{"type": "policy_type"},
+		func() *shardmanagerpb.GetPolicyRequest { return &shardmanagerpb.GetPolicyRequest{} }, (*Server).GetPolicy),
+
+	// MonitoringService
+	route("GET /v1/distribution", shardmanagerpb.MonitoringService_GetDistribution_FullMethodName, nil,
+		func() *shardmanagerpb.GetDistributionRequest { return &shardmanagerpb.GetDistributionRequest{} }, (*Server).GetDistribution),
+	route("GET /v1/health", shardmanagerpb.MonitoringService_GetHealth_FullMethodName, nil,
+		func() *shardmanagerpb.GetHealthRequest { return &shardmanagerpb.GetHealthRequest{} }, (*Server).GetHealth),
+
+	// FailureService
+	route("POST /v1/failures", shardmanagerpb.FailureService_ReportFailure_FullMethodName, nil,
+		func() *shardmanagerpb.ReportFailureRequest { return &shardmanagerpb.ReportFailureRequest{} }, (*Server).ReportFailure),
+
+	// AuditService
+	route("GET /v1/audit", shardmanagerpb.AuditService_ListAuditEvents_FullMethodName, nil,
+		func() *shardmanagerpb.ListAuditEventsRequest { return &shardmanagerpb.ListAuditEventsRequest{} }, (*Server).ListAuditEvents),
+}
+
+// gatewayHandler serves the HTTP/JSON gateway. Every call passes through
+// interceptor, so the gateway is authenticated, audited and measured exactly
+// like the gRPC listener.
+func (s *Server) gatewayHandler(interceptor grpc.UnaryServerInterceptor) http.Handler {
+	mux := http.NewServeMux()
+	for _, rt := range gatewayRoutes {
+		mux.Handle(rt.pattern, s.gatewayEndpoint(rt, interceptor))
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if allowed := gatewayAllowedMethods(r.URL.Path); len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			s.writeGatewayStatus(w, http.StatusMethodNotAllowed,
+				status.Newf(codes.Unimplemented, "%s is not supported on %s", r.Method, r.URL.Path))
+			return
+		}
+		s.writeGatewayError(w, status.Errorf(codes.NotFound, "no endpoint %s %s", r.Method, r.URL.Path))
+	})
+	return mux
+}
+
+// gatewayAllowedMethods lists, sorted, the methods of the routes whose path
+// matches path. GET routes also answer HEAD, as in http.ServeMux.
+func gatewayAllowedMethods(path string) []string {
+	var allowed []string
+	for _, rt := range gatewayRoutes {
+		method, pattern, _ := strings.Cut(rt.pattern, " ")
+		if !gatewayPathMatches(pattern, path) {
+			continue
+		}
+		allowed = append(allowed, method)
+		if method == http.MethodGet {
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	slices.Sort(allowed)
+	return slices.Compact(allowed)
+}
+
+// gatewayPathMatches reports whether path fits a route pattern, where a
+// {wildcard} segment matches any one segment
+func gatewayPathMatches(pattern, path string) bool {
+	want, got := strings.Split(pattern, "/"), strings.Split(path, "/")
+	if len(want) != len(got) {
+		return false
+	}
+	for i, segment := range want {
+		if segment != got[i] && !(strings.HasPrefix(segment, "{") && got[i] != "") {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) gatewayEndpoint(rt gatewayRoute, interceptor grpc.UnaryServerInterceptor) http.Handler {
+	call := func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return rt.call(s, ctx, req)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := rt.newRequest()
+		if err := decodeGatewayRequest(r, rt.pathFields, req); err != nil {
+			s.writeGatewayError(w, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+
+		resp, err := invokeUnary(gatewayContext(w, r), s, interceptor, rt.fullMethod, req, call)
+		if err != nil {
+			s.writeGatewayError(w, err)
+			return
+		}
+		body, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(resp)
+		if err != nil {
+			s.writeGatewayError(w, status.Error(codes.Internal, err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+// invokeUnary calls handler with req through interceptor, as the gRPC server
+// would for fullMethod. A nil interceptor calls handler directly.
+func invokeUnary[Req, Resp any](ctx context.Context, s *Server, interceptor grpc.UnaryServerInterceptor, fullMethod string, req Req, handler func(context.Context, Req) (Resp, error)) (Resp, error) {
+	unary := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return handler(ctx, req.(Req))
+	}
+	var resp interface{}
+	var err error
+	if interceptor != nil {
+		resp, err = interceptor(ctx, req, &grpc.UnaryServerInfo{Server: s, FullMethod: fullMethod}, unary)
+	} else {
+		resp, err = unary(ctx, req)
+	}
+	if err != nil {
+		var zero Resp
+		return zero, err
+	}
+	return resp.(Resp), nil
+}
+
+// chainUnaryInterceptors composes interceptors into one, outermost first, as
+// grpc.ChainUnaryInterceptor does for the gRPC listener
+func chainUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		next := handler
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, inner := interceptors[i], next
+			next = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, inner)
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// gatewayContext carries the HTTP caller's credentials, request ID and
+// address the way gRPC would, so the interceptors see no difference. The
+// request ID is echoed in the X-Request-Id response header.
+func gatewayContext(w http.ResponseWriter, r *http.Request) context.Context {
+	id := r.Header.Get(requestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	w.Header().Set(requestIDHeader, id)
+	md := metadata.MD{requestIDHeader: []string{id}}
+	if auth := r.Header.Values("Authorization"); len(auth) > 0 {
+		md["authorization"] = auth
+	}
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+	p := &peer.Peer{}
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		p.Addr = addr
+	}
+	if r.TLS != nil {
+		p.AuthInfo = credentials.TLSInfo{State: *r.TLS}
+	}
+	return peer.NewContext(ctx, p)
+}
+
+// maxGatewayBody matches the default gRPC limit on received messages
+const maxGatewayBody = 4 << 20
+
+// decodeGatewayRequest fills req from the JSON body, the query string and
+// the path parameters
+func decodeGatewayRequest(r *http.Request, pathFields map[string]string, req proto.Message) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxGatewayBody+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxGatewayBody {
+		return fmt.Errorf("request body exceeds %d bytes", maxGatewayBody)
+	}
+	if len(body) > 0 {
+		if err := protojson.Unmarshal(body, req); err != nil {
+			return fmt.Errorf("invalid JSON body: %w", err)
+		}
+	}
+	for name, values := range r.URL.Query() {
+		if err := setGatewayField(req, name, values[len(values)-1]); err != nil {
+			return err
+		}
+	}
+	for wildcard, field := range pathFields {
+		if err := setGatewayField(req, field, r.PathValue(wildcard)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setGatewayField sets the scalar field called name (proto or JSON name) on
+// msg from its string form
+func setGatewayField(msg proto.Message, name, value string) error {
+	m := msg.ProtoReflect()
+	fields := m.Descriptor().Fields()
+	fd := fields.ByName(protoreflect.Name(name))
+	if fd == nil {
+		fd = fields.ByJSONName(name)
+	}
+	if fd == nil || fd.IsList() || fd.IsMap() {
+		return fmt.Errorf("unknown parameter %q", name)
+	}
+
+	var v protoreflect.Value
+	var err error
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		v = protoreflect.ValueOfString(value)
+	case protoreflect.BoolKind:
+		var b bool
+		b, err = strconv.ParseBool(value)
+		v = protoreflect.ValueOfBool(b)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		var n int64
+		n, err = strconv.ParseInt(value, 10, 32)
+		v = protoreflect.ValueOfInt32(int32(n))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		var n int64
+		n, err = strconv.ParseInt(value, 10, 64)
+		v = protoreflect.ValueOfInt64(n)
+	case protoreflect.DoubleKind:
+		var f float64
+		f, err = strconv.ParseFloat(value, 64)
+		v = protoreflect.ValueOfFloat64(f)
+	default:
+		return fmt.Errorf("parameter %q cannot be set from the URL", name)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value for %q: %w", name, err)
+	}
+	m.Set(fd, v)
+	return nil
+}
+
+// gatewayErrorBody is the JSON written for a failed call
+type gatewayErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (s *Server) writeGatewayError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	s.writeGatewayStatus(w, httpStatusFromCode(st.Code()), st)
+}
+
+// writeGatewayStatus writes st as the error body of an httpStatus response
+func (s *Server) writeGatewayStatus(w http.ResponseWriter, httpStatus int, st *status.Status) {
+	body, merr := json.Marshal(gatewayErrorBody{Code: st.Code().String(), Message: st.Message()})
+	if merr != nil {
+		s.log().Warn("Could not encode gateway error", "err", merr)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	w.Write(body)
+}
+
+// httpStatusFromCode maps a gRPC status code to the closest HTTP status
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"gopkg.in/yaml.v3"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/server/testutil"
+)
+
+func TestGateway(t *testing.T) {
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+	gw := httptest.NewServer(server.gatewayHandler(chainUnaryInterceptors(server.metricsInterceptor, server.auditInterceptor)))
+	defer gw.Close()
+
+	do := func(method, path, body string) (int, map[string]interface{}) {
+		req, err := http.NewRequest(method, gw.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var decoded map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&decoded))
+		return resp.StatusCode, decoded
+	}
+
+	code, body := do("POST", "/v1/nodes", `{"node": {"location": "localhost:5001", "capacity": 10, "status": "active"}}`)
+	require.Equal(t, http.StatusOK, code, body)
+	nodeID := body["node_id"].(string)
+
+	code, body = do("GET", "/v1/nodes", "")
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, body["nodes"], 1)
+
+	code, _ = do("POST", "/v1/nodes/"+nodeID+"/heartbeat", `{"status": "active", "load": 3}`)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, body = do("POST", "/v1/shards", `{"shard": {"id": "`+uuid.NewString()+`", "type": "range", "size": 1}}`)
+	require.Equal(t, http.StatusOK, code, body)
+
+	t.Run("QueryParameters", func(t *testing.T) {
+		code, body := do("GET", "/v1/audit?limit=1", "")
+		require.Equal(t, http.StatusOK, code)
+		assert.Len(t, body["events"], 1)
+
+		code, body = do("GET", "/v1/audit?limit=lots", "")
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, codes.InvalidArgument.String(), body["code"])
+	})
+
+	t.Run("StatusCodes", func(t *testing.T) {
+		code, body := do("GET", "/v1/shards/not-a-uuid", "")
+		assert.Equal(t, http.StatusBadRequest, code, body)
+
+		code, _ = do("POST", "/v1/nodes", `{"node": `)
+		assert.Equal(t, http.StatusBadRequest, code)
+
+		code, body = do("DELETE", "/v1/nodes", "")
+		assert.Equal(t, http.StatusMethodNotAllowed, code)
+		assert.Equal(t, codes.Unimplemented.String(), body["code"])
+
+		code, body = do("GET", "/v1/nodes/"+nodeID+"/reboot", "")
+		assert.Equal(t, http.StatusNotFound, code)
+		assert.Equal(t, codes.NotFound.String(), body["code"])
+	})
+
+	t.Run("AllowHeader", func(t *testing.T) {
+		req, err := http.NewRequest("DELETE", gw.URL+"/v1/shards/"+uuid.NewString(), nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+		assert.Equal(t, "GET, HEAD", resp.Header.Get("Allow"))
+	})
+}
+
+func TestGatewayAuth(t *testing.T) {
+	var cfg AuthConfig
+	require.NoError(t, yaml.Unmarshal([]byte(testAuthConfig), &cfg))
+	server := NewServer(testutil.NewMockDB())
+	gw := httptest.NewServer(server.gatewayHandler(chainUnaryInterceptors(server.authInterceptor(&cfg), server.auditInterceptor)))
+	defer gw.Close()
+
+	call := func(method, path, token string) int {
+		body := ""
+		if method == "PUT" {
+			body = `{"parameters": "{}"}`
+		}
+		req, err := http.NewRequest(method, gw.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, call("GET", "/v1/nodes", ""))
+	assert.Equal(t, http.StatusUnauthorized, call("GET", "/v1/nodes", "wrong"))
+	assert.Equal(t, http.StatusOK, call("GET", "/v1/nodes", "reader-token"))
+	assert.Equal(t, http.StatusForbidden, call("PUT", "/v1/policies/balance", "reader-token"))
+	assert.Equal(t, http.StatusOK, call("PUT", "/v1/policies/balance", "admin-token"))
+
+	events, err := server.db.ListAuditEvents(context.Background(), db.AuditFilter{})
+	require.NoError(t, err)
+	require.NotEmpty(t, events)
+	assert.Equal(t, "ops", events[0].Actor)
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+// Overall cluster health reported by GetHealth
+const (
+	HealthOK       = "OK"
+	HealthDegraded = "DEGRADED"
+	HealthCritical = "CRITICAL"
+)
+
+// checkHealth builds a structured health report from the current cluster
+// state. A CRITICAL status means shards are unavailable or the database is
+// unreachable; DEGRADED means something needs attention but shards are served.
+// A shard's live replicas are its owner and any other node reporting it, if
+// they heartbeat; the replica inventory and outbox are the ones this
+// instance keeps, which is the leader's when leaders are elected.
+func (s *Server) checkHealth(ctx context.Context, now time.Time) *shardmanagerpb.GetHealthResponse {
+	resp := &shardmanagerpb.GetHealthResponse{
+		Status:          HealthOK,
+		DbReachable:     true,
+		CheckedAtUnixMs: now.UnixMilli(),
+	}
+
+	if err := s.db.PingContext(ctx); err != nil {
+		return criticalDBHealth(resp, err)
+	}
+	nodes, err := s.db.ListNodes(ctx)
+	if err != nil {
+		return criticalDBHealth(resp, err)
+	}
+	shards, err := s.db.ListShards(ctx)
+	if err != nil {
+		return criticalDBHealth(resp, err)
+	}
+
+	live := make(map[uuid.UUID]bool, len(nodes))
+	domains := make(map[uuid.UUID]string, len(nodes))
+	resp.TotalNodes = int64(len(nodes))
+	for _, node := range nodes {
+		domains[node.ID] = node.FaultDomain
+		switch node.Status {
+		case "active":
+			resp.ActiveNodes++
+			if now.Sub(node.LastHeartbeat) > s.heartbeatTimeout {
+				resp.StaleNodes = append(resp.StaleNodes, node.ID.String())
+			} else {
+				live[node.ID] = true
+			}
+		case "maintenance":
+			// A draining node still serves its shards while it heartbeats
+			if now.Sub(node.LastHeartbeat) <= s.heartbeatTimeout {
+				live[node.ID] = true
+			}
+		case "failed":
+			resp.FailedNodes = append(resp.FailedNodes, node.ID.String())
+		}
+	}
+
+	holders := s.replicas.holders()
+	want := max(s.replicationFactor, 1)
+	resp.TotalShards = int64(len(shards))
+	for _, shard := range shards {
+		id := shard.ID.String()
+		if shard.NodeID == nil {
+			resp.UnassignedShards = append(resp.UnassignedShards, id)
+		} else {
+			replicas := liveReplicas(*shard.NodeID, holders[shard.ID], live)
+			if len(replicas) == 0 {
+				resp.UnavailableShards = append(resp.UnavailableShards, id)
+			}
+			if len(replicas) != want {
+				resp.ReplicaViolations = append(resp.ReplicaViolations, id)
+			}
+			if sharesFaultDomain(replicas, domains) {
+				resp.FaultDomainViolations = append(resp.FaultDomainViolations, id)
+			}
+		}
+		if shard.Status == "migrating" && now.Sub(shard.UpdatedAt) > s.migrationStuckAfter {
+			resp.StuckMigratingShards = append(resp.StuckMigratingShards, id)
+		}
+	}
+
+	// A handoff waits at most a lease for the previous owner, so a
+	// notification queued for longer than two is backing up
+	backlog, oldest := s.outbox.backlog()
+	resp.OutboxBacklog = int64(backlog)
+	if backlog > 0 {
+		resp.OutboxOldestAgeMs = now.Sub(oldest).Milliseconds()
+	}
+	outboxStuck := backlog > 0 && now.Sub(oldest) > 2*s.leaseDuration
+
+	switch {
+	case len(resp.UnavailableShards) > 0 || (resp.TotalShards > 0 && len(live) == 0):
+		resp.Status = HealthCritical
+	case len(resp.StaleNodes) > 0 || len(resp.FailedNodes) > 0 ||
+		len(resp.UnassignedShards) > 0 || len(resp.StuckMigratingShards) > 0 ||
+		len(resp.ReplicaViolations) > 0 || len(resp.FaultDomainViolations) > 0 || outboxStuck:
+		resp.Status = HealthDegraded
+	}
+	resp.Summary = healthSummary(resp)
+	return resp
+}
+
+// liveReplicas lists the live nodes serving a shard: its owner and the
+// nodes that reported it
+func liveReplicas(owner uuid.UUID, reported []uuid.UUID, live map[uuid.UUID]bool) []uuid.UUID {
+	var replicas []uuid.UUID
+	if live[owner] {
+		replicas = append(replicas, owner)
+	}
+	for _, nodeID := range reported {
+		if nodeID != owner && live[nodeID] {
+			replicas = append(replicas, nodeID)
+		}
+	}
+	return replicas
+}
+
+// sharesFaultDomain reports whether two replicas are on nodes in the same
+// fault domain. Nodes that do not name one are not compared.
+func sharesFaultDomain(replicas []uuid.UUID, domains map[uuid.UUID]string) bool {
+	seen := make(map[string]bool, len(replicas))
+	for _, nodeID := range replicas {
+		domain := domains[nodeID]
+		if domain == "" {
+			continue
+		}
+		if seen[domain] {
+			return true
+		}
+		seen[domain] = true
+	}
+	return false
+}
+
+// criticalDBHealth marks a report as CRITICAL because the database failed
+func criticalDBHealth(resp *shardmanagerpb.GetHealthResponse, err error) *shardmanagerpb.GetHealthResponse {
+	resp.Status = HealthCritical
+	resp.DbReachable = false
+	resp.DbError = err.Error()
+	resp.Summary = healthSummary(resp)
+	return resp
+}
+
+// healthSummary renders a one-line description of a health report
+func healthSummary(resp *shardmanagerpb.GetHealthResponse) string {
+	if !resp.DbReachable {
+		return fmt.Sprintf("%s: database unreachable: %s", resp.Status, resp.DbError)
+	}
+	var problems []string
+	add := func(n int, what string) {
+		if n > 0 {
+			problems = append(problems, fmt.Sprintf("%d %s", n, what))
+		}
+	}
+	add(len(resp.UnavailableShards), "shard(s) without a live replica")
+	add(len(resp.ReplicaViolations), "shard(s) with the wrong replica count")
+	add(len(resp.FaultDomainViolations), "shard(s) with replicas sharing a fault domain")
+	add(len(resp.StaleNodes), "stale node(s)")
+	add(len(resp.FailedNodes), "failed node(s)")
+	add(len(resp.UnassignedShards), "unassigned shard(s)")
+	add(len(resp.StuckMigratingShards), "shard(s) stuck migrating")
+	add(int(resp.OutboxBacklog), "pending app server notification(s)")
+	if len(problems) == 0 {
+		return fmt.Sprintf("%s: %d/%d nodes active, %d shards", resp.Status, resp.ActiveNodes, resp.TotalNodes, resp.TotalShards)
+	}
+	return fmt.Sprintf("%s: %s", resp.Status, strings.Join(problems, ", "))
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/server/testutil"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+// unreachableDB fails every ping
+type unreachableDB struct {
+	testutil.DBOperations
+}
+
+func (unreachableDB) PingContext(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestGetHealth(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+	now := time.Now()
+
+	t.Run("OK", func(t *testing.T) {
+		mockDB.Reset()
+		node := &db.Node{ID: uuid.New(), Status: "active", LastHeartbeat: now}
+		require.NoError(t, mockDB.RegisterNode(ctx, node))
+		require.NoError(t, mockDB.RegisterShard(ctx, &db.Shard{ID: uuid.New(), NodeID: &node.ID, Status: "active"}))
+
+		resp, err := server.GetHealth(ctx, &shardmanagerpb.GetHealthRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, HealthOK, resp.Status)
+		assert.True(t, resp.DbReachable)
+		assert.Equal(t, int64(1), resp.ActiveNodes)
+		assert.Equal(t, int64(1), resp.TotalShards)
+		assert.NotEmpty(t, resp.Summary)
+	})
+
+	t.Run("Degraded", func(t *testing.T) {
+		mockDB.Reset()
+		healthy := &db.Node{ID: uuid.New(), Status: "active", LastHeartbeat: now}
+		failed := &db.Node{ID: uuid.New(), Status: "failed", LastHeartbeat: now}
+		require.NoError(t, mockDB.RegisterNode(ctx, healthy))
+		require.NoError(t, mockDB.RegisterNode(ctx, failed))
+		unassigned := &db.Shard{ID: uuid.New(), Status: "active"}
+		stuck := &db.Shard{ID: uuid.New(), NodeID: &healthy.ID, Status: "migrating", UpdatedAt: now.Add(-time.Hour)}
+		require.NoError(t, mockDB.RegisterShard(ctx, unassigned))
+		// The mock stamps rows itself, so register the stuck shard an hour ago
+		mockDB.(*testutil.MockDB).SetClock(func() time.Time { return stuck.UpdatedAt })
+		require.NoError(t, mockDB.RegisterShard(ctx, stuck))
+
+		resp := server.checkHealth(ctx, now)
+		assert.Equal(t, HealthDegraded, resp.Status)
+		assert.Equal(t, []string{failed.ID.String()}, resp.FailedNodes)
+		assert.Equal(t, []string{unassigned.ID.String()}, resp.UnassignedShards)
+		assert.Equal(t, []string{stuck.ID.String()}, resp.StuckMigratingShards)
+		assert.Empty(t, resp.ReplicaViolations)
+	})
+
+	t.Run("CriticalWhenOwnerIsStale", func(t *testing.T) {
+		mockDB.Reset()
+		stale := &db.Node{ID: uuid.New(), Status: "active", LastHeartbeat: now.Add(-time.Hour)}
+		require.NoError(t, mockDB.RegisterNode(ctx, stale))
+		shard := &db.Shard{ID: uuid.New(), NodeID: &stale.ID, Status: "active"}
+		require.NoError(t, mockDB.RegisterShard(ctx, shard))
+
+		resp := server.checkHealth(ctx, now)
+		assert.Equal(t, HealthCritical, resp.Status)
+		assert.Equal(t, []string{stale.ID.String()}, resp.StaleNodes)
+		assert.Equal(t, []string{shard.ID.String()}, resp.UnavailableShards)
+		assert.Equal(t, []string{shard.ID.String()}, resp.ReplicaViolations)
+	})
+
+	t.Run("ReplicaCountAndFaultDomains", func(t *testing.T) {
+		mockDB.Reset()
+		opts := DefaultOptions()
+		opts.ReplicationFactor = 2
+		server := NewServerWithOptions(mockDB, opts)
+		a := &db.Node{ID: uuid.New(), Status: "active", LastHeartbeat: now, FaultDomain: "rack-1"}
+		b := &db.Node{ID: uuid.New(), Status: "active", LastHeartbeat: now, FaultDomain: "rack-2"}
+		c := &db.Node{ID: uuid.New(), Status: "active", LastHeartbeat: now, FaultDomain: "rack-1"}
+		for _, node := range []*db.Node{a, b, c} {
+			require.NoError(t, mockDB.RegisterNode(ctx, node))
+		}
+		spread := &db.Shard{ID: uuid.New(), NodeID: &a.ID, Status: "active"}
+		sameRack := &db.Shard{ID: uuid.New(), NodeID: &a.ID, Status: "active"}
+		single := &db.Shard{ID: uuid.New(), NodeID: &b.ID, Status: "active"}
+		for _, shard := range []*db.Shard{spread, sameRack, single} {
+			require.NoError(t, mockDB.RegisterShard(ctx, shard))
+		}
+		server.replicas.set(b.ID, map[uuid.UUID]string{spread.ID: "secondary", single.ID: "primary"})
+		server.replicas.set(c.ID, map[uuid.UUID]string{sameRack.ID: "secondary"})
+
+		resp := server.checkHealth(ctx, now)
+		assert.Equal(t, HealthDegraded, resp.Status)
+		assert.Equal(t, []string{single.ID.String()}, resp.ReplicaViolations)
+		assert.Equal(t, []string{sameRack.ID.String()}, resp.FaultDomainViolations)
+		assert.Empty(t, resp.UnavailableShards)
+	})
+
+	t.Run("DegradedWhenOverReplicated", func(t *testing.T) {
+		mockDB.Reset()
+		server := NewServer(mockDB)
+		owner := &db.Node{ID: uuid.New(), Status: "active", LastHeartbeat: now}
+		old := &db.Node{ID: uuid.New(), Status: "active", LastHeartbeat: now}
+		require.NoError(t, mockDB.RegisterNode(ctx, owner))
+		require.NoError(t, mockDB.RegisterNode(ctx, old))
+		shard := &db.Shard{ID: uuid.New(), NodeID: &owner.ID, Status: "active"}
+		require.NoError(t, mockDB.RegisterShard(ctx, shard))
+		// The previous owner still serves the shard
+		server.replicas.set(old.ID, map[uuid.UUID]string{shard.ID: "primary"})
+
+		resp := server.checkHealth(ctx, now)
+		assert.Equal(t, HealthDegraded, resp.Status)
+		assert.Equal(t, []string{shard.ID.String()}, resp.ReplicaViolations)
+
+		server.replicas.remove(old.ID, shard.ID)
+		assert.Equal(t, HealthOK, server.checkHealth(ctx, now).Status)
+	})
+
+	t.Run("OutboxBacklog", func(t *testing.T) {
+		mockDB.Reset()
+		server := NewServer(mockDB)
+		queued := server.outbox.add(now.Add(-time.Second))
+
+		resp := server.checkHealth(ctx, now)
+		assert.Equal(t, HealthOK, resp.Status, "a handoff in progress is not a problem")
+		assert.Equal(t, int64(1), resp.OutboxBacklog)
+		assert.Equal(t, int64(1000), resp.OutboxOldestAgeMs)
+
+		server.outbox.add(now.Add(-time.Hour))
+		resp = server.checkHealth(ctx, now)
+		assert.Equal(t, HealthDegraded, resp.Status)
+		assert.Equal(t, int64(2), resp.OutboxBacklog)
+		assert.Equal(t, time.Hour.Milliseconds(), resp.OutboxOldestAgeMs)
+
+		server.outbox.done(queued)
+		assert.Equal(t, int64(1), server.checkHealth(ctx, now).OutboxBacklog)
+	})
+
+	t.Run("CriticalWhenDatabaseUnreachable", func(t *testing.T) {
+		server := NewServer(unreachableDB{mockDB})
+		resp := server.checkHealth(ctx, now)
+		assert.Equal(t, HealthCritical, resp.Status)
+		assert.False(t, resp.DbReachable)
+		assert.Contains(t, resp.DbError, "connection refused")
+	})
+}
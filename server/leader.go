@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// leaderHeader carries the leader's address on writes a follower rejects
+const leaderHeader = "x-shardmanager-leader"
+
+// leaderElection elects one leader among manager replicas sharing a
+// database. Each replica campaigns for the lease in leader_leases; the
+// winner renews it every third of the lease duration.
+type leaderElection struct {
+	id       string // unique per process
+	address  string // advertised to followers' clients
+	duration time.Duration
+
+	mu       sync.RWMutex
+	leaseEnd time.Time // zero while following
+	leader   string    // last known leader address
+}
+
+func newLeaderElection(address string, duration time.Duration) *leaderElection {
+	return &leaderElection{id: uuid.New().String(), address: address, duration: duration}
+}
+
+// isLeader reports whether this replica may act as the leader. Without
+// leader election every server is the leader. A leader that cannot renew
+// its lease steps down once the lease runs out.
+func (s *Server) isLeader() bool {
+	if s.election == nil {
+		return true
+	}
+	s.election.mu.RLock()
+	defer s.election.mu.RUnlock()
+	return s.now().Before(s.election.leaseEnd)
+}
+
+// leaderAddress returns the last known leader address, empty if unknown
+func (s *Server) leaderAddress() string {
+	if s.election == nil {
+		return ""
+	}
+	s.election.mu.RLock()
+	defer s.election.mu.RUnlock()
+	return s.election.leader
+}
+
+// runLeaderElection campaigns for leadership until ctx is cancelled, then
+// releases the lease so another replica can take over right away
+func (s *Server) runLeaderElection(ctx context.Context) {
+	e := s.election
+	ticker := time.NewTicker(e.duration / 3)
+	defer ticker.Stop()
+
+	s.campaign(ctx, s.now())
+	for {
+		select {
+		case <-ctx.Done():
+			if s.isLeader() {
+				releaseCtx, cancel := context.WithTimeout(context.Background(), s.notificationTimeout)
+				if err := s.db.ReleaseLeadership(releaseCtx, e.id); err != nil {
+					s.log().WarnContext(ctx, "Could not release leadership", "err", err)
+				}
+				cancel()
+			}
+			return
+		case <-ticker.C:
+			s.campaign(ctx, s.now())
+		}
+	}
+}
+
+// campaign acquires or renews the leader lease, or learns who holds it
+func (s *Server) campaign(ctx context.Context, now time.Time) {
+	e := s.election
+	won, err := s.db.AcquireLeadership(ctx, e.id, e.address, now, now.Add(e.duration))
+	if err != nil {
+		// Keep the current lease; it lapses on its own if renewals keep failing
+		s.log().WarnContext(ctx, "Leader election failed", "err", err)
+		return
+	}
+	leader := e.address
+	if !won {
+		var lease *db.LeaderLease
+		if lease, err = s.db.GetLeader(ctx); err != nil {
+			s.log().WarnContext(ctx, "Could not look up the leader", "err", err)
+		}
+		leader = ""
+		if lease != nil && lease.ExpiresAt.After(now) {
+			leader = lease.Address
+		}
+	}
+
+	e.mu.Lock()
+	wasLeader := now.Before(e.leaseEnd)
+	if won {
+		e.leaseEnd = now.Add(e.duration)
+	} else {
+		e.leaseEnd = time.Time{}
+	}
+	changed := e.leader != leader
+	e.leader = leader
+	e.mu.Unlock()
+
+	switch {
+	case won && !wasLeader:
+		s.log().InfoContext(ctx, "Elected leader", "address", e.address)
+	case !won && wasLeader:
+		s.log().WarnContext(ctx, "Lost leadership", "leader", leader)
+	case !won && changed:
+		s.log().InfoContext(ctx, "Following leader", "leader", leader)
+	}
+}
+
+// leaderInterceptor lets followers serve reads and rejects writes with
+// Unavailable, naming the leader in the message and in the
+// x-shardmanager-leader response header. Rejected writes are audited.
+func (s *Server) leaderInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if readMethods[info.FullMethod] || s.isLeader() {
+		return handler(ctx, req)
+	}
+	leader := s.leaderAddress()
+	var err error
+	if leader == "" {
+		err = status.Error(codes.Unavailable, "not the leader and no leader is elected; retry shortly")
+	} else {
+		// Fails harmlessly when called through the HTTP gateway
+		_ = grpc.SetHeader(ctx, metadata.Pairs(leaderHeader, leader))
+		err = status.Errorf(codes.Unavailable, "not the leader; send writes to the leader at %s", leader)
+	}
+	s.auditCall(ctx, callerIdentity(ctx), info.FullMethod, req, nil, err)
+	return nil, err
+}
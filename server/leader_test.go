@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/seaweedfs/shardmanager/server/testutil"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+func TestLeaderElection(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	opts := DefaultOptions()
+	opts.LeaderElection = true
+
+	opts.AdvertiseAddr = "a:7427"
+	a := NewServerWithOptions(mockDB, opts)
+	opts.AdvertiseAddr = "b:7427"
+	b := NewServerWithOptions(mockDB, opts)
+
+	assert.True(t, NewServer(mockDB).isLeader(), "without election every server leads")
+	assert.False(t, a.isLeader(), "no lease acquired yet")
+
+	now := time.Now()
+	a.campaign(ctx, now)
+	b.campaign(ctx, now)
+	assert.True(t, a.isLeader())
+	assert.False(t, b.isLeader())
+	assert.Equal(t, "a:7427", b.leaderAddress())
+
+	handled := false
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		handled = true
+		return nil, nil
+	}
+	call := func(s *Server, method string) error {
+		_, err := s.leaderInterceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return err
+	}
+
+	// Followers serve reads and reject writes with the leader's address
+	require.NoError(t, call(b, shardmanagerpb.NodeService_ListNodes_FullMethodName))
+	assert.True(t, handled)
+	handled = false
+	err := call(b, shardmanagerpb.ShardService_AssignShard_FullMethodName)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Contains(t, err.Error(), "a:7427")
+	assert.False(t, handled)
+
+	require.NoError(t, call(a, shardmanagerpb.ShardService_AssignShard_FullMethodName))
+	assert.True(t, handled)
+
+	// When the leader releases the lease a follower takes over
+	require.NoError(t, mockDB.ReleaseLeadership(ctx, a.election.id))
+	b.campaign(ctx, now.Add(time.Second))
+	a.campaign(ctx, now.Add(time.Second))
+	assert.True(t, b.isLeader())
+	assert.False(t, a.isLeader())
+	assert.Equal(t, "b:7427", a.leaderAddress())
+
+	// Without any leader writes are still rejected
+	require.NoError(t, mockDB.ReleaseLeadership(ctx, b.election.id))
+	a.election.leader = ""
+	err = call(a, shardmanagerpb.ShardService_AssignShard_FullMethodName)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Contains(t, err.Error(), "no leader")
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+// renewLeases renews the leases for every shard the node owns and returns the
+// ones it holds. Shards whose previous owner still holds a lease are left out
+// until that lease expires or is revoked.
+func (s *Server) renewLeases(ctx context.Context, nodeID uuid.UUID) ([]*shardmanagerpb.ShardLease, error) {
+	shards, err := s.db.ListShards(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	expiresAt := now.Add(s.leaseDuration)
+	var leases []*shardmanagerpb.ShardLease
+	for _, shard := range shards {
+		if shard.NodeID == nil || *shard.NodeID != nodeID {
+			continue
+		}
+		granted, err := s.db.AcquireShardLease(ctx, shard.ID, nodeID, now, expiresAt)
+		if err != nil {
+			return nil, err
+		}
+		if granted {
+			leases = append(leases, &shardmanagerpb.ShardLease{
+				ShardId:         shard.ID.String(),
+				ExpiresAtUnixMs: expiresAt.UnixMilli(),
+			})
+		}
+	}
+	return leases, nil
+}
+
+// goHandoff runs handoff in the background, outliving the RPC that started
+// it but keeping its context's values. Shutdown waits for it, cancelling it
+// if the shutdown deadline comes first. It stays in the outbox until done.
+func (s *Server) goHandoff(ctx context.Context, handoff func(context.Context)) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(s.handoffCtx, cancel)
+	queued := s.outbox.add(s.now())
+	s.handoffs.Add(1)
+	go func() {
+		defer s.handoffs.Done()
+		defer s.outbox.done(queued)
+		defer cancel()
+		defer stop()
+		handoff(ctx)
+	}()
+}
+
+// outbox tracks the app server notifications shard handoffs have yet to
+// deliver
+type outbox struct {
+	mu      sync.Mutex
+	next    uint64
+	pending map[uint64]time.Time // when each was queued
+}
+
+// add queues a notification at now and returns its key
+func (o *outbox) add(now time.Time) uint64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.pending == nil {
+		o.pending = make(map[uint64]time.Time)
+	}
+	o.next++
+	o.pending[o.next] = now
+	return o.next
+}
+
+// done removes a delivered or abandoned notification
+func (o *outbox) done(key uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.pending, key)
+}
+
+// backlog returns how many notifications are pending and when the oldest
+// was queued
+func (o *outbox) backlog() (int, time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var oldest time.Time
+	for _, queued := range o.pending {
+		if oldest.IsZero() || queued.Before(oldest) {
+			oldest = queued
+		}
+	}
+	return len(o.pending), oldest
+}
+
+// handoffShard moves a shard to a new owner without overlapping primaries
+// and records the outcome in the audit log. It reports whether the new
+// owner took the shard over.
+func (s *Server) handoffShard(ctx context.Context, shardID uuid.UUID, fromNodeID *uuid.UUID, toNodeID uuid.UUID) bool {
+	err := s.transferShard(ctx, shardID, fromNodeID, toNodeID)
+	details := map[string]string{"to_node_id": toNodeID.String()}
+	if fromNodeID != nil {
+		details["from_node_id"] = fromNodeID.String()
+	}
+	s.auditSystem(ctx, AuditHandoff, "shard", shardID.String(), details, err)
+	return err == nil
+}
+
+// transferShard does the work of handoffShard. The old owner is asked to
+// drop the shard and its lease is revoked once it confirms; otherwise the
+// new owner waits for the lease to expire. Only then is the new owner
+// granted a lease and sent AddShard.
+func (s *Server) transferShard(ctx context.Context, shardID uuid.UUID, fromNodeID *uuid.UUID, toNodeID uuid.UUID) error {
+	// An old lease cannot outlive its expiry, so give up if it is still held
+	// well past that; the shard was most likely reassigned again meanwhile
+	ctx, cancel := context.WithTimeout(ctx, 3*s.leaseDuration)
+	defer cancel()
+
+	if fromNodeID != nil && *fromNodeID != toNodeID {
+		if s.notifyAppServerDropShard(ctx, *fromNodeID, shardID) {
+			if err := s.revokeLease(ctx, shardID, *fromNodeID, "dropped for handoff"); err != nil {
+				s.log().WarnContext(ctx, "Could not revoke lease", "shard", shardID, "node", *fromNodeID, "err", err)
+			}
+		}
+	}
+
+	if err := s.waitForLeaseRelease(ctx, shardID, toNodeID); err != nil {
+		s.log().WarnContext(ctx, "Handoff abandoned", "shard", shardID, "node", toNodeID, "err", err)
+		return fmt.Errorf("previous owner still holds the lease: %w", err)
+	}
+
+	shard, err := s.db.GetShardInfo(ctx, shardID)
+	if err == nil && shard == nil {
+		err = errors.New("shard no longer exists")
+	}
+	if err != nil {
+		s.log().WarnContext(ctx, "Could not load shard for handoff", "shard", shardID, "err", err)
+		return err
+	}
+	if shard.NodeID == nil || *shard.NodeID != toNodeID {
+		s.log().InfoContext(ctx, "Shard was reassigned during handoff", "shard", shardID, "node", toNodeID)
+		return errors.New("shard was reassigned during handoff")
+	}
+
+	now := s.now()
+	granted, err := s.db.AcquireShardLease(ctx, shardID, toNodeID, now, now.Add(s.leaseDuration))
+	if err != nil {
+		s.log().WarnContext(ctx, "Could not grant lease", "shard", shardID, "node", toNodeID, "err", err)
+		return err
+	}
+	if !granted {
+		s.log().WarnContext(ctx, "Lease was taken before the node could acquire it", "shard", shardID, "node", toNodeID)
+		return errors.New("lease was taken before the node could acquire it")
+	}
+	if !s.notifyAppServerAddShard(ctx, toNodeID, shardID, "primary") {
+		return errors.New("new owner was not sent AddShard")
+	}
+	return nil
+}
+
+// revokeLease revokes a node's lease on a shard and records the attempt in
+// the audit log
+func (s *Server) revokeLease(ctx context.Context, shardID, nodeID uuid.UUID, reason string) error {
+	err := s.db.RevokeShardLease(ctx, shardID, nodeID)
+	s.auditSystem(ctx, AuditRevokeLease, "shard", shardID.String(), map[string]string{"node_id": nodeID.String(), "reason": reason}, err)
+	return err
+}
+
+// waitForLeaseRelease blocks until no node other than nodeID holds a valid
+// lease on the shard
+func (s *Server) waitForLeaseRelease(ctx context.Context, shardID, nodeID uuid.UUID) error {
+	ticker := time.NewTicker(s.leaseDuration / 10)
+	defer ticker.Stop()
+
+	for {
+		lease, err := s.db.GetShardLease(ctx, shardID)
+		if err != nil {
+			return err
+		}
+		if !leaseBlocks(lease, nodeID, s.now()) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// leaseBlocks reports whether lease prevents nodeID from owning the shard at now
+func leaseBlocks(lease *db.ShardLease, nodeID uuid.UUID, now time.Time) bool {
+	return lease != nil && lease.NodeID != nodeID && !lease.Revoked && lease.ExpiresAt.After(now)
+}
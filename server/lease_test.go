@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/server/testutil"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+func TestHeartbeatRenewsLeases(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+
+	nodeA := &db.Node{ID: uuid.New(), Location: "localhost:5001", Status: "active"}
+	nodeB := &db.Node{ID: uuid.New(), Location: "localhost:5002", Status: "active"}
+	require.NoError(t, mockDB.RegisterNode(ctx, nodeA))
+	require.NoError(t, mockDB.RegisterNode(ctx, nodeB))
+
+	shard := &db.Shard{ID: uuid.New(), NodeID: &nodeA.ID, Status: "active"}
+	require.NoError(t, mockDB.RegisterShard(ctx, shard))
+
+	resp, err := server.Heartbeat(ctx, &shardmanagerpb.HeartbeatRequest{NodeId: nodeA.ID.String(), Status: "active"})
+	require.NoError(t, err)
+	require.Len(t, resp.Leases, 1)
+	assert.Equal(t, shard.ID.String(), resp.Leases[0].ShardId)
+	assert.Greater(t, resp.Leases[0].ExpiresAtUnixMs, time.Now().UnixMilli())
+
+	// After the shard moves, the new owner is not granted a lease while the
+	// old one is still valid
+	require.NoError(t, mockDB.AssignShard(ctx, shard.ID, nodeB.ID))
+	resp, err = server.Heartbeat(ctx, &shardmanagerpb.HeartbeatRequest{NodeId: nodeB.ID.String(), Status: "active"})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Leases)
+
+	// The old owner no longer gets its lease renewed
+	resp, err = server.Heartbeat(ctx, &shardmanagerpb.HeartbeatRequest{NodeId: nodeA.ID.String(), Status: "active"})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Leases)
+
+	// Once revoked, the new owner picks the lease up on its next heartbeat
+	require.NoError(t, mockDB.RevokeShardLease(ctx, shard.ID, nodeA.ID))
+	resp, err = server.Heartbeat(ctx, &shardmanagerpb.HeartbeatRequest{NodeId: nodeB.ID.String(), Status: "active"})
+	require.NoError(t, err)
+	require.Len(t, resp.Leases, 1)
+	assert.Equal(t, shard.ID.String(), resp.Leases[0].ShardId)
+}
+
+func TestWaitForLeaseRelease(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+	server.leaseDuration = 200 * time.Millisecond
+
+	shardID := uuid.New()
+	oldOwner := uuid.New()
+	newOwner := uuid.New()
+
+	// No lease at all does not block
+	require.NoError(t, server.waitForLeaseRelease(ctx, shardID, newOwner))
+
+	now := time.Now()
+	granted, err := mockDB.AcquireShardLease(ctx, shardID, oldOwner, now, now.Add(server.leaseDuration))
+	require.NoError(t, err)
+	require.True(t, granted)
+
+	start := time.Now()
+	require.NoError(t, server.waitForLeaseRelease(ctx, shardID, newOwner))
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+	granted, err = mockDB.AcquireShardLease(ctx, shardID, newOwner, time.Now(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.True(t, granted)
+
+	// A holder that never releases its lease times out the wait
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.Error(t, server.waitForLeaseRelease(waitCtx, shardID, oldOwner))
+}
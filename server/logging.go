@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Log formats
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// NewLogger returns a logger writing records at level ("debug", "info",
+// "warn" or "error") and above to w, as logfmt-style text or JSON lines
+func NewLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q", level)
+	}
+	handlerOpts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case LogFormatText:
+		return slog.New(slog.NewTextHandler(w, handlerOpts)), nil
+	case LogFormatJSON:
+		return slog.New(slog.NewJSONHandler(w, handlerOpts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+// withRequestIDs makes logger add the request ID carried by the context of
+// each record
+func withRequestIDs(logger *slog.Logger) *slog.Logger {
+	if _, ok := logger.Handler().(requestIDHandler); ok {
+		return logger
+	}
+	return slog.New(requestIDHandler{logger.Handler()})
+}
+
+// requestIDHandler adds a request_id attribute to records logged with a
+// context carrying one
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h requestIDHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDHandler) WithGroup(name string) slog.Handler {
+	return requestIDHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewLogger(&buf, "warn", LogFormatJSON)
+	require.NoError(t, err)
+	server := NewServer(nil)
+	server.logger = withRequestIDs(logger)
+
+	ctx := contextWithRequestID(context.Background(), "req-1")
+	server.log().InfoContext(ctx, "Dropped below the level")
+	server.log().With("node", "n1").WarnContext(ctx, "Something happened")
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record), buf.String())
+	assert.Equal(t, "Something happened", record["msg"])
+	assert.Equal(t, "WARN", record["level"])
+	assert.Equal(t, "n1", record["node"])
+	assert.Equal(t, "req-1", record["request_id"])
+
+	buf.Reset()
+	logger, err = NewLogger(&buf, "DEBUG", LogFormatText)
+	require.NoError(t, err)
+	logger.Debug("Hello", "count", 2)
+	assert.Contains(t, buf.String(), "level=DEBUG msg=Hello count=2")
+
+	_, err = NewLogger(&buf, "loud", LogFormatText)
+	assert.EqualError(t, err, `unknown log level "loud"`)
+	_, err = NewLogger(&buf, "info", "xml")
+	assert.EqualError(t, err, `unknown log format "xml"`)
+}
@@ -0,0 +1,312 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// rpcLatencyBuckets are the upper bounds, in seconds, of the RPC latency histogram
+var rpcLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// serverMetrics holds the counters the server accumulates between scrapes.
+// Gauges such as nodes by status are computed from the database at scrape
+// time. A nil *serverMetrics discards everything.
+type serverMetrics struct {
+	mu sync.Mutex
+
+	rpcLatency           map[string]*histogram
+	rpcErrors            map[string]map[string]uint64 // method -> code -> count
+	migrationsInFlight   int64
+	migrationsSucceeded  uint64
+	migrationsFailed     uint64
+	notificationFailures map[string]uint64            // app server RPC -> count
+	shardMapMismatches   map[string]uint64            // entity -> count
+	policyEvaluations    map[string]map[string]uint64 // policy -> result -> count
+}
+
+// histogram is a cumulative Prometheus-style histogram
+type histogram struct {
+	counts []uint64 // one per bucket, non-cumulative
+	count  uint64
+	sum    float64
+}
+
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		rpcLatency:           make(map[string]*histogram),
+		rpcErrors:            make(map[string]map[string]uint64),
+		notificationFailures: make(map[string]uint64),
+		shardMapMismatches:   make(map[string]uint64),
+		policyEvaluations:    make(map[string]map[string]uint64),
+	}
+}
+
+// observeRPC records the latency and, on failure, the status code of an RPC
+func (m *serverMetrics) observeRPC(method string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.rpcLatency[method]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(rpcLatencyBuckets))}
+		m.rpcLatency[method] = h
+	}
+	seconds := d.Seconds()
+	for i, le := range rpcLatencyBuckets {
+		if seconds <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += seconds
+
+	if err != nil {
+		if m.rpcErrors[method] == nil {
+			m.rpcErrors[method] = make(map[string]uint64)
+		}
+		m.rpcErrors[method][status.Code(err).String()]++
+	}
+}
+
+// migrationStarted counts a migration whose handoff is in progress
+func (m *serverMetrics) migrationStarted() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.migrationsInFlight++
+	m.mu.Unlock()
+}
+
+// migrationFinished records the outcome of a migration handoff
+func (m *serverMetrics) migrationFinished(succeeded bool) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.migrationsInFlight--
+	if succeeded {
+		m.migrationsSucceeded++
+	} else {
+		m.migrationsFailed++
+	}
+	m.mu.Unlock()
+}
+
+// notificationFailed counts a failed call to an app server
+func (m *serverMetrics) notificationFailed(rpc string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.notificationFailures[rpc]++
+	m.mu.Unlock()
+}
+
+// shardMapMismatch counts a row the shard map held differently from the
+// database
+func (m *serverMetrics) shardMapMismatch(entity string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.shardMapMismatches[entity]++
+	m.mu.Unlock()
+}
+
+// Results of a policy evaluation
+const (
+	PolicyMatched    = "matched"
+	PolicyNotMatched = "not_matched"
+	PolicyError      = "error"
+)
+
+// policyEvaluated counts an evaluation of the named policy
+func (m *serverMetrics) policyEvaluated(name, result string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	if m.policyEvaluations[name] == nil {
+		m.policyEvaluations[name] = make(map[string]uint64)
+	}
+	m.policyEvaluations[name][result]++
+	m.mu.Unlock()
+}
+
+// metricsInterceptor records the latency of every unary RPC
+func (s *Server) metricsInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	s.metrics.observeRPC(info.FullMethod, time.Since(start), err)
+	return resp, err
+}
+
+// metricsHandler serves the server's metrics in the Prometheus text format.
+// They are rendered in full before anything is sent, so a failed scrape
+// gets a 500 instead of a truncated page.
+func (s *Server) metricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		if err := s.writeMetrics(r.Context(), &buf); err != nil {
+			s.log().WarnContext(r.Context(), "Could not write metrics", "err", err)
+			http.Error(w, "could not collect metrics", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		buf.WriteTo(w)
+	})
+}
+
+// writeMetrics renders cluster gauges from the database followed by the
+// accumulated counters
+func (s *Server) writeMetrics(ctx context.Context, w io.Writer) error {
+	nodes, err := s.db.ListNodes(ctx)
+	if err != nil {
+		return err
+	}
+	shards, err := s.db.ListShards(ctx)
+	if err != nil {
+		return err
+	}
+
+	nodesByStatus := make(map[string]float64)
+	for _, node := range nodes {
+		nodesByStatus[node.Status]++
+	}
+	shardsByStatus := make(map[string]float64)
+	shardsPerNode := make(map[string]float64)
+	for _, node := range nodes {
+		shardsPerNode[node.ID.String()] = 0
+	}
+	for _, shard := range shards {
+		shardsByStatus[shard.Status]++
+		if shard.NodeID != nil {
+			shardsPerNode[shard.NodeID.String()]++
+		}
+	}
+
+	pw := &promWriter{w: w}
+	pw.family("shardmanager_nodes", "gauge", "Number of nodes by status.")
+	for _, st := range sortedKeys(nodesByStatus) {
+		pw.sample("shardmanager_nodes", labels("status", st), nodesByStatus[st])
+	}
+	pw.family("shardmanager_shards", "gauge", "Number of shards by status.")
+	for _, st := range sortedKeys(shardsByStatus) {
+		pw.sample("shardmanager_shards", labels("status", st), shardsByStatus[st])
+	}
+	pw.family("shardmanager_node_shards", "gauge", "Number of shards assigned to each node.")
+	for _, id := range sortedKeys(shardsPerNode) {
+		pw.sample("shardmanager_node_shards", labels("node_id", id), shardsPerNode[id])
+	}
+	leader := 0.0
+	if s.isLeader() {
+		leader = 1
+	}
+	pw.family("shardmanager_leader", "gauge", "Whether this replica is the elected leader.")
+	pw.sample("shardmanager_leader", "", leader)
+
+	m := s.metrics
+	if m == nil {
+		return pw.err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pw.family("shardmanager_migrations_in_flight", "gauge", "Shard migrations whose handoff has not finished.")
+	pw.sample("shardmanager_migrations_in_flight", "", float64(m.migrationsInFlight))
+	pw.family("shardmanager_migrations_total", "counter", "Completed shard migrations by result.")
+	pw.sample("shardmanager_migrations_total", labels("result", "succeeded"), float64(m.migrationsSucceeded))
+	pw.sample("shardmanager_migrations_total", labels("result", "failed"), float64(m.migrationsFailed))
+
+	pw.family("shardmanager_appserver_notification_failures_total", "counter", "Failed calls to app servers by RPC.")
+	for _, rpc := range sortedKeys(m.notificationFailures) {
+		pw.sample("shardmanager_appserver_notification_failures_total", labels("rpc", rpc), float64(m.notificationFailures[rpc]))
+	}
+
+	pw.family("shardmanager_shard_map_mismatches_total", "counter", "Rows the in-memory shard map held differently from the database, by entity.")
+	for _, entity := range sortedKeys(m.shardMapMismatches) {
+		pw.sample("shardmanager_shard_map_mismatches_total", labels("entity", entity), float64(m.shardMapMismatches[entity]))
+	}
+
+	pw.family("shardmanager_policy_evaluations_total", "counter", "Policy evaluations by policy and result.")
+	for _, name := range sortedKeys(m.policyEvaluations) {
+		for _, result := range sortedKeys(m.policyEvaluations[name]) {
+			pw.sample("shardmanager_policy_evaluations_total", labels("policy", name, "result", result), float64(m.policyEvaluations[name][result]))
+		}
+	}
+
+	pw.family("shardmanager_rpc_duration_seconds", "histogram", "Latency of gRPC calls by method.")
+	for _, method := range sortedKeys(m.rpcLatency) {
+		h := m.rpcLatency[method]
+		var cumulative uint64
+		for i, le := range rpcLatencyBuckets {
+			cumulative += h.counts[i]
+			pw.sample("shardmanager_rpc_duration_seconds_bucket", labels("method", method, "le", fmt.Sprint(le)), float64(cumulative))
+		}
+		pw.sample("shardmanager_rpc_duration_seconds_bucket", labels("method", method, "le", "+Inf"), float64(h.count))
+		pw.sample("shardmanager_rpc_duration_seconds_sum", labels("method", method), h.sum)
+		pw.sample("shardmanager_rpc_duration_seconds_count", labels("method", method), float64(h.count))
+	}
+
+	pw.family("shardmanager_rpc_errors_total", "counter", "Failed gRPC calls by method and status code.")
+	for _, method := range sortedKeys(m.rpcErrors) {
+		for _, code := range sortedKeys(m.rpcErrors[method]) {
+			pw.sample("shardmanager_rpc_errors_total", labels("method", method, "code", code), float64(m.rpcErrors[method][code]))
+		}
+	}
+	return pw.err
+}
+
+// promWriter writes the Prometheus text exposition format, keeping the first error
+type promWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (p *promWriter) family(name, kind, help string) {
+	p.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (p *promWriter) sample(name, labels string, value float64) {
+	p.printf("%s%s %g\n", name, labels, value)
+}
+
+func (p *promWriter) printf(format string, args ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
+
+// labels formats alternating label names and values as {k="v",...}
+func labels(kv ...string) string {
+	parts := make([]string, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(kv[i+1])
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, kv[i], v))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
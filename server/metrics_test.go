@@ -0,0 +1,147 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/server/testutil"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+
+	active := &db.Node{ID: uuid.New(), Status: "active"}
+	failed := &db.Node{ID: uuid.New(), Status: "failed"}
+	require.NoError(t, mockDB.RegisterNode(ctx, active))
+	require.NoError(t, mockDB.RegisterNode(ctx, failed))
+	require.NoError(t, mockDB.RegisterShard(ctx, &db.Shard{ID: uuid.New(), NodeID: &active.ID, Status: "active"}))
+	require.NoError(t, mockDB.RegisterShard(ctx, &db.Shard{ID: uuid.New(), NodeID: &active.ID, Status: "migrating"}))
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/shardmanager.ShardService/AssignShard"}
+	_, err := server.metricsInterceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "shard not found")
+	})
+	require.Error(t, err)
+
+	server.metrics.migrationStarted()
+	server.metrics.migrationStarted()
+	server.metrics.migrationFinished(true)
+	server.metrics.notificationFailed("AddShard")
+
+	rec := httptest.NewRecorder()
+	server.metricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	out := string(body)
+
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, out, "# TYPE shardmanager_nodes gauge")
+	assert.Contains(t, out, `shardmanager_nodes{status="active"} 1`)
+	assert.Contains(t, out, `shardmanager_nodes{status="failed"} 1`)
+	assert.Contains(t, out, `shardmanager_shards{status="migrating"} 1`)
+	assert.Contains(t, out, `shardmanager_node_shards{node_id="`+active.ID.String()+`"} 2`)
+	assert.Contains(t, out, `shardmanager_node_shards{node_id="`+failed.ID.String()+`"} 0`)
+	assert.Contains(t, out, "shardmanager_migrations_in_flight 1")
+	assert.Contains(t, out, `shardmanager_migrations_total{result="succeeded"} 1`)
+	assert.Contains(t, out, `shardmanager_appserver_notification_failures_total{rpc="AddShard"} 1`)
+	assert.Contains(t, out, `shardmanager_rpc_duration_seconds_count{method="/shardmanager.ShardService/AssignShard"} 1`)
+	assert.Contains(t, out, `shardmanager_rpc_duration_seconds_bucket{method="/shardmanager.ShardService/AssignShard",le="+Inf"} 1`)
+	assert.Contains(t, out, `shardmanager_rpc_errors_total{method="/shardmanager.ShardService/AssignShard",code="NotFound"} 1`)
+}
+
+func TestPolicyEvaluationMetrics(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+
+	node := &db.Node{ID: uuid.New(), Status: "active"}
+	require.NoError(t, mockDB.RegisterNode(ctx, node))
+	shard := &db.Shard{ID: uuid.New(), NodeID: &node.ID, Status: "active"}
+	require.NoError(t, mockDB.RegisterShard(ctx, shard))
+	require.NoError(t, mockDB.RecordShardLoads(ctx, []*db.ShardLoad{{ShardID: shard.ID, NodeID: node.ID, CPUShare: 0.5}}))
+	state, err := server.SystemState(ctx)
+	require.NoError(t, err)
+
+	setPolicy := func(params string) {
+		require.NoError(t, mockDB.SetPolicy(ctx, &db.Policy{ID: uuid.New(), PolicyType: "load_balancing", Parameters: json.RawMessage(params)}))
+	}
+	evaluate := func(threshold string) {
+		setPolicy(`{"name": "spread-cpu", "type": "load_balancing",
+			"conditions": {"all": [{"metric": "cpu_share", "operator": "gt", "value": ` + threshold + `}]},
+			"actions": [{"type": "rebalance"}]}`)
+		_, err := server.loadBalancingMatches(ctx, state)
+		require.NoError(t, err)
+	}
+	evaluate("0.1")
+	evaluate("0.2")
+	evaluate("0.9")
+	setPolicy(`{"type": "load_balancing"}`)
+	_, err = server.loadBalancingMatches(ctx, state)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, server.writeMetrics(ctx, &buf))
+	out := buf.String()
+	assert.Contains(t, out, "# TYPE shardmanager_policy_evaluations_total counter")
+	assert.Contains(t, out, `shardmanager_policy_evaluations_total{policy="spread-cpu",result="matched"} 2`)
+	assert.Contains(t, out, `shardmanager_policy_evaluations_total{policy="spread-cpu",result="not_matched"} 1`)
+	assert.Contains(t, out, `shardmanager_policy_evaluations_total{policy="load_balancing",result="error"} 1`)
+}
+
+// nodeListFailingDB fails to list nodes
+type nodeListFailingDB struct {
+	testutil.DBOperations
+}
+
+func (nodeListFailingDB) ListNodes(ctx context.Context) ([]*db.Node, error) {
+	return nil, errors.New("connection reset")
+}
+
+func TestMetricsDatabaseError(t *testing.T) {
+	server := NewServer(nodeListFailingDB{testutil.NewMockDB()})
+	server.metrics.migrationStarted()
+
+	rec := httptest.NewRecorder()
+	server.metricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "shardmanager_", "no partial page is served")
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	m := newServerMetrics()
+	m.observeRPC("m", 3*time.Millisecond, nil)
+	m.observeRPC("m", 20*time.Second, errors.New("boom"))
+
+	h := m.rpcLatency["m"]
+	assert.Equal(t, uint64(2), h.count)
+	assert.Equal(t, uint64(1), h.counts[1]) // 5ms bucket
+	var bucketed uint64
+	for _, c := range h.counts {
+		bucketed += c
+	}
+	// Observations above the largest bucket only show up in +Inf
+	assert.Equal(t, uint64(1), bucketed)
+	assert.Equal(t, uint64(1), m.rpcErrors["m"][codes.Unknown.String()])
+
+	// A nil collector discards observations
+	var none *serverMetrics
+	none.observeRPC("m", time.Second, nil)
+	none.notificationFailed("AddShard")
+}
@@ -36,9 +36,5 @@ func (s *Server) GetDistribution(ctx context.Context, req *shardmanagerpb.GetDis
 }
 
 func (s *Server) GetHealth(ctx context.Context, req *shardmanagerpb.GetHealthRequest) (*shardmanagerpb.GetHealthResponse, error) {
-	// Implement health check logic here
-	// For now, return a simple summary
-	return &shardmanagerpb.GetHealthResponse{
-		Summary: "System is healthy",
-	}, nil
+	return s.checkHealth(ctx, s.now()), nil
 }
@@ -2,6 +2,9 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/seaweedfs/shardmanager/shardmanagerpb"
 
@@ -12,12 +15,86 @@ import (
 )
 
 // NodeService implementation
+//
+// RegisterNode is idempotent: a node identified by a caller-chosen UUID or
+// stable name keeps its ID across restarts, and re-registering it updates its
+// location, capacity and mode instead of inserting a duplicate.
 func (s *Server) RegisterNode(ctx context.Context, req *shardmanagerpb.RegisterNodeRequest) (*shardmanagerpb.RegisterNodeResponse, error) {
+	if req.Node == nil {
+		return nil, status.Error(codes.InvalidArgument, "node is required")
+	}
+
+	mode := req.Node.AssignmentMode
+	switch mode {
+	case "":
+		mode = AssignmentModePush
+	case AssignmentModePush, AssignmentModePull:
+	default:
+		return nil, status.Error(codes.InvalidArgument, "invalid assignment mode")
+	}
+	nodeStatus := req.Node.Status
+	if nodeStatus == "" {
+		nodeStatus = "active"
+	}
+	if !db.ValidNodeStatus(nodeStatus) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid node status %q", nodeStatus)
+	}
+
+	// IDs that are not UUIDs are treated as stable names
+	name := req.Node.Name
+	var nodeID uuid.UUID
+	if req.Node.Id != "" {
+		parsed, err := uuid.Parse(req.Node.Id)
+		switch {
+		case err == nil:
+			nodeID = parsed
+		case name == "":
+			name = req.Node.Id
+		default:
+			return nil, status.Error(codes.InvalidArgument, "invalid node ID")
+		}
+	}
+
+	existing, err := s.findRegisteredNode(ctx, nodeID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		existing.Location = req.Node.Location
+		existing.FaultDomain = req.Node.FaultDomain
+		existing.Capacity = req.Node.Capacity
+		// An operator's drain outlasts the node restarting
+		if existing.Status != "maintenance" {
+			existing.Status = nodeStatus
+		}
+		existing.AssignmentMode = mode
+		existing.ResourceCapacity = req.Node.ResourceCapacity
+		if name != "" {
+			existing.Name = name
+		}
+		if err := s.db.UpdateNode(ctx, existing); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		return &shardmanagerpb.RegisterNodeResponse{
+			Success: true,
+			Message: "Node re-registered successfully",
+			NodeId:  existing.ID.String(),
+		}, nil
+	}
+
+	if nodeID == uuid.Nil {
+		nodeID = uuid.New()
+	}
 	node := &db.Node{
-		ID:       uuid.New(),
-		Location: req.Node.Location,
-		Capacity: req.Node.Capacity,
-		Status:   req.Node.Status,
+		ID:               nodeID,
+		Name:             name,
+		Location:         req.Node.Location,
+		FaultDomain:      req.Node.FaultDomain,
+		Capacity:         req.Node.Capacity,
+		Status:           nodeStatus,
+		AssignmentMode:   mode,
+		ResourceCapacity: req.Node.ResourceCapacity,
 	}
 
 	if err := s.db.RegisterNode(ctx, node); err != nil {
@@ -27,20 +104,115 @@ func (s *Server) RegisterNode(ctx context.Context, req *shardmanagerpb.RegisterN
 	return &shardmanagerpb.RegisterNodeResponse{
 		Success: true,
 		Message: "Node registered successfully",
+		NodeId:  node.ID.String(),
 	}, nil
 }
 
+// findRegisteredNode looks up a previously registered node by ID, then by
+// name. A name already taken by a node with a different ID is a conflict.
+func (s *Server) findRegisteredNode(ctx context.Context, nodeID uuid.UUID, name string) (*db.Node, error) {
+	var byID, byName *db.Node
+	var err error
+	if nodeID != uuid.Nil {
+		if byID, err = s.db.GetNodeInfo(ctx, nodeID); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+	if name != "" {
+		if byName, err = s.db.GetNodeByName(ctx, name); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	switch {
+	case byName == nil:
+		return byID, nil
+	case nodeID != uuid.Nil && byName.ID != nodeID:
+		return nil, status.Errorf(codes.AlreadyExists, "node name %q is registered to node %s", name, byName.ID)
+	default:
+		return byName, nil
+	}
+}
+
 func (s *Server) Heartbeat(ctx context.Context, req *shardmanagerpb.HeartbeatRequest) (*shardmanagerpb.HeartbeatResponse, error) {
 	nodeID, err := uuid.Parse(req.NodeId)
 	if err != nil {
 		return nil, status.Error(codes.InvalidArgument, "invalid node ID")
 	}
 
-	if err := s.db.UpdateNodeHeartbeat(ctx, nodeID, req.Status, req.Load); err != nil {
+	if err := s.db.UpdateNodeHeartbeat(ctx, nodeID, req.Status, req.Load, req.ResourceLoad); err != nil {
+		if errors.Is(err, db.ErrInvalidStatus) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid node status %q", req.Status)
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if err := s.recordShardLoads(ctx, nodeID, req.ShardLoads); err != nil {
+		return nil, err
+	}
+
+	leases, err := s.renewLeases(ctx, nodeID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &shardmanagerpb.HeartbeatResponse{Success: true, Leases: leases}
+
+	node, err := s.db.GetNodeInfo(ctx, nodeID)
+	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
+	if isPullNode(node) {
+		s.replicas.set(nodeID, reportedShards(req.Shards))
+		if err := s.releaseDroppedLeases(ctx, nodeID, req.Shards); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		resp.DesiredShards = desiredShards(leases)
+	}
+
+	return resp, nil
+}
+
+// recordShardLoads stores the per-shard load samples carried by a heartbeat.
+// Samples for shards the shard map does not assign to the node are dropped,
+// so a node that has not caught up with a move cannot overwrite the load
+// the new owner reports.
+func (s *Server) recordShardLoads(ctx context.Context, nodeID uuid.UUID, reported []*shardmanagerpb.ShardLoad) error {
+	if len(reported) == 0 {
+		return nil
+	}
+	shards, err := s.db.ListShards(ctx)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	owned := make(map[uuid.UUID]bool)
+	for _, shard := range shards {
+		if shard.NodeID != nil && *shard.NodeID == nodeID {
+			owned[shard.ID] = true
+		}
+	}
 
-	return &shardmanagerpb.HeartbeatResponse{Success: true}, nil
+	loads := make([]*db.ShardLoad, 0, len(reported))
+	for _, r := range reported {
+		shardID, err := uuid.Parse(r.ShardId)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, "invalid shard ID in shard load")
+		}
+		if !owned[shardID] {
+			s.log().DebugContext(ctx, "Ignoring load for a shard the node does not own", "node", nodeID, "shard", shardID)
+			continue
+		}
+		loads = append(loads, &db.ShardLoad{
+			ShardID:        shardID,
+			NodeID:         nodeID,
+			QPS:            r.Qps,
+			BytesPerSecond: r.BytesPerSecond,
+			CPUShare:       r.CpuShare,
+			SizeBytes:      r.SizeBytes,
+		})
+	}
+	if err := s.db.RecordShardLoads(ctx, loads); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return nil
 }
 
 func (s *Server) ListNodes(ctx context.Context, req *shardmanagerpb.ListNodesRequest) (*shardmanagerpb.ListNodesResponse, error) {
@@ -48,16 +220,122 @@ func (s *Server) ListNodes(ctx context.Context, req *shardmanagerpb.ListNodesReq
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
+	shards, err := s.placementShards(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
 
 	pbNodes := make([]*shardmanagerpb.Node, len(nodes))
 	for i, node := range nodes {
 		pbNodes[i] = &shardmanagerpb.Node{
-			Id:       node.ID.String(),
-			Location: node.Location,
-			Capacity: node.Capacity,
-			Status:   node.Status,
+			Id:               node.ID.String(),
+			Name:             node.Name,
+			Location:         node.Location,
+			FaultDomain:      node.FaultDomain,
+			Capacity:         node.Capacity,
+			Status:           node.Status,
+			AssignmentMode:   node.AssignmentMode,
+			ResourceCapacity: node.ResourceCapacity,
+			ResourceLoad:     node.ResourceLoad,
+			ResourceHeadroom: nodeHeadroom(node, shards, uuid.Nil),
+			CurrentLoad:      node.CurrentLoad,
+		}
+		if !node.LastHeartbeat.IsZero() {
+			pbNodes[i].LastHeartbeatUnixMs = node.LastHeartbeat.UnixMilli()
 		}
 	}
 
 	return &shardmanagerpb.ListNodesResponse{Nodes: pbNodes}, nil
 }
+
+// DrainNode puts a node into maintenance, so nothing new is placed on it and
+// its heartbeats do not reactivate it, then migrates each of its shards to
+// the node the placement strategy picks. Shards that cannot be moved are
+// reported and stay on the node.
+func (s *Server) DrainNode(ctx context.Context, req *shardmanagerpb.DrainNodeRequest) (*shardmanagerpb.DrainNodeResponse, error) {
+	nodeID, err := uuid.Parse(req.NodeId)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid node ID")
+	}
+	node, err := s.db.GetNodeInfo(ctx, nodeID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if node == nil {
+		return nil, status.Error(codes.NotFound, "node not found")
+	}
+	if node.Status != "maintenance" {
+		node.Status = "maintenance"
+		if err := s.db.UpdateNode(ctx, node); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	nodes, err := s.db.ListNodes(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	shards, err := s.placementShards(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &shardmanagerpb.DrainNodeResponse{}
+	var problems []string
+	for _, shard := range shards {
+		if shard.NodeID == nil || *shard.NodeID != nodeID {
+			continue
+		}
+		target, err := selectNode(s.placementStrategy, nodes, shards, shard.ResourceDemand)
+		if err == nil {
+			_, err = s.MigrateShard(ctx, &shardmanagerpb.MigrateShardRequest{
+				ShardId:    shard.ID.String(),
+				FromNodeId: nodeID.String(),
+				ToNodeId:   target.ID.String(),
+			})
+		}
+		if err != nil {
+			resp.FailedShards = append(resp.FailedShards, shard.ID.String())
+			problems = append(problems, fmt.Sprintf("%s: %s", shard.ID, status.Convert(err).Message()))
+			continue
+		}
+		// Later picks must see the shard on its new node
+		shard.NodeID = &target.ID
+		resp.MigratedShards = append(resp.MigratedShards, shard.ID.String())
+	}
+
+	resp.Success = len(problems) == 0
+	resp.Message = fmt.Sprintf("Node drained: %d shards migrated", len(resp.MigratedShards))
+	if len(problems) > 0 {
+		resp.Message = fmt.Sprintf("Node in maintenance: %d shards migrated, %d failed (%s)",
+			len(resp.MigratedShards), len(problems), strings.Join(problems, "; "))
+	}
+	return resp, nil
+}
+
+// UndrainNode returns a node in maintenance to service. Its shards are not
+// moved back; new placements may pick it again.
+func (s *Server) UndrainNode(ctx context.Context, req *shardmanagerpb.UndrainNodeRequest) (*shardmanagerpb.UndrainNodeResponse, error) {
+	nodeID, err := uuid.Parse(req.NodeId)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid node ID")
+	}
+	node, err := s.db.GetNodeInfo(ctx, nodeID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if node == nil {
+		return nil, status.Error(codes.NotFound, "node not found")
+	}
+	if node.Status != "maintenance" {
+		return nil, status.Errorf(codes.FailedPrecondition, "node is %s, not in maintenance", node.Status)
+	}
+	node.Status = "active"
+	if err := s.db.UpdateNode(ctx, node); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &shardmanagerpb.UndrainNodeResponse{
+		Success: true,
+		Message: "Node returned to service",
+	}, nil
+}
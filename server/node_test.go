@@ -7,6 +7,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/seaweedfs/shardmanager/shardmanagerpb"
 
@@ -36,6 +38,82 @@ func TestNodeService(t *testing.T) {
 		assert.True(t, resp.Success)
 	})
 
+	t.Run("RegisterNodeReturnsID", func(t *testing.T) {
+		mockDB.Reset()
+		nodeID := uuid.New()
+		resp, err := server.RegisterNode(context.Background(), &shardmanagerpb.RegisterNodeRequest{
+			Node: &shardmanagerpb.Node{Id: nodeID.String(), Location: "localhost:8080", Capacity: 1000, Status: "active"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, nodeID.String(), resp.NodeId)
+
+		resp, err = server.RegisterNode(context.Background(), &shardmanagerpb.RegisterNodeRequest{
+			Node: &shardmanagerpb.Node{Location: "localhost:8081", Capacity: 1000, Status: "active"},
+		})
+		require.NoError(t, err)
+		_, err = uuid.Parse(resp.NodeId)
+		assert.NoError(t, err)
+	})
+
+	t.Run("ReRegisterNodeUpdatesInPlace", func(t *testing.T) {
+		mockDB.Reset()
+		first, err := server.RegisterNode(context.Background(), &shardmanagerpb.RegisterNodeRequest{
+			Node: &shardmanagerpb.Node{Name: "appserver-1", Location: "localhost:8080", Capacity: 1000, Status: "active"},
+		})
+		require.NoError(t, err)
+
+		// A restarted app server comes back on a new address
+		second, err := server.RegisterNode(context.Background(), &shardmanagerpb.RegisterNodeRequest{
+			Node: &shardmanagerpb.Node{Name: "appserver-1", Location: "localhost:9090", Capacity: 2000, Status: "active"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, first.NodeId, second.NodeId)
+
+		// Non-UUID IDs are treated as the node's name
+		third, err := server.RegisterNode(context.Background(), &shardmanagerpb.RegisterNodeRequest{
+			Node: &shardmanagerpb.Node{Id: "appserver-1", Location: "localhost:9091", Capacity: 2000, Status: "active", FaultDomain: "rack-2"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, first.NodeId, third.NodeId)
+
+		nodes, err := server.ListNodes(context.Background(), &shardmanagerpb.ListNodesRequest{})
+		require.NoError(t, err)
+		require.Len(t, nodes.Nodes, 1)
+		assert.Equal(t, "localhost:9091", nodes.Nodes[0].Location)
+		assert.Equal(t, int64(2000), nodes.Nodes[0].Capacity)
+		assert.Equal(t, "appserver-1", nodes.Nodes[0].Name)
+		assert.Equal(t, "rack-2", nodes.Nodes[0].FaultDomain)
+	})
+
+	t.Run("RegisterNodeNameConflict", func(t *testing.T) {
+		mockDB.Reset()
+		_, err := server.RegisterNode(context.Background(), &shardmanagerpb.RegisterNodeRequest{
+			Node: &shardmanagerpb.Node{Name: "appserver-1", Location: "localhost:8080", Status: "active"},
+		})
+		require.NoError(t, err)
+
+		_, err = server.RegisterNode(context.Background(), &shardmanagerpb.RegisterNodeRequest{
+			Node: &shardmanagerpb.Node{Id: uuid.New().String(), Name: "appserver-1", Location: "localhost:8081", Status: "active"},
+		})
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+
+	t.Run("RegisterNodeStatus", func(t *testing.T) {
+		mockDB.Reset()
+		resp, err := server.RegisterNode(context.Background(), &shardmanagerpb.RegisterNodeRequest{
+			Node: &shardmanagerpb.Node{Location: "localhost:8080"},
+		})
+		require.NoError(t, err)
+		node, err := mockDB.GetNodeInfo(context.Background(), uuid.MustParse(resp.NodeId))
+		require.NoError(t, err)
+		assert.Equal(t, "active", node.Status, "nodes register as active by default")
+
+		_, err = server.RegisterNode(context.Background(), &shardmanagerpb.RegisterNodeRequest{
+			Node: &shardmanagerpb.Node{Location: "localhost:8081", Status: "sleeping"},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
 	t.Run("Heartbeat", func(t *testing.T) {
 		mockDB.Reset()
 		nodeID := uuid.New()
@@ -76,3 +154,146 @@ func TestNodeService(t *testing.T) {
 		assert.Equal(t, "active", resp.Nodes[0].Status)
 	})
 }
+
+func TestDrainNode(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+
+	drained := &db.Node{ID: uuid.New(), Location: "localhost:1", Status: "active"}
+	spare := &db.Node{ID: uuid.New(), Location: "localhost:2", Status: "active"}
+	require.NoError(t, mockDB.RegisterNode(ctx, drained))
+	require.NoError(t, mockDB.RegisterNode(ctx, spare))
+	moved := &db.Shard{ID: uuid.New(), NodeID: &drained.ID, Status: "active"}
+	stuck := &db.Shard{ID: uuid.New(), NodeID: &drained.ID, Status: "active", ResourceDemand: db.Resources{"cpu": 4}}
+	require.NoError(t, mockDB.RegisterShard(ctx, moved))
+	require.NoError(t, mockDB.RegisterShard(ctx, stuck))
+	spare.ResourceCapacity = db.Resources{"cpu": 1}
+	require.NoError(t, mockDB.UpdateNode(ctx, spare))
+
+	resp, err := server.DrainNode(ctx, &shardmanagerpb.DrainNodeRequest{NodeId: drained.ID.String()})
+	require.NoError(t, err)
+	assert.False(t, resp.Success)
+	assert.Equal(t, []string{moved.ID.String()}, resp.MigratedShards)
+	assert.Equal(t, []string{stuck.ID.String()}, resp.FailedShards)
+	assert.Contains(t, resp.Message, "resource headroom")
+
+	shard, err := mockDB.GetShardInfo(ctx, moved.ID)
+	require.NoError(t, err)
+	assert.Equal(t, spare.ID, *shard.NodeID)
+
+	// Heartbeats and re-registration leave the drain in place
+	_, err = server.Heartbeat(ctx, &shardmanagerpb.HeartbeatRequest{NodeId: drained.ID.String(), Status: "active"})
+	require.NoError(t, err)
+	_, err = server.RegisterNode(ctx, &shardmanagerpb.RegisterNodeRequest{
+		Node: &shardmanagerpb.Node{Id: drained.ID.String(), Location: "localhost:1", Status: "active"},
+	})
+	require.NoError(t, err)
+	node, err := mockDB.GetNodeInfo(ctx, drained.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "maintenance", node.Status)
+
+	// Nothing new is placed on it
+	_, err = selectNode(PlacementLeastShards, []*db.Node{node}, nil, nil)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = server.UndrainNode(ctx, &shardmanagerpb.UndrainNodeRequest{NodeId: drained.ID.String()})
+	require.NoError(t, err)
+	node, err = mockDB.GetNodeInfo(ctx, drained.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "active", node.Status)
+
+	_, err = server.UndrainNode(ctx, &shardmanagerpb.UndrainNodeRequest{NodeId: drained.ID.String()})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = server.DrainNode(ctx, &shardmanagerpb.DrainNodeRequest{NodeId: uuid.NewString()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestShardLoadReporting(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+
+	node := &db.Node{ID: uuid.New(), Status: "active", AssignmentMode: AssignmentModePull}
+	require.NoError(t, mockDB.RegisterNode(ctx, node))
+	hot := &db.Shard{ID: uuid.New(), NodeID: &node.ID, Status: "active"}
+	cold := &db.Shard{ID: uuid.New(), NodeID: &node.ID, Status: "active"}
+	idle := &db.Shard{ID: uuid.New(), Status: "active"}
+	for _, shard := range []*db.Shard{hot, cold, idle} {
+		require.NoError(t, mockDB.RegisterShard(ctx, shard))
+	}
+
+	t.Run("RejectsInvalidShardID", func(t *testing.T) {
+		_, err := server.Heartbeat(ctx, &shardmanagerpb.HeartbeatRequest{
+			NodeId:     node.ID.String(),
+			Status:     "active",
+			ShardLoads: []*shardmanagerpb.ShardLoad{{ShardId: "not-a-uuid"}},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("StoresLoads", func(t *testing.T) {
+		_, err := server.Heartbeat(ctx, &shardmanagerpb.HeartbeatRequest{
+			NodeId: node.ID.String(),
+			Status: "active",
+			ShardLoads: []*shardmanagerpb.ShardLoad{
+				{ShardId: hot.ID.String(), Qps: 900, BytesPerSecond: 4096, CpuShare: 0.6, SizeBytes: 1 << 20},
+				{ShardId: cold.ID.String(), Qps: 100, CpuShare: 0.1, SizeBytes: 1 << 10},
+			},
+		})
+		require.NoError(t, err)
+
+		loads, err := mockDB.ListShardLoads(ctx)
+		require.NoError(t, err)
+		require.Len(t, loads, 2)
+		byShard := make(map[uuid.UUID]*db.ShardLoad)
+		for _, load := range loads {
+			byShard[load.ShardID] = load
+		}
+		hotLoad := byShard[hot.ID]
+		require.NotNil(t, hotLoad)
+		assert.Equal(t, node.ID, hotLoad.NodeID)
+		assert.Equal(t, 900.0, hotLoad.QPS)
+		assert.EqualValues(t, 4096, hotLoad.BytesPerSecond)
+		assert.Equal(t, 0.6, hotLoad.CPUShare)
+		assert.False(t, hotLoad.ReportedAt.IsZero())
+		assert.NotContains(t, byShard, idle.ID)
+	})
+
+	t.Run("IgnoresUnownedShards", func(t *testing.T) {
+		owner := &db.Node{ID: uuid.New(), Status: "active", AssignmentMode: AssignmentModePull}
+		require.NoError(t, mockDB.RegisterNode(ctx, owner))
+		moved := &db.Shard{ID: uuid.New(), NodeID: &owner.ID, Status: "active"}
+		require.NoError(t, mockDB.RegisterShard(ctx, moved))
+		_, err := server.Heartbeat(ctx, &shardmanagerpb.HeartbeatRequest{
+			NodeId:     owner.ID.String(),
+			Status:     "active",
+			ShardLoads: []*shardmanagerpb.ShardLoad{{ShardId: moved.ID.String(), Qps: 5}},
+		})
+		require.NoError(t, err)
+
+		// node still reports the shard it lost, and one that does not exist
+		_, err = server.Heartbeat(ctx, &shardmanagerpb.HeartbeatRequest{
+			NodeId: node.ID.String(),
+			Status: "active",
+			ShardLoads: []*shardmanagerpb.ShardLoad{
+				{ShardId: moved.ID.String(), Qps: 999},
+				{ShardId: uuid.New().String(), Qps: 999},
+				{ShardId: hot.ID.String(), Qps: 950},
+			},
+		})
+		require.NoError(t, err)
+
+		loads, err := mockDB.ListShardLoads(ctx)
+		require.NoError(t, err)
+		byShard := make(map[uuid.UUID]*db.ShardLoad)
+		for _, load := range loads {
+			byShard[load.ShardID] = load
+		}
+		assert.Len(t, byShard, 3)
+		require.Contains(t, byShard, moved.ID)
+		assert.Equal(t, owner.ID, byShard[moved.ID].NodeID, "the owner's sample stands")
+		assert.Equal(t, 5.0, byShard[moved.ID].QPS)
+		assert.Equal(t, 950.0, byShard[hot.ID].QPS, "samples for owned shards are still stored")
+	})
+}
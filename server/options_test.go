@@ -0,0 +1,128 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOptions(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("YAML", func(t *testing.T) {
+		path := filepath.Join(dir, "server.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(`
+grpc_addr: ":9000"
+database: "file:test.db"
+heartbeat_timeout: 45s
+placement_strategy: least_loaded
+replication_factor: 3
+tls:
+  cert_file: server.pem
+  key_file: server-key.pem
+database_pool:
+  max_open_conns: 5
+  query_timeout: 3s
+`), 0o600))
+		opts, err := LoadOptions(path)
+		require.NoError(t, err)
+		assert.Equal(t, ":9000", opts.GRPCAddr)
+		assert.Equal(t, "file:test.db", opts.Database)
+		assert.Equal(t, 45*time.Second, opts.HeartbeatTimeout)
+		assert.Equal(t, PlacementLeastLoaded, opts.PlacementStrategy)
+		assert.Equal(t, 3, opts.ReplicationFactor)
+		assert.Equal(t, "server.pem", opts.TLS.CertFile)
+		assert.Equal(t, 5, opts.DatabasePool.MaxOpenConns)
+		assert.Equal(t, 3*time.Second, opts.DatabasePool.QueryTimeout)
+		// Unset options keep their defaults
+		assert.Equal(t, DefaultOptions().LeaseDuration, opts.LeaseDuration)
+		assert.Equal(t, DefaultOptions().DatabasePool.MaxRetries, opts.DatabasePool.MaxRetries)
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		path := filepath.Join(dir, "server.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"metrics_addr": ":9090", "reconcile_interval": "1m"}`), 0o600))
+		opts, err := LoadOptions(path)
+		require.NoError(t, err)
+		assert.Equal(t, ":9090", opts.MetricsAddr)
+		assert.Equal(t, time.Minute, opts.ReconcileInterval)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		path := filepath.Join(dir, "bad.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("lease_duration: 0s\nplacement_strategy: random\n"), 0o600))
+		// Validation waits until the caller has applied its own overrides
+		opts, err := LoadOptions(path)
+		require.NoError(t, err)
+		err = opts.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "lease_duration must be positive")
+		assert.Contains(t, err.Error(), `unknown placement_strategy "random"`)
+
+		opts.LeaseDuration = time.Minute
+		opts.PlacementStrategy = PlacementLeastShards
+		assert.NoError(t, opts.Validate())
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		_, err := LoadOptions(filepath.Join(dir, "missing.yaml"))
+		assert.Error(t, err)
+	})
+}
+
+func TestOptionsEnvOverrides(t *testing.T) {
+	env := map[string]string{
+		"SHARDMANAGER_DATABASE":           "file:env.db",
+		"SHARDMANAGER_LEASE_DURATION":     "20s",
+		"SHARDMANAGER_TLS_CA_FILE":        "ca.pem",
+		"SHARDMANAGER_PLACEMENT_STRATEGY": "least_loaded",
+		"SHARDMANAGER_LEADER_ELECTION":    "true",
+		"SHARDMANAGER_DB_MAX_RETRIES":     "7",
+		"SHARDMANAGER_LOG_FORMAT":         "json",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	opts := DefaultOptions()
+	require.NoError(t, opts.applyEnv(lookup))
+	assert.Equal(t, "file:env.db", opts.Database)
+	assert.Equal(t, 20*time.Second, opts.LeaseDuration)
+	assert.Equal(t, "ca.pem", opts.TLS.CAFile)
+	assert.Equal(t, PlacementLeastLoaded, opts.PlacementStrategy)
+	assert.True(t, opts.LeaderElection)
+	assert.Equal(t, 7, opts.DatabasePool.MaxRetries)
+	assert.Equal(t, LogFormatJSON, opts.LogFormat)
+
+	env["SHARDMANAGER_LEASE_DURATION"] = "soon"
+	assert.ErrorContains(t, opts.applyEnv(lookup), "SHARDMANAGER_LEASE_DURATION")
+}
+
+func TestOptionsValidate(t *testing.T) {
+	opts := DefaultOptions()
+	require.NoError(t, opts.Validate())
+
+	opts.GRPCAddr = ""
+	opts.TLS.KeyFile = "key.pem"
+	opts.LeaderElection = true
+	opts.Database = "mem:"
+	opts.DatabasePool.MaxOpenConns = -1
+	opts.LogLevel = "loud"
+	opts.LogFormat = "xml"
+	opts.ReplicationFactor = 0
+	err := opts.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "grpc_addr is required")
+	assert.Contains(t, err.Error(), "tls.cert_file and tls.key_file must be set together")
+	assert.Contains(t, err.Error(), "leader_election needs advertise_addr")
+	assert.Contains(t, err.Error(), "leader_election needs a database replicas can share")
+	assert.Contains(t, err.Error(), "database_pool: max_open_conns must not be negative")
+	assert.Contains(t, err.Error(), `unknown log_level "loud"`)
+	assert.Contains(t, err.Error(), `unknown log_format "xml"`)
+	assert.Contains(t, err.Error(), "replication_factor must be at least 1")
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// allocatedDemand sums the resource demand of the shards assigned to a node,
+// leaving out the shard identified by exclude.
+func allocatedDemand(nodeID uuid.UUID, shards []*db.Shard, exclude uuid.UUID) db.Resources {
+	allocated := db.Resources{}
+	for _, shard := range shards {
+		if shard.NodeID == nil || *shard.NodeID != nodeID || shard.ID == exclude {
+			continue
+		}
+		allocated = allocated.Add(shard.ResourceDemand)
+	}
+	return allocated
+}
+
+// measuredDemand raises a shard's declared demand to its last reported load
+// in the qps, bytes_per_second and size_bytes dimensions, so a node that
+// declares capacity in those is held to what its shards really use. CPU
+// share is left out: it is a fraction of whichever node reported it.
+func measuredDemand(shard *db.Shard, load *db.ShardLoad) db.Resources {
+	if load == nil {
+		return shard.ResourceDemand
+	}
+	return shard.ResourceDemand.Max(db.Resources{
+		MetricQPS:            load.QPS,
+		MetricBytesPerSecond: float64(load.BytesPerSecond),
+		MetricSizeBytes:      float64(load.SizeBytes),
+	})
+}
+
+// placementShards lists the shards with their demand raised to their
+// measured load, as placement and headroom reports see them
+func (s *Server) placementShards(ctx context.Context) ([]*db.Shard, error) {
+	shards, err := s.db.ListShards(ctx)
+	if err != nil {
+		return nil, err
+	}
+	loads, err := s.db.ListShardLoads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return withMeasuredDemand(shards, loadsByShard(loads)), nil
+}
+
+// withMeasuredDemand returns copies of shards whose demand is raised to
+// their measured load
+func withMeasuredDemand(shards []*db.Shard, loads map[uuid.UUID]*db.ShardLoad) []*db.Shard {
+	measured := make([]*db.Shard, len(shards))
+	for i, shard := range shards {
+		copied := *shard
+		copied.ResourceDemand = measuredDemand(shard, loads[shard.ID])
+		measured[i] = &copied
+	}
+	return measured
+}
+
+// nodeHeadroom is what remains of each declared capacity dimension once the
+// larger of the reported load and the demand of assigned shards is taken out.
+func nodeHeadroom(node *db.Node, shards []*db.Shard, exclude uuid.UUID) db.Resources {
+	usage := node.ResourceLoad.Max(allocatedDemand(node.ID, shards, exclude))
+	return node.ResourceCapacity.Headroom(usage)
+}
+
+// selectNode picks, among the active nodes with headroom for demand in every
+// dimension, the one preferred by strategy: the fewest shards, or the lowest
+// reported load with the shard count breaking ties. It returns a
+// FailedPrecondition error when no node qualifies.
+func selectNode(strategy string, nodes []*db.Node, shards []*db.Shard, demand db.Resources) (*db.Node, error) {
+	shardCounts := make(map[uuid.UUID]int)
+	for _, shard := range shards {
+		if shard.NodeID != nil {
+			shardCounts[*shard.NodeID]++
+		}
+	}
+
+	var selected *db.Node
+	active := 0
+	for _, node := range nodes {
+		if node.Status != "active" {
+			continue
+		}
+		active++
+		if !nodeHeadroom(node, shards, uuid.Nil).Fits(demand) {
+			continue
+		}
+		if selected == nil || preferNode(strategy, node, selected, shardCounts) {
+			selected = node
+		}
+	}
+
+	switch {
+	case selected != nil:
+		return selected, nil
+	case active == 0:
+		return nil, status.Error(codes.FailedPrecondition, "no active nodes available for shard assignment")
+	default:
+		return nil, status.Error(codes.FailedPrecondition, "no node has enough resource headroom for the shard")
+	}
+}
+
+// preferNode reports whether strategy ranks candidate above current
+func preferNode(strategy string, candidate, current *db.Node, shardCounts map[uuid.UUID]int) bool {
+	if strategy == PlacementLeastLoaded && candidate.CurrentLoad != current.CurrentLoad {
+		return candidate.CurrentLoad < current.CurrentLoad
+	}
+	return shardCounts[candidate.ID] < shardCounts[current.ID]
+}
+
+// checkHeadroom verifies that nodeID can take on shard's resource demand,
+// raised to its measured load
+func (s *Server) checkHeadroom(ctx context.Context, nodeID uuid.UUID, shard *db.Shard) error {
+	shards, err := s.placementShards(ctx)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	demand := shard.ResourceDemand
+	for _, measured := range shards {
+		if measured.ID == shard.ID {
+			demand = measured.ResourceDemand
+			break
+		}
+	}
+	if len(demand) == 0 {
+		return nil
+	}
+	node, err := s.db.GetNodeInfo(ctx, nodeID)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if node == nil {
+		return status.Error(codes.NotFound, "node not found")
+	}
+	headroom := nodeHeadroom(node, shards, shard.ID)
+	if !headroom.Fits(demand) {
+		return status.Error(codes.FailedPrecondition, fmt.Sprintf("node %s lacks resource headroom for shard %s", nodeID, shard.ID))
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/server/testutil"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+func TestSelectNode(t *testing.T) {
+	small := &db.Node{ID: uuid.New(), Status: "active", ResourceCapacity: db.Resources{"cpu": 2, "memory": 4}}
+	large := &db.Node{ID: uuid.New(), Status: "active", ResourceCapacity: db.Resources{"cpu": 8, "memory": 32}}
+	shards := []*db.Shard{
+		{ID: uuid.New(), NodeID: &large.ID, ResourceDemand: db.Resources{"cpu": 1}},
+	}
+
+	t.Run("PrefersFewestShards", func(t *testing.T) {
+		node, err := selectNode(PlacementLeastShards, []*db.Node{small, large}, shards, db.Resources{"cpu": 1, "memory": 1})
+		require.NoError(t, err)
+		assert.Equal(t, small.ID, node.ID)
+	})
+
+	t.Run("RespectsEveryDimension", func(t *testing.T) {
+		// Fits small on CPU but not on memory
+		node, err := selectNode(PlacementLeastShards, []*db.Node{small, large}, shards, db.Resources{"cpu": 1, "memory": 8})
+		require.NoError(t, err)
+		assert.Equal(t, large.ID, node.ID)
+	})
+
+	t.Run("ReportedLoadCountsAgainstHeadroom", func(t *testing.T) {
+		busy := &db.Node{ID: uuid.New(), Status: "active",
+			ResourceCapacity: db.Resources{"qps": 100}, ResourceLoad: db.Resources{"qps": 90}}
+		_, err := selectNode(PlacementLeastShards, []*db.Node{busy}, nil, db.Resources{"qps": 20})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("UndeclaredDimensionsAreUnconstrained", func(t *testing.T) {
+		node, err := selectNode(PlacementLeastShards, []*db.Node{small}, nil, db.Resources{"disk": 1000})
+		require.NoError(t, err)
+		assert.Equal(t, small.ID, node.ID)
+	})
+
+	t.Run("LeastLoadedPrefersLowestLoad", func(t *testing.T) {
+		idle := &db.Node{ID: uuid.New(), Status: "active", CurrentLoad: 1}
+		hot := &db.Node{ID: uuid.New(), Status: "active", CurrentLoad: 50}
+		loaded := []*db.Shard{{ID: uuid.New(), NodeID: &idle.ID}}
+		node, err := selectNode(PlacementLeastLoaded, []*db.Node{hot, idle}, loaded, nil)
+		require.NoError(t, err)
+		assert.Equal(t, idle.ID, node.ID)
+
+		// Equal load falls back to the shard count
+		hot.CurrentLoad = idle.CurrentLoad
+		node, err = selectNode(PlacementLeastLoaded, []*db.Node{idle, hot}, loaded, nil)
+		require.NoError(t, err)
+		assert.Equal(t, hot.ID, node.ID)
+	})
+
+	t.Run("NoActiveNodes", func(t *testing.T) {
+		down := &db.Node{ID: uuid.New(), Status: "failed"}
+		_, err := selectNode(PlacementLeastShards, []*db.Node{down}, nil, nil)
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+}
+
+func TestResourceHeadroom(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+
+	node := &db.Node{ID: uuid.New(), Location: "localhost:0", Status: "active",
+		AssignmentMode: AssignmentModePull, ResourceCapacity: db.Resources{"cpu": 4, "memory": 16}}
+	require.NoError(t, mockDB.RegisterNode(ctx, node))
+	placed := &db.Shard{ID: uuid.New(), NodeID: &node.ID, Status: "active", ResourceDemand: db.Resources{"cpu": 3}}
+	require.NoError(t, mockDB.RegisterShard(ctx, placed))
+
+	t.Run("ListNodesReportsHeadroom", func(t *testing.T) {
+		_, err := server.Heartbeat(ctx, &shardmanagerpb.HeartbeatRequest{
+			NodeId:       node.ID.String(),
+			Status:       "active",
+			ResourceLoad: map[string]float64{"cpu": 1, "memory": 6},
+		})
+		require.NoError(t, err)
+
+		resp, err := server.ListNodes(ctx, &shardmanagerpb.ListNodesRequest{})
+		require.NoError(t, err)
+		require.Len(t, resp.Nodes, 1)
+		// CPU is bound by allocated demand, memory by reported load
+		assert.Equal(t, map[string]float64{"cpu": 1, "memory": 10}, resp.Nodes[0].ResourceHeadroom)
+		assert.Equal(t, map[string]float64{"cpu": 1, "memory": 6}, resp.Nodes[0].ResourceLoad)
+	})
+
+	t.Run("AssignRejectsInsufficientHeadroom", func(t *testing.T) {
+		shard := &db.Shard{ID: uuid.New(), Status: "active", ResourceDemand: db.Resources{"cpu": 2}}
+		require.NoError(t, mockDB.RegisterShard(ctx, shard))
+
+		_, err := server.AssignShard(ctx, &shardmanagerpb.AssignShardRequest{
+			ShardId: shard.ID.String(),
+			NodeId:  node.ID.String(),
+		})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("ReassigningToCurrentNodeExcludesOwnDemand", func(t *testing.T) {
+		_, err := server.AssignShard(ctx, &shardmanagerpb.AssignShardRequest{
+			ShardId: placed.ID.String(),
+			NodeId:  node.ID.String(),
+		})
+		assert.NoError(t, err)
+	})
+}
+
+func TestMeasuredDemand(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+
+	node := &db.Node{ID: uuid.New(), Location: "localhost:0", Status: "active",
+		AssignmentMode: AssignmentModePull, ResourceCapacity: db.Resources{"qps": 100}}
+	require.NoError(t, mockDB.RegisterNode(ctx, node))
+	placed := &db.Shard{ID: uuid.New(), NodeID: &node.ID, Status: "active", ResourceDemand: db.Resources{"qps": 10}}
+	require.NoError(t, mockDB.RegisterShard(ctx, placed))
+	busy := &db.Shard{ID: uuid.New(), Status: "active"}
+	require.NoError(t, mockDB.RegisterShard(ctx, busy))
+	require.NoError(t, mockDB.RecordShardLoads(ctx, []*db.ShardLoad{
+		{ShardID: placed.ID, NodeID: node.ID, QPS: 70},
+		{ShardID: busy.ID, NodeID: uuid.New(), QPS: 50},
+	}))
+
+	resp, err := server.ListNodes(ctx, &shardmanagerpb.ListNodesRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Nodes, 1)
+	assert.Equal(t, map[string]float64{"qps": 30}, resp.Nodes[0].ResourceHeadroom, "measured load outweighs the declared demand")
+
+	// busy declares no demand, but its measured load does not fit
+	_, err = server.AssignShard(ctx, &shardmanagerpb.AssignShardRequest{
+		ShardId: busy.ID.String(),
+		NodeId:  node.ID.String(),
+	})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+// Assignment modes selectable per node at registration
+const (
+	// AssignmentModePush has the shardmanager call the node's AppShardService
+	AssignmentModePush = "push"
+	// AssignmentModePull has the node converge on the desired shard set
+	// returned in each heartbeat response
+	AssignmentModePull = "pull"
+)
+
+// isPullNode reports whether the node converges on its own via heartbeats
+func isPullNode(node *db.Node) bool {
+	return node != nil && node.AssignmentMode == AssignmentModePull
+}
+
+// desiredShards converts the leases granted to a pull-mode node into the
+// shard set it should be serving. Shards still leased to a previous owner
+// are withheld until the handoff completes.
+func desiredShards(leases []*shardmanagerpb.ShardLease) []*shardmanagerpb.ShardReplica {
+	desired := make([]*shardmanagerpb.ShardReplica, 0, len(leases))
+	for _, lease := range leases {
+		desired = append(desired, &shardmanagerpb.ShardReplica{
+			ShardId: lease.ShardId,
+			Role:    "primary",
+		})
+	}
+	return desired
+}
+
+// releaseDroppedLeases revokes the leases a pull-mode node holds on shards it
+// no longer owns and no longer reports, so the new owner does not have to
+// wait for them to expire
+func (s *Server) releaseDroppedLeases(ctx context.Context, nodeID uuid.UUID, reported []*shardmanagerpb.ShardReplica) error {
+	leases, err := s.db.ListNodeLeases(ctx, nodeID)
+	if err != nil {
+		return err
+	}
+
+	serving := make(map[string]bool, len(reported))
+	for _, replica := range reported {
+		serving[replica.ShardId] = true
+	}
+
+	for _, lease := range leases {
+		if serving[lease.ShardID.String()] {
+			continue
+		}
+		shard, err := s.db.GetShardInfo(ctx, lease.ShardID)
+		if err != nil {
+			return err
+		}
+		if shard != nil && shard.NodeID != nil && *shard.NodeID == nodeID {
+			continue
+		}
+		if err := s.revokeLease(ctx, lease.ShardID, nodeID, "released by node"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
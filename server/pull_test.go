@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/server/testutil"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+func TestPullAssignment(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+
+	t.Run("RejectsUnknownMode", func(t *testing.T) {
+		_, err := server.RegisterNode(ctx, &shardmanagerpb.RegisterNodeRequest{
+			Node: &shardmanagerpb.Node{Location: "localhost:5001", Status: "active", AssignmentMode: "poll"},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("HeartbeatCarriesDesiredShards", func(t *testing.T) {
+		mockDB.Reset()
+		nodeA := &db.Node{ID: uuid.New(), Status: "active", AssignmentMode: AssignmentModePull}
+		nodeB := &db.Node{ID: uuid.New(), Status: "active", AssignmentMode: AssignmentModePull}
+		pushNode := &db.Node{ID: uuid.New(), Status: "active", AssignmentMode: AssignmentModePush}
+		for _, node := range []*db.Node{nodeA, nodeB, pushNode} {
+			require.NoError(t, mockDB.RegisterNode(ctx, node))
+		}
+		shard := &db.Shard{ID: uuid.New(), NodeID: &nodeA.ID, Status: "active"}
+		require.NoError(t, mockDB.RegisterShard(ctx, shard))
+
+		resp, err := server.Heartbeat(ctx, &shardmanagerpb.HeartbeatRequest{NodeId: nodeA.ID.String(), Status: "active"})
+		require.NoError(t, err)
+		require.Len(t, resp.DesiredShards, 1)
+		assert.Equal(t, shard.ID.String(), resp.DesiredShards[0].ShardId)
+		assert.Equal(t, "primary", resp.DesiredShards[0].Role)
+
+		// Push-mode nodes never get a desired set
+		resp, err = server.Heartbeat(ctx, &shardmanagerpb.HeartbeatRequest{NodeId: pushNode.ID.String(), Status: "active"})
+		require.NoError(t, err)
+		assert.Empty(t, resp.DesiredShards)
+
+		// Move the shard: B must not be told to serve it while A still reports it
+		require.NoError(t, mockDB.AssignShard(ctx, shard.ID, nodeB.ID))
+		resp, err = server.Heartbeat(ctx, &shardmanagerpb.HeartbeatRequest{NodeId: nodeB.ID.String(), Status: "active"})
+		require.NoError(t, err)
+		assert.Empty(t, resp.DesiredShards)
+
+		resp, err = server.Heartbeat(ctx, &shardmanagerpb.HeartbeatRequest{
+			NodeId: nodeA.ID.String(),
+			Status: "active",
+			Shards: []*shardmanagerpb.ShardReplica{{ShardId: shard.ID.String(), Role: "primary"}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, resp.DesiredShards)
+
+		resp, err = server.Heartbeat(ctx, &shardmanagerpb.HeartbeatRequest{NodeId: nodeB.ID.String(), Status: "active"})
+		require.NoError(t, err)
+		assert.Empty(t, resp.DesiredShards)
+
+		// Once A reports it dropped the shard its lease is released early
+		_, err = server.Heartbeat(ctx, &shardmanagerpb.HeartbeatRequest{NodeId: nodeA.ID.String(), Status: "active"})
+		require.NoError(t, err)
+
+		resp, err = server.Heartbeat(ctx, &shardmanagerpb.HeartbeatRequest{NodeId: nodeB.ID.String(), Status: "active"})
+		require.NoError(t, err)
+		require.Len(t, resp.DesiredShards, 1)
+		assert.Equal(t, shard.ID.String(), resp.DesiredShards[0].ShardId)
+	})
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/policy"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+// Reasons the rebalancer moves a shard
+const (
+	RebalanceOverCapacity = "over_capacity"
+	RebalanceHotspot      = "hotspot"
+)
+
+// rebalanceMove is a shard the rebalancer decided to move
+type rebalanceMove struct {
+	shard  *db.Shard
+	from   uuid.UUID
+	to     uuid.UUID
+	reason string
+}
+
+// runRebalancer periodically rebalances shards until ctx is cancelled.
+// Followers skip rebalancing and leave it to the leader.
+func (s *Server) runRebalancer(ctx context.Context) {
+	ticker := time.NewTicker(s.rebalanceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.isLeader() {
+				continue
+			}
+			passCtx := contextWithRequestID(ctx, newRequestID())
+			if _, err := s.rebalance(passCtx); err != nil {
+				s.log().WarnContext(passCtx, "Rebalancing failed", "err", err)
+			}
+		}
+	}
+}
+
+// rebalance moves at most one shard per pass. A node whose shards' demand,
+// raised to their measured load, exceeds a capacity it declares sheds the
+// shard using most of that resource. Otherwise, if the stored load_balancing
+// policy matches the cluster state, the busiest node by reported CPU share
+// hands its hottest shard to the least busy node that can take it. It
+// returns the move it started, if any; moves are audited.
+func (s *Server) rebalance(ctx context.Context) (*rebalanceMove, error) {
+	nodes, err := s.db.ListNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := s.db.ListShards(ctx)
+	if err != nil {
+		return nil, err
+	}
+	loadRows, err := s.db.ListShardLoads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	loads := loadsByShard(loadRows)
+	measured := withMeasuredDemand(shards, loads)
+
+	move := overCapacityMove(s.placementStrategy, nodes, measured)
+	if move == nil {
+		matched, err := s.loadBalancingMatches(ctx, buildSystemState(nodes, shards, loads))
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			move = hotspotMove(nodes, measured, loads)
+		}
+	}
+	if move == nil {
+		return nil, nil
+	}
+
+	s.log().InfoContext(ctx, "Rebalancing shard", "shard", move.shard.ID, "from", move.from, "to", move.to, "reason", move.reason)
+	_, err = s.MigrateShard(ctx, &shardmanagerpb.MigrateShardRequest{
+		ShardId:    move.shard.ID.String(),
+		FromNodeId: move.from.String(),
+		ToNodeId:   move.to.String(),
+	})
+	s.auditSystem(ctx, AuditRebalance, "shard", move.shard.ID.String(), map[string]string{
+		"from_node_id": move.from.String(),
+		"to_node_id":   move.to.String(),
+		"reason":       move.reason,
+	}, err)
+	if err != nil {
+		return nil, err
+	}
+	return move, nil
+}
+
+// loadBalancingMatches evaluates the stored load_balancing policy against
+// state, counting the result by policy name. Without a stored policy
+// nothing matches; a policy that does not parse is logged, counted under
+// its type and treated as not matching.
+func (s *Server) loadBalancingMatches(ctx context.Context, state *policy.SystemState) (bool, error) {
+	stored, err := s.db.GetPolicy(ctx, string(policy.PolicyTypeLoadBalancing))
+	if err != nil || stored == nil {
+		return false, err
+	}
+	parser := policy.NewDefaultParser()
+	p, err := parser.Parse(stored.Parameters)
+	if err != nil {
+		s.metrics.policyEvaluated(stored.PolicyType, PolicyError)
+		s.log().WarnContext(ctx, "Stored load_balancing policy is invalid", "err", err)
+		return false, nil
+	}
+	result, err := policy.NewDefaultEvaluator(parser).Evaluate(ctx, p, state)
+	if err != nil {
+		s.metrics.policyEvaluated(p.Name, PolicyError)
+		s.log().WarnContext(ctx, "Could not evaluate policy", "policy", p.Name, "err", err)
+		return false, nil
+	}
+	if result.Matched {
+		s.metrics.policyEvaluated(p.Name, PolicyMatched)
+	} else {
+		s.metrics.policyEvaluated(p.Name, PolicyNotMatched)
+	}
+	return result.Matched, nil
+}
+
+// overCapacityMove finds an active node whose usage exceeds a capacity it
+// declares, and the shard on it with the largest demand in that resource
+// that another node has headroom for
+func overCapacityMove(strategy string, nodes []*db.Node, shards []*db.Shard) *rebalanceMove {
+	for _, node := range nodes {
+		if node.Status != "active" {
+			continue
+		}
+		headroom := nodeHeadroom(node, shards, uuid.Nil)
+		var over []string
+		for name, left := range headroom {
+			if left < 0 {
+				over = append(over, name)
+			}
+		}
+		sort.Strings(over)
+
+		others := make([]*db.Node, 0, len(nodes))
+		for _, other := range nodes {
+			if other.ID != node.ID {
+				others = append(others, other)
+			}
+		}
+		for _, resource := range over {
+			candidates := movableShards(node.ID, shards)
+			sort.SliceStable(candidates, func(i, j int) bool {
+				return candidates[i].ResourceDemand[resource] > candidates[j].ResourceDemand[resource]
+			})
+			for _, shard := range candidates {
+				if shard.ResourceDemand[resource] <= 0 {
+					break
+				}
+				target, err := selectNode(strategy, others, shards, shard.ResourceDemand)
+				if err == nil {
+					return &rebalanceMove{shard: shard, from: node.ID, to: target.ID, reason: RebalanceOverCapacity}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hotspotMove moves the hottest shard it can off the active node with the
+// highest summed CPU share, to the least busy active node with headroom for
+// it. A move is only made if it leaves both nodes below the source's
+// current share, so shards never bounce back and forth.
+func hotspotMove(nodes []*db.Node, shards []*db.Shard, loads map[uuid.UUID]*db.ShardLoad) *rebalanceMove {
+	busy := make(map[uuid.UUID]float64)
+	var active []*db.Node
+	for _, node := range nodes {
+		if node.Status == "active" {
+			active = append(active, node)
+			busy[node.ID] = 0
+		}
+	}
+	if len(active) < 2 {
+		return nil
+	}
+	for _, shard := range shards {
+		if load, ok := loads[shard.ID]; ok && shard.NodeID != nil {
+			busy[*shard.NodeID] += load.CPUShare
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool { return busy[active[i].ID] > busy[active[j].ID] })
+	source := active[0]
+
+	candidates := movableShards(source.ID, shards)
+	share := func(shard *db.Shard) float64 {
+		if load, ok := loads[shard.ID]; ok {
+			return load.CPUShare
+		}
+		return 0
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return share(candidates[i]) > share(candidates[j]) })
+	for _, shard := range candidates {
+		for i := len(active) - 1; i > 0; i-- {
+			target := active[i]
+			if share(shard) <= 0 || busy[target.ID]+share(shard) >= busy[source.ID] {
+				continue
+			}
+			if nodeHeadroom(target, shards, uuid.Nil).Fits(shard.ResourceDemand) {
+				return &rebalanceMove{shard: shard, from: source.ID, to: target.ID, reason: RebalanceHotspot}
+			}
+		}
+	}
+	return nil
+}
+
+// movableShards lists the active shards on a node
+func movableShards(nodeID uuid.UUID, shards []*db.Shard) []*db.Shard {
+	var movable []*db.Shard
+	for _, shard := range shards {
+		if shard.NodeID != nil && *shard.NodeID == nodeID && shard.Status == "active" {
+			movable = append(movable, shard)
+		}
+	}
+	return movable
+}
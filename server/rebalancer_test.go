@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/server/testutil"
+)
+
+func TestRebalance(t *testing.T) {
+	ctx := context.Background()
+	// setup registers two pull-mode nodes with the given capacities, and on
+	// the first one a shard per load sample
+	setup := func(t *testing.T, capacity db.Resources, loads ...db.ShardLoad) (*Server, *testutil.MockDB, *db.Node, *db.Node, []*db.Shard) {
+		mockDB := testutil.NewMockDB()
+		server := NewServer(mockDB)
+		var nodes []*db.Node
+		for i := 0; i < 2; i++ {
+			node := &db.Node{ID: uuid.New(), Status: "active", AssignmentMode: AssignmentModePull, ResourceCapacity: capacity}
+			require.NoError(t, mockDB.RegisterNode(ctx, node))
+			nodes = append(nodes, node)
+		}
+		var shards []*db.Shard
+		var samples []*db.ShardLoad
+		for i := range loads {
+			shard := &db.Shard{ID: uuid.New(), NodeID: &nodes[0].ID, Status: "active"}
+			require.NoError(t, mockDB.RegisterShard(ctx, shard))
+			shards = append(shards, shard)
+			loads[i].ShardID, loads[i].NodeID = shard.ID, nodes[0].ID
+			samples = append(samples, &loads[i])
+		}
+		require.NoError(t, mockDB.RecordShardLoads(ctx, samples))
+		return server, mockDB.(*testutil.MockDB), nodes[0], nodes[1], shards
+	}
+	setPolicy := func(t *testing.T, mockDB *testutil.MockDB, threshold float64) {
+		params, err := json.Marshal(map[string]interface{}{
+			"name": "spread-cpu",
+			"type": "load_balancing",
+			"conditions": map[string]interface{}{
+				"all": []map[string]interface{}{{"metric": MetricCPUShare, "operator": "gt", "value": threshold}},
+			},
+			"actions": []map[string]interface{}{{"type": "rebalance"}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, mockDB.SetPolicy(ctx, &db.Policy{ID: uuid.New(), PolicyType: "load_balancing", Parameters: params}))
+	}
+	nodeOf := func(t *testing.T, mockDB *testutil.MockDB, shard *db.Shard) uuid.UUID {
+		got, err := mockDB.GetShardInfo(ctx, shard.ID)
+		require.NoError(t, err)
+		require.NotNil(t, got.NodeID)
+		return *got.NodeID
+	}
+
+	t.Run("OverCapacity", func(t *testing.T) {
+		server, mockDB, full, spare, shards := setup(t, db.Resources{"qps": 100}, db.ShardLoad{QPS: 40}, db.ShardLoad{QPS: 80})
+		move, err := server.rebalance(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, move)
+		assert.Equal(t, RebalanceOverCapacity, move.reason)
+		assert.Equal(t, shards[1].ID, move.shard.ID, "the shard using the most of the resource moves")
+		assert.Equal(t, spare.ID, nodeOf(t, mockDB, shards[1]))
+		assert.Equal(t, full.ID, nodeOf(t, mockDB, shards[0]))
+		events, err := mockDB.ListAuditEvents(ctx, db.AuditFilter{EntityID: shards[1].ID.String()})
+		require.NoError(t, err)
+		var methods []string
+		for _, event := range events {
+			assert.Equal(t, SystemActor, event.Actor)
+			methods = append(methods, event.Method)
+		}
+		assert.Contains(t, methods, AuditRebalance)
+
+		move, err = server.rebalance(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, move, "nothing is over capacity any more")
+	})
+
+	t.Run("HotspotNeedsPolicy", func(t *testing.T) {
+		server, mockDB, _, spare, shards := setup(t, nil, db.ShardLoad{CPUShare: 0.5}, db.ShardLoad{CPUShare: 0.3})
+		move, err := server.rebalance(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, move, "hot nodes are left alone without a load_balancing policy")
+
+		setPolicy(t, mockDB, 5)
+		move, err = server.rebalance(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, move, "the policy does not match")
+
+		setPolicy(t, mockDB, 0.5)
+		move, err = server.rebalance(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, move)
+		assert.Equal(t, RebalanceHotspot, move.reason)
+		assert.Equal(t, spare.ID, nodeOf(t, mockDB, shards[0]), "the hottest shard moves to the idle node")
+
+		move, err = server.rebalance(ctx)
+		require.NoError(t, err)
+		assert.Nil(t, move, "moving either shard again would not even out the nodes")
+	})
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Reconcile actions recorded for every correction
+const (
+	ReconcileActionAdd        = "add_shard"
+	ReconcileActionDrop       = "drop_shard"
+	ReconcileActionChangeRole = "change_role"
+)
+
+// reconcileActionRPCs maps each reconcile action to the app server RPC it issues
+var reconcileActionRPCs = map[string]string{
+	ReconcileActionAdd:        "AddShard",
+	ReconcileActionDrop:       "DropShard",
+	ReconcileActionChangeRole: "ChangeRole",
+}
+
+// runReconciler periodically reconciles every node until ctx is cancelled.
+// Followers skip reconciliation and leave it to the leader.
+func (s *Server) runReconciler(ctx context.Context) {
+	ticker := time.NewTicker(s.reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.isLeader() {
+				continue
+			}
+			// Each pass is correlated like an RPC
+			passCtx := contextWithRequestID(ctx, newRequestID())
+			if err := s.reconcile(passCtx); err != nil {
+				s.log().WarnContext(passCtx, "Reconciliation failed", "err", err)
+			}
+		}
+	}
+}
+
+// reconcile compares the inventory reported by each active node with the
+// shard map and issues the calls needed to converge
+func (s *Server) reconcile(ctx context.Context) error {
+	nodes, err := s.db.ListNodes(ctx)
+	if err != nil {
+		return err
+	}
+	shards, err := s.db.ListShards(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Nodes are reconciled side by side so a slow one cannot hold up the
+	// rest; every call to a node is bounded by the notification timeout
+	var wg sync.WaitGroup
+	for _, node := range nodes {
+		// Pull-mode nodes converge on their own from heartbeat responses
+		if node.Status != "active" || node.Location == "" || isPullNode(node) {
+			continue
+		}
+		wg.Add(1)
+		go func(node *db.Node) {
+			defer wg.Done()
+			conn, err := s.dialAppServer(node.Location)
+			if err != nil {
+				s.log().WarnContext(ctx, "Could not connect to appserver for reconciliation", "location", node.Location, "err", err)
+				return
+			}
+			defer conn.Close()
+			if err := s.reconcileNode(ctx, node, shards, shardmanagerpb.NewAppShardServiceClient(conn)); err != nil {
+				s.log().WarnContext(ctx, "Reconciliation of node failed", "node", node.ID, "err", err)
+			}
+		}(node)
+	}
+	wg.Wait()
+	return nil
+}
+
+// reconcileNode converges a single node towards the shards the shard map
+// assigns to it. Shards that are migrating are left alone.
+func (s *Server) reconcileNode(ctx context.Context, node *db.Node, shards []*db.Shard, client shardmanagerpb.AppShardServiceClient) error {
+	listCtx, cancel := context.WithTimeout(ctx, s.notificationTimeout)
+	resp, err := client.ListLocalShards(listCtx, &shardmanagerpb.ListLocalShardsRequest{})
+	cancel()
+	if status.Code(err) == codes.Unimplemented {
+		// App server predates inventory reporting; nothing to compare against
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	actual := make(map[uuid.UUID]string)
+	for _, replica := range resp.Shards {
+		shardID, err := uuid.Parse(replica.ShardId)
+		if err != nil {
+			s.log().WarnContext(ctx, "Node reported an invalid shard ID", "node", node.ID, "shard", replica.ShardId)
+			continue
+		}
+		actual[shardID] = replica.Role
+	}
+	s.replicas.set(node.ID, actual)
+
+	migrating := make(map[uuid.UUID]bool)
+	desired := make(map[uuid.UUID]bool)
+	for _, shard := range shards {
+		if shard.Status == "migrating" {
+			migrating[shard.ID] = true
+			continue
+		}
+		if shard.NodeID != nil && *shard.NodeID == node.ID {
+			desired[shard.ID] = true
+		}
+	}
+
+	now := s.now()
+	for shardID := range desired {
+		role, ok := actual[shardID]
+		switch {
+		case !ok:
+			// Never add a shard whose previous owner may still be serving it
+			granted, err := s.db.AcquireShardLease(ctx, shardID, node.ID, now, now.Add(s.leaseDuration))
+			if err != nil {
+				return err
+			}
+			if !granted {
+				continue
+			}
+			s.applyCorrection(ctx, node, shardID, ReconcileActionAdd, "primary", func(ctx context.Context) error {
+				return appServerResult(client.AddShard(ctx, &shardmanagerpb.AddShardRequest{
+					ShardId: shardID.String(),
+					Role:    "primary",
+				}))
+			})
+		case role != "primary":
+			s.applyCorrection(ctx, node, shardID, ReconcileActionChangeRole, "primary", func(ctx context.Context) error {
+				return appServerResult(client.ChangeRole(ctx, &shardmanagerpb.ChangeRoleRequest{
+					ShardId:     shardID.String(),
+					CurrentRole: role,
+					NewRole:     "primary",
+				}))
+			})
+		}
+	}
+
+	for shardID, role := range actual {
+		if desired[shardID] || migrating[shardID] {
+			continue
+		}
+		// Replicated shards keep the secondaries app servers place
+		if role == "secondary" && s.replicationFactor > 1 {
+			continue
+		}
+		s.applyCorrection(ctx, node, shardID, ReconcileActionDrop, role, func(ctx context.Context) error {
+			return appServerResult(client.DropShard(ctx, &shardmanagerpb.DropShardRequest{
+				ShardId: shardID.String(),
+			}))
+		})
+	}
+	return nil
+}
+
+// appServerReply is a reply from the app server that reports success
+type appServerReply interface {
+	GetSuccess() bool
+	GetMessage() string
+}
+
+// appServerResult turns a reply in which the app server refused a call into
+// an error
+func appServerResult(resp appServerReply, err error) error {
+	if err != nil {
+		return err
+	}
+	if !resp.GetSuccess() {
+		return fmt.Errorf("app server refused: %s", resp.GetMessage())
+	}
+	return nil
+}
+
+// applyCorrection issues a single corrective call and records its outcome,
+// also in the audit log
+func (s *Server) applyCorrection(ctx context.Context, node *db.Node, shardID uuid.UUID, action, role string, call func(ctx context.Context) error) {
+	callCtx, cancel := context.WithTimeout(ctx, s.notificationTimeout)
+	err := call(callCtx)
+	cancel()
+
+	record := &db.ReconcileAction{
+		NodeID:  node.ID,
+		ShardID: shardID,
+		Action:  action,
+		Role:    role,
+	}
+	if err != nil {
+		record.Error = err.Error()
+		s.metrics.notificationFailed(reconcileActionRPCs[action])
+		s.log().WarnContext(ctx, "Reconcile action failed", "action", action, "shard", shardID, "node", node.ID, "err", err)
+	} else {
+		s.log().InfoContext(ctx, "Reconcile action applied", "action", action, "shard", shardID, "node", node.ID)
+		if action == ReconcileActionDrop {
+			s.replicas.remove(node.ID, shardID)
+		} else {
+			s.replicas.add(node.ID, shardID, role)
+		}
+	}
+	if err := s.db.RecordReconcileAction(ctx, record); err != nil {
+		s.log().WarnContext(ctx, "Could not record reconcile action", "err", err)
+	}
+	s.auditSystem(ctx, AuditReconcile, "shard", shardID.String(), map[string]string{
+		"action":  action,
+		"node_id": node.ID.String(),
+		"role":    role,
+	}, err)
+}
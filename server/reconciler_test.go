@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/server/testutil"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+// fakeAppClient records the corrective calls made by the reconciler
+type fakeAppClient struct {
+	shardmanagerpb.AppShardServiceClient
+	local   []*shardmanagerpb.ShardReplica
+	added   []string
+	dropped []string
+	changed []string
+	refuse  bool // answer AddShard without success
+}
+
+func (f *fakeAppClient) ListLocalShards(ctx context.Context, in *shardmanagerpb.ListLocalShardsRequest, opts ...grpc.CallOption) (*shardmanagerpb.ListLocalShardsResponse, error) {
+	return &shardmanagerpb.ListLocalShardsResponse{Shards: f.local}, nil
+}
+
+func (f *fakeAppClient) AddShard(ctx context.Context, in *shardmanagerpb.AddShardRequest, opts ...grpc.CallOption) (*shardmanagerpb.AddShardResponse, error) {
+	f.added = append(f.added, in.ShardId)
+	if f.refuse {
+		return &shardmanagerpb.AddShardResponse{Message: "shard store is full"}, nil
+	}
+	return &shardmanagerpb.AddShardResponse{Success: true}, nil
+}
+
+func (f *fakeAppClient) DropShard(ctx context.Context, in *shardmanagerpb.DropShardRequest, opts ...grpc.CallOption) (*shardmanagerpb.DropShardResponse, error) {
+	f.dropped = append(f.dropped, in.ShardId)
+	return &shardmanagerpb.DropShardResponse{Success: true}, nil
+}
+
+func (f *fakeAppClient) ChangeRole(ctx context.Context, in *shardmanagerpb.ChangeRoleRequest, opts ...grpc.CallOption) (*shardmanagerpb.ChangeRoleResponse, error) {
+	f.changed = append(f.changed, in.ShardId)
+	return &shardmanagerpb.ChangeRoleResponse{Success: true}, nil
+}
+
+func TestReconcileNode(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+
+	node := &db.Node{ID: uuid.New(), Location: "localhost:5000", Capacity: 10, Status: "active"}
+	require.NoError(t, mockDB.RegisterNode(ctx, node))
+	otherNodeID := uuid.New()
+
+	missing := &db.Shard{ID: uuid.New(), NodeID: &node.ID, Status: "active"}
+	secondary := &db.Shard{ID: uuid.New(), NodeID: &node.ID, Status: "active"}
+	inSync := &db.Shard{ID: uuid.New(), NodeID: &node.ID, Status: "active"}
+	moved := &db.Shard{ID: uuid.New(), NodeID: &otherNodeID, Status: "active"}
+	migrating := &db.Shard{ID: uuid.New(), NodeID: &otherNodeID, Status: "migrating"}
+	for _, shard := range []*db.Shard{missing, secondary, inSync, moved, migrating} {
+		require.NoError(t, mockDB.RegisterShard(ctx, shard))
+	}
+	shards, err := mockDB.ListShards(ctx)
+	require.NoError(t, err)
+
+	client := &fakeAppClient{
+		local: []*shardmanagerpb.ShardReplica{
+			{ShardId: secondary.ID.String(), Role: "secondary"},
+			{ShardId: inSync.ID.String(), Role: "primary"},
+			{ShardId: moved.ID.String(), Role: "primary"},
+			{ShardId: migrating.ID.String(), Role: "primary"},
+		},
+	}
+	require.NoError(t, server.reconcileNode(ctx, node, shards, client))
+
+	assert.Equal(t, []string{missing.ID.String()}, client.added)
+	assert.Equal(t, []string{secondary.ID.String()}, client.changed)
+	assert.Equal(t, []string{moved.ID.String()}, client.dropped)
+
+	actions, err := mockDB.ListReconcileActions(ctx, node.ID)
+	require.NoError(t, err)
+	require.Len(t, actions, 3)
+	recorded := make(map[uuid.UUID]string)
+	for _, action := range actions {
+		assert.Empty(t, action.Error)
+		recorded[action.ShardID] = action.Action
+	}
+	assert.Equal(t, ReconcileActionAdd, recorded[missing.ID])
+	assert.Equal(t, ReconcileActionChangeRole, recorded[secondary.ID])
+	assert.Equal(t, ReconcileActionDrop, recorded[moved.ID])
+
+	// The inventory follows the corrections
+	holders := server.replicas.holders()
+	assert.Equal(t, []uuid.UUID{node.ID}, holders[missing.ID])
+	assert.Equal(t, []uuid.UUID{node.ID}, holders[migrating.ID])
+	assert.Empty(t, holders[moved.ID])
+}
+
+func TestReconcileKeepsSecondariesWhenReplicated(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	opts := DefaultOptions()
+	opts.ReplicationFactor = 2
+	server := NewServerWithOptions(mockDB, opts)
+
+	node := &db.Node{ID: uuid.New(), Location: "localhost:5000", Capacity: 10, Status: "active"}
+	require.NoError(t, mockDB.RegisterNode(ctx, node))
+	otherNodeID := uuid.New()
+	replicated := &db.Shard{ID: uuid.New(), NodeID: &otherNodeID, Status: "active"}
+	moved := &db.Shard{ID: uuid.New(), NodeID: &otherNodeID, Status: "active"}
+	require.NoError(t, mockDB.RegisterShard(ctx, replicated))
+	require.NoError(t, mockDB.RegisterShard(ctx, moved))
+	shards, err := mockDB.ListShards(ctx)
+	require.NoError(t, err)
+
+	client := &fakeAppClient{
+		local: []*shardmanagerpb.ShardReplica{
+			{ShardId: replicated.ID.String(), Role: "secondary"},
+			{ShardId: moved.ID.String(), Role: "primary"},
+		},
+	}
+	require.NoError(t, server.reconcileNode(ctx, node, shards, client))
+	assert.Equal(t, []string{moved.ID.String()}, client.dropped, "only primaries of other nodes are dropped")
+}
+
+func TestReconcileRecordsRefusals(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+
+	node := &db.Node{ID: uuid.New(), Location: "localhost:5000", Capacity: 10, Status: "active"}
+	require.NoError(t, mockDB.RegisterNode(ctx, node))
+	shard := &db.Shard{ID: uuid.New(), NodeID: &node.ID, Status: "active"}
+	require.NoError(t, mockDB.RegisterShard(ctx, shard))
+	shards, err := mockDB.ListShards(ctx)
+	require.NoError(t, err)
+
+	client := &fakeAppClient{refuse: true}
+	require.NoError(t, server.reconcileNode(ctx, node, shards, client))
+	assert.Equal(t, []string{shard.ID.String()}, client.added)
+
+	actions, err := mockDB.ListReconcileActions(ctx, node.ID)
+	require.NoError(t, err)
+	require.Len(t, actions, 1)
+	assert.Equal(t, "app server refused: shard store is full", actions[0].Error)
+}
+
+// barrierAppServer holds ListLocalShards until every node it stands in for
+// has been asked, so it only answers when nodes are reconciled side by side
+type barrierAppServer struct {
+	shardmanagerpb.UnimplementedAppShardServiceServer
+	arrived *sync.WaitGroup
+	all     chan struct{}
+}
+
+func (b *barrierAppServer) ListLocalShards(ctx context.Context, req *shardmanagerpb.ListLocalShardsRequest) (*shardmanagerpb.ListLocalShardsResponse, error) {
+	b.arrived.Done()
+	select {
+	case <-b.all:
+		return &shardmanagerpb.ListLocalShardsResponse{}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestReconcileNodesConcurrently(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+	server.notificationTimeout = 5 * time.Second
+
+	const nodes = 3
+	var arrived sync.WaitGroup
+	arrived.Add(nodes)
+	all := make(chan struct{})
+	go func() {
+		arrived.Wait()
+		close(all)
+	}()
+	for i := 0; i < nodes; i++ {
+		grpcServer := grpc.NewServer()
+		shardmanagerpb.RegisterAppShardServiceServer(grpcServer, &barrierAppServer{arrived: &arrived, all: all})
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		go grpcServer.Serve(lis)
+		defer grpcServer.Stop()
+		require.NoError(t, mockDB.RegisterNode(ctx, &db.Node{ID: uuid.New(), Location: lis.Addr().String(), Status: "active"}))
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- server.reconcile(ctx) }()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("nodes were reconciled one after another")
+	}
+}
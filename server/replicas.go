@@ -0,0 +1,69 @@
+package server
+
+import (
+	"maps"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+// replicaInventory remembers the shards each node last reported serving,
+// from reconciler passes and pull-mode heartbeats. It only lives on the
+// instance that saw the reports, normally the leader.
+type replicaInventory struct {
+	mu     sync.Mutex
+	byNode map[uuid.UUID]map[uuid.UUID]string // shard ID to role
+}
+
+// set replaces the shards reported by a node
+func (inv *replicaInventory) set(nodeID uuid.UUID, shards map[uuid.UUID]string) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if inv.byNode == nil {
+		inv.byNode = make(map[uuid.UUID]map[uuid.UUID]string)
+	}
+	inv.byNode[nodeID] = maps.Clone(shards)
+}
+
+// add records a shard a node was told to serve, if the node has reported
+// its shards before
+func (inv *replicaInventory) add(nodeID, shardID uuid.UUID, role string) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	if shards, ok := inv.byNode[nodeID]; ok {
+		shards[shardID] = role
+	}
+}
+
+// remove records that a node dropped a shard
+func (inv *replicaInventory) remove(nodeID, shardID uuid.UUID) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	delete(inv.byNode[nodeID], shardID)
+}
+
+// holders maps each reported shard to the nodes that reported it
+func (inv *replicaInventory) holders() map[uuid.UUID][]uuid.UUID {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	holders := make(map[uuid.UUID][]uuid.UUID)
+	for nodeID, shards := range inv.byNode {
+		for shardID := range shards {
+			holders[shardID] = append(holders[shardID], nodeID)
+		}
+	}
+	return holders
+}
+
+// reportedShards indexes the replicas a node reports by shard, skipping
+// IDs that are not UUIDs
+func reportedShards(replicas []*shardmanagerpb.ShardReplica) map[uuid.UUID]string {
+	shards := make(map[uuid.UUID]string, len(replicas))
+	for _, replica := range replicas {
+		if shardID, err := uuid.Parse(replica.ShardId); err == nil {
+			shards[shardID] = replica.Role
+		}
+	}
+	return shards
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// requestIDHeader is the metadata key, and HTTP header, carrying the ID
+// that correlates an RPC with the logs and app server calls it causes
+const requestIDHeader = "x-request-id"
+
+// maxRequestIDLen bounds the IDs accepted from callers
+const maxRequestIDLen = 128
+
+type requestIDKey struct{}
+
+// contextWithRequestID returns ctx carrying the request ID id
+func contextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// requestIDFromContext returns the request ID carried by ctx, if any
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID returns a fresh request ID
+func newRequestID() string {
+	return uuid.NewString()
+}
+
+// validRequestID reports whether a caller's request ID may be reused: not
+// too long, and printable ASCII so it cannot forge log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDInterceptor gives each RPC a request ID, reusing the caller's
+// x-request-id if it sent a valid one, and returns it in the response
+// header. It runs first so every later interceptor logs with the ID.
+func (s *Server) requestIDInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(requestIDHeader); len(ids) > 0 && validRequestID(ids[0]) {
+			id = ids[0]
+		}
+	}
+	if id == "" {
+		id = newRequestID()
+	}
+	// Fails harmlessly for gateway calls, which have no gRPC stream
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDHeader, id))
+	return handler(contextWithRequestID(ctx, id), req)
+}
+
+// propagateRequestID forwards the request ID carried by ctx to app
+// servers as x-request-id metadata
+func propagateRequestID(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if id := requestIDFromContext(ctx); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, requestIDHeader, id)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
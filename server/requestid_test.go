@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/server/testutil"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+func TestRequestIDInterceptor(t *testing.T) {
+	server := NewServer(testutil.NewMockDB())
+	info := &grpc.UnaryServerInfo{FullMethod: shardmanagerpb.NodeService_ListNodes_FullMethodName}
+	requestID := func(ctx context.Context) string {
+		var got string
+		_, err := server.requestIDInterceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			got = requestIDFromContext(ctx)
+			return nil, nil
+		})
+		require.NoError(t, err)
+		return got
+	}
+
+	incoming := func(id string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(requestIDHeader, id))
+	}
+	assert.Equal(t, "caller-1", requestID(incoming("caller-1")), "the caller's ID is reused")
+	for _, ctx := range []context.Context{
+		context.Background(),
+		incoming("forged\nline"),
+		incoming(strings.Repeat("x", maxRequestIDLen+1)),
+	} {
+		_, err := uuid.Parse(requestID(ctx))
+		assert.NoError(t, err, "a fresh ID replaces a missing or invalid one")
+	}
+}
+
+// requestIDRecorder is an app server that remembers the request IDs it
+// was called with
+type requestIDRecorder struct {
+	shardmanagerpb.UnimplementedAppShardServiceServer
+	mu  sync.Mutex
+	ids []string
+}
+
+func (r *requestIDRecorder) AddShard(ctx context.Context, req *shardmanagerpb.AddShardRequest) (*shardmanagerpb.AddShardResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	r.mu.Lock()
+	r.ids = append(r.ids, md.Get(requestIDHeader)...)
+	r.mu.Unlock()
+	return &shardmanagerpb.AddShardResponse{Success: true}, nil
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	app := &requestIDRecorder{}
+	grpcServer := grpc.NewServer()
+	shardmanagerpb.RegisterAppShardServiceServer(grpcServer, app)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(lis)
+	defer grpcServer.Stop()
+
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	node := &db.Node{ID: uuid.New(), Location: lis.Addr().String(), Status: "active"}
+	require.NoError(t, mockDB.RegisterNode(ctx, node))
+	server := NewServer(mockDB)
+
+	assert.True(t, server.notifyAppServerAddShard(contextWithRequestID(ctx, "req-1"), node.ID, uuid.New(), "primary"))
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	assert.Equal(t, []string{"req-1"}, app.ids)
+}
+
+func TestGatewayRequestID(t *testing.T) {
+	server := NewServer(testutil.NewMockDB())
+	gw := httptest.NewServer(server.gatewayHandler(chainUnaryInterceptors(server.requestIDInterceptor)))
+	defer gw.Close()
+
+	req, err := http.NewRequest("GET", gw.URL+"/v1/nodes", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Request-Id", "caller-1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "caller-1", resp.Header.Get("X-Request-Id"))
+
+	resp, err = http.Get(gw.URL + "/v1/nodes")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.NotEmpty(t, resp.Header.Get("X-Request-Id"), "an ID is made up when none is sent")
+}
@@ -1,68 +1,241 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
-	"strings"
+	"net/http"
 
 	"github.com/seaweedfs/shardmanager/db"
-	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+	"github.com/seaweedfs/shardmanager/db/memdb"
 	"google.golang.org/grpc"
 )
 
-// StartShardManagerServer starts the real shardmanager gRPC server.
-// It blocks until stopCh is closed.
-func StartShardManagerServer(dbConnStr string, grpcAddr string, stopCh <-chan struct{}) error {
-	log.Printf("[DEBUG] StartShardManagerServer called with dbConnStr: %s", dbConnStr)
-	// Detect driver from dbConnStr
-	var database *db.DB
-	var err error
-	if strings.HasPrefix(dbConnStr, "file:") {
-		log.Printf("[DEBUG] Using sqlite3 driver for dbConnStr: %s", dbConnStr)
-		database, err = db.NewDBWithDriver("sqlite3", dbConnStr)
-		if err == nil {
-			err = db.InitSQLiteSchema(database)
-			if err != nil {
-				log.Printf("[ERROR] Failed to initialize SQLite schema: %v", err)
-				return fmt.Errorf("failed to initialize SQLite schema: %w", err)
-			}
-		}
-	} else {
-		log.Printf("[DEBUG] Using postgres driver for dbConnStr: %s", dbConnStr)
-		database, err = db.NewDB(dbConnStr)
+// StartShardManagerServer starts the real shardmanager gRPC server on
+// opts.GRPCAddr, a Prometheus /metrics HTTP listener if opts.MetricsAddr is
+// not empty, and the HTTP/JSON gateway and status dashboard if
+// opts.GatewayAddr and opts.DashboardAddr are not empty. Callers are
+// authenticated and authorized against opts.AuthConfig if it is set. With
+// opts.TLS enabled the listener serves TLS and app servers are called over
+// mutual TLS. With opts.LeaderElection several replicas may share the
+// database; followers serve reads and reject writes with the leader's
+// address. Nodes and shards are read from an in-memory shard map loaded at
+// startup. It blocks until stopCh is closed. Programs embedding the manager
+// use New instead.
+func StartShardManagerServer(opts Options, stopCh <-chan struct{}) error {
+	if err := opts.Validate(); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
 	}
+	srv, err := New(WithOptions(opts))
 	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return err
 	}
-	defer database.Close()
-
-	// Create gRPC server
-	lis, err := net.Listen("tcp", grpcAddr)
+	lis, err := net.Listen("tcp", opts.GRPCAddr)
 	if err != nil {
+		srv.Shutdown(context.Background())
 		return fmt.Errorf("failed to listen: %w", err)
 	}
 
-	s := grpc.NewServer()
-	srv := NewServer(database)
-	shardmanagerpb.RegisterNodeServiceServer(s, srv)
-	shardmanagerpb.RegisterShardServiceServer(s, srv)
-	shardmanagerpb.RegisterPolicyServiceServer(s, srv)
-	shardmanagerpb.RegisterMonitoringServiceServer(s, srv)
-	shardmanagerpb.RegisterFailureServiceServer(s, srv)
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(lis) }()
+	select {
+	case <-stopCh:
+	case err := <-served:
+		srv.Shutdown(context.Background())
+		return err
+	}
+	return srv.Shutdown(context.Background())
+}
+
+var errServerShutDown = errors.New("server is shut down")
+
+// Serve serves gRPC on lis until Shutdown, when it returns nil. The first
+// call loads the shard map and starts the background loops and the HTTP
+// listeners named in the options. A server must be created by New to Serve.
+func (s *Server) Serve(lis net.Listener) error {
+	if s.grpcServer == nil {
+		lis.Close()
+		return errors.New("server was not created by New")
+	}
+	s.startOnce.Do(func() { s.startErr = s.start() })
+	if s.closed.Load() {
+		lis.Close()
+		return errServerShutDown
+	}
+	if s.startErr != nil {
+		lis.Close()
+		return s.startErr
+	}
+	s.log().Info("ShardManager server listening", "addr", lis.Addr().String())
+	if err := s.grpcServer.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
+}
+
+// start loads the shard map and starts everything that runs alongside the
+// gRPC listeners
+func (s *Server) start() error {
+	// With leader election the loops only act on the leader
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stop = cancel
+	if err := s.startShardMap(ctx); err != nil {
+		return fmt.Errorf("failed to load shard map: %w", err)
+	}
+	if s.election != nil {
+		s.goLoop(ctx, LoopLeaderElection, s.runLeaderElection)
+	}
+	s.goLoop(ctx, LoopReconciler, s.runReconciler)
+	s.goLoop(ctx, LoopRebalancer, s.runRebalancer)
+	s.goLoop(ctx, LoopChangeFeed, s.runChangeFeed)
+	s.goLoop(ctx, LoopShardMapChecker, s.runShardMapChecker)
+
+	if s.opts.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", s.metricsHandler())
+		s.startHTTPServer("Metrics", s.opts.MetricsAddr, mux, false)
+	}
+	if s.opts.GatewayAddr != "" {
+		s.startHTTPServer("HTTP gateway", s.opts.GatewayAddr, s.gatewayHandler(chainUnaryInterceptors(s.interceptors...)), true)
+	}
+	if s.opts.DashboardAddr != "" {
+		s.startHTTPServer("Dashboard", s.opts.DashboardAddr, s.dashboardHandler(chainUnaryInterceptors(s.interceptors...)), true)
+	}
+	return nil
+}
 
-	// Start serving in a goroutine
+// goLoop runs loop in the background until ctx is cancelled, unless it was
+// turned off with WithoutLoops
+func (s *Server) goLoop(ctx context.Context, name Loop, loop func(context.Context)) {
+	if s.disabled[name] {
+		return
+	}
+	s.loops.Add(1)
 	go func() {
-		if err := s.Serve(lis); err != nil {
-			log.Fatalf("Failed to serve: %v", err)
+		defer s.loops.Done()
+		loop(ctx)
+	}()
+}
+
+// Shutdown stops the HTTP listeners, lets in-flight RPCs finish, stops the
+// background loops, handing over leadership, waits for shard handoffs and
+// closes the database New opened. If ctx ends first the remaining RPCs and
+// handoffs are cut off and ctx's error is returned. Later calls return the
+// first call's result.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shutdownOnce.Do(func() { s.shutdownErr = s.shutdown(ctx) })
+	return s.shutdownErr
+}
+
+func (s *Server) shutdown(ctx context.Context) error {
+	// Never start after shutting down
+	s.closed.Store(true)
+	s.startOnce.Do(func() { s.startErr = errServerShutDown })
+	s.log().Info("Shutting down ShardManager server")
+	for _, hs := range s.httpServers {
+		hs.Shutdown(ctx)
+	}
+
+	var err error
+	if s.grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			s.grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			s.grpcServer.Stop()
+			<-stopped
+			err = ctx.Err()
 		}
+	}
+
+	// Hand over leadership before the database goes away
+	if s.stop != nil {
+		s.stop()
+	}
+	s.loops.Wait()
+
+	// Let handoffs finish with the database, unless ctx ends first
+	handedOff := make(chan struct{})
+	go func() {
+		s.handoffs.Wait()
+		close(handedOff)
 	}()
-	log.Printf("ShardManager server listening on %s", grpcAddr)
+	select {
+	case <-handedOff:
+	case <-ctx.Done():
+		s.stopHandoffs()
+		<-handedOff
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	if s.closeDB != nil {
+		if cerr := s.closeDB.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	s.log().Info("ShardManager server stopped")
+	return err
+}
 
-	// Wait for stop signal
-	<-stopCh
-	log.Println("Shutting down ShardManager server...")
-	s.GracefulStop()
-	log.Println("ShardManager server stopped")
-	return nil
+// database is a backend the server can run against
+type database interface {
+	db.DBOperations
+	Close() error
+}
+
+// openDatabase connects to dsn and brings its schema up to date, logging to
+// logger. pool is ignored by the in-memory backend.
+func openDatabase(dsn string, pool db.PoolOptions, logger *slog.Logger) (database, error) {
+	logger.Debug("Opening database", "dsn", db.RedactDSN(dsn))
+	if memdb.IsDSN(dsn) {
+		store, err := memdb.OpenDSN(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open in-memory database: %w", err)
+		}
+		store.SetLogger(logger)
+		return store, nil
+	}
+	store, err := db.OpenWithOptions(dsn, pool)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	store.SetLogger(logger)
+	if err := store.Migrate(context.Background()); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to migrate database schema: %w", err)
+	}
+	return store, nil
+}
+
+// startHTTPServer serves handler on addr in the background, over TLS when
+// withTLS is set and certificates are configured
+func (s *Server) startHTTPServer(name, addr string, handler http.Handler, withTLS bool) {
+	hs := &http.Server{Addr: addr, Handler: handler}
+	certs := s.tls
+	if !withTLS {
+		certs = nil
+	}
+	if certs != nil {
+		hs.TLSConfig = certs.serverTLSConfig()
+	}
+	go func() {
+		var err error
+		if certs != nil {
+			err = hs.ListenAndServeTLS("", "")
+		} else {
+			err = hs.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			s.log().Error("Listener failed", "listener", name, "err", err)
+		}
+	}()
+	s.log().Info("Listening", "listener", name, "addr", addr)
+	s.httpServers = append(s.httpServers, hs)
 }
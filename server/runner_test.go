@@ -0,0 +1,124 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/seaweedfs/shardmanager/server/testutil"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+func TestServeAndShutdown(t *testing.T) {
+	var logs bytes.Buffer
+	now := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	mockDB := testutil.NewMockDB()
+	srv, err := New(
+		WithDatabase(mockDB),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))),
+		WithClock(func() time.Time { return now }),
+		WithoutLoops(LoopReconciler, LoopChangeFeed, LoopShardMapChecker),
+	)
+	require.NoError(t, err)
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(lis) }()
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	ctx := context.Background()
+	_, err = shardmanagerpb.NewNodeServiceClient(conn).RegisterNode(ctx, &shardmanagerpb.RegisterNodeRequest{
+		Node: &shardmanagerpb.Node{Location: "localhost:1", Capacity: 10},
+	})
+	require.NoError(t, err)
+	health, err := shardmanagerpb.NewMonitoringServiceClient(conn).GetHealth(ctx, &shardmanagerpb.GetHealthRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, now.UnixMilli(), health.CheckedAtUnixMs, "the injected clock is used")
+	assert.EqualValues(t, 1, health.TotalNodes)
+
+	require.NoError(t, srv.Shutdown(ctx))
+	require.NoError(t, <-served)
+	assert.NoError(t, srv.Shutdown(ctx), "shutting down twice is harmless")
+	assert.Contains(t, logs.String(), "addr="+lis.Addr().String())
+	assert.Contains(t, logs.String(), "No auth config given")
+
+	// The caller still owns the database
+	nodes, err := mockDB.ListNodes(ctx)
+	require.NoError(t, err)
+	assert.Len(t, nodes, 1)
+
+	lis, err = net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	assert.EqualError(t, srv.Serve(lis), "server is shut down")
+}
+
+func TestShutdownWaitsForHandoffs(t *testing.T) {
+	newServer := func() *Server {
+		srv, err := New(
+			WithDatabase(testutil.NewMockDB()),
+			WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		)
+		require.NoError(t, err)
+		return srv
+	}
+
+	t.Run("Finished", func(t *testing.T) {
+		srv := newServer()
+		var done atomic.Bool
+		srv.goHandoff(context.Background(), func(ctx context.Context) {
+			time.Sleep(20 * time.Millisecond)
+			done.Store(true)
+		})
+		require.NoError(t, srv.Shutdown(context.Background()))
+		assert.True(t, done.Load(), "the handoff finished before Shutdown returned")
+	})
+
+	t.Run("CutOff", func(t *testing.T) {
+		srv := newServer()
+		var cancelled atomic.Bool
+		srv.goHandoff(context.Background(), func(ctx context.Context) {
+			<-ctx.Done()
+			cancelled.Store(true)
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, srv.Shutdown(ctx), context.DeadlineExceeded)
+		assert.True(t, cancelled.Load(), "the handoff was cancelled before Shutdown returned")
+	})
+}
+
+func TestNew(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Database = ""
+	_, err := New(WithOptions(opts))
+	assert.EqualError(t, err, "invalid options: database is required")
+
+	// grpc_addr is not needed when Serve is given the listener
+	opts.GRPCAddr = ""
+	_, err = New(WithOptions(opts), WithDatabase(testutil.NewMockDB()))
+	assert.NoError(t, err)
+
+	// A database New opened is closed by Shutdown
+	opts.Database = "mem:"
+	srv, err := New(WithOptions(opts), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	require.NoError(t, err)
+	require.NotNil(t, srv.closeDB)
+	assert.NoError(t, srv.Shutdown(context.Background()))
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	assert.EqualError(t, NewServer(testutil.NewMockDB()).Serve(lis), "server was not created by New")
+}
@@ -1,8 +1,20 @@
 package server
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/seaweedfs/shardmanager/db"
 	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 type Server struct {
@@ -11,10 +23,235 @@ type Server struct {
 	shardmanagerpb.UnimplementedPolicyServiceServer
 	shardmanagerpb.UnimplementedMonitoringServiceServer
 	shardmanagerpb.UnimplementedFailureServiceServer
+	shardmanagerpb.UnimplementedAuditServiceServer
+
+	db       db.DBOperations
+	metrics  *serverMetrics
+	tls      *certReloader    // nil when app servers are called in plaintext
+	election *leaderElection  // nil unless replicas elect a leader
+	changes  *changeHub       // changes made by every instance
+	shardMap *shardMap        // nil until startShardMap puts it in front of db
+	replicas replicaInventory // shards each node last reported serving
+	outbox   outbox           // notifications shard handoffs have yet to deliver
+	logger   *slog.Logger     // nil logs to slog.Default
+	clock    func() time.Time
+
+	reconcileInterval   time.Duration
+	rebalanceInterval   time.Duration
+	leaseDuration       time.Duration
+	heartbeatTimeout    time.Duration
+	notificationTimeout time.Duration
+	migrationStuckAfter time.Duration
+	placementStrategy   string
+	replicationFactor   int
 
-	db db.DBOperations
+	shardMapCheckInterval time.Duration
+
+	// Set by New for Serve and Shutdown
+	opts         Options
+	disabled     map[Loop]bool
+	closeDB      io.Closer // the database New opened, if it did
+	grpcServer   *grpc.Server
+	interceptors []grpc.UnaryServerInterceptor
+	startOnce    sync.Once
+	startErr     error
+	shutdownOnce sync.Once
+	shutdownErr  error
+	closed       atomic.Bool
+	stop         func()          // cancels the background loops
+	loops        sync.WaitGroup  // background loops still running
+	handoffs     sync.WaitGroup  // shard handoffs still running
+	handoffCtx   context.Context // parent of the shard handoffs
+	stopHandoffs func()          // cancels the shard handoffs
+	httpServers  []*http.Server
 }
 
+// NewServer creates a server with the default options
 func NewServer(db db.DBOperations) *Server {
-	return &Server{db: db}
+	return NewServerWithOptions(db, DefaultOptions())
+}
+
+// NewServerWithOptions creates a server tuned by opts that serves nothing
+// by itself; New creates one that can Serve. Listener, database, auth and
+// TLS settings in opts are ignored.
+func NewServerWithOptions(db db.DBOperations, opts Options) *Server {
+	s := &Server{
+		db:                  db,
+		metrics:             newServerMetrics(),
+		changes:             newChangeHub(),
+		reconcileInterval:   opts.ReconcileInterval,
+		rebalanceInterval:   opts.RebalanceInterval,
+		leaseDuration:       opts.LeaseDuration,
+		heartbeatTimeout:    opts.HeartbeatTimeout,
+		notificationTimeout: opts.NotificationTimeout,
+		migrationStuckAfter: opts.MigrationStuckAfter,
+		placementStrategy:   opts.PlacementStrategy,
+		replicationFactor:   opts.ReplicationFactor,
+
+		shardMapCheckInterval: opts.ShardMapCheckInterval,
+	}
+	s.handoffCtx, s.stopHandoffs = context.WithCancel(context.Background())
+	if opts.LeaderElection {
+		s.election = newLeaderElection(opts.AdvertiseAddr, opts.LeaderLeaseDuration)
+	}
+	return s
+}
+
+// Loop names a background loop that WithoutLoops can turn off
+type Loop string
+
+const (
+	LoopLeaderElection  Loop = "leader_election"
+	LoopReconciler      Loop = "reconciler"
+	LoopRebalancer      Loop = "rebalancer"
+	LoopChangeFeed      Loop = "change_feed"
+	LoopShardMapChecker Loop = "shard_map_checker"
+)
+
+// Option configures a server created by New
+type Option func(*settings)
+
+// settings collects the options given to New
+type settings struct {
+	opts     Options
+	db       db.DBOperations
+	logger   *slog.Logger
+	clock    func() time.Time
+	disabled map[Loop]bool
+}
+
+// WithOptions tunes the server with opts instead of DefaultOptions.
+// GRPCAddr is ignored, since Serve is given its listener.
+func WithOptions(opts Options) Option {
+	return func(s *settings) { s.opts = opts }
+}
+
+// WithDatabase runs the server against ops instead of opening
+// Options.Database. The caller keeps ownership of ops and closes it after
+// Shutdown.
+func WithDatabase(ops db.DBOperations) Option {
+	return func(s *settings) { s.db = ops }
+}
+
+// WithLogger sends the server's logs, and those of the database New opens,
+// to logger instead of one built from Options.LogLevel and LogFormat
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *settings) { s.logger = logger }
+}
+
+// WithClock makes the server read the time from clock, for leases, leader
+// election and health checks. Loop intervals still follow the wall clock.
+func WithClock(clock func() time.Time) Option {
+	return func(s *settings) { s.clock = clock }
+}
+
+// WithoutLoops keeps the named background loops from running. Without the
+// leader election loop a server configured for leader election never leads.
+func WithoutLoops(loops ...Loop) Option {
+	return func(s *settings) {
+		for _, loop := range loops {
+			s.disabled[loop] = true
+		}
+	}
+}
+
+// New creates a server ready to Serve. It opens Options.Database unless
+// WithDatabase is given, and loads the auth config and TLS certificates
+// named in the options. Without WithLogger it logs to stderr.
+func New(options ...Option) (*Server, error) {
+	set := settings{opts: DefaultOptions(), disabled: make(map[Loop]bool)}
+	for _, option := range options {
+		option(&set)
+	}
+	opts := set.opts
+	if err := opts.validate(false, set.db == nil); err != nil {
+		return nil, fmt.Errorf("invalid options: %w", err)
+	}
+
+	logger := set.logger
+	if logger == nil {
+		var err error
+		if logger, err = NewLogger(os.Stderr, opts.LogLevel, opts.LogFormat); err != nil {
+			return nil, err
+		}
+	}
+	logger = withRequestIDs(logger)
+
+	var authConfig *AuthConfig
+	if opts.AuthConfig != "" {
+		var err error
+		if authConfig, err = LoadAuthConfig(opts.AuthConfig); err != nil {
+			return nil, err
+		}
+	}
+	var certs *certReloader
+	if opts.TLS.Enabled() {
+		var err error
+		if certs, err = newCertReloader(opts.TLS); err != nil {
+			return nil, err
+		}
+	}
+
+	ops := set.db
+	var closeDB io.Closer
+	if ops == nil {
+		database, err := openDatabase(opts.Database, opts.DatabasePool, logger)
+		if err != nil {
+			return nil, err
+		}
+		ops, closeDB = database, database
+	}
+
+	s := NewServerWithOptions(ops, opts)
+	s.opts = opts
+	s.logger = logger
+	s.clock = set.clock
+	s.disabled = set.disabled
+	s.closeDB = closeDB
+	s.tls = certs
+	if certs != nil {
+		certs.logger = s.log()
+	}
+
+	s.interceptors = []grpc.UnaryServerInterceptor{s.requestIDInterceptor, s.metricsInterceptor}
+	if authConfig != nil {
+		s.interceptors = append(s.interceptors, s.authInterceptor(authConfig))
+	} else {
+		s.log().Warn("No auth config given; every caller may call every RPC")
+	}
+	if s.election != nil {
+		s.interceptors = append(s.interceptors, s.leaderInterceptor)
+	}
+	s.interceptors = append(s.interceptors, s.auditInterceptor)
+	serverOpts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(s.interceptors...)}
+	if certs != nil {
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(certs.serverTLSConfig())))
+	} else {
+		s.log().Warn("No TLS certificate given; serving and calling app servers in plaintext")
+	}
+	s.grpcServer = grpc.NewServer(serverOpts...)
+	shardmanagerpb.RegisterNodeServiceServer(s.grpcServer, s)
+	shardmanagerpb.RegisterShardServiceServer(s.grpcServer, s)
+	shardmanagerpb.RegisterPolicyServiceServer(s.grpcServer, s)
+	shardmanagerpb.RegisterMonitoringServiceServer(s.grpcServer, s)
+	shardmanagerpb.RegisterFailureServiceServer(s.grpcServer, s)
+	shardmanagerpb.RegisterAuditServiceServer(s.grpcServer, s)
+	return s, nil
+}
+
+// log returns the server's logger, which adds the request ID of the
+// context a record is logged with
+func (s *Server) log() *slog.Logger {
+	if s.logger == nil {
+		return withRequestIDs(slog.Default())
+	}
+	return s.logger
+}
+
+// now reads the server's clock
+func (s *Server) now() time.Time {
+	if s.clock == nil {
+		return time.Now()
+	}
+	return s.clock()
 }
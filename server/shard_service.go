@@ -2,15 +2,14 @@ package server
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 
 	"github.com/seaweedfs/shardmanager/shardmanagerpb"
 
-	"log"
-	"time"
-
 	"github.com/google/uuid"
 	"github.com/seaweedfs/shardmanager/db"
-	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -23,10 +22,15 @@ func (s *Server) RegisterShard(ctx context.Context, req *shardmanagerpb.Register
 		return nil, status.Error(codes.InvalidArgument, "invalid shard ID")
 	}
 	shard := &db.Shard{
-		ID:     shardID,
-		Type:   req.Shard.Type,
-		Size:   req.Shard.Size,
-		Status: req.Shard.Status,
+		ID:             shardID,
+		Type:           req.Shard.Type,
+		Size:           req.Shard.Size,
+		Status:         req.Shard.Status,
+		Version:        1,
+		ResourceDemand: req.Shard.ResourceDemand,
+	}
+	if shard.Status == "" {
+		shard.Status = "active"
 	}
 
 	// If no node is specified, find an available node
@@ -38,32 +42,16 @@ func (s *Server) RegisterShard(ctx context.Context, req *shardmanagerpb.Register
 		if len(nodes) == 0 {
 			return nil, status.Error(codes.FailedPrecondition, "no nodes available for shard assignment")
 		}
-
-		// Simple round-robin: find the node with the least number of shards
-		var selectedNode *db.Node
-		minShards := -1
-		for _, node := range nodes {
-			if node.Status != "active" {
-				continue
-			}
-			shards, err := s.db.ListShards(ctx)
-			if err != nil {
-				return nil, status.Error(codes.Internal, err.Error())
-			}
-			nodeShardCount := 0
-			for _, shard := range shards {
-				if shard.NodeID != nil && *shard.NodeID == node.ID {
-					nodeShardCount++
-				}
-			}
-			if minShards == -1 || nodeShardCount < minShards {
-				minShards = nodeShardCount
-				selectedNode = node
-			}
+		shards, err := s.placementShards(ctx)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
 		}
 
-		if selectedNode == nil {
-			return nil, status.Error(codes.FailedPrecondition, "no active nodes available for shard assignment")
+		// Node chosen by the placement strategy among those with headroom
+		// in every resource dimension
+		selectedNode, err := selectNode(s.placementStrategy, nodes, shards, shard.ResourceDemand)
+		if err != nil {
+			return nil, err
 		}
 
 		shard.NodeID = &selectedNode.ID
@@ -72,16 +60,24 @@ func (s *Server) RegisterShard(ctx context.Context, req *shardmanagerpb.Register
 		if err != nil {
 			return nil, status.Error(codes.InvalidArgument, "invalid node ID")
 		}
+		if err := s.checkHeadroom(ctx, nodeID, shard); err != nil {
+			return nil, err
+		}
 		shard.NodeID = &nodeID
 	}
 
 	if err := s.db.RegisterShard(ctx, shard); err != nil {
+		if errors.Is(err, db.ErrInvalidStatus) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid shard status %q", shard.Status)
+		}
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	// Notify appserver if assigned
+	// Grant the lease and notify appserver if assigned
 	if shard.NodeID != nil {
-		go s.notifyAppServerAddShard(context.Background(), *shard.NodeID, shard.ID, "primary")
+		s.goHandoff(ctx, func(ctx context.Context) {
+			s.handoffShard(ctx, shard.ID, nil, *shard.NodeID)
+		})
 	}
 
 	return &shardmanagerpb.RegisterShardResponse{
@@ -99,10 +95,11 @@ func (s *Server) ListShards(ctx context.Context, req *shardmanagerpb.ListShardsR
 	pbShards := make([]*shardmanagerpb.Shard, len(shards))
 	for i, shard := range shards {
 		pbShard := &shardmanagerpb.Shard{
-			Id:     shard.ID.String(),
-			Type:   shard.Type,
-			Size:   shard.Size,
-			Status: shard.Status,
+			Id:             shard.ID.String(),
+			Type:           shard.Type,
+			Size:           shard.Size,
+			Status:         shard.Status,
+			ResourceDemand: shard.ResourceDemand,
 		}
 		if shard.NodeID != nil {
 			pbShard.NodeId = shard.NodeID.String()
@@ -128,10 +125,11 @@ func (s *Server) GetShardInfo(ctx context.Context, req *shardmanagerpb.GetShardI
 	}
 
 	pbShard := &shardmanagerpb.Shard{
-		Id:     shard.ID.String(),
-		Type:   shard.Type,
-		Size:   shard.Size,
-		Status: shard.Status,
+		Id:             shard.ID.String(),
+		Type:           shard.Type,
+		Size:           shard.Size,
+		Status:         shard.Status,
+		ResourceDemand: shard.ResourceDemand,
 	}
 	if shard.NodeID != nil {
 		pbShard.NodeId = shard.NodeID.String()
@@ -151,12 +149,26 @@ func (s *Server) AssignShard(ctx context.Context, req *shardmanagerpb.AssignShar
 		return nil, status.Error(codes.InvalidArgument, "invalid node ID")
 	}
 
+	shard, err := s.db.GetShardInfo(ctx, shardID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if shard == nil {
+		return nil, status.Error(codes.NotFound, "shard not found")
+	}
+	previousNodeID := shard.NodeID
+	if err := s.checkHeadroom(ctx, nodeID, shard); err != nil {
+		return nil, err
+	}
+
 	if err := s.db.AssignShard(ctx, shardID, nodeID); err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	// Notify appserver
-	go s.notifyAppServerAddShard(context.Background(), nodeID, shardID, "primary")
+	// Hand the shard over once the previous owner's lease is released
+	s.goHandoff(ctx, func(ctx context.Context) {
+		s.handoffShard(ctx, shardID, previousNodeID, nodeID)
+	})
 
 	return &shardmanagerpb.AssignShardResponse{
 		Success: true,
@@ -191,23 +203,26 @@ func (s *Server) MigrateShard(ctx context.Context, req *shardmanagerpb.MigrateSh
 	if shard.NodeID == nil || *shard.NodeID != fromNodeID {
 		return nil, status.Error(codes.FailedPrecondition, "shard is not on the source node")
 	}
-
-	// Update shard status to migrating
-	if err := s.db.UpdateShardStatus(ctx, shardID, "migrating"); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+	if err := s.checkHeadroom(ctx, toNodeID, shard); err != nil {
+		return nil, err
 	}
 
-	// Assign shard to new node
-	if err := s.db.AssignShard(ctx, shardID, toNodeID); err != nil {
-		// Revert status if assignment fails
-		_ = s.db.UpdateShardStatus(ctx, shardID, "active")
+	// One version records the move, unless the shard moved meanwhile
+	if err := s.db.MoveShard(ctx, shardID, fromNodeID, toNodeID); err != nil {
+		switch {
+		case errors.Is(err, db.ErrShardMoved):
+			return nil, status.Error(codes.FailedPrecondition, "shard is not on the source node")
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, status.Error(codes.NotFound, "shard not found")
+		}
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
-	// Update shard status back to active
-	if err := s.db.UpdateShardStatus(ctx, shardID, "active"); err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
-	}
+	// The destination only gets AddShard after the source's lease is released
+	s.metrics.migrationStarted()
+	s.goHandoff(ctx, func(ctx context.Context) {
+		s.metrics.migrationFinished(s.handoffShard(ctx, shardID, &fromNodeID, toNodeID))
+	})
 
 	return &shardmanagerpb.MigrateShardResponse{
 		Success: true,
@@ -222,6 +237,9 @@ func (s *Server) UpdateShardStatus(ctx context.Context, req *shardmanagerpb.Upda
 	}
 
 	if err := s.db.UpdateShardStatus(ctx, shardID, req.Status); err != nil {
+		if errors.Is(err, db.ErrInvalidStatus) {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid shard status %q", req.Status)
+		}
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
@@ -231,27 +249,182 @@ func (s *Server) UpdateShardStatus(ctx context.Context, req *shardmanagerpb.Upda
 	}, nil
 }
 
-// notifyAppServerAddShard contacts the appserver and calls AddShard RPC
-func (s *Server) notifyAppServerAddShard(ctx context.Context, nodeID uuid.UUID, shardID uuid.UUID, role string) {
+// GetShardHistory lists a shard's versions, newest first, starting with its
+// current state
+func (s *Server) GetShardHistory(ctx context.Context, req *shardmanagerpb.GetShardHistoryRequest) (*shardmanagerpb.GetShardHistoryResponse, error) {
+	shardID, err := uuid.Parse(req.ShardId)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid shard ID")
+	}
+	shard, err := s.db.GetShardInfo(ctx, shardID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if shard == nil {
+		return nil, status.Error(codes.NotFound, "shard not found")
+	}
+	versions, err := s.db.ListShardVersions(ctx, shardID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	current := &shardmanagerpb.ShardVersion{
+		Version: int32(shard.Version),
+		Type:    shard.Type,
+		Size:    shard.Size,
+		Status:  shard.Status,
+	}
+	if shard.NodeID != nil {
+		current.NodeId = shard.NodeID.String()
+	}
+	resp := &shardmanagerpb.GetShardHistoryResponse{Versions: []*shardmanagerpb.ShardVersion{current}}
+	for _, sv := range versions {
+		pbVersion := &shardmanagerpb.ShardVersion{
+			Version:          int32(sv.Version),
+			Type:             sv.Type,
+			Size:             sv.Size,
+			Status:           sv.Status,
+			RecordedAtUnixMs: sv.CreatedAt.UnixMilli(),
+		}
+		if sv.NodeID != nil {
+			pbVersion.NodeId = sv.NodeID.String()
+		}
+		resp.Versions = append(resp.Versions, pbVersion)
+	}
+	return resp, nil
+}
+
+// RollbackShard returns a shard to a recorded version. A version on another
+// node moves the shard back there through the usual handoff; a version on
+// the same node restores the recorded fields in place.
+func (s *Server) RollbackShard(ctx context.Context, req *shardmanagerpb.RollbackShardRequest) (*shardmanagerpb.RollbackShardResponse, error) {
+	shardID, err := uuid.Parse(req.ShardId)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid shard ID")
+	}
+	shard, err := s.db.GetShardInfo(ctx, shardID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if shard == nil {
+		return nil, status.Error(codes.NotFound, "shard not found")
+	}
+	sv, err := s.db.GetShardVersion(ctx, shardID, int(req.Version))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if sv == nil {
+		return nil, status.Errorf(codes.NotFound, "shard has no version %d", req.Version)
+	}
+	if sv.Status == "migrating" {
+		return nil, status.Errorf(codes.FailedPrecondition, "version %d was recorded mid-migration", req.Version)
+	}
+	if sv.NodeID == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "version %d has no node", req.Version)
+	}
+
+	if shard.NodeID != nil && *shard.NodeID == *sv.NodeID {
+		if err := s.db.RollbackShardVersion(ctx, shardID, sv.Version); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		return &shardmanagerpb.RollbackShardResponse{
+			Success: true,
+			Message: fmt.Sprintf("Shard restored to version %d", sv.Version),
+		}, nil
+	}
+
+	node, err := s.db.GetNodeInfo(ctx, *sv.NodeID)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if node == nil || node.Status != "active" {
+		return nil, status.Errorf(codes.FailedPrecondition, "node %s of version %d is not active", sv.NodeID, sv.Version)
+	}
+	if shard.NodeID == nil {
+		_, err = s.AssignShard(ctx, &shardmanagerpb.AssignShardRequest{ShardId: req.ShardId, NodeId: node.ID.String()})
+	} else {
+		_, err = s.MigrateShard(ctx, &shardmanagerpb.MigrateShardRequest{
+			ShardId:    req.ShardId,
+			FromNodeId: shard.NodeID.String(),
+			ToNodeId:   node.ID.String(),
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &shardmanagerpb.RollbackShardResponse{
+		Success: true,
+		Message: fmt.Sprintf("Shard moved back to node %s of version %d", node.ID, sv.Version),
+	}, nil
+}
+
+// notifyAppServerAddShard contacts the appserver and calls AddShard RPC.
+// It reports whether the shard was handed to the node.
+func (s *Server) notifyAppServerAddShard(ctx context.Context, nodeID uuid.UUID, shardID uuid.UUID, role string) bool {
 	node, err := s.db.GetNodeInfo(ctx, nodeID)
 	if err != nil || node == nil {
-		log.Printf("[WARN] Could not find node %s for AddShard notification: %v", nodeID, err)
-		return
+		s.log().WarnContext(ctx, "Could not find node for AddShard notification", "node", nodeID, "err", err)
+		return false
+	}
+	if isPullNode(node) {
+		// Pull-mode nodes pick the shard up from their next heartbeat
+		return true
 	}
-	conn, err := grpc.Dial(node.Location, grpc.WithInsecure(), grpc.WithBlock(), grpc.WithTimeout(2*time.Second))
+	conn, err := s.dialAppServer(node.Location)
 	if err != nil {
-		log.Printf("[WARN] Could not connect to appserver at %s: %v", node.Location, err)
-		return
+		s.log().WarnContext(ctx, "Could not connect to appserver", "location", node.Location, "err", err)
+		s.metrics.notificationFailed("AddShard")
+		return false
 	}
 	defer conn.Close()
 	client := shardmanagerpb.NewAppShardServiceClient(conn)
-	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, s.notificationTimeout)
 	defer cancel()
 	_, err = client.AddShard(ctx, &shardmanagerpb.AddShardRequest{
 		ShardId: shardID.String(),
 		Role:    role,
 	})
 	if err != nil {
-		log.Printf("[WARN] AddShard RPC to appserver %s failed: %v", node.Location, err)
+		s.log().WarnContext(ctx, "AddShard RPC to appserver failed", "location", node.Location, "err", err)
+		s.metrics.notificationFailed("AddShard")
+		return false
+	}
+	s.replicas.add(nodeID, shardID, role)
+	return true
+}
+
+// notifyAppServerDropShard contacts the appserver and calls DropShard RPC.
+// It reports whether the appserver confirmed the drop.
+func (s *Server) notifyAppServerDropShard(ctx context.Context, nodeID uuid.UUID, shardID uuid.UUID) bool {
+	node, err := s.db.GetNodeInfo(ctx, nodeID)
+	if err != nil || node == nil {
+		s.log().WarnContext(ctx, "Could not find node for DropShard notification", "node", nodeID, "err", err)
+		return false
+	}
+	if isPullNode(node) {
+		// Pull-mode nodes release the lease themselves by heartbeat
+		return false
+	}
+	conn, err := s.dialAppServer(node.Location)
+	if err != nil {
+		s.log().WarnContext(ctx, "Could not connect to appserver", "location", node.Location, "err", err)
+		s.metrics.notificationFailed("DropShard")
+		return false
+	}
+	defer conn.Close()
+	client := shardmanagerpb.NewAppShardServiceClient(conn)
+	ctx, cancel := context.WithTimeout(ctx, s.notificationTimeout)
+	defer cancel()
+	resp, err := client.DropShard(ctx, &shardmanagerpb.DropShardRequest{
+		ShardId: shardID.String(),
+	})
+	if err != nil {
+		s.log().WarnContext(ctx, "DropShard RPC to appserver failed", "location", node.Location, "err", err)
+		s.metrics.notificationFailed("DropShard")
+		return false
+	}
+	if resp.Success {
+		s.replicas.remove(nodeID, shardID)
 	}
+	return resp.Success
 }
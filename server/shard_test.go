@@ -7,6 +7,10 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/seaweedfs/shardmanager/db"
 	"github.com/seaweedfs/shardmanager/server/testutil"
@@ -18,6 +22,7 @@ func TestShardServiceOperations(t *testing.T) {
 
 	const testShardID = "11111111-1111-1111-1111-111111111111"
 	const testNodeID = "22222222-2222-2222-2222-222222222222" // Node ID must also be a valid UUID
+	const infoShardID = "33333333-3333-3333-3333-333333333333"
 
 	// Register a node before registering a shard
 	nodeUUID, _ := uuid.Parse(testNodeID)
@@ -53,10 +58,10 @@ func TestShardServiceOperations(t *testing.T) {
 	})
 
 	t.Run("GetShardInfo", func(t *testing.T) {
-		// Create a shard before the test
+		// Create a shard of its own before the test, since IDs are unique
 		req := &shardmanagerpb.RegisterShardRequest{
 			Shard: &shardmanagerpb.Shard{
-				Id:     testShardID,
+				Id:     infoShardID,
 				Type:   "test-type",
 				Size:   100,
 				Status: "active",
@@ -70,7 +75,7 @@ func TestShardServiceOperations(t *testing.T) {
 
 		// Retrieve the shard
 		reqGet := &shardmanagerpb.GetShardInfoRequest{
-			ShardId: testShardID,
+			ShardId: infoShardID,
 		}
 		_, err = server.GetShardInfo(context.Background(), reqGet)
 		if err != nil {
@@ -78,7 +83,7 @@ func TestShardServiceOperations(t *testing.T) {
 		}
 
 		// Convert string to uuid.UUID
-		shardID, err := uuid.Parse(testShardID)
+		shardID, err := uuid.Parse(infoShardID)
 		if err != nil {
 			t.Errorf("Failed to parse shard ID: %v", err)
 		}
@@ -109,7 +114,7 @@ func TestShardServiceOperations(t *testing.T) {
 	t.Run("UpdateShardStatus", func(t *testing.T) {
 		req := &shardmanagerpb.UpdateShardStatusRequest{
 			ShardId: testShardID,
-			Status:  "inactive",
+			Status:  "maintenance",
 		}
 		_, err := server.UpdateShardStatus(context.Background(), req)
 		if err != nil {
@@ -117,3 +122,70 @@ func TestShardServiceOperations(t *testing.T) {
 		}
 	})
 }
+
+func TestShardHistoryAndRollback(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+
+	first := &db.Node{ID: uuid.New(), Location: "localhost:1", Status: "active"}
+	second := &db.Node{ID: uuid.New(), Location: "localhost:2", Status: "active"}
+	require.NoError(t, mockDB.RegisterNode(ctx, first))
+	require.NoError(t, mockDB.RegisterNode(ctx, second))
+	shardID := uuid.New()
+	_, err := server.RegisterShard(ctx, &shardmanagerpb.RegisterShardRequest{
+		Shard: &shardmanagerpb.Shard{Id: shardID.String(), Type: "range", Size: 1, NodeId: first.ID.String(), Status: "active"},
+	})
+	require.NoError(t, err)
+	_, err = server.MigrateShard(ctx, &shardmanagerpb.MigrateShardRequest{
+		ShardId: shardID.String(), FromNodeId: first.ID.String(), ToNodeId: second.ID.String(),
+	})
+	require.NoError(t, err)
+
+	history, err := server.GetShardHistory(ctx, &shardmanagerpb.GetShardHistoryRequest{ShardId: shardID.String()})
+	require.NoError(t, err)
+	require.Len(t, history.Versions, 2, "a migration is a single version")
+	assert.Equal(t, second.ID.String(), history.Versions[0].NodeId)
+	assert.Zero(t, history.Versions[0].RecordedAtUnixMs)
+	original := history.Versions[1]
+	assert.Equal(t, int32(1), original.Version)
+	assert.Equal(t, first.ID.String(), original.NodeId)
+	assert.Equal(t, "active", original.Status)
+
+	t.Run("MigratingVersionIsRejected", func(t *testing.T) {
+		for _, st := range []string{"migrating", "active"} {
+			_, err := server.UpdateShardStatus(ctx, &shardmanagerpb.UpdateShardStatusRequest{ShardId: shardID.String(), Status: st})
+			require.NoError(t, err)
+		}
+		history, err := server.GetShardHistory(ctx, &shardmanagerpb.GetShardHistoryRequest{ShardId: shardID.String()})
+		require.NoError(t, err)
+		migrating := history.Versions[1]
+		require.Equal(t, "migrating", migrating.Status)
+		_, err = server.RollbackShard(ctx, &shardmanagerpb.RollbackShardRequest{ShardId: shardID.String(), Version: migrating.Version})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+
+	t.Run("UnknownVersion", func(t *testing.T) {
+		_, err := server.RollbackShard(ctx, &shardmanagerpb.RollbackShardRequest{ShardId: shardID.String(), Version: 99})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("MovesBackToNode", func(t *testing.T) {
+		_, err := server.RollbackShard(ctx, &shardmanagerpb.RollbackShardRequest{ShardId: shardID.String(), Version: original.Version})
+		require.NoError(t, err)
+		shard, err := mockDB.GetShardInfo(ctx, shardID)
+		require.NoError(t, err)
+		assert.Equal(t, first.ID, *shard.NodeID)
+		assert.Equal(t, "active", shard.Status)
+	})
+
+	t.Run("RestoresInPlace", func(t *testing.T) {
+		_, err := server.UpdateShardStatus(ctx, &shardmanagerpb.UpdateShardStatusRequest{ShardId: shardID.String(), Status: "failed"})
+		require.NoError(t, err)
+		_, err = server.RollbackShard(ctx, &shardmanagerpb.RollbackShardRequest{ShardId: shardID.String(), Version: original.Version})
+		require.NoError(t, err)
+		shard, err := mockDB.GetShardInfo(ctx, shardID)
+		require.NoError(t, err)
+		assert.Equal(t, "active", shard.Status)
+	})
+}
@@ -0,0 +1,460 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+)
+
+// shardMap keeps every node and shard in memory so reads and placement
+// decisions don't scan the database. It wraps the server's database: writes
+// go to the database first and then re-read the rows they touched, and
+// changes made by other instances arrive through the change hub, so the map
+// only ever holds rows as the database held them. Callers get copies.
+type shardMap struct {
+	db.DBOperations
+
+	mu       sync.RWMutex
+	nodes    map[uuid.UUID]*db.Node
+	shards   map[uuid.UUID]*db.Shard
+	inflight map[uuid.UUID]int // writes made here but not yet re-read
+
+	// refreshMu orders reading rows from the database with storing them,
+	// so a slow read never overwrites a newer one
+	refreshMu sync.Mutex
+
+	logger *slog.Logger
+}
+
+func newShardMap(ops db.DBOperations) *shardMap {
+	return &shardMap{
+		DBOperations: ops,
+		nodes:        make(map[uuid.UUID]*db.Node),
+		shards:       make(map[uuid.UUID]*db.Shard),
+		inflight:     make(map[uuid.UUID]int),
+		logger:       slog.Default(),
+	}
+}
+
+// load replaces the map with every node and shard in the database
+func (m *shardMap) load(ctx context.Context) error {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+
+	nodes, err := m.DBOperations.ListNodes(ctx)
+	if err != nil {
+		return err
+	}
+	shards, err := m.DBOperations.ListShards(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nodes = make(map[uuid.UUID]*db.Node, len(nodes))
+	for _, node := range nodes {
+		m.nodes[node.ID] = node
+	}
+	m.shards = make(map[uuid.UUID]*db.Shard, len(shards))
+	for _, shard := range shards {
+		m.shards[shard.ID] = shard
+	}
+	return nil
+}
+
+// follow applies changes made by any instance until ctx is cancelled
+func (m *shardMap) follow(ctx context.Context, changes <-chan db.Change) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case change := <-changes:
+			m.apply(ctx, change)
+		}
+	}
+}
+
+// apply re-reads what change touched. Policies are not kept here.
+func (m *shardMap) apply(ctx context.Context, change db.Change) {
+	switch change.Entity {
+	case db.ChangeNode, db.ChangeShard:
+		id, err := uuid.Parse(change.ID)
+		if err != nil {
+			m.logger.WarnContext(ctx, "Ignoring change with an invalid ID", "entity", change.Entity, "id", change.ID)
+			return
+		}
+		if change.Entity == db.ChangeNode {
+			m.refreshNode(ctx, id)
+		} else {
+			m.refreshShard(ctx, id)
+		}
+	case db.ChangeResync:
+		if err := m.load(ctx); err != nil {
+			m.logger.WarnContext(ctx, "Could not reload the shard map", "err", err)
+		}
+	}
+}
+
+// refreshNode re-reads a node from the database. Failures are logged and
+// left for the checker to repair.
+func (m *shardMap) refreshNode(ctx context.Context, id uuid.UUID) {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+	node, err := m.DBOperations.GetNodeInfo(ctx, id)
+	if err != nil {
+		m.logger.WarnContext(ctx, "Could not refresh node in the shard map", "node", id, "err", err)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if node == nil {
+		delete(m.nodes, id)
+	} else {
+		m.nodes[id] = node
+	}
+}
+
+// refreshShard re-reads a shard from the database, like refreshNode
+func (m *shardMap) refreshShard(ctx context.Context, id uuid.UUID) {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+	shard, err := m.DBOperations.GetShardInfo(ctx, id)
+	if err != nil {
+		m.logger.WarnContext(ctx, "Could not refresh shard in the shard map", "shard", id, "err", err)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if shard == nil {
+		delete(m.shards, id)
+	} else {
+		m.shards[id] = shard
+	}
+}
+
+// write runs a database write to the row id, then re-reads the row even if
+// the write failed, since a failed commit may still have applied
+func (m *shardMap) write(ctx context.Context, id uuid.UUID, refresh func(context.Context, uuid.UUID), fn func() error) error {
+	m.mu.Lock()
+	m.inflight[id]++
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		if m.inflight[id]--; m.inflight[id] == 0 {
+			delete(m.inflight, id)
+		}
+		m.mu.Unlock()
+	}()
+
+	err := fn()
+	refresh(context.WithoutCancel(ctx), id)
+	return err
+}
+
+func (m *shardMap) RegisterNode(ctx context.Context, node *db.Node) error {
+	// Known up front so the row can be re-read
+	if node.ID == uuid.Nil {
+		node.ID = uuid.New()
+	}
+	return m.write(ctx, node.ID, m.refreshNode, func() error { return m.DBOperations.RegisterNode(ctx, node) })
+}
+
+func (m *shardMap) UpdateNode(ctx context.Context, node *db.Node) error {
+	return m.write(ctx, node.ID, m.refreshNode, func() error { return m.DBOperations.UpdateNode(ctx, node) })
+}
+
+func (m *shardMap) UpdateNodeHeartbeat(ctx context.Context, nodeID uuid.UUID, status string, currentLoad int64, resourceLoad db.Resources) error {
+	return m.write(ctx, nodeID, m.refreshNode, func() error {
+		return m.DBOperations.UpdateNodeHeartbeat(ctx, nodeID, status, currentLoad, resourceLoad)
+	})
+}
+
+func (m *shardMap) RegisterShard(ctx context.Context, shard *db.Shard) error {
+	if shard.ID == uuid.Nil {
+		shard.ID = uuid.New()
+	}
+	return m.write(ctx, shard.ID, m.refreshShard, func() error { return m.DBOperations.RegisterShard(ctx, shard) })
+}
+
+func (m *shardMap) AssignShard(ctx context.Context, shardID, nodeID uuid.UUID) error {
+	return m.write(ctx, shardID, m.refreshShard, func() error { return m.DBOperations.AssignShard(ctx, shardID, nodeID) })
+}
+
+func (m *shardMap) MoveShard(ctx context.Context, shardID, fromNodeID, toNodeID uuid.UUID) error {
+	return m.write(ctx, shardID, m.refreshShard, func() error {
+		return m.DBOperations.MoveShard(ctx, shardID, fromNodeID, toNodeID)
+	})
+}
+
+func (m *shardMap) UpdateShardStatus(ctx context.Context, shardID uuid.UUID, status string) error {
+	return m.write(ctx, shardID, m.refreshShard, func() error { return m.DBOperations.UpdateShardStatus(ctx, shardID, status) })
+}
+
+func (m *shardMap) UpdateShardVersion(ctx context.Context, shard *db.Shard) error {
+	return m.write(ctx, shard.ID, m.refreshShard, func() error { return m.DBOperations.UpdateShardVersion(ctx, shard) })
+}
+
+func (m *shardMap) RollbackShardVersion(ctx context.Context, shardID uuid.UUID, version int) error {
+	return m.write(ctx, shardID, m.refreshShard, func() error {
+		return m.DBOperations.RollbackShardVersion(ctx, shardID, version)
+	})
+}
+
+// ListNodes returns every node, oldest first
+func (m *shardMap) ListNodes(ctx context.Context) ([]*db.Node, error) {
+	m.mu.RLock()
+	nodes := make([]*db.Node, 0, len(m.nodes))
+	for _, node := range m.nodes {
+		nodes = append(nodes, cloneNode(node))
+	}
+	m.mu.RUnlock()
+	sort.Slice(nodes, func(i, j int) bool {
+		return createdBefore(nodes[i].CreatedAt, nodes[j].CreatedAt, nodes[i].ID, nodes[j].ID)
+	})
+	return nodes, nil
+}
+
+func (m *shardMap) GetNodeInfo(ctx context.Context, nodeID uuid.UUID) (*db.Node, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if node, ok := m.nodes[nodeID]; ok {
+		return cloneNode(node), nil
+	}
+	return nil, nil
+}
+
+func (m *shardMap) GetNodeByName(ctx context.Context, name string) (*db.Node, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, node := range m.nodes {
+		if name != "" && node.Name == name {
+			return cloneNode(node), nil
+		}
+	}
+	return nil, nil
+}
+
+// ListShards returns every shard, oldest first
+func (m *shardMap) ListShards(ctx context.Context) ([]*db.Shard, error) {
+	m.mu.RLock()
+	shards := make([]*db.Shard, 0, len(m.shards))
+	for _, shard := range m.shards {
+		shards = append(shards, cloneShard(shard))
+	}
+	m.mu.RUnlock()
+	sort.Slice(shards, func(i, j int) bool {
+		return createdBefore(shards[i].CreatedAt, shards[j].CreatedAt, shards[i].ID, shards[j].ID)
+	})
+	return shards, nil
+}
+
+func (m *shardMap) GetShardInfo(ctx context.Context, shardID uuid.UUID) (*db.Shard, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if shard, ok := m.shards[shardID]; ok {
+		return cloneShard(shard), nil
+	}
+	return nil, nil
+}
+
+// createdBefore orders rows by creation time, then by ID
+func createdBefore(a, b time.Time, aID, bID uuid.UUID) bool {
+	if !a.Equal(b) {
+		return a.Before(b)
+	}
+	return aID.String() < bID.String()
+}
+
+func cloneNode(node *db.Node) *db.Node {
+	copied := *node
+	copied.ResourceCapacity = maps.Clone(node.ResourceCapacity)
+	copied.ResourceLoad = maps.Clone(node.ResourceLoad)
+	return &copied
+}
+
+func cloneShard(shard *db.Shard) *db.Shard {
+	copied := *shard
+	if shard.NodeID != nil {
+		nodeID := *shard.NodeID
+		copied.NodeID = &nodeID
+	}
+	copied.Metadata = bytes.Clone(shard.Metadata)
+	copied.ResourceDemand = maps.Clone(shard.ResourceDemand)
+	return &copied
+}
+
+// mismatch is a row the shard map holds differently from the database
+type mismatch struct {
+	entity string // db.ChangeNode or db.ChangeShard
+	id     uuid.UUID
+	fields []string // the fields that differ; empty when one side lacks the row
+}
+
+func (d mismatch) String() string {
+	if len(d.fields) == 0 {
+		return d.entity + " " + d.id.String() + " is missing on one side"
+	}
+	return d.entity + " " + d.id.String() + " differs in " + strings.Join(d.fields, ", ")
+}
+
+// check compares the map with the database and re-reads every row that
+// differs. Rows with a write in progress are skipped, since they are
+// expected to differ until it finishes. Changes from other instances that
+// have not arrived yet show up as differences too.
+func (m *shardMap) check(ctx context.Context) ([]mismatch, error) {
+	found, err := m.diff(ctx)
+	for _, d := range found {
+		m.apply(ctx, db.Change{Entity: d.entity, ID: d.id.String()})
+	}
+	return found, err
+}
+
+// diff lists the rows that differ, holding off refreshes so none lands
+// between reading the database and comparing
+func (m *shardMap) diff(ctx context.Context) ([]mismatch, error) {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+	nodes, err := m.DBOperations.ListNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := m.DBOperations.ListShards(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []mismatch
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	seen := make(map[uuid.UUID]bool)
+	for _, stored := range nodes {
+		seen[stored.ID] = true
+		if m.inflight[stored.ID] > 0 {
+			continue
+		}
+		if cached, ok := m.nodes[stored.ID]; !ok {
+			found = append(found, mismatch{entity: db.ChangeNode, id: stored.ID})
+		} else if fields := nodeDiff(cached, stored); len(fields) > 0 {
+			found = append(found, mismatch{entity: db.ChangeNode, id: stored.ID, fields: fields})
+		}
+	}
+	for id := range m.nodes {
+		if !seen[id] && m.inflight[id] == 0 {
+			found = append(found, mismatch{entity: db.ChangeNode, id: id})
+		}
+	}
+	for _, stored := range shards {
+		seen[stored.ID] = true
+		if m.inflight[stored.ID] > 0 {
+			continue
+		}
+		if cached, ok := m.shards[stored.ID]; !ok {
+			found = append(found, mismatch{entity: db.ChangeShard, id: stored.ID})
+		} else if fields := shardDiff(cached, stored); len(fields) > 0 {
+			found = append(found, mismatch{entity: db.ChangeShard, id: stored.ID, fields: fields})
+		}
+	}
+	for id := range m.shards {
+		if !seen[id] && m.inflight[id] == 0 {
+			found = append(found, mismatch{entity: db.ChangeShard, id: id})
+		}
+	}
+	return found, nil
+}
+
+// nodeDiff names the fields in which two copies of a node differ
+func nodeDiff(a, b *db.Node) []string {
+	var fields []string
+	add := func(name string, same bool) {
+		if !same {
+			fields = append(fields, name)
+		}
+	}
+	add("name", a.Name == b.Name)
+	add("location", a.Location == b.Location)
+	add("fault_domain", a.FaultDomain == b.FaultDomain)
+	add("capacity", a.Capacity == b.Capacity)
+	add("status", a.Status == b.Status)
+	add("assignment_mode", a.AssignmentMode == b.AssignmentMode)
+	add("last_heartbeat", a.LastHeartbeat.Equal(b.LastHeartbeat))
+	add("current_load", a.CurrentLoad == b.CurrentLoad)
+	add("resource_capacity", maps.Equal(a.ResourceCapacity, b.ResourceCapacity))
+	add("resource_load", maps.Equal(a.ResourceLoad, b.ResourceLoad))
+	return fields
+}
+
+// shardDiff names the fields in which two copies of a shard differ
+func shardDiff(a, b *db.Shard) []string {
+	var fields []string
+	add := func(name string, same bool) {
+		if !same {
+			fields = append(fields, name)
+		}
+	}
+	add("type", a.Type == b.Type)
+	add("size", a.Size == b.Size)
+	add("node_id", (a.NodeID == nil) == (b.NodeID == nil) && (a.NodeID == nil || *a.NodeID == *b.NodeID))
+	add("status", a.Status == b.Status)
+	add("version", a.Version == b.Version)
+	add("metadata", bytes.Equal(a.Metadata, b.Metadata))
+	add("resource_demand", maps.Equal(a.ResourceDemand, b.ResourceDemand))
+	return fields
+}
+
+// startShardMap loads the shard map and puts it in front of the database,
+// keeping it up to date with the change hub until ctx is cancelled. It must
+// be called before the server starts serving.
+func (s *Server) startShardMap(ctx context.Context) error {
+	m := newShardMap(s.db)
+	m.logger = s.log()
+	// Subscribed first so no change made while loading is missed
+	changes, unsubscribe := s.changes.subscribe()
+	if err := m.load(ctx); err != nil {
+		unsubscribe()
+		return err
+	}
+	s.db = m
+	s.shardMap = m
+	s.loops.Add(1)
+	go func() {
+		defer s.loops.Done()
+		defer unsubscribe()
+		m.follow(ctx, changes)
+	}()
+	return nil
+}
+
+// runShardMapChecker periodically compares the shard map with the database
+// until ctx is cancelled, reporting and repairing any difference. Followers
+// skip it, since the changes they follow arrive late.
+func (s *Server) runShardMapChecker(ctx context.Context) {
+	ticker := time.NewTicker(s.shardMapCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !s.isLeader() {
+				continue
+			}
+			found, err := s.shardMap.check(ctx)
+			if err != nil {
+				s.log().WarnContext(ctx, "Shard map check failed", "err", err)
+			}
+			for _, d := range found {
+				s.log().WarnContext(ctx, "Shard map differed from the database", "mismatch", d.String())
+				s.metrics.shardMapMismatch(d.entity)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/server/testutil"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+func TestShardMap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockDB := testutil.NewMockDB()
+	node := &db.Node{ID: uuid.New(), Location: "localhost:1", Status: "active"}
+	require.NoError(t, mockDB.RegisterNode(ctx, node))
+	shard := &db.Shard{ID: uuid.New(), NodeID: &node.ID, Status: "active", Version: 1}
+	require.NoError(t, mockDB.RegisterShard(ctx, shard))
+
+	server := NewServer(mockDB)
+	require.NoError(t, server.startShardMap(ctx))
+
+	resp, err := server.ListShards(ctx, &shardmanagerpb.ListShardsRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Shards, 1)
+	assert.Equal(t, node.ID.String(), resp.Shards[0].NodeId)
+
+	t.Run("WritesThrough", func(t *testing.T) {
+		_, err := server.UpdateShardStatus(ctx, &shardmanagerpb.UpdateShardStatusRequest{ShardId: shard.ID.String(), Status: "migrating"})
+		require.NoError(t, err)
+		got, err := server.db.GetShardInfo(ctx, shard.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "migrating", got.Status, "reads see the server's own writes at once")
+		assert.Equal(t, 2, got.Version)
+
+		// Callers get copies
+		got.Status = "failed"
+		again, err := server.db.GetShardInfo(ctx, shard.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "migrating", again.Status)
+	})
+
+	t.Run("FollowsChanges", func(t *testing.T) {
+		// Another instance writes straight to the database
+		other := &db.Node{ID: uuid.New(), Location: "localhost:2", Status: "active"}
+		require.NoError(t, mockDB.RegisterNode(ctx, other))
+		missing, err := server.db.GetNodeInfo(ctx, other.ID)
+		require.NoError(t, err)
+		assert.Nil(t, missing, "reads are served from memory")
+
+		go server.runChangeFeed(ctx)
+		// Retried until the feed is watching
+		require.Eventually(t, func() bool {
+			require.NoError(t, mockDB.UpdateNodeHeartbeat(ctx, other.ID, "active", 5, nil))
+			got, err := server.db.GetNodeInfo(ctx, other.ID)
+			return err == nil && got != nil && got.CurrentLoad == 5
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestShardMapCheck(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	shard := &db.Shard{ID: uuid.New(), Status: "active", Version: 1}
+	require.NoError(t, mockDB.RegisterShard(ctx, shard))
+	m := newShardMap(mockDB)
+	require.NoError(t, m.load(ctx))
+
+	found, err := m.check(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+
+	// Changes the map never heard of
+	require.NoError(t, mockDB.UpdateShardStatus(ctx, shard.ID, "failed"))
+	node := &db.Node{ID: uuid.New(), Location: "localhost:1", Status: "active"}
+	require.NoError(t, mockDB.RegisterNode(ctx, node))
+
+	found, err = m.check(ctx)
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	assert.Contains(t, found, mismatch{entity: db.ChangeShard, id: shard.ID, fields: []string{"status", "version"}})
+	assert.Contains(t, found, mismatch{entity: db.ChangeNode, id: node.ID})
+
+	// Differences are repaired once reported
+	found, err = m.check(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+	got, err := m.GetShardInfo(ctx, shard.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "failed", got.Status)
+
+	// A resync reloads everything
+	require.NoError(t, mockDB.UpdateShardStatus(ctx, shard.ID, "active"))
+	m.apply(ctx, db.Change{Entity: db.ChangeResync})
+	got, err = m.GetShardInfo(ctx, shard.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "active", got.Status)
+}
+
+func TestShardMapChecker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+	server.shardMapCheckInterval = 10 * time.Millisecond
+	require.NoError(t, server.startShardMap(ctx))
+	go server.runShardMapChecker(ctx)
+
+	// Writes made through the server never count as differences
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, server.db.RegisterNode(ctx, &db.Node{Location: "localhost:1", Status: "active"}))
+		}()
+	}
+	wg.Wait()
+	time.Sleep(50 * time.Millisecond)
+	server.metrics.mu.Lock()
+	assert.Empty(t, server.metrics.shardMapMismatches)
+	server.metrics.mu.Unlock()
+
+	require.NoError(t, mockDB.RegisterShard(ctx, &db.Shard{ID: uuid.New(), Status: "active", Version: 1}))
+	require.Eventually(t, func() bool {
+		server.metrics.mu.Lock()
+		defer server.metrics.mu.Unlock()
+		return server.metrics.shardMapMismatches[db.ChangeShard] == 1
+	}, time.Second, 10*time.Millisecond)
+	shards, err := server.db.ListShards(ctx)
+	require.NoError(t, err)
+	assert.Len(t, shards, 1)
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/policy"
+)
+
+// Shard metric names populated from heartbeat load reports
+const (
+	MetricQPS            = "qps"
+	MetricBytesPerSecond = "bytes_per_second"
+	MetricCPUShare       = "cpu_share"
+	MetricSizeBytes      = "size_bytes"
+)
+
+// SystemState snapshots nodes, shards and their most recently reported load
+// in the form consumed by the policy evaluators. Node metrics hold the node's
+// reported load, its resource load vector under "resource.<name>" and the sum
+// of its shards' metrics. Cluster metrics are the sums over all shards.
+func (s *Server) SystemState(ctx context.Context) (*policy.SystemState, error) {
+	nodes, err := s.db.ListNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := s.db.ListShards(ctx)
+	if err != nil {
+		return nil, err
+	}
+	loads, err := s.db.ListShardLoads(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return buildSystemState(nodes, shards, loadsByShard(loads)), nil
+}
+
+// loadsByShard indexes load samples by shard
+func loadsByShard(loads []*db.ShardLoad) map[uuid.UUID]*db.ShardLoad {
+	byShard := make(map[uuid.UUID]*db.ShardLoad, len(loads))
+	for _, load := range loads {
+		byShard[load.ShardID] = load
+	}
+	return byShard
+}
+
+// buildSystemState is SystemState over rows already read
+func buildSystemState(nodes []*db.Node, shards []*db.Shard, loads map[uuid.UUID]*db.ShardLoad) *policy.SystemState {
+	state := &policy.SystemState{
+		Nodes:   make(map[string]policy.NodeState, len(nodes)),
+		Shards:  make(map[string]policy.ShardState, len(shards)),
+		Metrics: make(map[string]policy.MetricValue),
+	}
+
+	for _, node := range nodes {
+		metrics := map[string]policy.MetricValue{
+			"load":        {Value: float64(node.CurrentLoad), Timestamp: node.LastHeartbeat},
+			"shard_count": {},
+		}
+		for name, v := range node.ResourceLoad {
+			metrics["resource."+name] = policy.MetricValue{Value: v, Timestamp: node.LastHeartbeat}
+		}
+		state.Nodes[node.ID.String()] = policy.NodeState{
+			ID:      node.ID.String(),
+			Status:  node.Status,
+			Metrics: metrics,
+			Metadata: map[string]interface{}{
+				"name":            node.Name,
+				"location":        node.Location,
+				"assignment_mode": node.AssignmentMode,
+			},
+		}
+	}
+
+	for _, shard := range shards {
+		shardState := policy.ShardState{
+			ID:      shard.ID.String(),
+			Status:  shard.Status,
+			Metrics: make(map[string]policy.MetricValue),
+			Metadata: map[string]interface{}{
+				"type":    shard.Type,
+				"size":    shard.Size,
+				"version": shard.Version,
+			},
+		}
+		if load, ok := loads[shard.ID]; ok {
+			shardState.Metrics = shardLoadMetrics(load)
+		}
+		for name, m := range shardState.Metrics {
+			addMetric(state.Metrics, name, m)
+		}
+
+		if shard.NodeID != nil {
+			shardState.NodeID = shard.NodeID.String()
+			if node, ok := state.Nodes[shardState.NodeID]; ok {
+				addMetric(node.Metrics, "shard_count", policy.MetricValue{Value: 1})
+				for name, m := range shardState.Metrics {
+					addMetric(node.Metrics, name, m)
+				}
+			}
+		}
+		state.Shards[shardState.ID] = shardState
+	}
+	return state
+}
+
+// shardLoadMetrics converts a load sample into policy metrics
+func shardLoadMetrics(load *db.ShardLoad) map[string]policy.MetricValue {
+	return map[string]policy.MetricValue{
+		MetricQPS:            {Value: load.QPS, Timestamp: load.ReportedAt},
+		MetricBytesPerSecond: {Value: float64(load.BytesPerSecond), Timestamp: load.ReportedAt},
+		MetricCPUShare:       {Value: load.CPUShare, Timestamp: load.ReportedAt},
+		MetricSizeBytes:      {Value: float64(load.SizeBytes), Timestamp: load.ReportedAt},
+	}
+}
+
+// addMetric accumulates m into metrics[name], keeping the newest timestamp
+func addMetric(metrics map[string]policy.MetricValue, name string, m policy.MetricValue) {
+	cur := metrics[name]
+	cur.Value += m.Value
+	if m.Timestamp.After(cur.Timestamp) {
+		cur.Timestamp = m.Timestamp
+	}
+	metrics[name] = cur
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/server/testutil"
+)
+
+func TestSystemState(t *testing.T) {
+	ctx := context.Background()
+	mockDB := testutil.NewMockDB()
+	server := NewServer(mockDB)
+
+	node := &db.Node{ID: uuid.New(), Status: "active", CurrentLoad: 7, ResourceLoad: db.Resources{"cpu": 3}}
+	require.NoError(t, mockDB.RegisterNode(ctx, node))
+	require.NoError(t, mockDB.UpdateNodeHeartbeat(ctx, node.ID, "active", 7, db.Resources{"cpu": 3}))
+	hot := &db.Shard{ID: uuid.New(), NodeID: &node.ID, Status: "active"}
+	cold := &db.Shard{ID: uuid.New(), NodeID: &node.ID, Status: "active"}
+	idle := &db.Shard{ID: uuid.New(), Status: "active"}
+	for _, shard := range []*db.Shard{hot, cold, idle} {
+		require.NoError(t, mockDB.RegisterShard(ctx, shard))
+	}
+	require.NoError(t, mockDB.RecordShardLoads(ctx, []*db.ShardLoad{
+		{ShardID: hot.ID, NodeID: node.ID, QPS: 900, BytesPerSecond: 4096, CPUShare: 0.6, SizeBytes: 1 << 20},
+		{ShardID: cold.ID, NodeID: node.ID, QPS: 100, CPUShare: 0.1, SizeBytes: 1 << 10},
+	}))
+
+	state, err := server.SystemState(ctx)
+	require.NoError(t, err)
+
+	hotState := state.Shards[hot.ID.String()]
+	assert.Equal(t, node.ID.String(), hotState.NodeID)
+	assert.Equal(t, 900.0, hotState.Metrics[MetricQPS].Value)
+	assert.Equal(t, 4096.0, hotState.Metrics[MetricBytesPerSecond].Value)
+	assert.Equal(t, 0.6, hotState.Metrics[MetricCPUShare].Value)
+	assert.Equal(t, float64(1<<20), hotState.Metrics[MetricSizeBytes].Value)
+	assert.False(t, hotState.Metrics[MetricQPS].Timestamp.IsZero(), "samples keep their report time")
+	assert.Empty(t, state.Shards[idle.ID.String()].Metrics)
+
+	nodeState := state.Nodes[node.ID.String()]
+	assert.Equal(t, 1000.0, nodeState.Metrics[MetricQPS].Value)
+	assert.InDelta(t, 0.7, nodeState.Metrics[MetricCPUShare].Value, 1e-9)
+	assert.Equal(t, 2.0, nodeState.Metrics["shard_count"].Value)
+	assert.Equal(t, 7.0, nodeState.Metrics["load"].Value)
+	assert.Equal(t, 3.0, nodeState.Metrics["resource.cpu"].Value)
+	assert.Equal(t, 1000.0, state.Metrics[MetricQPS].Value)
+}
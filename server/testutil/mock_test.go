@@ -0,0 +1,12 @@
+package testutil
+
+import (
+	"testing"
+
+	"github.com/seaweedfs/shardmanager/db"
+	"github.com/seaweedfs/shardmanager/db/dbtest"
+)
+
+func TestMockConformance(t *testing.T) {
+	dbtest.Run(t, func(t *testing.T) db.DBOperations { return NewMockDB() })
+}
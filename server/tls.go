@@ -0,0 +1,160 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// tlsReloadCheckInterval is how often certificate files are checked for changes
+const tlsReloadCheckInterval = 5 * time.Second
+
+// TLSConfig names the PEM files used to secure the server and its calls to
+// app servers. The certificate and key are served to clients and presented
+// to app servers as a client certificate. The CA bundle verifies both client
+// certificates and app server certificates.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file" json:"cert_file"`
+	KeyFile  string `yaml:"key_file" json:"key_file"`
+	CAFile   string `yaml:"ca_file" json:"ca_file"`
+}
+
+// Enabled reports whether TLS was configured
+func (c *TLSConfig) Enabled() bool {
+	return c != nil && c.CertFile != ""
+}
+
+// certReloader serves a certificate and CA pool loaded from disk, reloading
+// them when any of the files changes
+type certReloader struct {
+	cfg TLSConfig
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+	logger    *slog.Logger
+}
+
+// newCertReloader loads the configured files, failing if any is unusable
+func newCertReloader(cfg TLSConfig) (*certReloader, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, fmt.Errorf("TLS needs both a certificate and a key file")
+	}
+	r := &certReloader{cfg: cfg, logger: slog.Default()}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load reads the certificate, key and CA bundle from disk
+func (r *certReloader) load() error {
+	modTimes, err := r.statFiles()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS key pair: %w", err)
+	}
+	var pool *x509.CertPool
+	if r.cfg.CAFile != "" {
+		pem, err := os.ReadFile(r.cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("read CA bundle: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in CA bundle %s", r.cfg.CAFile)
+		}
+	}
+
+	r.cert = &cert
+	r.pool = pool
+	r.modTimes = modTimes
+	return nil
+}
+
+func (r *certReloader) statFiles() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time)
+	for _, path := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.CAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[path] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// current returns the loaded certificate and CA pool, first reloading them
+// if a file changed. A failed reload keeps the previous material in use.
+func (r *certReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) >= tlsReloadCheckInterval {
+		r.lastCheck = time.Now()
+		if r.changed() {
+			if err := r.load(); err != nil {
+				r.logger.Warn("Could not reload TLS certificates, keeping the previous ones", "err", err)
+			} else {
+				r.logger.Info("Reloaded TLS certificates", "cert_file", r.cfg.CertFile)
+			}
+		}
+	}
+	return r.cert, r.pool
+}
+
+func (r *certReloader) changed() bool {
+	modTimes, err := r.statFiles()
+	if err != nil {
+		return false
+	}
+	for path, t := range modTimes {
+		if !t.Equal(r.modTimes[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+// serverTLSConfig returns the listener config. Client certificates are
+// verified against the CA bundle when presented, but not required, so
+// callers may still authenticate with bearer tokens.
+func (r *certReloader) serverTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := r.current()
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+			}
+			if pool != nil {
+				cfg.ClientCAs = pool
+				cfg.ClientAuth = tls.VerifyClientCertIfGiven
+			}
+			return cfg, nil
+		},
+	}
+}
+
+// clientTLSConfig returns the config for mutual TLS to an app server
+func (r *certReloader) clientTLSConfig() *tls.Config {
+	cert, pool := r.current()
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*cert},
+		RootCAs:      pool,
+	}
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	"github.com/seaweedfs/shardmanager/server/testutil"
+	"github.com/seaweedfs/shardmanager/shardmanagerpb"
+)
+
+// testCA issues certificates for TLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue writes a certificate and key for cn, valid as both server and client
+func (ca *testCA) issue(t *testing.T, dir, cn string, serial int64) TLSConfig {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	cfg := TLSConfig{
+		CertFile: filepath.Join(dir, cn+".crt"),
+		KeyFile:  filepath.Join(dir, cn+".key"),
+		CAFile:   filepath.Join(dir, "ca.crt"),
+	}
+	require.NoError(t, os.WriteFile(cfg.CertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(cfg.KeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.WriteFile(cfg.CAFile, ca.pem, 0o600))
+	return cfg
+}
+
+// peerRecorder is an app server that remembers the client certificate of
+// the last caller
+type peerRecorder struct {
+	shardmanagerpb.UnimplementedAppShardServiceServer
+	clientCN string
+}
+
+func (p *peerRecorder) ListLocalShards(ctx context.Context, req *shardmanagerpb.ListLocalShardsRequest) (*shardmanagerpb.ListLocalShardsResponse, error) {
+	if pr, ok := peer.FromContext(ctx); ok {
+		if info, ok := pr.AuthInfo.(credentials.TLSInfo); ok && len(info.State.VerifiedChains) > 0 {
+			p.clientCN = info.State.VerifiedChains[0][0].Subject.CommonName
+		}
+	}
+	return &shardmanagerpb.ListLocalShardsResponse{}, nil
+}
+
+func TestMutualTLSToAppServer(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	managerCfg := ca.issue(t, dir, "shardmanager", 2)
+	appCfg := ca.issue(t, dir, "appserver", 3)
+
+	// The app server only accepts callers with a certificate from the CA
+	appCert, err := tls.LoadX509KeyPair(appCfg.CertFile, appCfg.KeyFile)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	app := &peerRecorder{}
+	grpcServer := grpc.NewServer(grpc.Creds(credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{appCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})))
+	shardmanagerpb.RegisterAppShardServiceServer(grpcServer, app)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go grpcServer.Serve(lis)
+	defer grpcServer.Stop()
+
+	certs, err := newCertReloader(managerCfg)
+	require.NoError(t, err)
+	server := NewServer(testutil.NewMockDB())
+	server.tls = certs
+
+	conn, err := server.dialAppServer(lis.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = shardmanagerpb.NewAppShardServiceClient(conn).ListLocalShards(context.Background(), &shardmanagerpb.ListLocalShardsRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "shardmanager", app.clientCN)
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	cfg := ca.issue(t, dir, "shardmanager", 2)
+
+	r, err := newCertReloader(cfg)
+	require.NoError(t, err)
+	first, _ := r.current()
+
+	// Rewrite the pair in place with a newer modification time
+	rotated := ca.issue(t, dir, "shardmanager", 4)
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(rotated.CertFile, future, future))
+	r.lastCheck = time.Time{}
+	second, _ := r.current()
+	assert.NotEqual(t, first.Certificate[0], second.Certificate[0])
+
+	// A broken file keeps the previous certificate in use
+	require.NoError(t, os.WriteFile(cfg.KeyFile, []byte("garbage"), 0o600))
+	r.lastCheck = time.Time{}
+	third, _ := r.current()
+	assert.Equal(t, second.Certificate[0], third.Certificate[0])
+
+	_, err = newCertReloader(TLSConfig{CertFile: cfg.CertFile})
+	assert.Error(t, err)
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	r, err := newCertReloader(ca.issue(t, dir, "shardmanager", 2))
+	require.NoError(t, err)
+
+	cfg, err := r.serverTLSConfig().GetConfigForClient(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.Len(t, cfg.Certificates, 1)
+	assert.Equal(t, tls.VerifyClientCertIfGiven, cfg.ClientAuth)
+	assert.NotNil(t, cfg.ClientCAs)
+}
@@ -22,13 +22,22 @@ const (
 )
 
 type Node struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Location      string                 `protobuf:"bytes,2,opt,name=location,proto3" json:"location,omitempty"`
-	Capacity      int64                  `protobuf:"varint,3,opt,name=capacity,proto3" json:"capacity,omitempty"`
-	Status        string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Location       string                 `protobuf:"bytes,2,opt,name=location,proto3" json:"location,omitempty"`
+	Capacity       int64                  `protobuf:"varint,3,opt,name=capacity,proto3" json:"capacity,omitempty"`
+	Status         string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	AssignmentMode string                 `protobuf:"bytes,5,opt,name=assignment_mode,json=assignmentMode,proto3" json:"assignment_mode,omitempty"` // "push" (default) or "pull"
+	Name           string                 `protobuf:"bytes,6,opt,name=name,proto3" json:"name,omitempty"`                                           // optional stable name, unique across nodes
+	// Named resource dimensions, e.g. "cpu", "memory", "disk", "qps"
+	ResourceCapacity    map[string]float64 `protobuf:"bytes,7,rep,name=resource_capacity,json=resourceCapacity,proto3" json:"resource_capacity,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed64,2,opt,name=value"`
+	ResourceLoad        map[string]float64 `protobuf:"bytes,8,rep,name=resource_load,json=resourceLoad,proto3" json:"resource_load,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed64,2,opt,name=value"`
+	ResourceHeadroom    map[string]float64 `protobuf:"bytes,9,rep,name=resource_headroom,json=resourceHeadroom,proto3" json:"resource_headroom,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed64,2,opt,name=value"` // computed by ListNodes
+	CurrentLoad         int64              `protobuf:"varint,10,opt,name=current_load,json=currentLoad,proto3" json:"current_load,omitempty"`                                                                                          // last reported load, set by ListNodes
+	LastHeartbeatUnixMs int64              `protobuf:"varint,11,opt,name=last_heartbeat_unix_ms,json=lastHeartbeatUnixMs,proto3" json:"last_heartbeat_unix_ms,omitempty"`                                                              // set by ListNodes
+	FaultDomain         string             `protobuf:"bytes,12,opt,name=fault_domain,json=faultDomain,proto3" json:"fault_domain,omitempty"`                                                                                           // e.g. rack or zone; replicas are spread across these
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
 }
 
 func (x *Node) Reset() {
@@ -89,15 +98,72 @@ func (x *Node) GetStatus() string {
 	return ""
 }
 
+func (x *Node) GetAssignmentMode() string {
+	if x != nil {
+		return x.AssignmentMode
+	}
+	return ""
+}
+
+func (x *Node) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Node) GetResourceCapacity() map[string]float64 {
+	if x != nil {
+		return x.ResourceCapacity
+	}
+	return nil
+}
+
+func (x *Node) GetResourceLoad() map[string]float64 {
+	if x != nil {
+		return x.ResourceLoad
+	}
+	return nil
+}
+
+func (x *Node) GetResourceHeadroom() map[string]float64 {
+	if x != nil {
+		return x.ResourceHeadroom
+	}
+	return nil
+}
+
+func (x *Node) GetCurrentLoad() int64 {
+	if x != nil {
+		return x.CurrentLoad
+	}
+	return 0
+}
+
+func (x *Node) GetLastHeartbeatUnixMs() int64 {
+	if x != nil {
+		return x.LastHeartbeatUnixMs
+	}
+	return 0
+}
+
+func (x *Node) GetFaultDomain() string {
+	if x != nil {
+		return x.FaultDomain
+	}
+	return ""
+}
+
 type Shard struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
-	Size          int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
-	NodeId        string                 `protobuf:"bytes,4,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
-	Status        string                 `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Id             string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type           string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Size           int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	NodeId         string                 `protobuf:"bytes,4,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	Status         string                 `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	ResourceDemand map[string]float64     `protobuf:"bytes,6,rep,name=resource_demand,json=resourceDemand,proto3" json:"resource_demand,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed64,2,opt,name=value"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
 }
 
 func (x *Shard) Reset() {
@@ -165,6 +231,65 @@ func (x *Shard) GetStatus() string {
 	return ""
 }
 
+func (x *Shard) GetResourceDemand() map[string]float64 {
+	if x != nil {
+		return x.ResourceDemand
+	}
+	return nil
+}
+
+type ShardReplica struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShardId       string                 `protobuf:"bytes,1,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
+	Role          string                 `protobuf:"bytes,2,opt,name=role,proto3" json:"role,omitempty"` // "primary" or "secondary"
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ShardReplica) Reset() {
+	*x = ShardReplica{}
+	mi := &file_shardmanager_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShardReplica) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardReplica) ProtoMessage() {}
+
+func (x *ShardReplica) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardReplica.ProtoReflect.Descriptor instead.
+func (*ShardReplica) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ShardReplica) GetShardId() string {
+	if x != nil {
+		return x.ShardId
+	}
+	return ""
+}
+
+func (x *ShardReplica) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
 // NodeService messages
 type RegisterNodeRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -175,7 +300,7 @@ type RegisterNodeRequest struct {
 
 func (x *RegisterNodeRequest) Reset() {
 	*x = RegisterNodeRequest{}
-	mi := &file_shardmanager_proto_msgTypes[2]
+	mi := &file_shardmanager_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -187,7 +312,7 @@ func (x *RegisterNodeRequest) String() string {
 func (*RegisterNodeRequest) ProtoMessage() {}
 
 func (x *RegisterNodeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[2]
+	mi := &file_shardmanager_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -200,7 +325,7 @@ func (x *RegisterNodeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RegisterNodeRequest.ProtoReflect.Descriptor instead.
 func (*RegisterNodeRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{2}
+	return file_shardmanager_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *RegisterNodeRequest) GetNode() *Node {
@@ -214,13 +339,14 @@ type RegisterNodeResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
 	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	NodeId        string                 `protobuf:"bytes,3,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *RegisterNodeResponse) Reset() {
 	*x = RegisterNodeResponse{}
-	mi := &file_shardmanager_proto_msgTypes[3]
+	mi := &file_shardmanager_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -232,7 +358,7 @@ func (x *RegisterNodeResponse) String() string {
 func (*RegisterNodeResponse) ProtoMessage() {}
 
 func (x *RegisterNodeResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[3]
+	mi := &file_shardmanager_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -245,7 +371,7 @@ func (x *RegisterNodeResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RegisterNodeResponse.ProtoReflect.Descriptor instead.
 func (*RegisterNodeResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{3}
+	return file_shardmanager_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *RegisterNodeResponse) GetSuccess() bool {
@@ -262,18 +388,28 @@ func (x *RegisterNodeResponse) GetMessage() string {
 	return ""
 }
 
+func (x *RegisterNodeResponse) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
 type HeartbeatRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	NodeId        string                 `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
 	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
 	Load          int64                  `protobuf:"varint,3,opt,name=load,proto3" json:"load,omitempty"`
+	Shards        []*ShardReplica        `protobuf:"bytes,4,rep,name=shards,proto3" json:"shards,omitempty"` // current shard set, reported by pull-mode nodes
+	ResourceLoad  map[string]float64     `protobuf:"bytes,5,rep,name=resource_load,json=resourceLoad,proto3" json:"resource_load,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed64,2,opt,name=value"`
+	ShardLoads    []*ShardLoad           `protobuf:"bytes,6,rep,name=shard_loads,json=shardLoads,proto3" json:"shard_loads,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *HeartbeatRequest) Reset() {
 	*x = HeartbeatRequest{}
-	mi := &file_shardmanager_proto_msgTypes[4]
+	mi := &file_shardmanager_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -285,7 +421,7 @@ func (x *HeartbeatRequest) String() string {
 func (*HeartbeatRequest) ProtoMessage() {}
 
 func (x *HeartbeatRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[4]
+	mi := &file_shardmanager_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -298,7 +434,7 @@ func (x *HeartbeatRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HeartbeatRequest.ProtoReflect.Descriptor instead.
 func (*HeartbeatRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{4}
+	return file_shardmanager_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *HeartbeatRequest) GetNodeId() string {
@@ -322,16 +458,115 @@ func (x *HeartbeatRequest) GetLoad() int64 {
 	return 0
 }
 
+func (x *HeartbeatRequest) GetShards() []*ShardReplica {
+	if x != nil {
+		return x.Shards
+	}
+	return nil
+}
+
+func (x *HeartbeatRequest) GetResourceLoad() map[string]float64 {
+	if x != nil {
+		return x.ResourceLoad
+	}
+	return nil
+}
+
+func (x *HeartbeatRequest) GetShardLoads() []*ShardLoad {
+	if x != nil {
+		return x.ShardLoads
+	}
+	return nil
+}
+
+type ShardLoad struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ShardId        string                 `protobuf:"bytes,1,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
+	Qps            float64                `protobuf:"fixed64,2,opt,name=qps,proto3" json:"qps,omitempty"`
+	BytesPerSecond int64                  `protobuf:"varint,3,opt,name=bytes_per_second,json=bytesPerSecond,proto3" json:"bytes_per_second,omitempty"`
+	CpuShare       float64                `protobuf:"fixed64,4,opt,name=cpu_share,json=cpuShare,proto3" json:"cpu_share,omitempty"` // fraction of the node's CPU used by this shard
+	SizeBytes      int64                  `protobuf:"varint,5,opt,name=size_bytes,json=sizeBytes,proto3" json:"size_bytes,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ShardLoad) Reset() {
+	*x = ShardLoad{}
+	mi := &file_shardmanager_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShardLoad) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardLoad) ProtoMessage() {}
+
+func (x *ShardLoad) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardLoad.ProtoReflect.Descriptor instead.
+func (*ShardLoad) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ShardLoad) GetShardId() string {
+	if x != nil {
+		return x.ShardId
+	}
+	return ""
+}
+
+func (x *ShardLoad) GetQps() float64 {
+	if x != nil {
+		return x.Qps
+	}
+	return 0
+}
+
+func (x *ShardLoad) GetBytesPerSecond() int64 {
+	if x != nil {
+		return x.BytesPerSecond
+	}
+	return 0
+}
+
+func (x *ShardLoad) GetCpuShare() float64 {
+	if x != nil {
+		return x.CpuShare
+	}
+	return 0
+}
+
+func (x *ShardLoad) GetSizeBytes() int64 {
+	if x != nil {
+		return x.SizeBytes
+	}
+	return 0
+}
+
 type HeartbeatResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Leases        []*ShardLease          `protobuf:"bytes,2,rep,name=leases,proto3" json:"leases,omitempty"`
+	DesiredShards []*ShardReplica        `protobuf:"bytes,3,rep,name=desired_shards,json=desiredShards,proto3" json:"desired_shards,omitempty"` // only set for pull-mode nodes
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *HeartbeatResponse) Reset() {
 	*x = HeartbeatResponse{}
-	mi := &file_shardmanager_proto_msgTypes[5]
+	mi := &file_shardmanager_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -343,7 +578,7 @@ func (x *HeartbeatResponse) String() string {
 func (*HeartbeatResponse) ProtoMessage() {}
 
 func (x *HeartbeatResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[5]
+	mi := &file_shardmanager_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -356,7 +591,7 @@ func (x *HeartbeatResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use HeartbeatResponse.ProtoReflect.Descriptor instead.
 func (*HeartbeatResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{5}
+	return file_shardmanager_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *HeartbeatResponse) GetSuccess() bool {
@@ -366,6 +601,72 @@ func (x *HeartbeatResponse) GetSuccess() bool {
 	return false
 }
 
+func (x *HeartbeatResponse) GetLeases() []*ShardLease {
+	if x != nil {
+		return x.Leases
+	}
+	return nil
+}
+
+func (x *HeartbeatResponse) GetDesiredShards() []*ShardReplica {
+	if x != nil {
+		return x.DesiredShards
+	}
+	return nil
+}
+
+type ShardLease struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	ShardId         string                 `protobuf:"bytes,1,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
+	ExpiresAtUnixMs int64                  `protobuf:"varint,2,opt,name=expires_at_unix_ms,json=expiresAtUnixMs,proto3" json:"expires_at_unix_ms,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *ShardLease) Reset() {
+	*x = ShardLease{}
+	mi := &file_shardmanager_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShardLease) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardLease) ProtoMessage() {}
+
+func (x *ShardLease) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardLease.ProtoReflect.Descriptor instead.
+func (*ShardLease) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ShardLease) GetShardId() string {
+	if x != nil {
+		return x.ShardId
+	}
+	return ""
+}
+
+func (x *ShardLease) GetExpiresAtUnixMs() int64 {
+	if x != nil {
+		return x.ExpiresAtUnixMs
+	}
+	return 0
+}
+
 type ListNodesRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
@@ -374,7 +675,7 @@ type ListNodesRequest struct {
 
 func (x *ListNodesRequest) Reset() {
 	*x = ListNodesRequest{}
-	mi := &file_shardmanager_proto_msgTypes[6]
+	mi := &file_shardmanager_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -386,7 +687,7 @@ func (x *ListNodesRequest) String() string {
 func (*ListNodesRequest) ProtoMessage() {}
 
 func (x *ListNodesRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[6]
+	mi := &file_shardmanager_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -399,7 +700,7 @@ func (x *ListNodesRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListNodesRequest.ProtoReflect.Descriptor instead.
 func (*ListNodesRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{6}
+	return file_shardmanager_proto_rawDescGZIP(), []int{9}
 }
 
 type ListNodesResponse struct {
@@ -411,7 +712,7 @@ type ListNodesResponse struct {
 
 func (x *ListNodesResponse) Reset() {
 	*x = ListNodesResponse{}
-	mi := &file_shardmanager_proto_msgTypes[7]
+	mi := &file_shardmanager_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -423,7 +724,7 @@ func (x *ListNodesResponse) String() string {
 func (*ListNodesResponse) ProtoMessage() {}
 
 func (x *ListNodesResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[7]
+	mi := &file_shardmanager_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -436,7 +737,7 @@ func (x *ListNodesResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListNodesResponse.ProtoReflect.Descriptor instead.
 func (*ListNodesResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{7}
+	return file_shardmanager_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *ListNodesResponse) GetNodes() []*Node {
@@ -446,17 +747,225 @@ func (x *ListNodesResponse) GetNodes() []*Node {
 	return nil
 }
 
-// ShardService messages
-type RegisterShardRequest struct {
+type DrainNodeRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Shard         *Shard                 `protobuf:"bytes,1,opt,name=shard,proto3" json:"shard,omitempty"`
+	NodeId        string                 `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *RegisterShardRequest) Reset() {
-	*x = RegisterShardRequest{}
-	mi := &file_shardmanager_proto_msgTypes[8]
+func (x *DrainNodeRequest) Reset() {
+	*x = DrainNodeRequest{}
+	mi := &file_shardmanager_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DrainNodeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DrainNodeRequest) ProtoMessage() {}
+
+func (x *DrainNodeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DrainNodeRequest.ProtoReflect.Descriptor instead.
+func (*DrainNodeRequest) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *DrainNodeRequest) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+type DrainNodeResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Success        bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message        string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	MigratedShards []string               `protobuf:"bytes,3,rep,name=migrated_shards,json=migratedShards,proto3" json:"migrated_shards,omitempty"`
+	FailedShards   []string               `protobuf:"bytes,4,rep,name=failed_shards,json=failedShards,proto3" json:"failed_shards,omitempty"` // left on the node, see message
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *DrainNodeResponse) Reset() {
+	*x = DrainNodeResponse{}
+	mi := &file_shardmanager_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DrainNodeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DrainNodeResponse) ProtoMessage() {}
+
+func (x *DrainNodeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DrainNodeResponse.ProtoReflect.Descriptor instead.
+func (*DrainNodeResponse) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *DrainNodeResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *DrainNodeResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *DrainNodeResponse) GetMigratedShards() []string {
+	if x != nil {
+		return x.MigratedShards
+	}
+	return nil
+}
+
+func (x *DrainNodeResponse) GetFailedShards() []string {
+	if x != nil {
+		return x.FailedShards
+	}
+	return nil
+}
+
+type UndrainNodeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	NodeId        string                 `protobuf:"bytes,1,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UndrainNodeRequest) Reset() {
+	*x = UndrainNodeRequest{}
+	mi := &file_shardmanager_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UndrainNodeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UndrainNodeRequest) ProtoMessage() {}
+
+func (x *UndrainNodeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UndrainNodeRequest.ProtoReflect.Descriptor instead.
+func (*UndrainNodeRequest) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *UndrainNodeRequest) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+type UndrainNodeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UndrainNodeResponse) Reset() {
+	*x = UndrainNodeResponse{}
+	mi := &file_shardmanager_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UndrainNodeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UndrainNodeResponse) ProtoMessage() {}
+
+func (x *UndrainNodeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UndrainNodeResponse.ProtoReflect.Descriptor instead.
+func (*UndrainNodeResponse) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *UndrainNodeResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *UndrainNodeResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// ShardService messages
+type RegisterShardRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Shard         *Shard                 `protobuf:"bytes,1,opt,name=shard,proto3" json:"shard,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RegisterShardRequest) Reset() {
+	*x = RegisterShardRequest{}
+	mi := &file_shardmanager_proto_msgTypes[15]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -468,7 +977,7 @@ func (x *RegisterShardRequest) String() string {
 func (*RegisterShardRequest) ProtoMessage() {}
 
 func (x *RegisterShardRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[8]
+	mi := &file_shardmanager_proto_msgTypes[15]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -481,7 +990,7 @@ func (x *RegisterShardRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RegisterShardRequest.ProtoReflect.Descriptor instead.
 func (*RegisterShardRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{8}
+	return file_shardmanager_proto_rawDescGZIP(), []int{15}
 }
 
 func (x *RegisterShardRequest) GetShard() *Shard {
@@ -501,7 +1010,7 @@ type RegisterShardResponse struct {
 
 func (x *RegisterShardResponse) Reset() {
 	*x = RegisterShardResponse{}
-	mi := &file_shardmanager_proto_msgTypes[9]
+	mi := &file_shardmanager_proto_msgTypes[16]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -513,7 +1022,7 @@ func (x *RegisterShardResponse) String() string {
 func (*RegisterShardResponse) ProtoMessage() {}
 
 func (x *RegisterShardResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[9]
+	mi := &file_shardmanager_proto_msgTypes[16]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -526,7 +1035,7 @@ func (x *RegisterShardResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use RegisterShardResponse.ProtoReflect.Descriptor instead.
 func (*RegisterShardResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{9}
+	return file_shardmanager_proto_rawDescGZIP(), []int{16}
 }
 
 func (x *RegisterShardResponse) GetSuccess() bool {
@@ -551,7 +1060,7 @@ type ListShardsRequest struct {
 
 func (x *ListShardsRequest) Reset() {
 	*x = ListShardsRequest{}
-	mi := &file_shardmanager_proto_msgTypes[10]
+	mi := &file_shardmanager_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -563,7 +1072,7 @@ func (x *ListShardsRequest) String() string {
 func (*ListShardsRequest) ProtoMessage() {}
 
 func (x *ListShardsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[10]
+	mi := &file_shardmanager_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -576,7 +1085,7 @@ func (x *ListShardsRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListShardsRequest.ProtoReflect.Descriptor instead.
 func (*ListShardsRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{10}
+	return file_shardmanager_proto_rawDescGZIP(), []int{17}
 }
 
 type ListShardsResponse struct {
@@ -588,7 +1097,7 @@ type ListShardsResponse struct {
 
 func (x *ListShardsResponse) Reset() {
 	*x = ListShardsResponse{}
-	mi := &file_shardmanager_proto_msgTypes[11]
+	mi := &file_shardmanager_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -600,7 +1109,7 @@ func (x *ListShardsResponse) String() string {
 func (*ListShardsResponse) ProtoMessage() {}
 
 func (x *ListShardsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[11]
+	mi := &file_shardmanager_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -613,7 +1122,7 @@ func (x *ListShardsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListShardsResponse.ProtoReflect.Descriptor instead.
 func (*ListShardsResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{11}
+	return file_shardmanager_proto_rawDescGZIP(), []int{18}
 }
 
 func (x *ListShardsResponse) GetShards() []*Shard {
@@ -632,7 +1141,7 @@ type GetShardInfoRequest struct {
 
 func (x *GetShardInfoRequest) Reset() {
 	*x = GetShardInfoRequest{}
-	mi := &file_shardmanager_proto_msgTypes[12]
+	mi := &file_shardmanager_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -644,7 +1153,7 @@ func (x *GetShardInfoRequest) String() string {
 func (*GetShardInfoRequest) ProtoMessage() {}
 
 func (x *GetShardInfoRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[12]
+	mi := &file_shardmanager_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -657,7 +1166,7 @@ func (x *GetShardInfoRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetShardInfoRequest.ProtoReflect.Descriptor instead.
 func (*GetShardInfoRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{12}
+	return file_shardmanager_proto_rawDescGZIP(), []int{19}
 }
 
 func (x *GetShardInfoRequest) GetShardId() string {
@@ -676,7 +1185,7 @@ type GetShardInfoResponse struct {
 
 func (x *GetShardInfoResponse) Reset() {
 	*x = GetShardInfoResponse{}
-	mi := &file_shardmanager_proto_msgTypes[13]
+	mi := &file_shardmanager_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -688,7 +1197,7 @@ func (x *GetShardInfoResponse) String() string {
 func (*GetShardInfoResponse) ProtoMessage() {}
 
 func (x *GetShardInfoResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[13]
+	mi := &file_shardmanager_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -701,7 +1210,7 @@ func (x *GetShardInfoResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetShardInfoResponse.ProtoReflect.Descriptor instead.
 func (*GetShardInfoResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{13}
+	return file_shardmanager_proto_rawDescGZIP(), []int{20}
 }
 
 func (x *GetShardInfoResponse) GetShard() *Shard {
@@ -721,7 +1230,7 @@ type AssignShardRequest struct {
 
 func (x *AssignShardRequest) Reset() {
 	*x = AssignShardRequest{}
-	mi := &file_shardmanager_proto_msgTypes[14]
+	mi := &file_shardmanager_proto_msgTypes[21]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -733,7 +1242,7 @@ func (x *AssignShardRequest) String() string {
 func (*AssignShardRequest) ProtoMessage() {}
 
 func (x *AssignShardRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[14]
+	mi := &file_shardmanager_proto_msgTypes[21]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -746,7 +1255,7 @@ func (x *AssignShardRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AssignShardRequest.ProtoReflect.Descriptor instead.
 func (*AssignShardRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{14}
+	return file_shardmanager_proto_rawDescGZIP(), []int{21}
 }
 
 func (x *AssignShardRequest) GetShardId() string {
@@ -773,7 +1282,7 @@ type AssignShardResponse struct {
 
 func (x *AssignShardResponse) Reset() {
 	*x = AssignShardResponse{}
-	mi := &file_shardmanager_proto_msgTypes[15]
+	mi := &file_shardmanager_proto_msgTypes[22]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -785,7 +1294,7 @@ func (x *AssignShardResponse) String() string {
 func (*AssignShardResponse) ProtoMessage() {}
 
 func (x *AssignShardResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[15]
+	mi := &file_shardmanager_proto_msgTypes[22]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -798,7 +1307,7 @@ func (x *AssignShardResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AssignShardResponse.ProtoReflect.Descriptor instead.
 func (*AssignShardResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{15}
+	return file_shardmanager_proto_rawDescGZIP(), []int{22}
 }
 
 func (x *AssignShardResponse) GetSuccess() bool {
@@ -826,7 +1335,7 @@ type MigrateShardRequest struct {
 
 func (x *MigrateShardRequest) Reset() {
 	*x = MigrateShardRequest{}
-	mi := &file_shardmanager_proto_msgTypes[16]
+	mi := &file_shardmanager_proto_msgTypes[23]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -838,7 +1347,7 @@ func (x *MigrateShardRequest) String() string {
 func (*MigrateShardRequest) ProtoMessage() {}
 
 func (x *MigrateShardRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[16]
+	mi := &file_shardmanager_proto_msgTypes[23]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -851,7 +1360,7 @@ func (x *MigrateShardRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MigrateShardRequest.ProtoReflect.Descriptor instead.
 func (*MigrateShardRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{16}
+	return file_shardmanager_proto_rawDescGZIP(), []int{23}
 }
 
 func (x *MigrateShardRequest) GetShardId() string {
@@ -885,7 +1394,7 @@ type MigrateShardResponse struct {
 
 func (x *MigrateShardResponse) Reset() {
 	*x = MigrateShardResponse{}
-	mi := &file_shardmanager_proto_msgTypes[17]
+	mi := &file_shardmanager_proto_msgTypes[24]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -897,7 +1406,7 @@ func (x *MigrateShardResponse) String() string {
 func (*MigrateShardResponse) ProtoMessage() {}
 
 func (x *MigrateShardResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[17]
+	mi := &file_shardmanager_proto_msgTypes[24]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -910,7 +1419,7 @@ func (x *MigrateShardResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use MigrateShardResponse.ProtoReflect.Descriptor instead.
 func (*MigrateShardResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{17}
+	return file_shardmanager_proto_rawDescGZIP(), []int{24}
 }
 
 func (x *MigrateShardResponse) GetSuccess() bool {
@@ -937,7 +1446,7 @@ type UpdateShardStatusRequest struct {
 
 func (x *UpdateShardStatusRequest) Reset() {
 	*x = UpdateShardStatusRequest{}
-	mi := &file_shardmanager_proto_msgTypes[18]
+	mi := &file_shardmanager_proto_msgTypes[25]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -949,7 +1458,7 @@ func (x *UpdateShardStatusRequest) String() string {
 func (*UpdateShardStatusRequest) ProtoMessage() {}
 
 func (x *UpdateShardStatusRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[18]
+	mi := &file_shardmanager_proto_msgTypes[25]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -962,7 +1471,7 @@ func (x *UpdateShardStatusRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateShardStatusRequest.ProtoReflect.Descriptor instead.
 func (*UpdateShardStatusRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{18}
+	return file_shardmanager_proto_rawDescGZIP(), []int{25}
 }
 
 func (x *UpdateShardStatusRequest) GetShardId() string {
@@ -989,7 +1498,7 @@ type UpdateShardStatusResponse struct {
 
 func (x *UpdateShardStatusResponse) Reset() {
 	*x = UpdateShardStatusResponse{}
-	mi := &file_shardmanager_proto_msgTypes[19]
+	mi := &file_shardmanager_proto_msgTypes[26]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1001,7 +1510,7 @@ func (x *UpdateShardStatusResponse) String() string {
 func (*UpdateShardStatusResponse) ProtoMessage() {}
 
 func (x *UpdateShardStatusResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[19]
+	mi := &file_shardmanager_proto_msgTypes[26]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1014,7 +1523,7 @@ func (x *UpdateShardStatusResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateShardStatusResponse.ProtoReflect.Descriptor instead.
 func (*UpdateShardStatusResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{19}
+	return file_shardmanager_proto_rawDescGZIP(), []int{26}
 }
 
 func (x *UpdateShardStatusResponse) GetSuccess() bool {
@@ -1031,30 +1540,28 @@ func (x *UpdateShardStatusResponse) GetMessage() string {
 	return ""
 }
 
-// PolicyService messages
-type SetPolicyRequest struct {
+type GetShardHistoryRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	PolicyType    string                 `protobuf:"bytes,1,opt,name=policy_type,json=policyType,proto3" json:"policy_type,omitempty"`
-	Parameters    string                 `protobuf:"bytes,2,opt,name=parameters,proto3" json:"parameters,omitempty"`
+	ShardId       string                 `protobuf:"bytes,1,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *SetPolicyRequest) Reset() {
-	*x = SetPolicyRequest{}
-	mi := &file_shardmanager_proto_msgTypes[20]
+func (x *GetShardHistoryRequest) Reset() {
+	*x = GetShardHistoryRequest{}
+	mi := &file_shardmanager_proto_msgTypes[27]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *SetPolicyRequest) String() string {
+func (x *GetShardHistoryRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*SetPolicyRequest) ProtoMessage() {}
+func (*GetShardHistoryRequest) ProtoMessage() {}
 
-func (x *SetPolicyRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[20]
+func (x *GetShardHistoryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[27]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1065,20 +1572,298 @@ func (x *SetPolicyRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use SetPolicyRequest.ProtoReflect.Descriptor instead.
-func (*SetPolicyRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{20}
+// Deprecated: Use GetShardHistoryRequest.ProtoReflect.Descriptor instead.
+func (*GetShardHistoryRequest) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{27}
 }
 
-func (x *SetPolicyRequest) GetPolicyType() string {
+func (x *GetShardHistoryRequest) GetShardId() string {
 	if x != nil {
-		return x.PolicyType
+		return x.ShardId
 	}
 	return ""
 }
 
-func (x *SetPolicyRequest) GetParameters() string {
-	if x != nil {
+type GetShardHistoryResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Versions      []*ShardVersion        `protobuf:"bytes,1,rep,name=versions,proto3" json:"versions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetShardHistoryResponse) Reset() {
+	*x = GetShardHistoryResponse{}
+	mi := &file_shardmanager_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetShardHistoryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetShardHistoryResponse) ProtoMessage() {}
+
+func (x *GetShardHistoryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetShardHistoryResponse.ProtoReflect.Descriptor instead.
+func (*GetShardHistoryResponse) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *GetShardHistoryResponse) GetVersions() []*ShardVersion {
+	if x != nil {
+		return x.Versions
+	}
+	return nil
+}
+
+type ShardVersion struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Version          int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Type             string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Size             int64                  `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	NodeId           string                 `protobuf:"bytes,4,opt,name=node_id,json=nodeId,proto3" json:"node_id,omitempty"`
+	Status           string                 `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	RecordedAtUnixMs int64                  `protobuf:"varint,6,opt,name=recorded_at_unix_ms,json=recordedAtUnixMs,proto3" json:"recorded_at_unix_ms,omitempty"` // when this version was superseded
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *ShardVersion) Reset() {
+	*x = ShardVersion{}
+	mi := &file_shardmanager_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShardVersion) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardVersion) ProtoMessage() {}
+
+func (x *ShardVersion) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardVersion.ProtoReflect.Descriptor instead.
+func (*ShardVersion) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *ShardVersion) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *ShardVersion) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ShardVersion) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *ShardVersion) GetNodeId() string {
+	if x != nil {
+		return x.NodeId
+	}
+	return ""
+}
+
+func (x *ShardVersion) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ShardVersion) GetRecordedAtUnixMs() int64 {
+	if x != nil {
+		return x.RecordedAtUnixMs
+	}
+	return 0
+}
+
+type RollbackShardRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShardId       string                 `protobuf:"bytes,1,opt,name=shard_id,json=shardId,proto3" json:"shard_id,omitempty"`
+	Version       int32                  `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RollbackShardRequest) Reset() {
+	*x = RollbackShardRequest{}
+	mi := &file_shardmanager_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RollbackShardRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RollbackShardRequest) ProtoMessage() {}
+
+func (x *RollbackShardRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RollbackShardRequest.ProtoReflect.Descriptor instead.
+func (*RollbackShardRequest) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *RollbackShardRequest) GetShardId() string {
+	if x != nil {
+		return x.ShardId
+	}
+	return ""
+}
+
+func (x *RollbackShardRequest) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+type RollbackShardResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RollbackShardResponse) Reset() {
+	*x = RollbackShardResponse{}
+	mi := &file_shardmanager_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RollbackShardResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RollbackShardResponse) ProtoMessage() {}
+
+func (x *RollbackShardResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RollbackShardResponse.ProtoReflect.Descriptor instead.
+func (*RollbackShardResponse) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *RollbackShardResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *RollbackShardResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// PolicyService messages
+type SetPolicyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	PolicyType    string                 `protobuf:"bytes,1,opt,name=policy_type,json=policyType,proto3" json:"policy_type,omitempty"`
+	Parameters    string                 `protobuf:"bytes,2,opt,name=parameters,proto3" json:"parameters,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetPolicyRequest) Reset() {
+	*x = SetPolicyRequest{}
+	mi := &file_shardmanager_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetPolicyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetPolicyRequest) ProtoMessage() {}
+
+func (x *SetPolicyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetPolicyRequest.ProtoReflect.Descriptor instead.
+func (*SetPolicyRequest) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *SetPolicyRequest) GetPolicyType() string {
+	if x != nil {
+		return x.PolicyType
+	}
+	return ""
+}
+
+func (x *SetPolicyRequest) GetParameters() string {
+	if x != nil {
 		return x.Parameters
 	}
 	return ""
@@ -1094,7 +1879,7 @@ type SetPolicyResponse struct {
 
 func (x *SetPolicyResponse) Reset() {
 	*x = SetPolicyResponse{}
-	mi := &file_shardmanager_proto_msgTypes[21]
+	mi := &file_shardmanager_proto_msgTypes[33]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1106,7 +1891,7 @@ func (x *SetPolicyResponse) String() string {
 func (*SetPolicyResponse) ProtoMessage() {}
 
 func (x *SetPolicyResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[21]
+	mi := &file_shardmanager_proto_msgTypes[33]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1119,7 +1904,7 @@ func (x *SetPolicyResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SetPolicyResponse.ProtoReflect.Descriptor instead.
 func (*SetPolicyResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{21}
+	return file_shardmanager_proto_rawDescGZIP(), []int{33}
 }
 
 func (x *SetPolicyResponse) GetSuccess() bool {
@@ -1145,7 +1930,7 @@ type GetPolicyRequest struct {
 
 func (x *GetPolicyRequest) Reset() {
 	*x = GetPolicyRequest{}
-	mi := &file_shardmanager_proto_msgTypes[22]
+	mi := &file_shardmanager_proto_msgTypes[34]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1157,7 +1942,7 @@ func (x *GetPolicyRequest) String() string {
 func (*GetPolicyRequest) ProtoMessage() {}
 
 func (x *GetPolicyRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[22]
+	mi := &file_shardmanager_proto_msgTypes[34]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1170,7 +1955,7 @@ func (x *GetPolicyRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetPolicyRequest.ProtoReflect.Descriptor instead.
 func (*GetPolicyRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{22}
+	return file_shardmanager_proto_rawDescGZIP(), []int{34}
 }
 
 func (x *GetPolicyRequest) GetPolicyType() string {
@@ -1190,7 +1975,7 @@ type GetPolicyResponse struct {
 
 func (x *GetPolicyResponse) Reset() {
 	*x = GetPolicyResponse{}
-	mi := &file_shardmanager_proto_msgTypes[23]
+	mi := &file_shardmanager_proto_msgTypes[35]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1202,7 +1987,7 @@ func (x *GetPolicyResponse) String() string {
 func (*GetPolicyResponse) ProtoMessage() {}
 
 func (x *GetPolicyResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[23]
+	mi := &file_shardmanager_proto_msgTypes[35]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1215,7 +2000,7 @@ func (x *GetPolicyResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetPolicyResponse.ProtoReflect.Descriptor instead.
 func (*GetPolicyResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{23}
+	return file_shardmanager_proto_rawDescGZIP(), []int{35}
 }
 
 func (x *GetPolicyResponse) GetPolicyType() string {
@@ -1241,7 +2026,7 @@ type GetDistributionRequest struct {
 
 func (x *GetDistributionRequest) Reset() {
 	*x = GetDistributionRequest{}
-	mi := &file_shardmanager_proto_msgTypes[24]
+	mi := &file_shardmanager_proto_msgTypes[36]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1253,7 +2038,7 @@ func (x *GetDistributionRequest) String() string {
 func (*GetDistributionRequest) ProtoMessage() {}
 
 func (x *GetDistributionRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[24]
+	mi := &file_shardmanager_proto_msgTypes[36]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1266,7 +2051,7 @@ func (x *GetDistributionRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetDistributionRequest.ProtoReflect.Descriptor instead.
 func (*GetDistributionRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{24}
+	return file_shardmanager_proto_rawDescGZIP(), []int{36}
 }
 
 type GetDistributionResponse struct {
@@ -1278,7 +2063,7 @@ type GetDistributionResponse struct {
 
 func (x *GetDistributionResponse) Reset() {
 	*x = GetDistributionResponse{}
-	mi := &file_shardmanager_proto_msgTypes[25]
+	mi := &file_shardmanager_proto_msgTypes[37]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1290,7 +2075,7 @@ func (x *GetDistributionResponse) String() string {
 func (*GetDistributionResponse) ProtoMessage() {}
 
 func (x *GetDistributionResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[25]
+	mi := &file_shardmanager_proto_msgTypes[37]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1303,38 +2088,354 @@ func (x *GetDistributionResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetDistributionResponse.ProtoReflect.Descriptor instead.
 func (*GetDistributionResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{25}
+	return file_shardmanager_proto_rawDescGZIP(), []int{37}
 }
 
 func (x *GetDistributionResponse) GetNodeShards() map[string]*ShardList {
 	if x != nil {
 		return x.NodeShards
 	}
-	return nil
+	return nil
+}
+
+type ShardList struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShardIds      []string               `protobuf:"bytes,1,rep,name=shard_ids,json=shardIds,proto3" json:"shard_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ShardList) Reset() {
+	*x = ShardList{}
+	mi := &file_shardmanager_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShardList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShardList) ProtoMessage() {}
+
+func (x *ShardList) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShardList.ProtoReflect.Descriptor instead.
+func (*ShardList) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *ShardList) GetShardIds() []string {
+	if x != nil {
+		return x.ShardIds
+	}
+	return nil
+}
+
+type GetHealthRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetHealthRequest) Reset() {
+	*x = GetHealthRequest{}
+	mi := &file_shardmanager_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetHealthRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetHealthRequest) ProtoMessage() {}
+
+func (x *GetHealthRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetHealthRequest.ProtoReflect.Descriptor instead.
+func (*GetHealthRequest) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{39}
+}
+
+type GetHealthResponse struct {
+	state                protoimpl.MessageState `protogen:"open.v1"`
+	Summary              string                 `protobuf:"bytes,1,opt,name=summary,proto3" json:"summary,omitempty"`
+	Status               string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"` // OK, DEGRADED or CRITICAL
+	DbReachable          bool                   `protobuf:"varint,3,opt,name=db_reachable,json=dbReachable,proto3" json:"db_reachable,omitempty"`
+	DbError              string                 `protobuf:"bytes,4,opt,name=db_error,json=dbError,proto3" json:"db_error,omitempty"`
+	TotalNodes           int64                  `protobuf:"varint,5,opt,name=total_nodes,json=totalNodes,proto3" json:"total_nodes,omitempty"`
+	ActiveNodes          int64                  `protobuf:"varint,6,opt,name=active_nodes,json=activeNodes,proto3" json:"active_nodes,omitempty"`
+	TotalShards          int64                  `protobuf:"varint,7,opt,name=total_shards,json=totalShards,proto3" json:"total_shards,omitempty"`
+	StaleNodes           []string               `protobuf:"bytes,8,rep,name=stale_nodes,json=staleNodes,proto3" json:"stale_nodes,omitempty"` // active nodes whose heartbeat is overdue
+	FailedNodes          []string               `protobuf:"bytes,9,rep,name=failed_nodes,json=failedNodes,proto3" json:"failed_nodes,omitempty"`
+	UnassignedShards     []string               `protobuf:"bytes,10,rep,name=unassigned_shards,json=unassignedShards,proto3" json:"unassigned_shards,omitempty"`
+	StuckMigratingShards []string               `protobuf:"bytes,11,rep,name=stuck_migrating_shards,json=stuckMigratingShards,proto3" json:"stuck_migrating_shards,omitempty"`
+	// shards whose live replica count differs from the replication factor
+	ReplicaViolations []string `protobuf:"bytes,12,rep,name=replica_violations,json=replicaViolations,proto3" json:"replica_violations,omitempty"`
+	CheckedAtUnixMs   int64    `protobuf:"varint,13,opt,name=checked_at_unix_ms,json=checkedAtUnixMs,proto3" json:"checked_at_unix_ms,omitempty"`
+	UnavailableShards []string `protobuf:"bytes,14,rep,name=unavailable_shards,json=unavailableShards,proto3" json:"unavailable_shards,omitempty"` // assigned shards with no live replica
+	// shards with two live replicas in the same fault domain
+	FaultDomainViolations []string `protobuf:"bytes,15,rep,name=fault_domain_violations,json=faultDomainViolations,proto3" json:"fault_domain_violations,omitempty"`
+	OutboxBacklog         int64    `protobuf:"varint,16,opt,name=outbox_backlog,json=outboxBacklog,proto3" json:"outbox_backlog,omitempty"` // app server notifications not yet delivered
+	OutboxOldestAgeMs     int64    `protobuf:"varint,17,opt,name=outbox_oldest_age_ms,json=outboxOldestAgeMs,proto3" json:"outbox_oldest_age_ms,omitempty"`
+	unknownFields         protoimpl.UnknownFields
+	sizeCache             protoimpl.SizeCache
+}
+
+func (x *GetHealthResponse) Reset() {
+	*x = GetHealthResponse{}
+	mi := &file_shardmanager_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetHealthResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetHealthResponse) ProtoMessage() {}
+
+func (x *GetHealthResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetHealthResponse.ProtoReflect.Descriptor instead.
+func (*GetHealthResponse) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *GetHealthResponse) GetSummary() string {
+	if x != nil {
+		return x.Summary
+	}
+	return ""
+}
+
+func (x *GetHealthResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *GetHealthResponse) GetDbReachable() bool {
+	if x != nil {
+		return x.DbReachable
+	}
+	return false
+}
+
+func (x *GetHealthResponse) GetDbError() string {
+	if x != nil {
+		return x.DbError
+	}
+	return ""
+}
+
+func (x *GetHealthResponse) GetTotalNodes() int64 {
+	if x != nil {
+		return x.TotalNodes
+	}
+	return 0
+}
+
+func (x *GetHealthResponse) GetActiveNodes() int64 {
+	if x != nil {
+		return x.ActiveNodes
+	}
+	return 0
+}
+
+func (x *GetHealthResponse) GetTotalShards() int64 {
+	if x != nil {
+		return x.TotalShards
+	}
+	return 0
+}
+
+func (x *GetHealthResponse) GetStaleNodes() []string {
+	if x != nil {
+		return x.StaleNodes
+	}
+	return nil
+}
+
+func (x *GetHealthResponse) GetFailedNodes() []string {
+	if x != nil {
+		return x.FailedNodes
+	}
+	return nil
+}
+
+func (x *GetHealthResponse) GetUnassignedShards() []string {
+	if x != nil {
+		return x.UnassignedShards
+	}
+	return nil
+}
+
+func (x *GetHealthResponse) GetStuckMigratingShards() []string {
+	if x != nil {
+		return x.StuckMigratingShards
+	}
+	return nil
+}
+
+func (x *GetHealthResponse) GetReplicaViolations() []string {
+	if x != nil {
+		return x.ReplicaViolations
+	}
+	return nil
+}
+
+func (x *GetHealthResponse) GetCheckedAtUnixMs() int64 {
+	if x != nil {
+		return x.CheckedAtUnixMs
+	}
+	return 0
+}
+
+func (x *GetHealthResponse) GetUnavailableShards() []string {
+	if x != nil {
+		return x.UnavailableShards
+	}
+	return nil
+}
+
+func (x *GetHealthResponse) GetFaultDomainViolations() []string {
+	if x != nil {
+		return x.FaultDomainViolations
+	}
+	return nil
+}
+
+func (x *GetHealthResponse) GetOutboxBacklog() int64 {
+	if x != nil {
+		return x.OutboxBacklog
+	}
+	return 0
+}
+
+func (x *GetHealthResponse) GetOutboxOldestAgeMs() int64 {
+	if x != nil {
+		return x.OutboxOldestAgeMs
+	}
+	return 0
+}
+
+// FailureService messages
+type ReportFailureRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Details       string                 `protobuf:"bytes,3,opt,name=details,proto3" json:"details,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReportFailureRequest) Reset() {
+	*x = ReportFailureRequest{}
+	mi := &file_shardmanager_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReportFailureRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReportFailureRequest) ProtoMessage() {}
+
+func (x *ReportFailureRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReportFailureRequest.ProtoReflect.Descriptor instead.
+func (*ReportFailureRequest) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{41}
+}
+
+func (x *ReportFailureRequest) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ReportFailureRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ReportFailureRequest) GetDetails() string {
+	if x != nil {
+		return x.Details
+	}
+	return ""
 }
 
-type ShardList struct {
+type ReportFailureResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	ShardIds      []string               `protobuf:"bytes,1,rep,name=shard_ids,json=shardIds,proto3" json:"shard_ids,omitempty"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ShardList) Reset() {
-	*x = ShardList{}
-	mi := &file_shardmanager_proto_msgTypes[26]
+func (x *ReportFailureResponse) Reset() {
+	*x = ReportFailureResponse{}
+	mi := &file_shardmanager_proto_msgTypes[42]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ShardList) String() string {
+func (x *ReportFailureResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ShardList) ProtoMessage() {}
+func (*ReportFailureResponse) ProtoMessage() {}
 
-func (x *ShardList) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[26]
+func (x *ReportFailureResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[42]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1345,39 +2446,56 @@ func (x *ShardList) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ShardList.ProtoReflect.Descriptor instead.
-func (*ShardList) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{26}
+// Deprecated: Use ReportFailureResponse.ProtoReflect.Descriptor instead.
+func (*ReportFailureResponse) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{42}
 }
 
-func (x *ShardList) GetShardIds() []string {
+func (x *ReportFailureResponse) GetSuccess() bool {
 	if x != nil {
-		return x.ShardIds
+		return x.Success
 	}
-	return nil
+	return false
 }
 
-type GetHealthRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *ReportFailureResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
 }
 
-func (x *GetHealthRequest) Reset() {
-	*x = GetHealthRequest{}
-	mi := &file_shardmanager_proto_msgTypes[27]
+// AuditService messages
+type AuditEvent struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Actor           string                 `protobuf:"bytes,2,opt,name=actor,proto3" json:"actor,omitempty"`                             // caller identity
+	Method          string                 `protobuf:"bytes,3,opt,name=method,proto3" json:"method,omitempty"`                           // full gRPC method name
+	EntityType      string                 `protobuf:"bytes,4,opt,name=entity_type,json=entityType,proto3" json:"entity_type,omitempty"` // node, shard, policy, ...
+	EntityId        string                 `protobuf:"bytes,5,opt,name=entity_id,json=entityId,proto3" json:"entity_id,omitempty"`
+	Request         string                 `protobuf:"bytes,6,opt,name=request,proto3" json:"request,omitempty"` // request payload as JSON
+	Result          string                 `protobuf:"bytes,7,opt,name=result,proto3" json:"result,omitempty"`   // gRPC status code name, "OK" on success
+	Error           string                 `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`
+	CreatedAtUnixMs int64                  `protobuf:"varint,9,opt,name=created_at_unix_ms,json=createdAtUnixMs,proto3" json:"created_at_unix_ms,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *AuditEvent) Reset() {
+	*x = AuditEvent{}
+	mi := &file_shardmanager_proto_msgTypes[43]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *GetHealthRequest) String() string {
+func (x *AuditEvent) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*GetHealthRequest) ProtoMessage() {}
+func (*AuditEvent) ProtoMessage() {}
 
-func (x *GetHealthRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[27]
+func (x *AuditEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[43]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1388,80 +2506,100 @@ func (x *GetHealthRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use GetHealthRequest.ProtoReflect.Descriptor instead.
-func (*GetHealthRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{27}
+// Deprecated: Use AuditEvent.ProtoReflect.Descriptor instead.
+func (*AuditEvent) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{43}
 }
 
-type GetHealthResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Summary       string                 `protobuf:"bytes,1,opt,name=summary,proto3" json:"summary,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *AuditEvent) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
 }
 
-func (x *GetHealthResponse) Reset() {
-	*x = GetHealthResponse{}
-	mi := &file_shardmanager_proto_msgTypes[28]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+func (x *AuditEvent) GetActor() string {
+	if x != nil {
+		return x.Actor
+	}
+	return ""
 }
 
-func (x *GetHealthResponse) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *AuditEvent) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
 }
 
-func (*GetHealthResponse) ProtoMessage() {}
+func (x *AuditEvent) GetEntityType() string {
+	if x != nil {
+		return x.EntityType
+	}
+	return ""
+}
 
-func (x *GetHealthResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[28]
+func (x *AuditEvent) GetEntityId() string {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+		return x.EntityId
 	}
-	return mi.MessageOf(x)
+	return ""
 }
 
-// Deprecated: Use GetHealthResponse.ProtoReflect.Descriptor instead.
-func (*GetHealthResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{28}
+func (x *AuditEvent) GetRequest() string {
+	if x != nil {
+		return x.Request
+	}
+	return ""
 }
 
-func (x *GetHealthResponse) GetSummary() string {
+func (x *AuditEvent) GetResult() string {
 	if x != nil {
-		return x.Summary
+		return x.Result
 	}
 	return ""
 }
 
-// FailureService messages
-type ReportFailureRequest struct {
+func (x *AuditEvent) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *AuditEvent) GetCreatedAtUnixMs() int64 {
+	if x != nil {
+		return x.CreatedAtUnixMs
+	}
+	return 0
+}
+
+type ListAuditEventsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
-	Id            string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
-	Details       string                 `protobuf:"bytes,3,opt,name=details,proto3" json:"details,omitempty"`
+	EntityId      string                 `protobuf:"bytes,1,opt,name=entity_id,json=entityId,proto3" json:"entity_id,omitempty"`
+	Actor         string                 `protobuf:"bytes,2,opt,name=actor,proto3" json:"actor,omitempty"`
+	SinceUnixMs   int64                  `protobuf:"varint,3,opt,name=since_unix_ms,json=sinceUnixMs,proto3" json:"since_unix_ms,omitempty"` // inclusive; 0 for no lower bound
+	UntilUnixMs   int64                  `protobuf:"varint,4,opt,name=until_unix_ms,json=untilUnixMs,proto3" json:"until_unix_ms,omitempty"` // exclusive; 0 for no upper bound
+	Limit         int32                  `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`                                  // defaults to 100
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ReportFailureRequest) Reset() {
-	*x = ReportFailureRequest{}
-	mi := &file_shardmanager_proto_msgTypes[29]
+func (x *ListAuditEventsRequest) Reset() {
+	*x = ListAuditEventsRequest{}
+	mi := &file_shardmanager_proto_msgTypes[44]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ReportFailureRequest) String() string {
+func (x *ListAuditEventsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ReportFailureRequest) ProtoMessage() {}
+func (*ListAuditEventsRequest) ProtoMessage() {}
 
-func (x *ReportFailureRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[29]
+func (x *ListAuditEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[44]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1472,55 +2610,68 @@ func (x *ReportFailureRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ReportFailureRequest.ProtoReflect.Descriptor instead.
-func (*ReportFailureRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{29}
+// Deprecated: Use ListAuditEventsRequest.ProtoReflect.Descriptor instead.
+func (*ListAuditEventsRequest) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{44}
 }
 
-func (x *ReportFailureRequest) GetType() string {
+func (x *ListAuditEventsRequest) GetEntityId() string {
 	if x != nil {
-		return x.Type
+		return x.EntityId
 	}
 	return ""
 }
 
-func (x *ReportFailureRequest) GetId() string {
+func (x *ListAuditEventsRequest) GetActor() string {
 	if x != nil {
-		return x.Id
+		return x.Actor
 	}
 	return ""
 }
 
-func (x *ReportFailureRequest) GetDetails() string {
+func (x *ListAuditEventsRequest) GetSinceUnixMs() int64 {
 	if x != nil {
-		return x.Details
+		return x.SinceUnixMs
 	}
-	return ""
+	return 0
 }
 
-type ReportFailureResponse struct {
+func (x *ListAuditEventsRequest) GetUntilUnixMs() int64 {
+	if x != nil {
+		return x.UntilUnixMs
+	}
+	return 0
+}
+
+func (x *ListAuditEventsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type ListAuditEventsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
-	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Events        []*AuditEvent          `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ReportFailureResponse) Reset() {
-	*x = ReportFailureResponse{}
-	mi := &file_shardmanager_proto_msgTypes[30]
+func (x *ListAuditEventsResponse) Reset() {
+	*x = ListAuditEventsResponse{}
+	mi := &file_shardmanager_proto_msgTypes[45]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ReportFailureResponse) String() string {
+func (x *ListAuditEventsResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ReportFailureResponse) ProtoMessage() {}
+func (*ListAuditEventsResponse) ProtoMessage() {}
 
-func (x *ReportFailureResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[30]
+func (x *ListAuditEventsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[45]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1531,23 +2682,16 @@ func (x *ReportFailureResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ReportFailureResponse.ProtoReflect.Descriptor instead.
-func (*ReportFailureResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{30}
-}
-
-func (x *ReportFailureResponse) GetSuccess() bool {
-	if x != nil {
-		return x.Success
-	}
-	return false
+// Deprecated: Use ListAuditEventsResponse.ProtoReflect.Descriptor instead.
+func (*ListAuditEventsResponse) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{45}
 }
 
-func (x *ReportFailureResponse) GetMessage() string {
+func (x *ListAuditEventsResponse) GetEvents() []*AuditEvent {
 	if x != nil {
-		return x.Message
+		return x.Events
 	}
-	return ""
+	return nil
 }
 
 // AppShardService messages
@@ -1561,7 +2705,7 @@ type AddShardRequest struct {
 
 func (x *AddShardRequest) Reset() {
 	*x = AddShardRequest{}
-	mi := &file_shardmanager_proto_msgTypes[31]
+	mi := &file_shardmanager_proto_msgTypes[46]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1573,7 +2717,7 @@ func (x *AddShardRequest) String() string {
 func (*AddShardRequest) ProtoMessage() {}
 
 func (x *AddShardRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[31]
+	mi := &file_shardmanager_proto_msgTypes[46]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1586,7 +2730,7 @@ func (x *AddShardRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AddShardRequest.ProtoReflect.Descriptor instead.
 func (*AddShardRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{31}
+	return file_shardmanager_proto_rawDescGZIP(), []int{46}
 }
 
 func (x *AddShardRequest) GetShardId() string {
@@ -1613,7 +2757,7 @@ type AddShardResponse struct {
 
 func (x *AddShardResponse) Reset() {
 	*x = AddShardResponse{}
-	mi := &file_shardmanager_proto_msgTypes[32]
+	mi := &file_shardmanager_proto_msgTypes[47]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1625,7 +2769,7 @@ func (x *AddShardResponse) String() string {
 func (*AddShardResponse) ProtoMessage() {}
 
 func (x *AddShardResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[32]
+	mi := &file_shardmanager_proto_msgTypes[47]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1638,7 +2782,7 @@ func (x *AddShardResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use AddShardResponse.ProtoReflect.Descriptor instead.
 func (*AddShardResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{32}
+	return file_shardmanager_proto_rawDescGZIP(), []int{47}
 }
 
 func (x *AddShardResponse) GetSuccess() bool {
@@ -1664,7 +2808,7 @@ type DropShardRequest struct {
 
 func (x *DropShardRequest) Reset() {
 	*x = DropShardRequest{}
-	mi := &file_shardmanager_proto_msgTypes[33]
+	mi := &file_shardmanager_proto_msgTypes[48]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1676,7 +2820,7 @@ func (x *DropShardRequest) String() string {
 func (*DropShardRequest) ProtoMessage() {}
 
 func (x *DropShardRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[33]
+	mi := &file_shardmanager_proto_msgTypes[48]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1689,7 +2833,7 @@ func (x *DropShardRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DropShardRequest.ProtoReflect.Descriptor instead.
 func (*DropShardRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{33}
+	return file_shardmanager_proto_rawDescGZIP(), []int{48}
 }
 
 func (x *DropShardRequest) GetShardId() string {
@@ -1709,7 +2853,7 @@ type DropShardResponse struct {
 
 func (x *DropShardResponse) Reset() {
 	*x = DropShardResponse{}
-	mi := &file_shardmanager_proto_msgTypes[34]
+	mi := &file_shardmanager_proto_msgTypes[49]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1721,7 +2865,7 @@ func (x *DropShardResponse) String() string {
 func (*DropShardResponse) ProtoMessage() {}
 
 func (x *DropShardResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[34]
+	mi := &file_shardmanager_proto_msgTypes[49]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1734,7 +2878,7 @@ func (x *DropShardResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DropShardResponse.ProtoReflect.Descriptor instead.
 func (*DropShardResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{34}
+	return file_shardmanager_proto_rawDescGZIP(), []int{49}
 }
 
 func (x *DropShardResponse) GetSuccess() bool {
@@ -1762,7 +2906,7 @@ type ChangeRoleRequest struct {
 
 func (x *ChangeRoleRequest) Reset() {
 	*x = ChangeRoleRequest{}
-	mi := &file_shardmanager_proto_msgTypes[35]
+	mi := &file_shardmanager_proto_msgTypes[50]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1774,7 +2918,7 @@ func (x *ChangeRoleRequest) String() string {
 func (*ChangeRoleRequest) ProtoMessage() {}
 
 func (x *ChangeRoleRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[35]
+	mi := &file_shardmanager_proto_msgTypes[50]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1787,7 +2931,7 @@ func (x *ChangeRoleRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChangeRoleRequest.ProtoReflect.Descriptor instead.
 func (*ChangeRoleRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{35}
+	return file_shardmanager_proto_rawDescGZIP(), []int{50}
 }
 
 func (x *ChangeRoleRequest) GetShardId() string {
@@ -1821,7 +2965,7 @@ type ChangeRoleResponse struct {
 
 func (x *ChangeRoleResponse) Reset() {
 	*x = ChangeRoleResponse{}
-	mi := &file_shardmanager_proto_msgTypes[36]
+	mi := &file_shardmanager_proto_msgTypes[51]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1833,7 +2977,7 @@ func (x *ChangeRoleResponse) String() string {
 func (*ChangeRoleResponse) ProtoMessage() {}
 
 func (x *ChangeRoleResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[36]
+	mi := &file_shardmanager_proto_msgTypes[51]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1846,7 +2990,7 @@ func (x *ChangeRoleResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ChangeRoleResponse.ProtoReflect.Descriptor instead.
 func (*ChangeRoleResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{36}
+	return file_shardmanager_proto_rawDescGZIP(), []int{51}
 }
 
 func (x *ChangeRoleResponse) GetSuccess() bool {
@@ -1874,7 +3018,7 @@ type PrepareAddShardRequest struct {
 
 func (x *PrepareAddShardRequest) Reset() {
 	*x = PrepareAddShardRequest{}
-	mi := &file_shardmanager_proto_msgTypes[37]
+	mi := &file_shardmanager_proto_msgTypes[52]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1886,7 +3030,7 @@ func (x *PrepareAddShardRequest) String() string {
 func (*PrepareAddShardRequest) ProtoMessage() {}
 
 func (x *PrepareAddShardRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[37]
+	mi := &file_shardmanager_proto_msgTypes[52]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1899,7 +3043,7 @@ func (x *PrepareAddShardRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PrepareAddShardRequest.ProtoReflect.Descriptor instead.
 func (*PrepareAddShardRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{37}
+	return file_shardmanager_proto_rawDescGZIP(), []int{52}
 }
 
 func (x *PrepareAddShardRequest) GetShardId() string {
@@ -1933,7 +3077,7 @@ type PrepareAddShardResponse struct {
 
 func (x *PrepareAddShardResponse) Reset() {
 	*x = PrepareAddShardResponse{}
-	mi := &file_shardmanager_proto_msgTypes[38]
+	mi := &file_shardmanager_proto_msgTypes[53]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1945,7 +3089,7 @@ func (x *PrepareAddShardResponse) String() string {
 func (*PrepareAddShardResponse) ProtoMessage() {}
 
 func (x *PrepareAddShardResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[38]
+	mi := &file_shardmanager_proto_msgTypes[53]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1958,7 +3102,7 @@ func (x *PrepareAddShardResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PrepareAddShardResponse.ProtoReflect.Descriptor instead.
 func (*PrepareAddShardResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{38}
+	return file_shardmanager_proto_rawDescGZIP(), []int{53}
 }
 
 func (x *PrepareAddShardResponse) GetSuccess() bool {
@@ -1986,7 +3130,7 @@ type PrepareDropShardRequest struct {
 
 func (x *PrepareDropShardRequest) Reset() {
 	*x = PrepareDropShardRequest{}
-	mi := &file_shardmanager_proto_msgTypes[39]
+	mi := &file_shardmanager_proto_msgTypes[54]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1998,7 +3142,7 @@ func (x *PrepareDropShardRequest) String() string {
 func (*PrepareDropShardRequest) ProtoMessage() {}
 
 func (x *PrepareDropShardRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[39]
+	mi := &file_shardmanager_proto_msgTypes[54]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2011,7 +3155,7 @@ func (x *PrepareDropShardRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PrepareDropShardRequest.ProtoReflect.Descriptor instead.
 func (*PrepareDropShardRequest) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{39}
+	return file_shardmanager_proto_rawDescGZIP(), []int{54}
 }
 
 func (x *PrepareDropShardRequest) GetShardId() string {
@@ -2045,7 +3189,7 @@ type PrepareDropShardResponse struct {
 
 func (x *PrepareDropShardResponse) Reset() {
 	*x = PrepareDropShardResponse{}
-	mi := &file_shardmanager_proto_msgTypes[40]
+	mi := &file_shardmanager_proto_msgTypes[55]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -2057,7 +3201,7 @@ func (x *PrepareDropShardResponse) String() string {
 func (*PrepareDropShardResponse) ProtoMessage() {}
 
 func (x *PrepareDropShardResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_shardmanager_proto_msgTypes[40]
+	mi := &file_shardmanager_proto_msgTypes[55]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -2070,7 +3214,7 @@ func (x *PrepareDropShardResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use PrepareDropShardResponse.ProtoReflect.Descriptor instead.
 func (*PrepareDropShardResponse) Descriptor() ([]byte, []int) {
-	return file_shardmanager_proto_rawDescGZIP(), []int{40}
+	return file_shardmanager_proto_rawDescGZIP(), []int{55}
 }
 
 func (x *PrepareDropShardResponse) GetSuccess() bool {
@@ -2087,36 +3231,174 @@ func (x *PrepareDropShardResponse) GetMessage() string {
 	return ""
 }
 
+type ListLocalShardsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListLocalShardsRequest) Reset() {
+	*x = ListLocalShardsRequest{}
+	mi := &file_shardmanager_proto_msgTypes[56]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListLocalShardsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListLocalShardsRequest) ProtoMessage() {}
+
+func (x *ListLocalShardsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[56]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListLocalShardsRequest.ProtoReflect.Descriptor instead.
+func (*ListLocalShardsRequest) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{56}
+}
+
+type ListLocalShardsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Shards        []*ShardReplica        `protobuf:"bytes,1,rep,name=shards,proto3" json:"shards,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListLocalShardsResponse) Reset() {
+	*x = ListLocalShardsResponse{}
+	mi := &file_shardmanager_proto_msgTypes[57]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListLocalShardsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListLocalShardsResponse) ProtoMessage() {}
+
+func (x *ListLocalShardsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_shardmanager_proto_msgTypes[57]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListLocalShardsResponse.ProtoReflect.Descriptor instead.
+func (*ListLocalShardsResponse) Descriptor() ([]byte, []int) {
+	return file_shardmanager_proto_rawDescGZIP(), []int{57}
+}
+
+func (x *ListLocalShardsResponse) GetShards() []*ShardReplica {
+	if x != nil {
+		return x.Shards
+	}
+	return nil
+}
+
 var File_shardmanager_proto protoreflect.FileDescriptor
 
 const file_shardmanager_proto_rawDesc = "" +
 	"\n" +
-	"\x12shardmanager.proto\x12\x0eshardmanagerpb\"f\n" +
+	"\x12shardmanager.proto\x12\x0eshardmanagerpb\"\xe8\x05\n" +
 	"\x04Node\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1a\n" +
 	"\blocation\x18\x02 \x01(\tR\blocation\x12\x1a\n" +
 	"\bcapacity\x18\x03 \x01(\x03R\bcapacity\x12\x16\n" +
-	"\x06status\x18\x04 \x01(\tR\x06status\"p\n" +
+	"\x06status\x18\x04 \x01(\tR\x06status\x12'\n" +
+	"\x0fassignment_mode\x18\x05 \x01(\tR\x0eassignmentMode\x12\x12\n" +
+	"\x04name\x18\x06 \x01(\tR\x04name\x12W\n" +
+	"\x11resource_capacity\x18\a \x03(\v2*.shardmanagerpb.Node.ResourceCapacityEntryR\x10resourceCapacity\x12K\n" +
+	"\rresource_load\x18\b \x03(\v2&.shardmanagerpb.Node.ResourceLoadEntryR\fresourceLoad\x12W\n" +
+	"\x11resource_headroom\x18\t \x03(\v2*.shardmanagerpb.Node.ResourceHeadroomEntryR\x10resourceHeadroom\x12!\n" +
+	"\fcurrent_load\x18\n" +
+	" \x01(\x03R\vcurrentLoad\x123\n" +
+	"\x16last_heartbeat_unix_ms\x18\v \x01(\x03R\x13lastHeartbeatUnixMs\x12!\n" +
+	"\ffault_domain\x18\f \x01(\tR\vfaultDomain\x1aC\n" +
+	"\x15ResourceCapacityEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value:\x028\x01\x1a?\n" +
+	"\x11ResourceLoadEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value:\x028\x01\x1aC\n" +
+	"\x15ResourceHeadroomEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value:\x028\x01\"\x87\x02\n" +
 	"\x05Shard\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
 	"\x04type\x18\x02 \x01(\tR\x04type\x12\x12\n" +
 	"\x04size\x18\x03 \x01(\x03R\x04size\x12\x17\n" +
 	"\anode_id\x18\x04 \x01(\tR\x06nodeId\x12\x16\n" +
-	"\x06status\x18\x05 \x01(\tR\x06status\"?\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12R\n" +
+	"\x0fresource_demand\x18\x06 \x03(\v2).shardmanagerpb.Shard.ResourceDemandEntryR\x0eresourceDemand\x1aA\n" +
+	"\x13ResourceDemandEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value:\x028\x01\"=\n" +
+	"\fShardReplica\x12\x19\n" +
+	"\bshard_id\x18\x01 \x01(\tR\ashardId\x12\x12\n" +
+	"\x04role\x18\x02 \x01(\tR\x04role\"?\n" +
 	"\x13RegisterNodeRequest\x12(\n" +
-	"\x04node\x18\x01 \x01(\v2\x14.shardmanagerpb.NodeR\x04node\"J\n" +
+	"\x04node\x18\x01 \x01(\v2\x14.shardmanagerpb.NodeR\x04node\"c\n" +
 	"\x14RegisterNodeResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\"W\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x17\n" +
+	"\anode_id\x18\x03 \x01(\tR\x06nodeId\"\xe3\x02\n" +
 	"\x10HeartbeatRequest\x12\x17\n" +
 	"\anode_id\x18\x01 \x01(\tR\x06nodeId\x12\x16\n" +
 	"\x06status\x18\x02 \x01(\tR\x06status\x12\x12\n" +
-	"\x04load\x18\x03 \x01(\x03R\x04load\"-\n" +
+	"\x04load\x18\x03 \x01(\x03R\x04load\x124\n" +
+	"\x06shards\x18\x04 \x03(\v2\x1c.shardmanagerpb.ShardReplicaR\x06shards\x12W\n" +
+	"\rresource_load\x18\x05 \x03(\v22.shardmanagerpb.HeartbeatRequest.ResourceLoadEntryR\fresourceLoad\x12:\n" +
+	"\vshard_loads\x18\x06 \x03(\v2\x19.shardmanagerpb.ShardLoadR\n" +
+	"shardLoads\x1a?\n" +
+	"\x11ResourceLoadEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value:\x028\x01\"\x9e\x01\n" +
+	"\tShardLoad\x12\x19\n" +
+	"\bshard_id\x18\x01 \x01(\tR\ashardId\x12\x10\n" +
+	"\x03qps\x18\x02 \x01(\x01R\x03qps\x12(\n" +
+	"\x10bytes_per_second\x18\x03 \x01(\x03R\x0ebytesPerSecond\x12\x1b\n" +
+	"\tcpu_share\x18\x04 \x01(\x01R\bcpuShare\x12\x1d\n" +
+	"\n" +
+	"size_bytes\x18\x05 \x01(\x03R\tsizeBytes\"\xa6\x01\n" +
 	"\x11HeartbeatResponse\x12\x18\n" +
-	"\asuccess\x18\x01 \x01(\bR\asuccess\"\x12\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x122\n" +
+	"\x06leases\x18\x02 \x03(\v2\x1a.shardmanagerpb.ShardLeaseR\x06leases\x12C\n" +
+	"\x0edesired_shards\x18\x03 \x03(\v2\x1c.shardmanagerpb.ShardReplicaR\rdesiredShards\"T\n" +
+	"\n" +
+	"ShardLease\x12\x19\n" +
+	"\bshard_id\x18\x01 \x01(\tR\ashardId\x12+\n" +
+	"\x12expires_at_unix_ms\x18\x02 \x01(\x03R\x0fexpiresAtUnixMs\"\x12\n" +
 	"\x10ListNodesRequest\"?\n" +
 	"\x11ListNodesResponse\x12*\n" +
-	"\x05nodes\x18\x01 \x03(\v2\x14.shardmanagerpb.NodeR\x05nodes\"C\n" +
+	"\x05nodes\x18\x01 \x03(\v2\x14.shardmanagerpb.NodeR\x05nodes\"+\n" +
+	"\x10DrainNodeRequest\x12\x17\n" +
+	"\anode_id\x18\x01 \x01(\tR\x06nodeId\"\x95\x01\n" +
+	"\x11DrainNodeResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12'\n" +
+	"\x0fmigrated_shards\x18\x03 \x03(\tR\x0emigratedShards\x12#\n" +
+	"\rfailed_shards\x18\x04 \x03(\tR\ffailedShards\"-\n" +
+	"\x12UndrainNodeRequest\x12\x17\n" +
+	"\anode_id\x18\x01 \x01(\tR\x06nodeId\"I\n" +
+	"\x13UndrainNodeResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"C\n" +
 	"\x14RegisterShardRequest\x12+\n" +
 	"\x05shard\x18\x01 \x01(\v2\x15.shardmanagerpb.ShardR\x05shard\"K\n" +
 	"\x15RegisterShardResponse\x12\x18\n" +
@@ -2149,6 +3431,23 @@ const file_shardmanager_proto_rawDesc = "" +
 	"\x06status\x18\x02 \x01(\tR\x06status\"O\n" +
 	"\x19UpdateShardStatusResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"3\n" +
+	"\x16GetShardHistoryRequest\x12\x19\n" +
+	"\bshard_id\x18\x01 \x01(\tR\ashardId\"S\n" +
+	"\x17GetShardHistoryResponse\x128\n" +
+	"\bversions\x18\x01 \x03(\v2\x1c.shardmanagerpb.ShardVersionR\bversions\"\xb0\x01\n" +
+	"\fShardVersion\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x05R\aversion\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12\x12\n" +
+	"\x04size\x18\x03 \x01(\x03R\x04size\x12\x17\n" +
+	"\anode_id\x18\x04 \x01(\tR\x06nodeId\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12-\n" +
+	"\x13recorded_at_unix_ms\x18\x06 \x01(\x03R\x10recordedAtUnixMs\"K\n" +
+	"\x14RollbackShardRequest\x12\x19\n" +
+	"\bshard_id\x18\x01 \x01(\tR\ashardId\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\x05R\aversion\"K\n" +
+	"\x15RollbackShardResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
 	"\amessage\x18\x02 \x01(\tR\amessage\"S\n" +
 	"\x10SetPolicyRequest\x12\x1f\n" +
 	"\vpolicy_type\x18\x01 \x01(\tR\n" +
@@ -2177,16 +3476,55 @@ const file_shardmanager_proto_rawDesc = "" +
 	"\x05value\x18\x02 \x01(\v2\x19.shardmanagerpb.ShardListR\x05value:\x028\x01\"(\n" +
 	"\tShardList\x12\x1b\n" +
 	"\tshard_ids\x18\x01 \x03(\tR\bshardIds\"\x12\n" +
-	"\x10GetHealthRequest\"-\n" +
+	"\x10GetHealthRequest\"\xac\x05\n" +
 	"\x11GetHealthResponse\x12\x18\n" +
-	"\asummary\x18\x01 \x01(\tR\asummary\"T\n" +
+	"\asummary\x18\x01 \x01(\tR\asummary\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12!\n" +
+	"\fdb_reachable\x18\x03 \x01(\bR\vdbReachable\x12\x19\n" +
+	"\bdb_error\x18\x04 \x01(\tR\adbError\x12\x1f\n" +
+	"\vtotal_nodes\x18\x05 \x01(\x03R\n" +
+	"totalNodes\x12!\n" +
+	"\factive_nodes\x18\x06 \x01(\x03R\vactiveNodes\x12!\n" +
+	"\ftotal_shards\x18\a \x01(\x03R\vtotalShards\x12\x1f\n" +
+	"\vstale_nodes\x18\b \x03(\tR\n" +
+	"staleNodes\x12!\n" +
+	"\ffailed_nodes\x18\t \x03(\tR\vfailedNodes\x12+\n" +
+	"\x11unassigned_shards\x18\n" +
+	" \x03(\tR\x10unassignedShards\x124\n" +
+	"\x16stuck_migrating_shards\x18\v \x03(\tR\x14stuckMigratingShards\x12-\n" +
+	"\x12replica_violations\x18\f \x03(\tR\x11replicaViolations\x12+\n" +
+	"\x12checked_at_unix_ms\x18\r \x01(\x03R\x0fcheckedAtUnixMs\x12-\n" +
+	"\x12unavailable_shards\x18\x0e \x03(\tR\x11unavailableShards\x126\n" +
+	"\x17fault_domain_violations\x18\x0f \x03(\tR\x15faultDomainViolations\x12%\n" +
+	"\x0eoutbox_backlog\x18\x10 \x01(\x03R\routboxBacklog\x12/\n" +
+	"\x14outbox_oldest_age_ms\x18\x11 \x01(\x03R\x11outboxOldestAgeMs\"T\n" +
 	"\x14ReportFailureRequest\x12\x12\n" +
 	"\x04type\x18\x01 \x01(\tR\x04type\x12\x0e\n" +
 	"\x02id\x18\x02 \x01(\tR\x02id\x12\x18\n" +
 	"\adetails\x18\x03 \x01(\tR\adetails\"K\n" +
 	"\x15ReportFailureResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\"@\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"\xfd\x01\n" +
+	"\n" +
+	"AuditEvent\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05actor\x18\x02 \x01(\tR\x05actor\x12\x16\n" +
+	"\x06method\x18\x03 \x01(\tR\x06method\x12\x1f\n" +
+	"\ventity_type\x18\x04 \x01(\tR\n" +
+	"entityType\x12\x1b\n" +
+	"\tentity_id\x18\x05 \x01(\tR\bentityId\x12\x18\n" +
+	"\arequest\x18\x06 \x01(\tR\arequest\x12\x16\n" +
+	"\x06result\x18\a \x01(\tR\x06result\x12\x14\n" +
+	"\x05error\x18\b \x01(\tR\x05error\x12+\n" +
+	"\x12created_at_unix_ms\x18\t \x01(\x03R\x0fcreatedAtUnixMs\"\xa9\x01\n" +
+	"\x16ListAuditEventsRequest\x12\x1b\n" +
+	"\tentity_id\x18\x01 \x01(\tR\bentityId\x12\x14\n" +
+	"\x05actor\x18\x02 \x01(\tR\x05actor\x12\"\n" +
+	"\rsince_unix_ms\x18\x03 \x01(\x03R\vsinceUnixMs\x12\"\n" +
+	"\runtil_unix_ms\x18\x04 \x01(\x03R\vuntilUnixMs\x12\x14\n" +
+	"\x05limit\x18\x05 \x01(\x05R\x05limit\"M\n" +
+	"\x17ListAuditEventsResponse\x122\n" +
+	"\x06events\x18\x01 \x03(\v2\x1a.shardmanagerpb.AuditEventR\x06events\"@\n" +
 	"\x0fAddShardRequest\x12\x19\n" +
 	"\bshard_id\x18\x01 \x01(\tR\ashardId\x12\x12\n" +
 	"\x04role\x18\x02 \x01(\tR\x04role\"F\n" +
@@ -2218,11 +3556,16 @@ const file_shardmanager_proto_rawDesc = "" +
 	"\x04role\x18\x03 \x01(\tR\x04role\"N\n" +
 	"\x18PrepareDropShardResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage2\x8c\x02\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"\x18\n" +
+	"\x16ListLocalShardsRequest\"O\n" +
+	"\x17ListLocalShardsResponse\x124\n" +
+	"\x06shards\x18\x01 \x03(\v2\x1c.shardmanagerpb.ShardReplicaR\x06shards2\xb6\x03\n" +
 	"\vNodeService\x12Y\n" +
 	"\fRegisterNode\x12#.shardmanagerpb.RegisterNodeRequest\x1a$.shardmanagerpb.RegisterNodeResponse\x12P\n" +
 	"\tHeartbeat\x12 .shardmanagerpb.HeartbeatRequest\x1a!.shardmanagerpb.HeartbeatResponse\x12P\n" +
-	"\tListNodes\x12 .shardmanagerpb.ListNodesRequest\x1a!.shardmanagerpb.ListNodesResponse2\xb9\x04\n" +
+	"\tListNodes\x12 .shardmanagerpb.ListNodesRequest\x1a!.shardmanagerpb.ListNodesResponse\x12P\n" +
+	"\tDrainNode\x12 .shardmanagerpb.DrainNodeRequest\x1a!.shardmanagerpb.DrainNodeResponse\x12V\n" +
+	"\vUndrainNode\x12\".shardmanagerpb.UndrainNodeRequest\x1a#.shardmanagerpb.UndrainNodeResponse2\xfb\x05\n" +
 	"\fShardService\x12\\\n" +
 	"\rRegisterShard\x12$.shardmanagerpb.RegisterShardRequest\x1a%.shardmanagerpb.RegisterShardResponse\x12S\n" +
 	"\n" +
@@ -2230,7 +3573,9 @@ const file_shardmanager_proto_rawDesc = "" +
 	"\fGetShardInfo\x12#.shardmanagerpb.GetShardInfoRequest\x1a$.shardmanagerpb.GetShardInfoResponse\x12V\n" +
 	"\vAssignShard\x12\".shardmanagerpb.AssignShardRequest\x1a#.shardmanagerpb.AssignShardResponse\x12Y\n" +
 	"\fMigrateShard\x12#.shardmanagerpb.MigrateShardRequest\x1a$.shardmanagerpb.MigrateShardResponse\x12h\n" +
-	"\x11UpdateShardStatus\x12(.shardmanagerpb.UpdateShardStatusRequest\x1a).shardmanagerpb.UpdateShardStatusResponse2\xb3\x01\n" +
+	"\x11UpdateShardStatus\x12(.shardmanagerpb.UpdateShardStatusRequest\x1a).shardmanagerpb.UpdateShardStatusResponse\x12b\n" +
+	"\x0fGetShardHistory\x12&.shardmanagerpb.GetShardHistoryRequest\x1a'.shardmanagerpb.GetShardHistoryResponse\x12\\\n" +
+	"\rRollbackShard\x12$.shardmanagerpb.RollbackShardRequest\x1a%.shardmanagerpb.RollbackShardResponse2\xb3\x01\n" +
 	"\rPolicyService\x12P\n" +
 	"\tSetPolicy\x12 .shardmanagerpb.SetPolicyRequest\x1a!.shardmanagerpb.SetPolicyResponse\x12P\n" +
 	"\tGetPolicy\x12 .shardmanagerpb.GetPolicyRequest\x1a!.shardmanagerpb.GetPolicyResponse2\xc9\x01\n" +
@@ -2238,14 +3583,17 @@ const file_shardmanager_proto_rawDesc = "" +
 	"\x0fGetDistribution\x12&.shardmanagerpb.GetDistributionRequest\x1a'.shardmanagerpb.GetDistributionResponse\x12P\n" +
 	"\tGetHealth\x12 .shardmanagerpb.GetHealthRequest\x1a!.shardmanagerpb.GetHealthResponse2n\n" +
 	"\x0eFailureService\x12\\\n" +
-	"\rReportFailure\x12$.shardmanagerpb.ReportFailureRequest\x1a%.shardmanagerpb.ReportFailureResponse2\xd2\x03\n" +
+	"\rReportFailure\x12$.shardmanagerpb.ReportFailureRequest\x1a%.shardmanagerpb.ReportFailureResponse2r\n" +
+	"\fAuditService\x12b\n" +
+	"\x0fListAuditEvents\x12&.shardmanagerpb.ListAuditEventsRequest\x1a'.shardmanagerpb.ListAuditEventsResponse2\xb6\x04\n" +
 	"\x0fAppShardService\x12M\n" +
 	"\bAddShard\x12\x1f.shardmanagerpb.AddShardRequest\x1a .shardmanagerpb.AddShardResponse\x12P\n" +
 	"\tDropShard\x12 .shardmanagerpb.DropShardRequest\x1a!.shardmanagerpb.DropShardResponse\x12S\n" +
 	"\n" +
 	"ChangeRole\x12!.shardmanagerpb.ChangeRoleRequest\x1a\".shardmanagerpb.ChangeRoleResponse\x12b\n" +
 	"\x0fPrepareAddShard\x12&.shardmanagerpb.PrepareAddShardRequest\x1a'.shardmanagerpb.PrepareAddShardResponse\x12e\n" +
-	"\x10PrepareDropShard\x12'.shardmanagerpb.PrepareDropShardRequest\x1a(.shardmanagerpb.PrepareDropShardResponseB2Z0github.com/seaweedfs/shardmanager/shardmanagerpbb\x06proto3"
+	"\x10PrepareDropShard\x12'.shardmanagerpb.PrepareDropShardRequest\x1a(.shardmanagerpb.PrepareDropShardResponse\x12b\n" +
+	"\x0fListLocalShards\x12&.shardmanagerpb.ListLocalShardsRequest\x1a'.shardmanagerpb.ListLocalShardsResponseB2Z0github.com/seaweedfs/shardmanager/shardmanagerpbb\x06proto3"
 
 var (
 	file_shardmanager_proto_rawDescOnce sync.Once
@@ -2259,102 +3607,148 @@ func file_shardmanager_proto_rawDescGZIP() []byte {
 	return file_shardmanager_proto_rawDescData
 }
 
-var file_shardmanager_proto_msgTypes = make([]protoimpl.MessageInfo, 42)
+var file_shardmanager_proto_msgTypes = make([]protoimpl.MessageInfo, 64)
 var file_shardmanager_proto_goTypes = []any{
 	(*Node)(nil),                      // 0: shardmanagerpb.Node
 	(*Shard)(nil),                     // 1: shardmanagerpb.Shard
-	(*RegisterNodeRequest)(nil),       // 2: shardmanagerpb.RegisterNodeRequest
-	(*RegisterNodeResponse)(nil),      // 3: shardmanagerpb.RegisterNodeResponse
-	(*HeartbeatRequest)(nil),          // 4: shardmanagerpb.HeartbeatRequest
-	(*HeartbeatResponse)(nil),         // 5: shardmanagerpb.HeartbeatResponse
-	(*ListNodesRequest)(nil),          // 6: shardmanagerpb.ListNodesRequest
-	(*ListNodesResponse)(nil),         // 7: shardmanagerpb.ListNodesResponse
-	(*RegisterShardRequest)(nil),      // 8: shardmanagerpb.RegisterShardRequest
-	(*RegisterShardResponse)(nil),     // 9: shardmanagerpb.RegisterShardResponse
-	(*ListShardsRequest)(nil),         // 10: shardmanagerpb.ListShardsRequest
-	(*ListShardsResponse)(nil),        // 11: shardmanagerpb.ListShardsResponse
-	(*GetShardInfoRequest)(nil),       // 12: shardmanagerpb.GetShardInfoRequest
-	(*GetShardInfoResponse)(nil),      // 13: shardmanagerpb.GetShardInfoResponse
-	(*AssignShardRequest)(nil),        // 14: shardmanagerpb.AssignShardRequest
-	(*AssignShardResponse)(nil),       // 15: shardmanagerpb.AssignShardResponse
-	(*MigrateShardRequest)(nil),       // 16: shardmanagerpb.MigrateShardRequest
-	(*MigrateShardResponse)(nil),      // 17: shardmanagerpb.MigrateShardResponse
-	(*UpdateShardStatusRequest)(nil),  // 18: shardmanagerpb.UpdateShardStatusRequest
-	(*UpdateShardStatusResponse)(nil), // 19: shardmanagerpb.UpdateShardStatusResponse
-	(*SetPolicyRequest)(nil),          // 20: shardmanagerpb.SetPolicyRequest
-	(*SetPolicyResponse)(nil),         // 21: shardmanagerpb.SetPolicyResponse
-	(*GetPolicyRequest)(nil),          // 22: shardmanagerpb.GetPolicyRequest
-	(*GetPolicyResponse)(nil),         // 23: shardmanagerpb.GetPolicyResponse
-	(*GetDistributionRequest)(nil),    // 24: shardmanagerpb.GetDistributionRequest
-	(*GetDistributionResponse)(nil),   // 25: shardmanagerpb.GetDistributionResponse
-	(*ShardList)(nil),                 // 26: shardmanagerpb.ShardList
-	(*GetHealthRequest)(nil),          // 27: shardmanagerpb.GetHealthRequest
-	(*GetHealthResponse)(nil),         // 28: shardmanagerpb.GetHealthResponse
-	(*ReportFailureRequest)(nil),      // 29: shardmanagerpb.ReportFailureRequest
-	(*ReportFailureResponse)(nil),     // 30: shardmanagerpb.ReportFailureResponse
-	(*AddShardRequest)(nil),           // 31: shardmanagerpb.AddShardRequest
-	(*AddShardResponse)(nil),          // 32: shardmanagerpb.AddShardResponse
-	(*DropShardRequest)(nil),          // 33: shardmanagerpb.DropShardRequest
-	(*DropShardResponse)(nil),         // 34: shardmanagerpb.DropShardResponse
-	(*ChangeRoleRequest)(nil),         // 35: shardmanagerpb.ChangeRoleRequest
-	(*ChangeRoleResponse)(nil),        // 36: shardmanagerpb.ChangeRoleResponse
-	(*PrepareAddShardRequest)(nil),    // 37: shardmanagerpb.PrepareAddShardRequest
-	(*PrepareAddShardResponse)(nil),   // 38: shardmanagerpb.PrepareAddShardResponse
-	(*PrepareDropShardRequest)(nil),   // 39: shardmanagerpb.PrepareDropShardRequest
-	(*PrepareDropShardResponse)(nil),  // 40: shardmanagerpb.PrepareDropShardResponse
-	nil,                               // 41: shardmanagerpb.GetDistributionResponse.NodeShardsEntry
+	(*ShardReplica)(nil),              // 2: shardmanagerpb.ShardReplica
+	(*RegisterNodeRequest)(nil),       // 3: shardmanagerpb.RegisterNodeRequest
+	(*RegisterNodeResponse)(nil),      // 4: shardmanagerpb.RegisterNodeResponse
+	(*HeartbeatRequest)(nil),          // 5: shardmanagerpb.HeartbeatRequest
+	(*ShardLoad)(nil),                 // 6: shardmanagerpb.ShardLoad
+	(*HeartbeatResponse)(nil),         // 7: shardmanagerpb.HeartbeatResponse
+	(*ShardLease)(nil),                // 8: shardmanagerpb.ShardLease
+	(*ListNodesRequest)(nil),          // 9: shardmanagerpb.ListNodesRequest
+	(*ListNodesResponse)(nil),         // 10: shardmanagerpb.ListNodesResponse
+	(*DrainNodeRequest)(nil),          // 11: shardmanagerpb.DrainNodeRequest
+	(*DrainNodeResponse)(nil),         // 12: shardmanagerpb.DrainNodeResponse
+	(*UndrainNodeRequest)(nil),        // 13: shardmanagerpb.UndrainNodeRequest
+	(*UndrainNodeResponse)(nil),       // 14: shardmanagerpb.UndrainNodeResponse
+	(*RegisterShardRequest)(nil),      // 15: shardmanagerpb.RegisterShardRequest
+	(*RegisterShardResponse)(nil),     // 16: shardmanagerpb.RegisterShardResponse
+	(*ListShardsRequest)(nil),         // 17: shardmanagerpb.ListShardsRequest
+	(*ListShardsResponse)(nil),        // 18: shardmanagerpb.ListShardsResponse
+	(*GetShardInfoRequest)(nil),       // 19: shardmanagerpb.GetShardInfoRequest
+	(*GetShardInfoResponse)(nil),      // 20: shardmanagerpb.GetShardInfoResponse
+	(*AssignShardRequest)(nil),        // 21: shardmanagerpb.AssignShardRequest
+	(*AssignShardResponse)(nil),       // 22: shardmanagerpb.AssignShardResponse
+	(*MigrateShardRequest)(nil),       // 23: shardmanagerpb.MigrateShardRequest
+	(*MigrateShardResponse)(nil),      // 24: shardmanagerpb.MigrateShardResponse
+	(*UpdateShardStatusRequest)(nil),  // 25: shardmanagerpb.UpdateShardStatusRequest
+	(*UpdateShardStatusResponse)(nil), // 26: shardmanagerpb.UpdateShardStatusResponse
+	(*GetShardHistoryRequest)(nil),    // 27: shardmanagerpb.GetShardHistoryRequest
+	(*GetShardHistoryResponse)(nil),   // 28: shardmanagerpb.GetShardHistoryResponse
+	(*ShardVersion)(nil),              // 29: shardmanagerpb.ShardVersion
+	(*RollbackShardRequest)(nil),      // 30: shardmanagerpb.RollbackShardRequest
+	(*RollbackShardResponse)(nil),     // 31: shardmanagerpb.RollbackShardResponse
+	(*SetPolicyRequest)(nil),          // 32: shardmanagerpb.SetPolicyRequest
+	(*SetPolicyResponse)(nil),         // 33: shardmanagerpb.SetPolicyResponse
+	(*GetPolicyRequest)(nil),          // 34: shardmanagerpb.GetPolicyRequest
+	(*GetPolicyResponse)(nil),         // 35: shardmanagerpb.GetPolicyResponse
+	(*GetDistributionRequest)(nil),    // 36: shardmanagerpb.GetDistributionRequest
+	(*GetDistributionResponse)(nil),   // 37: shardmanagerpb.GetDistributionResponse
+	(*ShardList)(nil),                 // 38: shardmanagerpb.ShardList
+	(*GetHealthRequest)(nil),          // 39: shardmanagerpb.GetHealthRequest
+	(*GetHealthResponse)(nil),         // 40: shardmanagerpb.GetHealthResponse
+	(*ReportFailureRequest)(nil),      // 41: shardmanagerpb.ReportFailureRequest
+	(*ReportFailureResponse)(nil),     // 42: shardmanagerpb.ReportFailureResponse
+	(*AuditEvent)(nil),                // 43: shardmanagerpb.AuditEvent
+	(*ListAuditEventsRequest)(nil),    // 44: shardmanagerpb.ListAuditEventsRequest
+	(*ListAuditEventsResponse)(nil),   // 45: shardmanagerpb.ListAuditEventsResponse
+	(*AddShardRequest)(nil),           // 46: shardmanagerpb.AddShardRequest
+	(*AddShardResponse)(nil),          // 47: shardmanagerpb.AddShardResponse
+	(*DropShardRequest)(nil),          // 48: shardmanagerpb.DropShardRequest
+	(*DropShardResponse)(nil),         // 49: shardmanagerpb.DropShardResponse
+	(*ChangeRoleRequest)(nil),         // 50: shardmanagerpb.ChangeRoleRequest
+	(*ChangeRoleResponse)(nil),        // 51: shardmanagerpb.ChangeRoleResponse
+	(*PrepareAddShardRequest)(nil),    // 52: shardmanagerpb.PrepareAddShardRequest
+	(*PrepareAddShardResponse)(nil),   // 53: shardmanagerpb.PrepareAddShardResponse
+	(*PrepareDropShardRequest)(nil),   // 54: shardmanagerpb.PrepareDropShardRequest
+	(*PrepareDropShardResponse)(nil),  // 55: shardmanagerpb.PrepareDropShardResponse
+	(*ListLocalShardsRequest)(nil),    // 56: shardmanagerpb.ListLocalShardsRequest
+	(*ListLocalShardsResponse)(nil),   // 57: shardmanagerpb.ListLocalShardsResponse
+	nil,                               // 58: shardmanagerpb.Node.ResourceCapacityEntry
+	nil,                               // 59: shardmanagerpb.Node.ResourceLoadEntry
+	nil,                               // 60: shardmanagerpb.Node.ResourceHeadroomEntry
+	nil,                               // 61: shardmanagerpb.Shard.ResourceDemandEntry
+	nil,                               // 62: shardmanagerpb.HeartbeatRequest.ResourceLoadEntry
+	nil,                               // 63: shardmanagerpb.GetDistributionResponse.NodeShardsEntry
 }
 var file_shardmanager_proto_depIdxs = []int32{
-	0,  // 0: shardmanagerpb.RegisterNodeRequest.node:type_name -> shardmanagerpb.Node
-	0,  // 1: shardmanagerpb.ListNodesResponse.nodes:type_name -> shardmanagerpb.Node
-	1,  // 2: shardmanagerpb.RegisterShardRequest.shard:type_name -> shardmanagerpb.Shard
-	1,  // 3: shardmanagerpb.ListShardsResponse.shards:type_name -> shardmanagerpb.Shard
-	1,  // 4: shardmanagerpb.GetShardInfoResponse.shard:type_name -> shardmanagerpb.Shard
-	41, // 5: shardmanagerpb.GetDistributionResponse.node_shards:type_name -> shardmanagerpb.GetDistributionResponse.NodeShardsEntry
-	26, // 6: shardmanagerpb.GetDistributionResponse.NodeShardsEntry.value:type_name -> shardmanagerpb.ShardList
-	2,  // 7: shardmanagerpb.NodeService.RegisterNode:input_type -> shardmanagerpb.RegisterNodeRequest
-	4,  // 8: shardmanagerpb.NodeService.Heartbeat:input_type -> shardmanagerpb.HeartbeatRequest
-	6,  // 9: shardmanagerpb.NodeService.ListNodes:input_type -> shardmanagerpb.ListNodesRequest
-	8,  // 10: shardmanagerpb.ShardService.RegisterShard:input_type -> shardmanagerpb.RegisterShardRequest
-	10, // 11: shardmanagerpb.ShardService.ListShards:input_type -> shardmanagerpb.ListShardsRequest
-	12, // 12: shardmanagerpb.ShardService.GetShardInfo:input_type -> shardmanagerpb.GetShardInfoRequest
-	14, // 13: shardmanagerpb.ShardService.AssignShard:input_type -> shardmanagerpb.AssignShardRequest
-	16, // 14: shardmanagerpb.ShardService.MigrateShard:input_type -> shardmanagerpb.MigrateShardRequest
-	18, // 15: shardmanagerpb.ShardService.UpdateShardStatus:input_type -> shardmanagerpb.UpdateShardStatusRequest
-	20, // 16: shardmanagerpb.PolicyService.SetPolicy:input_type -> shardmanagerpb.SetPolicyRequest
-	22, // 17: shardmanagerpb.PolicyService.GetPolicy:input_type -> shardmanagerpb.GetPolicyRequest
-	24, // 18: shardmanagerpb.MonitoringService.GetDistribution:input_type -> shardmanagerpb.GetDistributionRequest
-	27, // 19: shardmanagerpb.MonitoringService.GetHealth:input_type -> shardmanagerpb.GetHealthRequest
-	29, // 20: shardmanagerpb.FailureService.ReportFailure:input_type -> shardmanagerpb.ReportFailureRequest
-	31, // 21: shardmanagerpb.AppShardService.AddShard:input_type -> shardmanagerpb.AddShardRequest
-	33, // 22: shardmanagerpb.AppShardService.DropShard:input_type -> shardmanagerpb.DropShardRequest
-	35, // 23: shardmanagerpb.AppShardService.ChangeRole:input_type -> shardmanagerpb.ChangeRoleRequest
-	37, // 24: shardmanagerpb.AppShardService.PrepareAddShard:input_type -> shardmanagerpb.PrepareAddShardRequest
-	39, // 25: shardmanagerpb.AppShardService.PrepareDropShard:input_type -> shardmanagerpb.PrepareDropShardRequest
-	3,  // 26: shardmanagerpb.NodeService.RegisterNode:output_type -> shardmanagerpb.RegisterNodeResponse
-	5,  // 27: shardmanagerpb.NodeService.Heartbeat:output_type -> shardmanagerpb.HeartbeatResponse
-	7,  // 28: shardmanagerpb.NodeService.ListNodes:output_type -> shardmanagerpb.ListNodesResponse
-	9,  // 29: shardmanagerpb.ShardService.RegisterShard:output_type -> shardmanagerpb.RegisterShardResponse
-	11, // 30: shardmanagerpb.ShardService.ListShards:output_type -> shardmanagerpb.ListShardsResponse
-	13, // 31: shardmanagerpb.ShardService.GetShardInfo:output_type -> shardmanagerpb.GetShardInfoResponse
-	15, // 32: shardmanagerpb.ShardService.AssignShard:output_type -> shardmanagerpb.AssignShardResponse
-	17, // 33: shardmanagerpb.ShardService.MigrateShard:output_type -> shardmanagerpb.MigrateShardResponse
-	19, // 34: shardmanagerpb.ShardService.UpdateShardStatus:output_type -> shardmanagerpb.UpdateShardStatusResponse
-	21, // 35: shardmanagerpb.PolicyService.SetPolicy:output_type -> shardmanagerpb.SetPolicyResponse
-	23, // 36: shardmanagerpb.PolicyService.GetPolicy:output_type -> shardmanagerpb.GetPolicyResponse
-	25, // 37: shardmanagerpb.MonitoringService.GetDistribution:output_type -> shardmanagerpb.GetDistributionResponse
-	28, // 38: shardmanagerpb.MonitoringService.GetHealth:output_type -> shardmanagerpb.GetHealthResponse
-	30, // 39: shardmanagerpb.FailureService.ReportFailure:output_type -> shardmanagerpb.ReportFailureResponse
-	32, // 40: shardmanagerpb.AppShardService.AddShard:output_type -> shardmanagerpb.AddShardResponse
-	34, // 41: shardmanagerpb.AppShardService.DropShard:output_type -> shardmanagerpb.DropShardResponse
-	36, // 42: shardmanagerpb.AppShardService.ChangeRole:output_type -> shardmanagerpb.ChangeRoleResponse
-	38, // 43: shardmanagerpb.AppShardService.PrepareAddShard:output_type -> shardmanagerpb.PrepareAddShardResponse
-	40, // 44: shardmanagerpb.AppShardService.PrepareDropShard:output_type -> shardmanagerpb.PrepareDropShardResponse
-	26, // [26:45] is the sub-list for method output_type
-	7,  // [7:26] is the sub-list for method input_type
-	7,  // [7:7] is the sub-list for extension type_name
-	7,  // [7:7] is the sub-list for extension extendee
-	0,  // [0:7] is the sub-list for field type_name
+	58, // 0: shardmanagerpb.Node.resource_capacity:type_name -> shardmanagerpb.Node.ResourceCapacityEntry
+	59, // 1: shardmanagerpb.Node.resource_load:type_name -> shardmanagerpb.Node.ResourceLoadEntry
+	60, // 2: shardmanagerpb.Node.resource_headroom:type_name -> shardmanagerpb.Node.ResourceHeadroomEntry
+	61, // 3: shardmanagerpb.Shard.resource_demand:type_name -> shardmanagerpb.Shard.ResourceDemandEntry
+	0,  // 4: shardmanagerpb.RegisterNodeRequest.node:type_name -> shardmanagerpb.Node
+	2,  // 5: shardmanagerpb.HeartbeatRequest.shards:type_name -> shardmanagerpb.ShardReplica
+	62, // 6: shardmanagerpb.HeartbeatRequest.resource_load:type_name -> shardmanagerpb.HeartbeatRequest.ResourceLoadEntry
+	6,  // 7: shardmanagerpb.HeartbeatRequest.shard_loads:type_name -> shardmanagerpb.ShardLoad
+	8,  // 8: shardmanagerpb.HeartbeatResponse.leases:type_name -> shardmanagerpb.ShardLease
+	2,  // 9: shardmanagerpb.HeartbeatResponse.desired_shards:type_name -> shardmanagerpb.ShardReplica
+	0,  // 10: shardmanagerpb.ListNodesResponse.nodes:type_name -> shardmanagerpb.Node
+	1,  // 11: shardmanagerpb.RegisterShardRequest.shard:type_name -> shardmanagerpb.Shard
+	1,  // 12: shardmanagerpb.ListShardsResponse.shards:type_name -> shardmanagerpb.Shard
+	1,  // 13: shardmanagerpb.GetShardInfoResponse.shard:type_name -> shardmanagerpb.Shard
+	29, // 14: shardmanagerpb.GetShardHistoryResponse.versions:type_name -> shardmanagerpb.ShardVersion
+	63, // 15: shardmanagerpb.GetDistributionResponse.node_shards:type_name -> shardmanagerpb.GetDistributionResponse.NodeShardsEntry
+	43, // 16: shardmanagerpb.ListAuditEventsResponse.events:type_name -> shardmanagerpb.AuditEvent
+	2,  // 17: shardmanagerpb.ListLocalShardsResponse.shards:type_name -> shardmanagerpb.ShardReplica
+	38, // 18: shardmanagerpb.GetDistributionResponse.NodeShardsEntry.value:type_name -> shardmanagerpb.ShardList
+	3,  // 19: shardmanagerpb.NodeService.RegisterNode:input_type -> shardmanagerpb.RegisterNodeRequest
+	5,  // 20: shardmanagerpb.NodeService.Heartbeat:input_type -> shardmanagerpb.HeartbeatRequest
+	9,  // 21: shardmanagerpb.NodeService.ListNodes:input_type -> shardmanagerpb.ListNodesRequest
+	11, // 22: shardmanagerpb.NodeService.DrainNode:input_type -> shardmanagerpb.DrainNodeRequest
+	13, // 23: shardmanagerpb.NodeService.UndrainNode:input_type -> shardmanagerpb.UndrainNodeRequest
+	15, // 24: shardmanagerpb.ShardService.RegisterShard:input_type -> shardmanagerpb.RegisterShardRequest
+	17, // 25: shardmanagerpb.ShardService.ListShards:input_type -> shardmanagerpb.ListShardsRequest
+	19, // 26: shardmanagerpb.ShardService.GetShardInfo:input_type -> shardmanagerpb.GetShardInfoRequest
+	21, // 27: shardmanagerpb.ShardService.AssignShard:input_type -> shardmanagerpb.AssignShardRequest
+	23, // 28: shardmanagerpb.ShardService.MigrateShard:input_type -> shardmanagerpb.MigrateShardRequest
+	25, // 29: shardmanagerpb.ShardService.UpdateShardStatus:input_type -> shardmanagerpb.UpdateShardStatusRequest
+	27, // 30: shardmanagerpb.ShardService.GetShardHistory:input_type -> shardmanagerpb.GetShardHistoryRequest
+	30, // 31: shardmanagerpb.ShardService.RollbackShard:input_type -> shardmanagerpb.RollbackShardRequest
+	32, // 32: shardmanagerpb.PolicyService.SetPolicy:input_type -> shardmanagerpb.SetPolicyRequest
+	34, // 33: shardmanagerpb.PolicyService.GetPolicy:input_type -> shardmanagerpb.GetPolicyRequest
+	36, // 34: shardmanagerpb.MonitoringService.GetDistribution:input_type -> shardmanagerpb.GetDistributionRequest
+	39, // 35: shardmanagerpb.MonitoringService.GetHealth:input_type -> shardmanagerpb.GetHealthRequest
+	41, // 36: shardmanagerpb.FailureService.ReportFailure:input_type -> shardmanagerpb.ReportFailureRequest
+	44, // 37: shardmanagerpb.AuditService.ListAuditEvents:input_type -> shardmanagerpb.ListAuditEventsRequest
+	46, // 38: shardmanagerpb.AppShardService.AddShard:input_type -> shardmanagerpb.AddShardRequest
+	48, // 39: shardmanagerpb.AppShardService.DropShard:input_type -> shardmanagerpb.DropShardRequest
+	50, // 40: shardmanagerpb.AppShardService.ChangeRole:input_type -> shardmanagerpb.ChangeRoleRequest
+	52, // 41: shardmanagerpb.AppShardService.PrepareAddShard:input_type -> shardmanagerpb.PrepareAddShardRequest
+	54, // 42: shardmanagerpb.AppShardService.PrepareDropShard:input_type -> shardmanagerpb.PrepareDropShardRequest
+	56, // 43: shardmanagerpb.AppShardService.ListLocalShards:input_type -> shardmanagerpb.ListLocalShardsRequest
+	4,  // 44: shardmanagerpb.NodeService.RegisterNode:output_type -> shardmanagerpb.RegisterNodeResponse
+	7,  // 45: shardmanagerpb.NodeService.Heartbeat:output_type -> shardmanagerpb.HeartbeatResponse
+	10, // 46: shardmanagerpb.NodeService.ListNodes:output_type -> shardmanagerpb.ListNodesResponse
+	12, // 47: shardmanagerpb.NodeService.DrainNode:output_type -> shardmanagerpb.DrainNodeResponse
+	14, // 48: shardmanagerpb.NodeService.UndrainNode:output_type -> shardmanagerpb.UndrainNodeResponse
+	16, // 49: shardmanagerpb.ShardService.RegisterShard:output_type -> shardmanagerpb.RegisterShardResponse
+	18, // 50: shardmanagerpb.ShardService.ListShards:output_type -> shardmanagerpb.ListShardsResponse
+	20, // 51: shardmanagerpb.ShardService.GetShardInfo:output_type -> shardmanagerpb.GetShardInfoResponse
+	22, // 52: shardmanagerpb.ShardService.AssignShard:output_type -> shardmanagerpb.AssignShardResponse
+	24, // 53: shardmanagerpb.ShardService.MigrateShard:output_type -> shardmanagerpb.MigrateShardResponse
+	26, // 54: shardmanagerpb.ShardService.UpdateShardStatus:output_type -> shardmanagerpb.UpdateShardStatusResponse
+	28, // 55: shardmanagerpb.ShardService.GetShardHistory:output_type -> shardmanagerpb.GetShardHistoryResponse
+	31, // 56: shardmanagerpb.ShardService.RollbackShard:output_type -> shardmanagerpb.RollbackShardResponse
+	33, // 57: shardmanagerpb.PolicyService.SetPolicy:output_type -> shardmanagerpb.SetPolicyResponse
+	35, // 58: shardmanagerpb.PolicyService.GetPolicy:output_type -> shardmanagerpb.GetPolicyResponse
+	37, // 59: shardmanagerpb.MonitoringService.GetDistribution:output_type -> shardmanagerpb.GetDistributionResponse
+	40, // 60: shardmanagerpb.MonitoringService.GetHealth:output_type -> shardmanagerpb.GetHealthResponse
+	42, // 61: shardmanagerpb.FailureService.ReportFailure:output_type -> shardmanagerpb.ReportFailureResponse
+	45, // 62: shardmanagerpb.AuditService.ListAuditEvents:output_type -> shardmanagerpb.ListAuditEventsResponse
+	47, // 63: shardmanagerpb.AppShardService.AddShard:output_type -> shardmanagerpb.AddShardResponse
+	49, // 64: shardmanagerpb.AppShardService.DropShard:output_type -> shardmanagerpb.DropShardResponse
+	51, // 65: shardmanagerpb.AppShardService.ChangeRole:output_type -> shardmanagerpb.ChangeRoleResponse
+	53, // 66: shardmanagerpb.AppShardService.PrepareAddShard:output_type -> shardmanagerpb.PrepareAddShardResponse
+	55, // 67: shardmanagerpb.AppShardService.PrepareDropShard:output_type -> shardmanagerpb.PrepareDropShardResponse
+	57, // 68: shardmanagerpb.AppShardService.ListLocalShards:output_type -> shardmanagerpb.ListLocalShardsResponse
+	44, // [44:69] is the sub-list for method output_type
+	19, // [19:44] is the sub-list for method input_type
+	19, // [19:19] is the sub-list for extension type_name
+	19, // [19:19] is the sub-list for extension extendee
+	0,  // [0:19] is the sub-list for field type_name
 }
 
 func init() { file_shardmanager_proto_init() }
@@ -2368,9 +3762,9 @@ func file_shardmanager_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_shardmanager_proto_rawDesc), len(file_shardmanager_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   42,
+			NumMessages:   64,
 			NumExtensions: 0,
-			NumServices:   6,
+			NumServices:   7,
 		},
 		GoTypes:           file_shardmanager_proto_goTypes,
 		DependencyIndexes: file_shardmanager_proto_depIdxs,
@@ -22,6 +22,8 @@ const (
 	NodeService_RegisterNode_FullMethodName = "/shardmanagerpb.NodeService/RegisterNode"
 	NodeService_Heartbeat_FullMethodName    = "/shardmanagerpb.NodeService/Heartbeat"
 	NodeService_ListNodes_FullMethodName    = "/shardmanagerpb.NodeService/ListNodes"
+	NodeService_DrainNode_FullMethodName    = "/shardmanagerpb.NodeService/DrainNode"
+	NodeService_UndrainNode_FullMethodName  = "/shardmanagerpb.NodeService/UndrainNode"
 )
 
 // NodeServiceClient is the client API for NodeService service.
@@ -31,6 +33,8 @@ type NodeServiceClient interface {
 	RegisterNode(ctx context.Context, in *RegisterNodeRequest, opts ...grpc.CallOption) (*RegisterNodeResponse, error)
 	Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error)
 	ListNodes(ctx context.Context, in *ListNodesRequest, opts ...grpc.CallOption) (*ListNodesResponse, error)
+	DrainNode(ctx context.Context, in *DrainNodeRequest, opts ...grpc.CallOption) (*DrainNodeResponse, error)
+	UndrainNode(ctx context.Context, in *UndrainNodeRequest, opts ...grpc.CallOption) (*UndrainNodeResponse, error)
 }
 
 type nodeServiceClient struct {
@@ -71,6 +75,26 @@ func (c *nodeServiceClient) ListNodes(ctx context.Context, in *ListNodesRequest,
 	return out, nil
 }
 
+func (c *nodeServiceClient) DrainNode(ctx context.Context, in *DrainNodeRequest, opts ...grpc.CallOption) (*DrainNodeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DrainNodeResponse)
+	err := c.cc.Invoke(ctx, NodeService_DrainNode_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeServiceClient) UndrainNode(ctx context.Context, in *UndrainNodeRequest, opts ...grpc.CallOption) (*UndrainNodeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UndrainNodeResponse)
+	err := c.cc.Invoke(ctx, NodeService_UndrainNode_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // NodeServiceServer is the server API for NodeService service.
 // All implementations must embed UnimplementedNodeServiceServer
 // for forward compatibility.
@@ -78,6 +102,8 @@ type NodeServiceServer interface {
 	RegisterNode(context.Context, *RegisterNodeRequest) (*RegisterNodeResponse, error)
 	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error)
 	ListNodes(context.Context, *ListNodesRequest) (*ListNodesResponse, error)
+	DrainNode(context.Context, *DrainNodeRequest) (*DrainNodeResponse, error)
+	UndrainNode(context.Context, *UndrainNodeRequest) (*UndrainNodeResponse, error)
 	mustEmbedUnimplementedNodeServiceServer()
 }
 
@@ -97,6 +123,12 @@ func (UnimplementedNodeServiceServer) Heartbeat(context.Context, *HeartbeatReque
 func (UnimplementedNodeServiceServer) ListNodes(context.Context, *ListNodesRequest) (*ListNodesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListNodes not implemented")
 }
+func (UnimplementedNodeServiceServer) DrainNode(context.Context, *DrainNodeRequest) (*DrainNodeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DrainNode not implemented")
+}
+func (UnimplementedNodeServiceServer) UndrainNode(context.Context, *UndrainNodeRequest) (*UndrainNodeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UndrainNode not implemented")
+}
 func (UnimplementedNodeServiceServer) mustEmbedUnimplementedNodeServiceServer() {}
 func (UnimplementedNodeServiceServer) testEmbeddedByValue()                     {}
 
@@ -172,6 +204,42 @@ func _NodeService_ListNodes_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _NodeService_DrainNode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DrainNodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServiceServer).DrainNode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NodeService_DrainNode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServiceServer).DrainNode(ctx, req.(*DrainNodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NodeService_UndrainNode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UndrainNodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServiceServer).UndrainNode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NodeService_UndrainNode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServiceServer).UndrainNode(ctx, req.(*UndrainNodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // NodeService_ServiceDesc is the grpc.ServiceDesc for NodeService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -191,6 +259,14 @@ var NodeService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListNodes",
 			Handler:    _NodeService_ListNodes_Handler,
 		},
+		{
+			MethodName: "DrainNode",
+			Handler:    _NodeService_DrainNode_Handler,
+		},
+		{
+			MethodName: "UndrainNode",
+			Handler:    _NodeService_UndrainNode_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "shardmanager.proto",
@@ -203,6 +279,8 @@ const (
 	ShardService_AssignShard_FullMethodName       = "/shardmanagerpb.ShardService/AssignShard"
 	ShardService_MigrateShard_FullMethodName      = "/shardmanagerpb.ShardService/MigrateShard"
 	ShardService_UpdateShardStatus_FullMethodName = "/shardmanagerpb.ShardService/UpdateShardStatus"
+	ShardService_GetShardHistory_FullMethodName   = "/shardmanagerpb.ShardService/GetShardHistory"
+	ShardService_RollbackShard_FullMethodName     = "/shardmanagerpb.ShardService/RollbackShard"
 )
 
 // ShardServiceClient is the client API for ShardService service.
@@ -215,6 +293,8 @@ type ShardServiceClient interface {
 	AssignShard(ctx context.Context, in *AssignShardRequest, opts ...grpc.CallOption) (*AssignShardResponse, error)
 	MigrateShard(ctx context.Context, in *MigrateShardRequest, opts ...grpc.CallOption) (*MigrateShardResponse, error)
 	UpdateShardStatus(ctx context.Context, in *UpdateShardStatusRequest, opts ...grpc.CallOption) (*UpdateShardStatusResponse, error)
+	GetShardHistory(ctx context.Context, in *GetShardHistoryRequest, opts ...grpc.CallOption) (*GetShardHistoryResponse, error)
+	RollbackShard(ctx context.Context, in *RollbackShardRequest, opts ...grpc.CallOption) (*RollbackShardResponse, error)
 }
 
 type shardServiceClient struct {
@@ -285,6 +365,26 @@ func (c *shardServiceClient) UpdateShardStatus(ctx context.Context, in *UpdateSh
 	return out, nil
 }
 
+func (c *shardServiceClient) GetShardHistory(ctx context.Context, in *GetShardHistoryRequest, opts ...grpc.CallOption) (*GetShardHistoryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetShardHistoryResponse)
+	err := c.cc.Invoke(ctx, ShardService_GetShardHistory_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shardServiceClient) RollbackShard(ctx context.Context, in *RollbackShardRequest, opts ...grpc.CallOption) (*RollbackShardResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RollbackShardResponse)
+	err := c.cc.Invoke(ctx, ShardService_RollbackShard_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ShardServiceServer is the server API for ShardService service.
 // All implementations must embed UnimplementedShardServiceServer
 // for forward compatibility.
@@ -295,6 +395,8 @@ type ShardServiceServer interface {
 	AssignShard(context.Context, *AssignShardRequest) (*AssignShardResponse, error)
 	MigrateShard(context.Context, *MigrateShardRequest) (*MigrateShardResponse, error)
 	UpdateShardStatus(context.Context, *UpdateShardStatusRequest) (*UpdateShardStatusResponse, error)
+	GetShardHistory(context.Context, *GetShardHistoryRequest) (*GetShardHistoryResponse, error)
+	RollbackShard(context.Context, *RollbackShardRequest) (*RollbackShardResponse, error)
 	mustEmbedUnimplementedShardServiceServer()
 }
 
@@ -323,6 +425,12 @@ func (UnimplementedShardServiceServer) MigrateShard(context.Context, *MigrateSha
 func (UnimplementedShardServiceServer) UpdateShardStatus(context.Context, *UpdateShardStatusRequest) (*UpdateShardStatusResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateShardStatus not implemented")
 }
+func (UnimplementedShardServiceServer) GetShardHistory(context.Context, *GetShardHistoryRequest) (*GetShardHistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetShardHistory not implemented")
+}
+func (UnimplementedShardServiceServer) RollbackShard(context.Context, *RollbackShardRequest) (*RollbackShardResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RollbackShard not implemented")
+}
 func (UnimplementedShardServiceServer) mustEmbedUnimplementedShardServiceServer() {}
 func (UnimplementedShardServiceServer) testEmbeddedByValue()                      {}
 
@@ -452,6 +560,42 @@ func _ShardService_UpdateShardStatus_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ShardService_GetShardHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetShardHistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShardServiceServer).GetShardHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShardService_GetShardHistory_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShardServiceServer).GetShardHistory(ctx, req.(*GetShardHistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ShardService_RollbackShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RollbackShardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShardServiceServer).RollbackShard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ShardService_RollbackShard_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShardServiceServer).RollbackShard(ctx, req.(*RollbackShardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ShardService_ServiceDesc is the grpc.ServiceDesc for ShardService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -483,6 +627,14 @@ var ShardService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateShardStatus",
 			Handler:    _ShardService_UpdateShardStatus_Handler,
 		},
+		{
+			MethodName: "GetShardHistory",
+			Handler:    _ShardService_GetShardHistory_Handler,
+		},
+		{
+			MethodName: "RollbackShard",
+			Handler:    _ShardService_RollbackShard_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "shardmanager.proto",
@@ -870,12 +1022,115 @@ var FailureService_ServiceDesc = grpc.ServiceDesc{
 	Metadata: "shardmanager.proto",
 }
 
+const (
+	AuditService_ListAuditEvents_FullMethodName = "/shardmanagerpb.AuditService/ListAuditEvents"
+)
+
+// AuditServiceClient is the client API for AuditService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AuditServiceClient interface {
+	ListAuditEvents(ctx context.Context, in *ListAuditEventsRequest, opts ...grpc.CallOption) (*ListAuditEventsResponse, error)
+}
+
+type auditServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAuditServiceClient(cc grpc.ClientConnInterface) AuditServiceClient {
+	return &auditServiceClient{cc}
+}
+
+func (c *auditServiceClient) ListAuditEvents(ctx context.Context, in *ListAuditEventsRequest, opts ...grpc.CallOption) (*ListAuditEventsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListAuditEventsResponse)
+	err := c.cc.Invoke(ctx, AuditService_ListAuditEvents_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AuditServiceServer is the server API for AuditService service.
+// All implementations must embed UnimplementedAuditServiceServer
+// for forward compatibility.
+type AuditServiceServer interface {
+	ListAuditEvents(context.Context, *ListAuditEventsRequest) (*ListAuditEventsResponse, error)
+	mustEmbedUnimplementedAuditServiceServer()
+}
+
+// UnimplementedAuditServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAuditServiceServer struct{}
+
+func (UnimplementedAuditServiceServer) ListAuditEvents(context.Context, *ListAuditEventsRequest) (*ListAuditEventsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAuditEvents not implemented")
+}
+func (UnimplementedAuditServiceServer) mustEmbedUnimplementedAuditServiceServer() {}
+func (UnimplementedAuditServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeAuditServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AuditServiceServer will
+// result in compilation errors.
+type UnsafeAuditServiceServer interface {
+	mustEmbedUnimplementedAuditServiceServer()
+}
+
+func RegisterAuditServiceServer(s grpc.ServiceRegistrar, srv AuditServiceServer) {
+	// If the following call pancis, it indicates UnimplementedAuditServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&AuditService_ServiceDesc, srv)
+}
+
+func _AuditService_ListAuditEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAuditEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AuditServiceServer).ListAuditEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AuditService_ListAuditEvents_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AuditServiceServer).ListAuditEvents(ctx, req.(*ListAuditEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AuditService_ServiceDesc is the grpc.ServiceDesc for AuditService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AuditService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "shardmanagerpb.AuditService",
+	HandlerType: (*AuditServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListAuditEvents",
+			Handler:    _AuditService_ListAuditEvents_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "shardmanager.proto",
+}
+
 const (
 	AppShardService_AddShard_FullMethodName         = "/shardmanagerpb.AppShardService/AddShard"
 	AppShardService_DropShard_FullMethodName        = "/shardmanagerpb.AppShardService/DropShard"
 	AppShardService_ChangeRole_FullMethodName       = "/shardmanagerpb.AppShardService/ChangeRole"
 	AppShardService_PrepareAddShard_FullMethodName  = "/shardmanagerpb.AppShardService/PrepareAddShard"
 	AppShardService_PrepareDropShard_FullMethodName = "/shardmanagerpb.AppShardService/PrepareDropShard"
+	AppShardService_ListLocalShards_FullMethodName  = "/shardmanagerpb.AppShardService/ListLocalShards"
 )
 
 // AppShardServiceClient is the client API for AppShardService service.
@@ -889,6 +1144,7 @@ type AppShardServiceClient interface {
 	ChangeRole(ctx context.Context, in *ChangeRoleRequest, opts ...grpc.CallOption) (*ChangeRoleResponse, error)
 	PrepareAddShard(ctx context.Context, in *PrepareAddShardRequest, opts ...grpc.CallOption) (*PrepareAddShardResponse, error)
 	PrepareDropShard(ctx context.Context, in *PrepareDropShardRequest, opts ...grpc.CallOption) (*PrepareDropShardResponse, error)
+	ListLocalShards(ctx context.Context, in *ListLocalShardsRequest, opts ...grpc.CallOption) (*ListLocalShardsResponse, error)
 }
 
 type appShardServiceClient struct {
@@ -949,6 +1205,16 @@ func (c *appShardServiceClient) PrepareDropShard(ctx context.Context, in *Prepar
 	return out, nil
 }
 
+func (c *appShardServiceClient) ListLocalShards(ctx context.Context, in *ListLocalShardsRequest, opts ...grpc.CallOption) (*ListLocalShardsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListLocalShardsResponse)
+	err := c.cc.Invoke(ctx, AppShardService_ListLocalShards_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AppShardServiceServer is the server API for AppShardService service.
 // All implementations must embed UnimplementedAppShardServiceServer
 // for forward compatibility.
@@ -960,6 +1226,7 @@ type AppShardServiceServer interface {
 	ChangeRole(context.Context, *ChangeRoleRequest) (*ChangeRoleResponse, error)
 	PrepareAddShard(context.Context, *PrepareAddShardRequest) (*PrepareAddShardResponse, error)
 	PrepareDropShard(context.Context, *PrepareDropShardRequest) (*PrepareDropShardResponse, error)
+	ListLocalShards(context.Context, *ListLocalShardsRequest) (*ListLocalShardsResponse, error)
 	mustEmbedUnimplementedAppShardServiceServer()
 }
 
@@ -985,6 +1252,9 @@ func (UnimplementedAppShardServiceServer) PrepareAddShard(context.Context, *Prep
 func (UnimplementedAppShardServiceServer) PrepareDropShard(context.Context, *PrepareDropShardRequest) (*PrepareDropShardResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method PrepareDropShard not implemented")
 }
+func (UnimplementedAppShardServiceServer) ListLocalShards(context.Context, *ListLocalShardsRequest) (*ListLocalShardsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListLocalShards not implemented")
+}
 func (UnimplementedAppShardServiceServer) mustEmbedUnimplementedAppShardServiceServer() {}
 func (UnimplementedAppShardServiceServer) testEmbeddedByValue()                         {}
 
@@ -1096,6 +1366,24 @@ func _AppShardService_PrepareDropShard_Handler(srv interface{}, ctx context.Cont
 	return interceptor(ctx, in, info, handler)
 }
 
+func _AppShardService_ListLocalShards_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListLocalShardsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AppShardServiceServer).ListLocalShards(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AppShardService_ListLocalShards_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AppShardServiceServer).ListLocalShards(ctx, req.(*ListLocalShardsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // AppShardService_ServiceDesc is the grpc.ServiceDesc for AppShardService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -1123,6 +1411,10 @@ var AppShardService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "PrepareDropShard",
 			Handler:    _AppShardService_PrepareDropShard_Handler,
 		},
+		{
+			MethodName: "ListLocalShards",
+			Handler:    _AppShardService_ListLocalShards_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "shardmanager.proto",